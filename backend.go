@@ -0,0 +1,193 @@
+package unitard
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// backend deploys units with a service manager other than systemd. Deploy
+// still works out what has changed, backs up and writes the files, and
+// rolls back on failure; the backend decides which files to write and how
+// to load and unload them.
+type backend interface {
+	// setup fills in the environment in place of setupEnvironment, and
+	// checks the options can be used with the backend.
+	setup(u *Unit) error
+	// files returns the files Deploy writes.
+	files(u Unit) []unitFile
+	// start loads and starts the unit after the files have been written.
+	// If changed is false they were already up to date.
+	start(u Unit, changed bool) error
+	// undeploy stops the unit and removes its files.
+	undeploy(u Unit) error
+	// control runs a lifecycle command - enable, disable, start, stop,
+	// restart or reload - against the deployed unit.
+	control(u Unit, command string) error
+	// status returns the state of the deployed unit.
+	status(u Unit) (Status, error)
+	// kind returns which Backend this is.
+	kind(u Unit) Backend
+}
+
+// notSupported returns the error for a lifecycle command the backend can't
+// run.
+func notSupported(backend Backend, command string) error {
+	return fmt.Errorf("%w: cannot %s with %s", ErrNotSupported, command, backend)
+}
+
+// backendStatus returns a Status from whether the unit is installed and
+// running, for backends which can't say more.
+func backendStatus(loaded, active bool) Status {
+	s := Status{LoadState: "not-found", ActiveState: "inactive"}
+	if loaded {
+		s.LoadState = "loaded"
+	}
+	if active {
+		s.ActiveState = "active"
+	}
+	return s
+}
+
+// restoreBackend restores the backed up files after a failed deploy with a
+// backend, and starts the previous version again, or undeploys the new one
+// if there was no previous version.
+func (u Unit) restoreBackend(backups []backup) error {
+	if !existedBefore(backups) {
+		return u.backend.undeploy(u)
+	}
+	err := u.restoreFiles(backups)
+	if err != nil {
+		return err
+	}
+	return u.backend.start(u, true)
+}
+
+// splitQuoted splits a command line or Environment= value into words the
+// way systemd does, honouring single and double quotes and backslash
+// escapes.
+func splitQuoted(s string) []string {
+	words := []string{}
+	word := strings.Builder{}
+	inWord := false
+	quote := rune(0)
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// command returns the program and its arguments, as ExecStart would run them.
+func (u Unit) command() []string {
+	return append([]string{u.binary}, splitQuoted(u.binaryArgs)...)
+}
+
+// environment returns the variables set with Environment= directives, in
+// order, with later values replacing earlier ones.
+func (u Unit) environment() []envVar {
+	vars := []envVar{}
+	for _, d := range u.sectionDirectives(SectionService) {
+		if d.Key != "Environment" {
+			continue
+		}
+		if d.Value == "" {
+			vars = nil
+			continue
+		}
+		for _, assignment := range splitQuoted(d.Value) {
+			key, value, ok := strings.Cut(assignment, "=")
+			if !ok {
+				continue
+			}
+			for i := range vars {
+				if vars[i].Key == key {
+					vars = append(vars[:i], vars[i+1:]...)
+					break
+				}
+			}
+			vars = append(vars, envVar{Key: key, Value: value})
+		}
+	}
+	return vars
+}
+
+// envVar is an environment variable for the service.
+type envVar struct {
+	Key   string
+	Value string
+}
+
+// serviceDirective returns the last value of a [Service] directive set by
+// an option, or an empty string.
+func (u Unit) serviceDirective(key string) string {
+	value := ""
+	for _, d := range u.sectionDirectives(SectionService) {
+		if d.Key == key {
+			value = d.Value
+		}
+	}
+	return value
+}
+
+// checkBackendOptions returns an error if the unit uses options which only
+// make sense with systemd.
+func (u Unit) checkBackendOptions(backend string) error {
+	switch {
+	case u.instances:
+		return fmt.Errorf("OptInstances cannot be used with %s", backend)
+	case u.dropIn != "":
+		return fmt.Errorf("OptDropIn cannot be used with %s", backend)
+	case u.serviceTemplate != nil:
+		return fmt.Errorf("OptTemplate cannot be used with %s", backend)
+	case u.maxExposure > 0:
+		return fmt.Errorf("OptMaxExposure cannot be used with %s", backend)
+	case u.deployMode != DeployEnableAndStart:
+		return fmt.Errorf("OptDeployMode cannot be used with %s", backend)
+	}
+	return nil
+}
+
+// warnUnsupported logs the directives a backend ignores.
+func (u Unit) warnUnsupported(backend string, supported map[string]bool) {
+	for _, d := range u.directives {
+		if !supported[d.Key] {
+			u.log(slog.LevelWarn, "directive not supported by "+backend, "key", d.Key)
+		}
+	}
+}
+
+// runPrivileged runs a command, with root privileges for system scope if
+// needed.
+func (u Unit) runPrivileged(command string, args ...string) error {
+	if u.escalate != nil {
+		return u.runEscalated(command, args...)
+	}
+	return u.runExpectZero(command, args...)
+}
@@ -0,0 +1,59 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeTool writes a script standing in for a backend's command, which logs
+// its arguments and then runs body. It returns the script and the log.
+func fakeTool(t *testing.T, name, body string) (string, string) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	script := filepath.Join(dir, name)
+	os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" >> "+log+"\n"+body+"\n"), 0700)
+	return script, log
+}
+
+func TestSplitQuoted(t *testing.T) {
+	tests := map[string][]string{
+		"":                           {},
+		"-a  -b":                     {"-a", "-b"},
+		`-name "two words" 'single'`: {"-name", "two words", "single"},
+		`a\ b "x\"y"`:                {"a b", `x"y`},
+		`FOO="bar baz"`:              {"FOO=bar baz"},
+	}
+	for in, want := range tests {
+		if got := splitQuoted(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitQuoted(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	u := Unit{}
+	u.addDirective(SectionService, "Environment", `A=1 "B=two words"`)
+	u.addDirective(SectionService, "Environment", "A=3 C=4")
+	want := []envVar{{"B", "two words"}, {"A", "3"}, {"C", "4"}}
+	if got := u.environment(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong environment %v", got)
+	}
+	u.addDirective(SectionService, "Environment", "")
+	if got := u.environment(); len(got) != 0 {
+		t.Errorf("environment was not reset %v", got)
+	}
+}
+
+func TestCheckBackendOptions(t *testing.T) {
+	if err := (Unit{}).checkBackendOptions("test"); err != nil {
+		t.Errorf("plain unit should be fine: %s", err)
+	}
+	if err := (Unit{instances: true}).checkBackendOptions("test"); err == nil {
+		t.Error("instances should fail")
+	}
+	if err := (Unit{deployMode: DeployStartOnly}).checkBackendOptions("test"); err == nil {
+		t.Error("deploy mode should fail")
+	}
+}
@@ -0,0 +1,241 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// dependencyKeys are the [Unit] directives which order a unit after
+// another in a Batch.
+var dependencyKeys = map[string]bool{
+	"After": true, "Requires": true, "Wants": true, "BindsTo": true, "Requisite": true,
+	"Slice": true,
+}
+
+// Batch deploys several units as one operation, for instance the workers of
+// a multi-process application. All the unit files are written before
+// systemd is reloaded once, then the units are started so that each starts
+// after any others in the batch it depends on (with After, Requires and so
+// on). If any unit fails, all of them are rolled back.
+//
+// The units must all have the same scope.
+type Batch struct {
+	units []Unit // in the order they should be started
+}
+
+// NewBatch creates a batch of units.
+func NewBatch(units ...Unit) (Batch, error) {
+	if len(units) == 0 {
+		return Batch{}, errors.New("batch needs at least one unit")
+	}
+	seen := map[string]bool{}
+	for _, u := range units {
+		if u.scope != units[0].scope {
+			return Batch{}, errors.New("all units in a batch must have the same scope")
+		}
+		if seen[u.serviceName()] {
+			return Batch{}, fmt.Errorf("unit '%s' is in the batch twice", u.serviceName())
+		}
+		seen[u.serviceName()] = true
+	}
+	ordered, err := dependencyOrder(units)
+	if err != nil {
+		return Batch{}, err
+	}
+	return Batch{units: ordered}, nil
+}
+
+// Units returns the units in the order they are started.
+func (b Batch) Units() []Unit {
+	return append([]Unit{}, b.units...)
+}
+
+// dependsOn returns true if u has a dependency on the other unit.
+func (u Unit) dependsOn(other Unit) bool {
+	names := map[string]bool{other.serviceName(): true, fullUnitName(other.serviceName()): true}
+	for _, unit := range other.activeUnits() {
+		names[unit] = true
+	}
+	if other.slice {
+		names[other.name+".slice"] = true
+	}
+	for _, d := range append(u.sectionDirectives(SectionUnit), u.sectionDirectives(SectionService)...) {
+		if !dependencyKeys[d.Key] {
+			continue
+		}
+		for _, name := range strings.Fields(d.Value) {
+			if names[name] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dependencyOrder sorts units so that each comes after those it depends on,
+// otherwise keeping the order they were given in.
+func dependencyOrder(units []Unit) ([]Unit, error) {
+	ordered := []Unit{}
+	done := make([]bool, len(units))
+	for len(ordered) < len(units) {
+		progress := false
+		for i, u := range units {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for j, other := range units {
+				if i != j && !done[j] && u.dependsOn(other) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, u)
+				done[i] = true
+				progress = true
+				break
+			}
+		}
+		if !progress {
+			return nil, errors.New("units in the batch have circular dependencies")
+		}
+	}
+	return ordered, nil
+}
+
+// Deploy deploys all the units in the batch. If any of them fails to
+// deploy, those which were changed are rolled back and a *RollbackError
+// is returned.
+func (b Batch) Deploy() error {
+	b.units = append([]Unit{}, b.units...)
+	reported := []func(error){}
+	for i, u := range b.units {
+		if u.reports() {
+			var done func(error)
+			b.units[i], done = u.startReport("deploy")
+			reported = append(reported, done)
+		}
+	}
+	err := b.deploy()
+	for _, done := range reported {
+		done(err)
+	}
+	return err
+}
+
+// deploy is Deploy, once the units are recording their reports.
+func (b Batch) deploy() error {
+	deploys := []deploy{}
+	changed := false
+	installed := map[string]bool{}
+	locked := map[string]bool{}
+	for _, u := range b.units {
+		// instances of a template share its lock, so take it once
+		if name, err := u.lockFilename(); err == nil {
+			if locked[name] {
+				continue
+			}
+			locked[name] = true
+		}
+		release, err := u.lock()
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	for _, u := range b.units {
+		d, err := u.prepareDeploy()
+		if err != nil {
+			return err
+		}
+		// units of NewServices share the installed program
+		if d.install && installed[u.binary] {
+			d.install = false
+		}
+		installed[u.binary] = installed[u.binary] || d.install
+		deploys = append(deploys, d)
+		changed = changed || d.changed
+	}
+
+	var err error
+	for _, d := range deploys {
+		err = d.writeFiles()
+		if err != nil {
+			break
+		}
+	}
+	if err == nil && changed && b.units[0].backend == nil {
+		err = b.units[0].systemctl("daemon-reload")
+	}
+	if err == nil {
+		for _, d := range deploys {
+			err = d.start()
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return b.rollback(deploys, err)
+	}
+
+	for _, d := range deploys {
+		err := d.finish()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback rolls back all the units in the batch after err.
+func (b Batch) rollback(deploys []deploy, err error) error {
+	rollbackErrs := []error{}
+	rolledBack := false
+	for i := len(deploys) - 1; i >= 0; i-- {
+		d := deploys[i]
+		if d.backups == nil || d.u.plan != nil {
+			continue
+		}
+		rolledBack = true
+		var rollbackErr *RollbackError
+		if errors.As(d.rollback(err), &rollbackErr) && rollbackErr.RollbackErr != nil {
+			rollbackErrs = append(rollbackErrs, rollbackErr.RollbackErr)
+		}
+	}
+	if !rolledBack {
+		return err
+	}
+	return &RollbackError{Err: err, RollbackErr: errors.Join(rollbackErrs...)}
+}
+
+// Undeploy undeploys all the units in the batch, in the reverse of the
+// order they are started, and removes the program they share if it was
+// installed for NewServices.
+func (b Batch) Undeploy() error {
+	for i := len(b.units) - 1; i >= 0; i-- {
+		err := b.units[i].Undeploy()
+		if err != nil {
+			return err
+		}
+	}
+	for _, u := range b.units {
+		if u.installShared {
+			return u.removeInstalled()
+		}
+	}
+	return nil
+}
+
+// DryRun returns the actions Deploy would take, without taking them.
+func (b Batch) DryRun() (Plan, error) {
+	plan := &Plan{}
+	b.units = append([]Unit{}, b.units...)
+	for i := range b.units {
+		b.units[i].plan = plan
+	}
+	err := b.Deploy()
+	return *plan, err
+}
@@ -0,0 +1,99 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBatchOrder(t *testing.T) {
+	web := Unit{name: "web", directives: []Directive{{Section: SectionUnit, Key: "After", Value: "db.service cache.service"}}}
+	db := Unit{name: "db"}
+	cache := Unit{name: "cache", directives: []Directive{{Section: SectionUnit, Key: "Wants", Value: "db"}}}
+
+	b, err := NewBatch(web, db, cache)
+	if err != nil {
+		t.Fatalf("could not create batch: %s", err)
+	}
+	names := []string{}
+	for _, u := range b.Units() {
+		names = append(names, u.name)
+	}
+	if strings.Join(names, " ") != "db cache web" {
+		t.Errorf("wrong order %v", names)
+	}
+
+	db.directives = []Directive{{Section: SectionUnit, Key: "After", Value: "web.service"}}
+	if _, err := NewBatch(web, db); err == nil {
+		t.Error("circular dependencies should fail")
+	}
+	if _, err := NewBatch(web, web); err == nil {
+		t.Error("duplicate units should fail")
+	}
+	if _, err := NewBatch(web, Unit{name: "other", scope: ScopeSystem}); err == nil {
+		t.Error("mixed scopes should fail")
+	}
+}
+
+func TestBatchDeploy(t *testing.T) {
+	client := &recordingClient{dir: t.TempDir()}
+	one := Unit{name: "one", binary: "/fullpath/to/one", skipVerify: true, client: client, unitFilePath: client.dir}
+	two := Unit{name: "two", binary: "/fullpath/to/two", skipVerify: true, client: client, unitFilePath: client.dir}
+	b, _ := NewBatch(one, two)
+
+	if err := b.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	want := "daemon-reload\nenable one\nrestart one\nenable two\nrestart two"
+	if got := strings.Join(client.commands, "\n"); got != want {
+		t.Errorf("wrong commands:\n%s", got)
+	}
+
+	plan, err := b.DryRun()
+	if err != nil || len(plan.Commands()) != 4 || b.units[0].plan != nil {
+		t.Errorf("wrong dry run %v %v", plan.Commands(), err)
+	}
+}
+
+func TestBatchInstances(t *testing.T) {
+	client := &recordingClient{dir: t.TempDir()}
+	worker := Unit{name: "worker", binary: "/fullpath/to/worker", skipVerify: true, client: client, unitFilePath: client.dir}
+	(OptInstances{}).Apply(&worker)
+	b, err := NewBatch(worker.Instance("a"), worker.Instance("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Deploy(); err != nil {
+		t.Fatalf("instances sharing a lock should deploy: %s", err)
+	}
+	if got := strings.Join(client.commands, "\n"); !strings.Contains(got, "worker@a") || !strings.Contains(got, "worker@b") {
+		t.Errorf("wrong commands:\n%s", got)
+	}
+
+	// and the lock was released
+	release, err := worker.lock()
+	if err != nil {
+		t.Fatalf("lock was not released: %s", err)
+	}
+	release()
+}
+
+func TestBatchRollback(t *testing.T) {
+	systemctl, _ := fakeSystemctl(t, "restart")
+	dir := t.TempDir()
+	one := Unit{name: "one", binary: "/fullpath/to/one", skipVerify: true, systemCtlPath: systemctl, unitFilePath: dir}
+	two := Unit{name: "two", binary: "/fullpath/to/two", skipVerify: true, systemCtlPath: systemctl, unitFilePath: dir}
+	b, _ := NewBatch(one, two)
+
+	err := b.Deploy()
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) || rollbackErr.RollbackErr != nil {
+		t.Fatalf("expected a successful rollback, got %v", err)
+	}
+	for _, u := range []Unit{one, two} {
+		if _, err := os.Stat(u.UnitFilename()); !os.IsNotExist(err) {
+			t.Errorf("%s was not rolled back", u.UnitFilename())
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// OptBinary runs another program instead of the running one, such as a
+// worker binary installed next to it. Path must exist and be executable; a
+// relative path is taken from the current directory.
+type OptBinary struct {
+	Path string
+}
+
+func (o OptBinary) Apply(u *Unit) error {
+	if o.Path == "" {
+		return errors.New("OptBinary needs a path")
+	}
+	binary, err := filepath.Abs(o.Path)
+	if err != nil {
+		return err
+	}
+	return u.setBinary(binary)
+}
+
+// OptExecStart gives the whole command line the service runs, in place of
+// the running program and OptProgramArgs. It must start with the absolute
+// path of an executable, which can't contain spaces; the rest is passed to
+// it as arguments, quoted as systemd expects.
+type OptExecStart struct {
+	Command string
+}
+
+func (o OptExecStart) Apply(u *Unit) error {
+	binary, args, _ := strings.Cut(strings.TrimSpace(o.Command), " ")
+	if !filepath.IsAbs(binary) {
+		return fmt.Errorf("sorry, ExecStart '%s' must start with an absolute path", o.Command)
+	}
+	if u.binaryArgs != "" {
+		return errors.New("args were already set - use OptExecStart or OptProgramArgs")
+	}
+	err := u.setBinary(filepath.Clean(binary))
+	if err != nil {
+		return err
+	}
+	u.binaryArgs = strings.TrimSpace(args)
+	u.execStart = true
+	return nil
+}
+
+// setBinary points the unit at binary, after checking it can be run.
+func (u *Unit) setBinary(binary string) error {
+	if u.binaryChosen {
+		return errors.New("binary was already set - use OptBinary or OptExecStart only once")
+	}
+	err := checkExecutable(binary)
+	if err != nil {
+		return err
+	}
+	u.binary = binary
+	u.binaryPath = filepath.Dir(binary)
+	u.binaryChosen = true
+	return nil
+}
+
+// checkExecutable returns an error unless binary is an executable file.
+func checkExecutable(binary string) error {
+	info, err := os.Stat(binary)
+	if err != nil {
+		return fmt.Errorf("sorry, binary '%s' can't be used: %w", binary, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("sorry, binary '%s' is not a file", binary)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("sorry, binary '%s' is not executable", binary)
+	}
+	return nil
+}
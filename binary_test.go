@@ -0,0 +1,64 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOptBinary(t *testing.T) {
+	dir := t.TempDir()
+	worker := filepath.Join(dir, "worker")
+	os.WriteFile(worker, []byte("#!/bin/sh\n"), 0755)
+	data := filepath.Join(dir, "data")
+	os.WriteFile(data, nil, 0644)
+
+	u := Unit{name: "test_unit"}
+	if err := (OptBinary{Path: worker}).Apply(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.binary != worker || u.binaryPath != dir {
+		t.Errorf("wrong binary %s in %s", u.binary, u.binaryPath)
+	}
+	if err := (OptBinary{Path: worker}).Apply(&u); err == nil {
+		t.Error("binary should only be set once")
+	}
+	if err := (OptProgramArgs{Args: "-x"}).Apply(&u); err != nil {
+		t.Errorf("OptBinary should take program args: %s", err)
+	}
+
+	for _, bad := range []string{"", data, dir, filepath.Join(dir, "missing")} {
+		if err := (OptBinary{Path: bad}).Apply(&Unit{}); err == nil {
+			t.Errorf("binary '%s' should be refused", bad)
+		}
+	}
+}
+
+func TestOptExecStart(t *testing.T) {
+	dir := t.TempDir()
+	worker := filepath.Join(dir, "worker")
+	os.WriteFile(worker, []byte("#!/bin/sh\n"), 0755)
+
+	u := Unit{name: "test_unit", unitFilePath: t.TempDir()}
+	if err := (OptExecStart{Command: worker + ` -config "my file.conf"`}).Apply(&u); err != nil {
+		t.Fatal(err)
+	}
+	files, err := u.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := files[u.UnitFilename()]
+	if !strings.Contains(content, "\nExecStart="+worker+` -config "my file.conf"`+"\n") || !strings.Contains(content, "WorkingDirectory="+dir+"\n") {
+		t.Errorf("wrong ExecStart:\n%s", content)
+	}
+	if err := (OptProgramArgs{Args: "-x"}).Apply(&u); err == nil {
+		t.Error("OptProgramArgs should not be combined with OptExecStart")
+	}
+
+	for _, bad := range []string{"", "worker -x", "/missing/worker", dir} {
+		if err := (OptExecStart{Command: bad}).Apply(&Unit{}); err == nil {
+			t.Errorf("ExecStart '%s' should be refused", bad)
+		}
+	}
+}
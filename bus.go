@@ -0,0 +1,319 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	systemdBusName = "org.freedesktop.systemd1"
+	systemdBusPath = busObjectPath("/org/freedesktop/systemd1")
+	systemdManager = "org.freedesktop.systemd1.Manager"
+)
+
+// unitTypes are the suffixes which make a name a full unit name.
+var unitTypes = []string{".service", ".socket", ".timer", ".path", ".target", ".slice", ".mount"}
+
+// JobError is returned with OptDBus when a systemd job (starting or
+// stopping a unit and so on) does not complete successfully.
+type JobError struct {
+	Unit   string
+	Result string // eg failed, timeout, canceled or dependency
+}
+
+func (e *JobError) Error() string {
+	return fmt.Sprintf("job for %s did not complete: %s", e.Unit, e.Result)
+}
+
+// OptDBus talks to the systemd manager over D-Bus, instead of running
+// systemctl. systemctl does not need to be installed, and starting or
+// stopping a unit waits for the job to actually complete.
+//
+// For system scope units, the file changes are still made with OptEscalate
+// if needed, but the D-Bus calls are made as the current user and are
+// subject to polkit.
+type OptDBus struct{}
+
+func (o OptDBus) Apply(u *Unit) error {
+	u.dbus = true
+	return nil
+}
+
+// busSocket returns the socket path of the bus the systemd manager for the
+// scope is reached on.
+func (s Scope) busSocket() (string, error) {
+	if s == ScopeSystem {
+		if addr := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS"); addr != "" {
+			return busAddress(addr)
+		}
+		return "/run/dbus/system_bus_socket", nil
+	}
+	if addr := os.Getenv("DBUS_SESSION_BUS_ADDRESS"); addr != "" {
+		return busAddress(addr)
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "bus"), nil
+	}
+	return "", ErrNoUserBus
+}
+
+// dialSystemd connects to the bus of the systemd manager for the scope.
+func (u Unit) dialSystemd() (*busConn, error) {
+	socket, err := u.scope.busSocket()
+	if u.scope == ScopeUser && u.runtimeDir != "" && os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		socket, err = filepath.Join(u.runtimeDir, "bus"), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := dialBus(u.context(), socket)
+	if err != nil {
+		if u.scope == ScopeUser && u.context().Err() == nil {
+			return nil, fmt.Errorf("%w: %s", ErrNoUserBus, err)
+		}
+		return nil, fmt.Errorf("could not connect to D-Bus: %w", err)
+	}
+	return c, nil
+}
+
+// fullUnitName adds .service to a unit name without a type, as systemctl
+// does.
+func fullUnitName(name string) string {
+	for _, t := range unitTypes {
+		if strings.HasSuffix(name, t) {
+			return name
+		}
+	}
+	return name + ".service"
+}
+
+// busJobMethods are the manager methods for the systemctl commands which
+// queue a job.
+var busJobMethods = map[string]string{
+	"start":             "StartUnit",
+	"stop":              "StopUnit",
+	"restart":           "RestartUnit",
+	"reload":            "ReloadUnit",
+	"try-restart":       "TryRestartUnit",
+	"reload-or-restart": "ReloadOrRestartUnit",
+}
+
+// busSystemctl does what systemctl would with the given arguments, over
+// D-Bus.
+func (u Unit) busSystemctl(args ...string) error {
+	c, err := u.dialSystemd()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	verb := args[0]
+	now := false
+	whats := []string{}
+	names := []string{}
+	for _, arg := range args[1:] {
+		switch {
+		case arg == "--now":
+			now = true
+		case strings.HasPrefix(arg, "--what="):
+			whats = append(whats, strings.TrimPrefix(arg, "--what="))
+		default:
+			names = append(names, fullUnitName(arg))
+		}
+	}
+	manager := func(method, sig string, args ...interface{}) ([]interface{}, error) {
+		return c.call(systemdBusName, systemdBusPath, systemdManager, method, sig, args...)
+	}
+
+	switch verb {
+	case "daemon-reload":
+		_, err := manager("Reload", "")
+		return err
+	case "enable", "disable":
+		if verb == "enable" {
+			_, err = manager("EnableUnitFiles", "asbb", names, false, false)
+		} else {
+			_, err = manager("DisableUnitFiles", "asb", names, false)
+		}
+		if err != nil {
+			return err
+		}
+		// systemctl reloads after changing the unit file links
+		_, err = manager("Reload", "")
+		if err != nil || !now {
+			return err
+		}
+		verb = "start"
+		if args[0] == "disable" {
+			verb = "stop"
+		}
+		return u.busJobs(c, busJobMethods[verb], names)
+	case "clean":
+		for _, name := range names {
+			_, err := manager("CleanUnit", "sas", name, whats)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	method, ok := busJobMethods[verb]
+	if !ok {
+		return fmt.Errorf("systemctl %s is not supported with OptDBus", verb)
+	}
+	return u.busJobs(c, method, names)
+}
+
+// busJobs queues a job for each unit, and waits for them to complete.
+// Names with wildcards are matched against the loaded units.
+func (u Unit) busJobs(c *busConn, method string, names []string) error {
+	err := busSubscribe(c)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		units := []string{name}
+		if strings.ContainsAny(name, "*?[") {
+			units, err = busListUnits(c, name)
+			if err != nil {
+				return err
+			}
+		}
+		for _, unit := range units {
+			err := busJob(c, method, unit)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// busSubscribe asks for the manager's JobRemoved signals.
+func busSubscribe(c *busConn) error {
+	rule := "type='signal',sender='" + systemdBusName + "',interface='" + systemdManager + "',member='JobRemoved'"
+	_, err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", "s", rule)
+	if err != nil {
+		return err
+	}
+	_, err = c.call(systemdBusName, systemdBusPath, systemdManager, "Subscribe", "")
+	return err
+}
+
+// busListUnits returns the names of the loaded units matching a pattern.
+func busListUnits(c *busConn, pattern string) ([]string, error) {
+	body, err := c.call(systemdBusName, systemdBusPath, systemdManager, "ListUnitsByPatterns", "asas", []string{}, []string{pattern})
+	if err != nil {
+		return nil, err
+	}
+	units := []string{}
+	list, _ := body[0].([]interface{})
+	for _, item := range list {
+		fields, _ := item.([]interface{})
+		if len(fields) > 0 {
+			if name, ok := fields[0].(string); ok {
+				units = append(units, name)
+			}
+		}
+	}
+	return units, nil
+}
+
+// busJob queues a job for a unit and waits for it to be removed, returning
+// a *JobError if it did not succeed.
+func busJob(c *busConn, method, unit string) error {
+	body, err := c.call(systemdBusName, systemdBusPath, systemdManager, method, "ss", unit, "replace")
+	if err != nil {
+		return err
+	}
+	job, _ := body[0].(busObjectPath)
+	removed, err := c.waitSignal(func(m *busMessage) bool {
+		return m.iface == systemdManager && m.member == "JobRemoved" && len(m.body) == 4 && m.body[1] == job
+	})
+	if err != nil {
+		return err
+	}
+	result, _ := removed.body[3].(string)
+	if result != "done" && result != "skipped" {
+		return &JobError{Unit: unit, Result: result}
+	}
+	return nil
+}
+
+// busShow returns properties of a unit, formatted as systemctl show would.
+func (u Unit) busShow(unit string, properties ...string) (map[string]string, error) {
+	c, err := u.dialSystemd()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	body, err := c.call(systemdBusName, systemdBusPath, systemdManager, "LoadUnit", "s", fullUnitName(unit))
+	if err != nil {
+		return nil, err
+	}
+	path, _ := body[0].(busObjectPath)
+
+	wanted := map[string]bool{}
+	for _, p := range properties {
+		wanted[p] = true
+	}
+	props := map[string]string{}
+	for _, iface := range []string{"org.freedesktop.systemd1.Unit", "org.freedesktop.systemd1.Service"} {
+		body, err := c.call(systemdBusName, path, "org.freedesktop.DBus.Properties", "GetAll", "s", iface)
+		var busErr *BusError
+		if errors.As(err, &busErr) && iface != "org.freedesktop.systemd1.Unit" {
+			// not a service
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		entries, _ := body[0].([]interface{})
+		for _, e := range entries {
+			kv, _ := e.([]interface{})
+			if len(kv) != 2 {
+				continue
+			}
+			name, _ := kv[0].(string)
+			if v, ok := kv[1].(busVariant); ok && wanted[name] {
+				props[name] = busPropertyString(name, v.value)
+			}
+		}
+	}
+	return props, nil
+}
+
+// busPropertyString formats a property value the way systemctl show does.
+func busPropertyString(name string, v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case busObjectPath:
+		return string(value)
+	case bool:
+		if value {
+			return "yes"
+		}
+		return "no"
+	case uint64:
+		if strings.HasSuffix(name, "Timestamp") {
+			if value == 0 {
+				return ""
+			}
+			return time.UnixMicro(int64(value)).Format(systemdTimestamp)
+		}
+		return strconv.FormatUint(value, 10)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case uint32:
+		return strconv.FormatUint(uint64(value), 10)
+	case int32:
+		return strconv.FormatInt(int64(value), 10)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,158 @@
+package unitard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeBus is a D-Bus server pretending to be the systemd manager.
+type fakeBus struct {
+	mu    sync.Mutex
+	calls []string // member and first argument of each call
+}
+
+func (f *fakeBus) serve(t *testing.T, l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(t, newBusConn(context.Background(), conn))
+	}
+}
+
+func (f *fakeBus) handle(t *testing.T, c *busConn) {
+	defer c.Close()
+	if _, err := c.r.ReadString('\n'); err != nil {
+		return
+	}
+	c.conn.Write([]byte("OK 0123456789abcdef\r\n"))
+	if _, err := c.r.ReadString('\n'); err != nil {
+		return
+	}
+	for {
+		m, err := c.receive()
+		if err != nil {
+			return
+		}
+		call := m.member
+		if len(m.body) > 0 {
+			if s, ok := m.body[0].(string); ok {
+				call += " " + s
+			}
+		}
+		f.mu.Lock()
+		f.calls = append(f.calls, call)
+		f.mu.Unlock()
+
+		reply := &busMessage{kind: busMethodReturn, replySerial: m.serial}
+		var signal *busMessage
+		switch m.member {
+		case "Hello":
+			reply.sig, reply.body = "s", []interface{}{":1.1"}
+		case "EnableUnitFiles":
+			reply.sig, reply.body = "ba(sss)", []interface{}{true, []interface{}{}}
+		case "StartUnit", "RestartUnit", "StopUnit":
+			job := busObjectPath("/org/freedesktop/systemd1/job/1")
+			reply.sig, reply.body = "o", []interface{}{job}
+			result := "done"
+			if m.body[0] == "bad.service" {
+				result = "failed"
+			}
+			signal = &busMessage{kind: busSignal, path: systemdBusPath, iface: systemdManager, member: "JobRemoved",
+				sig: "uoss", body: []interface{}{uint32(1), job, m.body[0], result}}
+		case "LoadUnit":
+			reply.sig, reply.body = "o", []interface{}{busObjectPath("/org/freedesktop/systemd1/unit/test_5funit_2eservice")}
+		case "GetAll":
+			props := []interface{}{
+				[]interface{}{"ActiveState", busVariant{"s", "active"}},
+				[]interface{}{"SubState", busVariant{"s", "running"}},
+			}
+			if m.body[0] == "org.freedesktop.systemd1.Service" {
+				props = []interface{}{
+					[]interface{}{"MainPID", busVariant{"u", uint32(123)}},
+					[]interface{}{"ExecMainStartTimestamp", busVariant{"t", uint64(0)}},
+				}
+			}
+			reply.sig, reply.body = "a{sv}", []interface{}{props}
+		case "ListUnitsByPatterns":
+			reply.sig, reply.body = "a(ssssssouso)", []interface{}{[]interface{}{}}
+		}
+		c.serial++
+		reply.serial = c.serial
+		if err := c.send(reply); err != nil {
+			t.Error(err)
+			return
+		}
+		if signal != nil {
+			c.serial++
+			signal.serial = c.serial
+			c.send(signal)
+		}
+	}
+}
+
+func (f *fakeBus) log() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return strings.Join(f.calls, "\n")
+}
+
+func startFakeBus(t *testing.T) *fakeBus {
+	socket := filepath.Join(t.TempDir(), "bus")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("could not listen: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+socket)
+	f := &fakeBus{}
+	go f.serve(t, l)
+	return f
+}
+
+func TestBusSystemctl(t *testing.T) {
+	f := startFakeBus(t)
+	u := Unit{name: "test_unit", dbus: true}
+
+	if err := u.systemctl("enable", "--now", "test_unit"); err != nil {
+		t.Fatalf("enable failed: %s", err)
+	}
+	log := f.log()
+	for _, want := range []string{"AddMatch type='signal'", "EnableUnitFiles", "Reload", "Subscribe", "StartUnit test_unit.service"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("calls do not contain %q:\n%s", want, log)
+		}
+	}
+
+	err := u.systemctl("restart", "bad")
+	var jobErr *JobError
+	if !errors.As(err, &jobErr) || jobErr.Result != "failed" {
+		t.Errorf("expected a failed job, got %v", err)
+	}
+
+	if err := u.systemctl("stop", "test_unit@*.service"); err != nil {
+		t.Errorf("stopping a pattern failed: %s", err)
+	}
+	if err := u.systemctl("frobnicate", "test_unit"); err == nil {
+		t.Error("unknown command should fail")
+	}
+}
+
+func TestBusStatus(t *testing.T) {
+	startFakeBus(t)
+	u := Unit{name: "test_unit", dbus: true}
+
+	s, err := u.Status()
+	if err != nil {
+		t.Fatalf("could not get status: %s", err)
+	}
+	if s.ActiveState != "active" || s.SubState != "running" || s.MainPID != 123 || !s.ExecMainStartTimestamp.IsZero() {
+		t.Errorf("wrong status %+v", s)
+	}
+}
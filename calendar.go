@@ -0,0 +1,147 @@
+package unitard
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// calendarShorthands are the systemd calendar expressions with names.
+var calendarShorthands = map[string]string{
+	"minutely": "*-*-* *:*:00",
+	"hourly":   "*-*-* *:00:00",
+	"daily":    "*-*-* 00:00:00",
+	"weekly":   "Mon *-*-* 00:00:00",
+	"monthly":  "*-*-01 00:00:00",
+	"yearly":   "*-01-01 00:00:00",
+	"annually": "*-01-01 00:00:00",
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// calendarSpec is the subset of a systemd calendar expression which other
+// schedulers (cron, Task Scheduler) can also express.
+type calendarSpec struct {
+	weekdays []time.Weekday // empty for every day of the week
+	month    int            // 1-12, or 0 for every month
+	day      int            // day of the month, or 0 for every day
+	hour     int            // 0-23, or -1 for every hour
+	minute   int            // 0-59, or -1 for every minute
+}
+
+// parseCalendar parses the simple forms of an OnCalendar expression: the
+// shorthands such as daily, or "[weekdays] [*-month-day] [hour:minute[:00]]"
+// where each field is a number or *, and weekdays is a list like Mon,Wed or
+// a range like Mon..Fri.
+func parseCalendar(expr string) (calendarSpec, error) {
+	bad := func(why string) (calendarSpec, error) {
+		return calendarSpec{}, fmt.Errorf("calendar expression '%s' is not supported: %s", expr, why)
+	}
+	fields := strings.Fields(expr)
+	if long, ok := calendarShorthands[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		fields = strings.Fields(long)
+	}
+	if len(fields) == 0 {
+		return bad("it is empty")
+	}
+
+	spec := calendarSpec{}
+	if !strings.ContainsAny(fields[0], "-:*") {
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return bad(err.Error())
+		}
+		spec.weekdays = days
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && strings.Contains(fields[0], "-") {
+		date := strings.Split(fields[0], "-")
+		if len(date) != 3 || date[0] != "*" {
+			return bad("the date must be *-month-day")
+		}
+		var err error
+		if spec.month, err = calendarField(date[1], 1, 12); err != nil {
+			return bad("month " + err.Error())
+		}
+		if spec.day, err = calendarField(date[2], 1, 31); err != nil {
+			return bad("day " + err.Error())
+		}
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && strings.Contains(fields[0], ":") {
+		clock := strings.Split(fields[0], ":")
+		if len(clock) == 3 {
+			if s, err := strconv.Atoi(clock[2]); err != nil || s != 0 {
+				return bad("seconds must be 00")
+			}
+		} else if len(clock) != 2 {
+			return bad("the time must be hour:minute")
+		}
+		var err error
+		if spec.hour, err = calendarField(clock[0], 0, 23); err != nil {
+			return bad("hour " + err.Error())
+		}
+		if spec.minute, err = calendarField(clock[1], 0, 59); err != nil {
+			return bad("minute " + err.Error())
+		}
+		fields = fields[1:]
+	}
+	if len(fields) > 0 {
+		return bad("unexpected '" + fields[0] + "'")
+	}
+	if spec.month == -1 {
+		spec.month = 0
+	}
+	if spec.day == -1 {
+		spec.day = 0
+	}
+	return spec, nil
+}
+
+// calendarField parses a number between min and max, or * which is
+// returned as -1.
+func calendarField(s string, min, max int) (int, error) {
+	if s == "*" {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("must be * or %d-%d", min, max)
+	}
+	return n, nil
+}
+
+// parseWeekdays parses a list of weekdays like Mon,Wed or Mon..Fri.
+func parseWeekdays(s string) ([]time.Weekday, error) {
+	days := []time.Weekday{}
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "..")
+		first, ok := weekdayNames[strings.ToLower(from)]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday '%s'", from)
+		}
+		last := first
+		if isRange {
+			last, ok = weekdayNames[strings.ToLower(to)]
+			if !ok {
+				return nil, fmt.Errorf("unknown weekday '%s'", to)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days = append(days, d)
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
@@ -0,0 +1,52 @@
+package unitard
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCalendar(t *testing.T) {
+	tests := map[string]calendarSpec{
+		"daily":                 {hour: 0, minute: 0},
+		"hourly":                {hour: -1, minute: 0},
+		"minutely":              {hour: -1, minute: -1},
+		"weekly":                {weekdays: []time.Weekday{time.Monday}},
+		"monthly":               {day: 1},
+		"yearly":                {month: 1, day: 1},
+		"*-*-* 09:30":           {hour: 9, minute: 30},
+		"Mon..Wed *-*-* 9:00":   {weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday}, hour: 9},
+		"Sat,Sun 10:15:00":      {weekdays: []time.Weekday{time.Saturday, time.Sunday}, hour: 10, minute: 15},
+		"*-*-15 *:00":           {day: 15, hour: -1},
+		"Fri..Mon *-*-* 00:00":  {weekdays: []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday}},
+		"*-06-01 12:00:00":      {month: 6, day: 1, hour: 12},
+		"  Daily  ":             {},
+		"Thursday *-*-* 23:59":  {weekdays: []time.Weekday{time.Thursday}, hour: 23, minute: 59},
+		"*-*-* *:*":             {hour: -1, minute: -1},
+		"*-*-* 00:00:00":        {},
+		"Tue *-*-* 07:05:00":    {weekdays: []time.Weekday{time.Tuesday}, hour: 7, minute: 5},
+		"Mon,Wed..Thu 01:02:00": {weekdays: []time.Weekday{time.Monday, time.Wednesday, time.Thursday}, hour: 1, minute: 2},
+	}
+	for expr, want := range tests {
+		got, err := parseCalendar(expr)
+		if err != nil {
+			t.Errorf("%q failed: %s", expr, err)
+			continue
+		}
+		if len(want.weekdays) == 0 {
+			want.weekdays = nil
+		}
+		if len(got.weekdays) == 0 {
+			got.weekdays = nil
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q parsed as %+v, want %+v", expr, got, want)
+		}
+	}
+
+	for _, expr := range []string{"", "quarterly", "2024-*-* 00:00", "*-*-* 00:00:30", "Mon 25:00", "Funday", "*-*-* 1:2:3:4", "*-13-01"} {
+		if _, err := parseCalendar(expr); err == nil {
+			t.Errorf("%q should fail", expr)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package unitard
+
+import (
+	"context"
+	"errors"
+)
+
+// SystemdClient is how unitard manages units, for OptClient. Implement it
+// to manage units some other way, or to test deploys without systemd (see
+// the unitardtest package).
+//
+// Only the systemctl interaction goes through the client. Unit files are
+// still written to the UnitDirectory, but aren't checked with
+// systemd-analyze verify.
+type SystemdClient interface {
+	// UnitDirectory returns the directory unit files for the scope are
+	// installed in.
+	UnitDirectory(scope Scope) (string, error)
+	// Systemctl does what systemctl does with args (such as "daemon-reload",
+	// or "enable", "name.timer"), against the manager for the scope.
+	Systemctl(ctx context.Context, scope Scope, args ...string) error
+	// Show returns properties of a unit, as `systemctl show` does.
+	Show(ctx context.Context, scope Scope, unit string, properties ...string) (map[string]string, error)
+}
+
+// OptClient replaces systemctl with a SystemdClient. The environment checks
+// NewUnit usually makes are skipped, and the unit files are installed in the
+// directory the client returns.
+type OptClient struct {
+	Client SystemdClient
+}
+
+func (o OptClient) Apply(u *Unit) error {
+	if o.Client == nil {
+		return errors.New("OptClient needs a Client")
+	}
+	u.client = o.Client
+	return nil
+}
+
+// setupClient sets up a unit with a SystemdClient.
+func (u *Unit) setupClient() error {
+	dir, err := u.client.UnitDirectory(u.scope)
+	if err != nil {
+		return err
+	}
+	u.systemCtlPath = "systemctl"
+	u.unitFilePath = dir
+	return nil
+}
@@ -0,0 +1,61 @@
+package unitard
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+// recordingClient is a SystemdClient which records the commands it is
+// given.
+type recordingClient struct {
+	dir      string
+	commands []string
+}
+
+func (c *recordingClient) UnitDirectory(scope Scope) (string, error) {
+	return c.dir, nil
+}
+
+func (c *recordingClient) Systemctl(ctx context.Context, scope Scope, args ...string) error {
+	command := strings.Join(args, " ")
+	c.commands = append(c.commands, command)
+	return nil
+}
+
+func (c *recordingClient) Show(ctx context.Context, scope Scope, unit string, properties ...string) (map[string]string, error) {
+	return map[string]string{"ActiveState": "active", "SubState": "running"}, nil
+}
+
+func TestClient(t *testing.T) {
+	client := &recordingClient{dir: t.TempDir()}
+	u, err := NewUnit("test_unit", OptClient{Client: client}, OptSkipVerify{})
+	if err != nil {
+		t.Fatalf("could not create unit: %s", err)
+	}
+	if u.UnitFilename() != client.dir+"/test_unit.service" {
+		t.Errorf("unit file not in the client directory: %s", u.UnitFilename())
+	}
+
+	err = u.Deploy()
+	if err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	if _, err := os.Stat(u.UnitFilename()); err != nil {
+		t.Errorf("unit file not installed: %s", err)
+	}
+	want := "daemon-reload\nenable test_unit\nrestart test_unit"
+	if got := strings.Join(client.commands, "\n"); got != want {
+		t.Errorf("wrong commands:\n%s", got)
+	}
+
+	s, err := u.Status()
+	if err != nil || s.ActiveState != "active" {
+		t.Errorf("wrong status %+v %v", s, err)
+	}
+
+	if _, err := NewUnit("test_unit", OptClient{}); err == nil {
+		t.Error("OptClient without a client should fail")
+	}
+}
@@ -0,0 +1,101 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// conditionEnvironmentRegexp matches a ConditionEnvironment= value, a
+// variable name optionally with the value it must have.
+var conditionEnvironmentRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(=.*)?$`)
+
+// OptConditions makes the service conditional: when it is started, a
+// condition which isn't met skips it quietly (or fails the start, with
+// Assert). Several conditions must all be met. Prefix a value with "!" to
+// negate it, or with "|" to make it one of a group of which any one will
+// do. All paths must be absolute. It can be used more than once.
+//
+//	OptConditions{PathExists: []string{"/etc/myapp.conf"}, ACPower: "true"}
+type OptConditions struct {
+	PathExists        []string // these paths exist
+	PathIsDirectory   []string // these paths are directories
+	FileNotEmpty      []string // these files exist and aren't empty
+	DirectoryNotEmpty []string // these directories exist and contain files
+	Environment       []string // these variables are set in the service manager, as "VAR" or "VAR=value"
+	ACPower           string   // "true" if the machine must be on AC power, "false" if on battery
+	Host              string   // the hostname or machine ID
+	Virtualization    string   // such as "vm", "container" or "!container"
+	Assert            bool     // fail instead of skipping the service, with Assert...= directives
+}
+
+func (o OptConditions) Apply(u *Unit) error {
+	conditions := []struct {
+		key    string
+		values []string
+		valid  func(string) error
+	}{
+		{"PathExists", o.PathExists, conditionPath},
+		{"PathIsDirectory", o.PathIsDirectory, conditionPath},
+		{"FileNotEmpty", o.FileNotEmpty, conditionPath},
+		{"DirectoryNotEmpty", o.DirectoryNotEmpty, conditionPath},
+		{"Environment", o.Environment, conditionEnvironment},
+		{"ACPower", nonEmpty(o.ACPower), conditionACPower},
+		{"Host", nonEmpty(o.Host), nil},
+		{"Virtualization", nonEmpty(o.Virtualization), nil},
+	}
+	prefix := "Condition"
+	if o.Assert {
+		prefix = "Assert"
+	}
+	set := false
+	for _, c := range conditions {
+		for _, value := range c.values {
+			if c.valid != nil {
+				if err := c.valid(strings.TrimLeft(value, "|!")); err != nil {
+					return fmt.Errorf("bad %s%s '%s': %w", prefix, c.key, value, err)
+				}
+			}
+			err := u.addDirective(SectionUnit, prefix+c.key, value)
+			if err != nil {
+				return err
+			}
+			set = true
+		}
+	}
+	if !set {
+		return errors.New("OptConditions needs at least one condition")
+	}
+	return nil
+}
+
+// nonEmpty returns a list of the value, or no values if it is empty.
+func nonEmpty(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+func conditionPath(p string) error {
+	if !filepath.IsAbs(p) {
+		return errors.New("path must be absolute")
+	}
+	return nil
+}
+
+func conditionEnvironment(v string) error {
+	if !conditionEnvironmentRegexp.MatchString(v) {
+		return errors.New("must be VAR or VAR=value")
+	}
+	return nil
+}
+
+func conditionACPower(v string) error {
+	if v != "true" && v != "false" {
+		return errors.New("must be true or false")
+	}
+	return nil
+}
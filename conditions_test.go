@@ -0,0 +1,48 @@
+package unitard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConditions(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar"}
+	err := (OptConditions{
+		PathExists:  []string{"/etc/foobar.conf", "!/etc/foobar.disabled"},
+		Environment: []string{"DISPLAY"},
+		ACPower:     "true",
+	}).Apply(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (OptConditions{Virtualization: "!container", Assert: true}).Apply(&u); err != nil {
+		t.Fatal(err)
+	}
+	files, err := u.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := files[u.UnitFilename()]
+	for _, want := range []string{
+		"ConditionPathExists=/etc/foobar.conf\nConditionPathExists=!/etc/foobar.disabled\n",
+		"ConditionEnvironment=DISPLAY\n",
+		"ConditionACPower=true\n",
+		"AssertVirtualization=!container\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("unit file is missing %q:\n%s", want, content)
+		}
+	}
+
+	for _, o := range []OptConditions{
+		{},
+		{PathExists: []string{"relative"}},
+		{FileNotEmpty: []string{"|!relative"}},
+		{Environment: []string{"NOT VALID"}},
+		{ACPower: "maybe"},
+	} {
+		if err := o.Apply(&Unit{}); err == nil {
+			t.Errorf("%+v should be rejected", o)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OptConflicts sets Conflicts= on the service, for units which must not run
+// alongside it: starting the service stops them, and starting one of them
+// stops the service.
+//
+// With Replace, the service takes over from them, such as from a legacy
+// service it supersedes: once the service has started, Deploy disables them
+// too, so they no longer start at boot. Give the old name as an OptWantedBy
+// Alias as well, so that `systemctl start legacy` runs the new service. An
+// alias cannot replace a unit file in the unit directory though, so the
+// legacy unit must be removed first.
+type OptConflicts struct {
+	Units   []string // the conflicting units, eg "legacy-agent.service"
+	Replace bool     // disable the conflicting units once the service has started
+}
+
+func (o OptConflicts) Apply(u *Unit) error {
+	if len(o.Units) == 0 {
+		return errors.New("OptConflicts needs at least one unit")
+	}
+	for _, unit := range o.Units {
+		if !unitNameRegexp.MatchString(unit) {
+			return fmt.Errorf("sorry, unit name '%s' is not valid", unit)
+		}
+	}
+	if o.Replace {
+		u.replaces = append(u.replaces, o.Units...)
+	}
+	return u.addDirective(SectionUnit, "Conflicts", strings.Join(o.Units, " "))
+}
+
+// aliases returns the Alias= names of the service.
+func (u Unit) aliases() []string {
+	aliases := []string{}
+	for _, d := range u.directives {
+		if d.Section == SectionInstall && d.Key == "Alias" {
+			aliases = append(aliases, strings.Fields(d.Value)...)
+		}
+	}
+	return aliases
+}
+
+// checkAliases returns an error if an alias already names another unit in
+// the unit directory, as systemctl enable would refuse to link it.
+func (u Unit) checkAliases() error {
+	if u.remote != nil || u.backend != nil || u.dropIn != "" {
+		return nil
+	}
+	for _, alias := range u.aliases() {
+		name := filepath.Join(u.unitFilePath, alias)
+		fi, err := os.Lstat(name)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(name)
+			if err == nil && filepath.Base(target) == filepath.Base(u.UnitFilename()) {
+				// linked by an earlier deploy
+				continue
+			}
+		}
+		return fmt.Errorf("sorry, alias '%s' is already a unit at '%s'", alias, name)
+	}
+	return nil
+}
+
+// disableReplaced stops and disables the units the service replaces, with
+// OptConflicts. Units which are not installed are skipped.
+func (u Unit) disableReplaced() error {
+	if u.isTemplate() || u.deployMode == DeployStartOnly {
+		return nil
+	}
+	for _, unit := range u.replaces {
+		if u.plan == nil {
+			props, err := u.show(unit, "LoadState")
+			if err != nil {
+				return err
+			}
+			if props["LoadState"] == "not-found" {
+				continue
+			}
+		}
+		err := u.disableAndStop(unit)
+		if err != nil {
+			return fmt.Errorf("could not disable replaced unit '%s': %w", unit, err)
+		}
+	}
+	return nil
+}
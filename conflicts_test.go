@@ -0,0 +1,53 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConflicts(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	systemctl := filepath.Join(dir, "systemctl")
+	os.WriteFile(systemctl, []byte("#!/bin/sh\necho \"$@\" >> "+log+"\n[ \"$2\" = show ] && [ \"$3\" = legacy.service ] && echo LoadState=loaded && exit 0\n[ \"$2\" = show ] && echo LoadState=not-found\nexit 0\n"), 0700)
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+	if err := (OptConflicts{Units: []string{"legacy.service", "older.service"}, Replace: true}).Apply(&u); err != nil {
+		t.Fatal(err)
+	}
+	(OptWantedBy{Alias: []string{"legacy.service"}}).Apply(&u)
+
+	// the legacy unit file is in the way of the alias
+	legacy := filepath.Join(u.unitFilePath, "legacy.service")
+	os.WriteFile(legacy, []byte("[Service]\n"), 0600)
+	if err := u.Deploy(); err == nil || !strings.Contains(err.Error(), "alias 'legacy.service'") {
+		t.Errorf("expected an alias error, got %v", err)
+	}
+	os.Remove(legacy)
+
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(u.UnitFilename())
+	if !strings.Contains(string(content), "Conflicts=legacy.service older.service\n") {
+		t.Errorf("unit file is missing Conflicts:\n%s", content)
+	}
+	commands, _ := os.ReadFile(log)
+	if !strings.HasSuffix(string(commands), "--user restart test_unit\n--user show legacy.service --property=LoadState\n--user disable legacy.service\n--user stop legacy.service\n--user show older.service --property=LoadState\n") {
+		t.Errorf("replaced unit was not disabled:\n%s", commands)
+	}
+
+	// the alias link made by enable is not in the way
+	os.Symlink(u.UnitFilename(), legacy)
+	u.binaryArgs = "-v"
+	if err := u.Deploy(); err != nil {
+		t.Errorf("alias link should be allowed: %v", err)
+	}
+
+	for _, o := range []OptConflicts{{}, {Units: []string{"bad name.service"}}} {
+		if err := o.Apply(&Unit{}); err == nil {
+			t.Errorf("%+v should be rejected", o)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package unitard
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// rollbackTimeout limits how long restoring the previous unit can take
+// after a failed deploy, even if the deploy was cancelled.
+const rollbackTimeout = time.Minute
+
+// context returns the context commands are run with.
+func (u Unit) context() context.Context {
+	if u.ctx == nil {
+		return context.Background()
+	}
+	return u.ctx
+}
+
+// withContext returns the unit with all commands run using ctx.
+func (u Unit) withContext(ctx context.Context) Unit {
+	u.ctx = ctx
+	return u
+}
+
+// DeployContext is like Deploy, but commands are killed, and the deploy
+// abandoned, when ctx is done. If the unit files were already changed they
+// are rolled back, as when the unit fails to start.
+func (u Unit) DeployContext(ctx context.Context) error {
+	return u.withContext(ctx).Deploy()
+}
+
+// UndeployContext is like Undeploy, but gives up when ctx is done.
+func (u Unit) UndeployContext(ctx context.Context) error {
+	return u.withContext(ctx).Undeploy()
+}
+
+// EnableContext is like Enable, but gives up when ctx is done.
+func (u Unit) EnableContext(ctx context.Context) error {
+	return u.withContext(ctx).Enable()
+}
+
+// DisableContext is like Disable, but gives up when ctx is done.
+func (u Unit) DisableContext(ctx context.Context) error {
+	return u.withContext(ctx).Disable()
+}
+
+// StartContext is like Start, but gives up when ctx is done.
+func (u Unit) StartContext(ctx context.Context) error {
+	return u.withContext(ctx).Start()
+}
+
+// StopContext is like Stop, but gives up when ctx is done.
+func (u Unit) StopContext(ctx context.Context) error {
+	return u.withContext(ctx).Stop()
+}
+
+// RestartContext is like Restart, but gives up when ctx is done.
+func (u Unit) RestartContext(ctx context.Context) error {
+	return u.withContext(ctx).Restart()
+}
+
+// ReloadContext is like Reload, but gives up when ctx is done.
+func (u Unit) ReloadContext(ctx context.Context) error {
+	return u.withContext(ctx).Reload()
+}
+
+// StatusContext is like Status, but gives up when ctx is done.
+func (u Unit) StatusContext(ctx context.Context) (Status, error) {
+	return u.withContext(ctx).Status()
+}
+
+// WaitUntilActiveContext is like WaitUntilActive, but also stops waiting
+// when ctx is done.
+func (u Unit) WaitUntilActiveContext(ctx context.Context, timeout time.Duration) error {
+	return u.withContext(ctx).WaitUntilActive(timeout)
+}
+
+// SecurityContext is like Security, but gives up when ctx is done.
+func (u Unit) SecurityContext(ctx context.Context) (SecurityReport, error) {
+	return u.withContext(ctx).Security()
+}
+
+// LogsContext is like Logs, but journalctl is killed when ctx is done.
+func (u Unit) LogsContext(ctx context.Context, opts LogOptions) (io.ReadCloser, error) {
+	return u.withContext(ctx).Logs(opts)
+}
+
+// VerifyContext is like Verify, but gives up when ctx is done.
+func (u Unit) VerifyContext(ctx context.Context) (DriftReport, error) {
+	return u.withContext(ctx).Verify()
+}
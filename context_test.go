@@ -0,0 +1,44 @@
+package unitard
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeployContext(t *testing.T) {
+	systemctl := filepath.Join(t.TempDir(), "systemctl")
+	os.WriteFile(systemctl, []byte("#!/bin/sh\n[ \"$2\" = restart ] && exec sleep 10\nexit 0\n"), 0700)
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := u.DeployContext(ctx)
+	if time.Since(start) > 5*time.Second {
+		t.Error("deploy was not cancelled")
+	}
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a rolled back deadline error, got %v", err)
+	}
+	if rollbackErr != nil && rollbackErr.RollbackErr != nil {
+		t.Errorf("rollback should not be cancelled: %s", rollbackErr.RollbackErr)
+	}
+}
+
+func TestWaitUntilActiveContext(t *testing.T) {
+	systemctl := filepath.Join(t.TempDir(), "systemctl")
+	os.WriteFile(systemctl, []byte("#!/bin/sh\necho ActiveState=activating\necho SubState=start\n"), 0700)
+	u := Unit{name: "test_unit", systemCtlPath: systemctl}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := u.WaitUntilActiveContext(ctx, time.Minute)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package unitard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// credentialSystemDirectory is where system scope credentials given by
+// value are stored, in a directory for each unit.
+const credentialSystemDirectory = "/etc/unitard/credentials"
+
+// credentialNameRegexp matches the name of a credential.
+var credentialNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// OptCredential passes a secret to the service with systemd's credentials
+// (systemd 247 or later), rather than in its environment or command line.
+// The service reads it with Credential. It can be used more than once.
+//
+// Give either Value, which Deploy writes to a file only root (or the user,
+// for user scope) can read, or File, which already holds the secret. With
+// Encrypt, Value is encrypted with systemd-creds (systemd 250 or later,
+// system scope only) so it can only be decrypted on this machine; set
+// Encrypt with File if it was encrypted already. A changed Value restarts
+// the service, and is put back as it was if the deploy is rolled back.
+type OptCredential struct {
+	Name    string // the name the service reads it as
+	Value   []byte // the secret
+	File    string // or the absolute path of a file holding it
+	Encrypt bool   // Value is to be, or File is, encrypted with systemd-creds
+}
+
+// credential is a secret passed to the service with OptCredential.
+type credential struct {
+	name    string
+	value   []byte // nil if file was given
+	file    string
+	encrypt bool
+}
+
+func (o OptCredential) Apply(u *Unit) error {
+	if !credentialNameRegexp.MatchString(o.Name) || o.Name == "." || o.Name == ".." {
+		return fmt.Errorf("sorry, credential name '%s' is not valid", o.Name)
+	}
+	if (o.Value == nil) == (o.File == "") {
+		return errors.New("OptCredential needs exactly one of Value or File")
+	}
+	if o.File != "" && !filepath.IsAbs(o.File) {
+		return fmt.Errorf("sorry, credential file '%s' must be absolute", o.File)
+	}
+	for _, c := range u.credentials {
+		if c.name == o.Name {
+			return fmt.Errorf("credential '%s' was already set", o.Name)
+		}
+	}
+	u.credentials = append(u.credentials, credential{name: o.Name, value: o.Value, file: o.File, encrypt: o.Encrypt})
+	return nil
+}
+
+// checkCredentials returns an error if the credentials can't be used with
+// the other options.
+func (u Unit) checkCredentials() error {
+	if len(u.credentials) == 0 {
+		return nil
+	}
+	if u.dropIn != "" || u.quadlet != nil || u.backend != nil {
+		return errors.New("OptCredential can only be used with systemd, not with OptDropIn or OptQuadlet")
+	}
+	for _, c := range u.credentials {
+		if c.value != nil && u.root != "" {
+			return errors.New("OptCredential with a Value cannot be used with OptRoot, give a File")
+		}
+		if c.value != nil && c.encrypt && u.scope != ScopeSystem {
+			return errors.New("OptCredential can only encrypt a Value for system scope")
+		}
+	}
+	return nil
+}
+
+// credentialDir returns the directory credentials given by value are
+// stored in.
+func (u Unit) credentialDir() string {
+	if u.scope == ScopeSystem {
+		return filepath.Join(credentialSystemDirectory, u.name)
+	}
+	if configHome, err := userConfigHome(); err == nil && u.unitDirectory != "" && u.remote == nil {
+		return filepath.Join(configHome, "unitard", "credentials", u.name)
+	}
+	// next to $XDG_CONFIG_HOME/systemd/user
+	return filepath.Join(filepath.Dir(filepath.Dir(u.unitFilePath)), "unitard", "credentials", u.name)
+}
+
+// credentialFile returns the file a credential is loaded from.
+func (u Unit) credentialFile(c credential) string {
+	if c.value == nil {
+		return c.file
+	}
+	return filepath.Join(u.credentialDir(), c.name)
+}
+
+// credentialDirectives returns the LoadCredential= directives for the
+// service.
+func (u Unit) credentialDirectives() []Directive {
+	directives := []Directive{}
+	for _, c := range u.credentials {
+		key := "LoadCredential"
+		if c.encrypt {
+			key = "LoadCredentialEncrypted"
+		}
+		directives = append(directives, Directive{Section: SectionService, Key: key, Value: c.name + ":" + u.credentialFile(c)})
+	}
+	return directives
+}
+
+// credentialsChanged returns true if any of the credentials given by value
+// have not been written, or have changed.
+func (u Unit) credentialsChanged() bool {
+	for _, c := range u.credentials {
+		if c.value == nil {
+			continue
+		}
+		var current []byte
+		var err error
+		if c.encrypt {
+			command := u.withEscalation("systemd-creds", "decrypt", "--name="+c.name, u.credentialFile(c), "-")
+			var out string
+			out, err = u.runOutput(command[0], command[1:]...)
+			current = []byte(out)
+		} else {
+			current, err = u.readFile(u.credentialFile(c))
+		}
+		if err != nil || !bytes.Equal(current, c.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// backupCredentials saves the credentials given by value which are about to
+// be written, so the previous ones are put back if the deploy fails.
+func (u Unit) backupCredentials() ([]backup, error) {
+	backups := []backup{}
+	for _, c := range u.credentials {
+		if c.value == nil {
+			continue
+		}
+		name := u.credentialFile(c)
+		content, err := u.readFile(name)
+		if os.IsNotExist(err) {
+			backups = append(backups, backup{name: name, secret: true})
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("could not back up credential '%s': %w", c.name, err)
+		}
+		backups = append(backups, backup{name: name, content: content, existed: true, secret: true})
+	}
+	return backups, nil
+}
+
+// writeCredentials writes the credentials given by value, readable only by
+// their owner unless OptFileMode says otherwise. The contents are left out of plans and logs.
+func (u Unit) writeCredentials() error {
+	written := false
+	for _, c := range u.credentials {
+		if c.value == nil {
+			continue
+		}
+		if !written {
+			err := u.mkdirAll(u.credentialDir())
+			if err != nil {
+				return err
+			}
+			written = true
+		}
+		name := u.credentialFile(c)
+		var err error
+		if c.encrypt {
+			command := u.withEscalation("systemd-creds", "encrypt", "--name="+c.name, "-", name)
+			if !u.step(Action{Kind: ActionRun, Command: command}) {
+				_, err = u.runInput(bytes.NewReader(c.value), command[0], command[1:]...)
+			}
+			if err == nil {
+				err = u.chown(name)
+			}
+		} else if u.step(Action{Kind: ActionWrite, Path: name}) {
+			err = u.chown(name)
+		} else {
+			err = u.writeFile(name, c.value, u.secretMode())
+		}
+		if err != nil {
+			return fmt.Errorf("could not write credential '%s': %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+// removeCredentials removes the credentials given by value.
+func (u Unit) removeCredentials() error {
+	for _, c := range u.credentials {
+		if c.value == nil {
+			continue
+		}
+		err := u.removeFile(u.credentialFile(c))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// withEscalation returns a command, run with root privileges if needed.
+func (u Unit) withEscalation(command ...string) []string {
+	if u.escalate == nil {
+		return command
+	}
+	return append(append([]string{}, u.escalate...), command...)
+}
+
+// Credential returns a credential passed to the running service with
+// OptCredential (or LoadCredential= and the like), from the directory
+// systemd gives in $CREDENTIALS_DIRECTORY.
+func Credential(name string) ([]byte, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return nil, errors.New("no credentials, $CREDENTIALS_DIRECTORY is not set")
+	}
+	if !credentialNameRegexp.MatchString(name) || name == "." || name == ".." {
+		return nil, fmt.Errorf("sorry, credential name '%s' is not valid", name)
+	}
+	return os.ReadFile(filepath.Join(dir, name))
+}
@@ -0,0 +1,165 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCredential(t *testing.T) {
+	systemctl, log := fakeSystemctl(t, "")
+	config := t.TempDir()
+	unitDir := filepath.Join(config, "systemd", "user")
+	os.MkdirAll(unitDir, 0755)
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: unitDir}
+	for _, o := range []UnitOpts{
+		OptCredential{Name: "api-key", Value: []byte("secret 1")},
+		OptCredential{Name: "tls.key", File: "/etc/ssl/private/foobar.key"},
+		OptCredential{Name: "db", File: "/etc/foobar/db.cred", Encrypt: true},
+	} {
+		if err := o.Apply(&u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	secret := filepath.Join(config, "unitard", "credentials", "test_unit", "api-key")
+	if content, err := os.ReadFile(secret); err != nil || string(content) != "secret 1" {
+		t.Fatalf("credential was not written: %v %q", err, content)
+	}
+	if info, _ := os.Stat(secret); info.Mode().Perm() != 0600 {
+		t.Errorf("credential has mode %s", info.Mode())
+	}
+	content, _ := os.ReadFile(u.UnitFilename())
+	for _, want := range []string{
+		"LoadCredential=api-key:" + secret,
+		"LoadCredential=tls.key:/etc/ssl/private/foobar.key",
+		"LoadCredentialEncrypted=db:/etc/foobar/db.cred",
+	} {
+		if !strings.Contains(string(content), want+"\n") {
+			t.Errorf("unit file is missing %s:\n%s", want, content)
+		}
+	}
+
+	// a changed secret is written and restarted, without changing the unit file
+	os.Truncate(log, 0)
+	u.credentials[0].value = []byte("secret 2")
+	plan, err := u.DryRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range plan.Actions {
+		if strings.Contains(a.Content, "secret 2") {
+			t.Errorf("plan should not show the secret: %+v", a)
+		}
+	}
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(secret); string(content) != "secret 2" {
+		t.Errorf("changed credential was not written: %q", content)
+	}
+	if commands, _ := os.ReadFile(log); !strings.Contains(string(commands), "restart test_unit") {
+		t.Errorf("changed credential should restart the service: %s", commands)
+	}
+
+	if err := u.Undeploy(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(secret); !os.IsNotExist(err) {
+		t.Error("credential was not removed")
+	}
+}
+
+func TestCredentialOptions(t *testing.T) {
+	for _, o := range []OptCredential{
+		{Name: "", Value: []byte("x")},
+		{Name: "../x", Value: []byte("x")},
+		{Name: "x"},
+		{Name: "x", Value: []byte("x"), File: "/etc/x"},
+		{Name: "x", File: "relative"},
+	} {
+		u := Unit{}
+		if err := o.Apply(&u); err == nil {
+			t.Errorf("%+v should be rejected", o)
+		}
+	}
+
+	u := Unit{}
+	(OptCredential{Name: "x", Value: []byte("x"), Encrypt: true}).Apply(&u)
+	if err := u.checkCredentials(); err == nil {
+		t.Error("encrypting for user scope should be rejected")
+	}
+	u = Unit{dropIn: "override"}
+	(OptCredential{Name: "x", File: "/etc/x"}).Apply(&u)
+	if err := u.checkCredentials(); err == nil {
+		t.Error("credentials should be rejected with OptDropIn")
+	}
+	if err := (OptCredential{Name: "x", File: "/etc/y"}).Apply(&u); err == nil {
+		t.Error("a credential given twice should be rejected")
+	}
+}
+
+func TestCredentialRuntime(t *testing.T) {
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+	if _, err := Credential("api-key"); err == nil {
+		t.Error("expected an error without $CREDENTIALS_DIRECTORY")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "api-key"), []byte("secret"), 0600)
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+	if value, err := Credential("api-key"); err != nil || string(value) != "secret" {
+		t.Errorf("wrong credential %q: %v", value, err)
+	}
+	if _, err := Credential("../api-key"); err == nil {
+		t.Error("expected an error for a name outside the directory")
+	}
+}
+
+func TestCredentialRollback(t *testing.T) {
+	systemctl, _ := fakeSystemctl(t, "")
+	config := t.TempDir()
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: filepath.Join(config, "systemd", "user")}
+	os.MkdirAll(u.unitFilePath, 0755)
+	(OptCredential{Name: "api-key", Value: []byte("old secret")}).Apply(&u)
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the credential changes, and the restart fails
+	systemctl, log := fakeSystemctl(t, "restart")
+	u.systemCtlPath = systemctl
+	u.credentials = nil
+	(OptCredential{Name: "api-key", Value: []byte("new secret")}).Apply(&u)
+	err := u.Deploy()
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) || rollbackErr.RollbackErr != nil {
+		t.Fatalf("expected a successful rollback, got %v", err)
+	}
+	name := filepath.Join(config, "unitard", "credentials", "test_unit", "api-key")
+	if content, _ := os.ReadFile(name); string(content) != "old secret" {
+		t.Errorf("old credential was not restored: %q", content)
+	}
+	if info, err := os.Stat(name); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("restored credential should be 0600: %v %v", info, err)
+	}
+	commands, _ := os.ReadFile(log)
+	if !strings.HasSuffix(string(commands), "--user daemon-reload\n--user restart test_unit\n") {
+		t.Errorf("previous unit was not restarted:\n%s", commands)
+	}
+
+	// a new credential is removed again
+	os.Remove(filepath.Dir(systemctl) + "/ok")
+	u.credentials = nil
+	(OptCredential{Name: "token", Value: []byte("secret")}).Apply(&u)
+	if err := u.Deploy(); !errors.As(err, &rollbackErr) {
+		t.Fatalf("expected a rollback, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(name), "token")); !os.IsNotExist(err) {
+		t.Error("new credential was not removed")
+	}
+}
@@ -0,0 +1,489 @@
+package unitard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// cronDirectory is where system scope cron entries are installed.
+const cronDirectory = "/etc/cron.d"
+
+// cronStateDirectory is where the supervise scripts of system scope
+// services are installed.
+const cronStateDirectory = "/var/lib/unitard"
+
+// cronDirectives are the directives the cron backend uses. Others are
+// ignored, with a warning logged.
+var cronDirectives = map[string]bool{
+	markerManaged: true, "Environment": true, "RestartSec": true, "User": true,
+	"StandardOutput": true, "StandardError": true,
+}
+
+// OptCron deploys the unit with cron, for machines without systemd such as
+// containers and minimal distributions. A long-running service is started
+// at boot from an @reboot entry by a supervise script, which starts it
+// again whenever it exits; Deploy also starts it straight away. A unit with
+// an OptTimer runs on a schedule instead: OnCalendar must be a shorthand
+// such as daily or a simple expression like "Mon..Fri *-*-* 09:30",
+// OnBootSec becomes a delayed @reboot entry, and OnUnitActiveSec must be a
+// whole number of minutes or hours which divides the hour or day.
+//
+// User scope entries are put in the user's crontab between marker comments,
+// leaving the rest of it alone, and a copy is kept in ~/.config/unitard.
+// System scope entries are installed in /etc/cron.d, and run as the
+// service's User, if it has one; Deploy starts it as that User with su.
+type OptCron struct{}
+
+func (o OptCron) Apply(u *Unit) error {
+	u.backend = cron{}
+	return nil
+}
+
+// cron is the backend for machines without systemd.
+type cron struct{}
+
+func (cron) setup(u *Unit) error {
+	err := u.checkBackendOptions("cron")
+	if err != nil {
+		return err
+	}
+	timer, isJob := u.timer()
+	if len(u.triggers) > 1 || (len(u.triggers) == 1 && !isJob) {
+		return errors.New("sockets and paths cannot be used with cron")
+	}
+	if isJob {
+		_, err = cronSchedules(timer)
+		if err != nil {
+			return err
+		}
+	}
+	u.warnUnsupported("cron", cronDirectives)
+
+	u.toolPath = "crontab"
+	if path, err := exec.LookPath("crontab"); err == nil {
+		u.toolPath = path
+	} else if !u.dryRun && u.scope != ScopeSystem {
+		return fmt.Errorf("crontab not found: %w", err)
+	}
+
+	if u.scope == ScopeSystem {
+		u.unitFilePath = cronStateDirectory
+		if os.Getuid() != 0 && !u.dryRun {
+			escalate, err := u.escalation.command()
+			if err != nil {
+				return err
+			}
+			u.escalate = escalate
+		}
+		return nil
+	}
+	if os.Getuid() == 0 && !u.dryRun {
+		return ErrRootNotAllowed
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	u.unitFilePath = filepath.Join(dir, "unitard")
+	if u.dryRun {
+		return nil
+	}
+	err = os.MkdirAll(u.unitFilePath, 0700)
+	if err != nil {
+		return fmt.Errorf("cannot create the unitard path '%s': %w", u.unitFilePath, err)
+	}
+	return nil
+}
+
+// CronFilename returns the full path to the cron entries of a unit deployed
+// with OptCron.
+func (u Unit) CronFilename() string {
+	if u.scope == ScopeSystem {
+		return filepath.Join(cronDirectory, u.name)
+	}
+	return filepath.Join(u.unitFilePath, u.name+".cron")
+}
+
+// superviseFilename returns the full path to the supervise script of a
+// service deployed with OptCron.
+func (u Unit) superviseFilename() string {
+	return filepath.Join(u.unitFilePath, u.name+".sh")
+}
+
+// pidFilename returns the file the supervise script records its pid in.
+// A system service run as another User can't write to the state
+// directory, so it is kept in a directory of the service's own.
+func (u Unit) pidFilename() string {
+	if u.cronUser() != "" {
+		return filepath.Join(u.unitFilePath, u.name, u.name+".pid")
+	}
+	return filepath.Join(u.unitFilePath, u.name+".pid")
+}
+
+// cronUser returns the User a system service is run as, or an empty string
+// if it is run as root or the user deploying it.
+func (u Unit) cronUser() string {
+	if u.scope != ScopeSystem {
+		return ""
+	}
+	if user := u.serviceDirective("User"); user != "root" {
+		return user
+	}
+	return ""
+}
+
+// isJob returns true if the unit runs on a schedule rather than as a
+// service.
+func (u Unit) isJob() bool {
+	_, ok := u.timer()
+	return ok
+}
+
+func (cron) files(u Unit) []unitFile {
+	files := []unitFile{}
+	if !u.isJob() {
+		files = append(files, unitFile{u.superviseFilename(), withChecksum(u.writeSupervise)})
+	}
+	return append(files, unitFile{u.CronFilename(), withChecksum(u.writeCron)})
+}
+
+func (u Unit) writeSupervise(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	environment := []string{}
+	for _, v := range u.environment() {
+		environment = append(environment, v.Key+"="+shellQuote(v.Value))
+	}
+	restartSec := "1"
+	if sec := u.serviceDirective("RestartSec"); sec != "" {
+		if _, err := strconv.Atoi(sec); err == nil {
+			restartSec = sec
+		}
+	}
+	return t.ExecuteTemplate(f, "supervise.sh", map[string]interface{}{
+		"Name":             u.name,
+		"PidFile":          shellQuote(u.pidFilename()),
+		"WorkingDirectory": shellQuote(u.binaryPath),
+		"Environment":      environment,
+		"Command":          shellCommand(u.command()),
+		"Output":           u.shellRedirects(),
+		"RestartSec":       restartSec,
+	})
+}
+
+// shellRedirects returns the redirections for the OptOutput files, or
+// discards the output.
+func (u Unit) shellRedirects() string {
+	redirect := func(fd, value string) string {
+		file, _ := outputFile(value)
+		switch {
+		case file == "":
+			return fd + ">/dev/null"
+		case strings.HasPrefix(value, "append:"):
+			return fd + ">>" + shellQuote(file)
+		}
+		return fd + ">" + shellQuote(file)
+	}
+	return redirect("", u.serviceDirective("StandardOutput")) + " " + redirect("2", u.serviceDirective("StandardError"))
+}
+
+// cronEntry is a line of a crontab.
+type cronEntry struct {
+	Schedule string
+	Command  string
+}
+
+func (u Unit) writeCron(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	user := ""
+	if u.scope == ScopeSystem {
+		user = u.serviceDirective("User")
+		if user == "" {
+			user = "root"
+		}
+	}
+	entries := []cronEntry{}
+	timer, isJob := u.timer()
+	if !isJob {
+		entries = append(entries, cronEntry{"@reboot", cronEscape(shellCommand([]string{"/bin/sh", u.superviseFilename()}))})
+	} else {
+		command := "cd " + shellQuote(u.binaryPath) + " && "
+		for _, v := range u.environment() {
+			command += v.Key + "=" + shellQuote(v.Value) + " "
+		}
+		command += shellCommand(u.command())
+		schedules, err := cronSchedules(timer)
+		if err != nil {
+			return err
+		}
+		for _, e := range schedules {
+			entries = append(entries, cronEntry{e.Schedule, e.Command + cronEscape(command)})
+		}
+	}
+	return t.ExecuteTemplate(f, "cron", map[string]interface{}{"User": user, "Entries": entries})
+}
+
+// cronSchedules maps a timer to cron entries, with the command to run
+// before the program (if any) as the Command.
+func cronSchedules(timer OptTimer) ([]cronEntry, error) {
+	schedules := []cronEntry{}
+	if timer.OnBootSec > 0 {
+		delay := fmt.Sprintf("sleep %d && ", timer.OnBootSec.Round(time.Second)/time.Second)
+		schedules = append(schedules, cronEntry{"@reboot", delay})
+	}
+	if d := timer.OnUnitActiveSec; d > 0 {
+		switch {
+		case d%time.Minute == 0 && d < time.Hour && time.Hour%d == 0:
+			schedules = append(schedules, cronEntry{Schedule: fmt.Sprintf("*/%d * * * *", d/time.Minute)})
+		case d%time.Hour == 0 && d <= 24*time.Hour && (24*time.Hour)%d == 0:
+			schedules = append(schedules, cronEntry{Schedule: fmt.Sprintf("0 */%d * * *", d/time.Hour)})
+		default:
+			return nil, fmt.Errorf("cron can't run every %s, it must divide an hour or a day", d)
+		}
+	}
+	if timer.OnCalendar != "" {
+		spec, err := parseCalendar(timer.OnCalendar)
+		if err != nil {
+			return nil, err
+		}
+		// every hour or minute is -1, every day or month is 0
+		field := func(n, every int) string {
+			if n == every {
+				return "*"
+			}
+			return strconv.Itoa(n)
+		}
+		weekdays := "*"
+		if len(spec.weekdays) > 0 {
+			days := []string{}
+			for _, d := range spec.weekdays {
+				days = append(days, strconv.Itoa(int(d)))
+			}
+			weekdays = strings.Join(days, ",")
+		}
+		if weekdays != "*" && spec.day != 0 {
+			// cron runs when either matches, systemd when both do
+			return nil, fmt.Errorf("calendar expression '%s' is not supported: cron can't combine weekdays with a date", timer.OnCalendar)
+		}
+		schedule := strings.Join([]string{
+			field(spec.minute, -1), field(spec.hour, -1), field(spec.day, 0), field(spec.month, 0), weekdays,
+		}, " ")
+		schedules = append(schedules, cronEntry{Schedule: schedule})
+	}
+	return schedules, nil
+}
+
+// shellQuote quotes a string for the shell.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-./=:,+@") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellCommand quotes a command and its arguments for the shell.
+func shellCommand(args []string) string {
+	quoted := []string{}
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// cronEscape escapes the characters cron treats specially in a command.
+func cronEscape(command string) string {
+	return strings.ReplaceAll(command, "%", `\%`)
+}
+
+// cronBlockStart and cronBlockEnd mark the unit's entries in a crontab.
+func cronBlockStart(name string) string { return "# BEGIN unitard " + name }
+func cronBlockEnd(name string) string   { return "# END unitard " + name }
+
+// replaceCronBlock replaces the unit's entries in a crontab, adding them at
+// the end if they aren't there. An empty block removes them.
+func replaceCronBlock(crontab, name, block string) string {
+	lines := strings.SplitAfter(crontab, "\n")
+	kept := []string{}
+	inBlock := false
+	for _, line := range lines {
+		switch strings.TrimRight(line, "\n") {
+		case cronBlockStart(name):
+			inBlock = true
+			continue
+		case cronBlockEnd(name):
+			inBlock = false
+			continue
+		}
+		if !inBlock && line != "" {
+			kept = append(kept, line)
+		}
+	}
+	result := strings.Join(kept, "")
+	if result != "" && !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+	if block != "" {
+		result += cronBlockStart(name) + "\n" + strings.TrimRight(block, "\n") + "\n" + cronBlockEnd(name) + "\n"
+	}
+	return result
+}
+
+// crontab returns the user's crontab, which is empty if they have none.
+func (u Unit) crontab() (string, error) {
+	out, err := u.runOutput(u.toolPath, "-l")
+	var systemctlErr *SystemctlError
+	if errors.As(err, &systemctlErr) && strings.Contains(systemctlErr.Stderr, "no crontab") {
+		return "", nil
+	}
+	return out, err
+}
+
+// syncCrontab puts the unit's entries into the user's crontab, or removes
+// them if block is empty, installing it only if it changes.
+func (u Unit) syncCrontab(block string) error {
+	current, err := u.crontab()
+	if err != nil {
+		return err
+	}
+	updated := replaceCronBlock(current, u.name, block)
+	if updated == current {
+		return nil
+	}
+	tmp, err := os.CreateTemp("", "unitard-crontab-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(updated)
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	return u.runExpectZero(u.toolPath, tmp.Name())
+}
+
+// cronBlock returns the installed cron entries of the unit, or as they
+// would be if nothing is being written.
+func (u Unit) cronBlock() (string, error) {
+	if u.plan == nil {
+		content, err := os.ReadFile(u.CronFilename())
+		return string(content), err
+	}
+	b := bytes.NewBuffer(nil)
+	err := withChecksum(u.writeCron)(b)
+	return b.String(), err
+}
+
+func (cron) start(u Unit, changed bool) error {
+	if u.scope != ScopeSystem {
+		block, err := u.cronBlock()
+		if err != nil {
+			return err
+		}
+		err = u.syncCrontab(block)
+		if err != nil {
+			return err
+		}
+	}
+	if u.isJob() {
+		return nil
+	}
+	if changed || u.alwaysRestart {
+		err := u.stopSupervise()
+		if err != nil {
+			return err
+		}
+	}
+	// the script exits straight away if it is already running
+	command := "nohup /bin/sh " + shellQuote(u.superviseFilename()) + " >/dev/null 2>&1 &"
+	user := u.cronUser()
+	if user == "" {
+		return u.runPrivileged("/bin/sh", "-c", command)
+	}
+	// as cron runs it at boot
+	err := u.runPrivileged("install", "-d", "-o", user, "-m", "0755", filepath.Dir(u.pidFilename()))
+	if err != nil {
+		return err
+	}
+	return u.runPrivileged("su", "-s", "/bin/sh", "-c", command, user)
+}
+
+// stopSupervise stops the supervise script and the service, if running.
+func (u Unit) stopSupervise() error {
+	pidFile := shellQuote(u.pidFilename())
+	return u.runPrivileged("/bin/sh", "-c", "if [ -e "+pidFile+" ]; then kill $(cat "+pidFile+") 2>/dev/null; rm -f "+pidFile+"; fi; true")
+}
+
+func (cron) undeploy(u Unit) error {
+	if u.scope != ScopeSystem {
+		err := u.syncCrontab("")
+		if err != nil {
+			return err
+		}
+	}
+	if !u.isJob() {
+		err := u.stopSupervise()
+		if err != nil {
+			return err
+		}
+	}
+	for _, f := range (cron{}).files(u) {
+		err := u.removeFile(f.name)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if u.cronUser() != "" && !u.isJob() {
+		return u.runPrivileged("rm", "-rf", "--", filepath.Dir(u.pidFilename()))
+	}
+	return nil
+}
+
+// control can only start and stop services, cron jobs run on their
+// schedule.
+func (cron) control(u Unit, command string) error {
+	if u.isJob() {
+		return notSupported(BackendCron, command)
+	}
+	switch command {
+	case "start":
+		return (cron{}).start(u, false)
+	case "stop":
+		return u.stopSupervise()
+	case "restart":
+		return (cron{}).start(u, true)
+	}
+	return notSupported(BackendCron, command)
+}
+
+func (cron) status(u Unit) (Status, error) {
+	_, err := os.Stat(u.CronFilename())
+	loaded := err == nil
+	if u.isJob() || !loaded {
+		return backendStatus(loaded, false), nil
+	}
+	pidFile := shellQuote(u.pidFilename())
+	_, err = u.runOutput("/bin/sh", "-c", "kill -0 $(cat "+pidFile+") 2>/dev/null")
+	return backendStatus(true, err == nil), nil
+}
+
+func (cron) kind(u Unit) Backend {
+	return BackendCron
+}
@@ -0,0 +1,180 @@
+package unitard
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCrontab returns a crontab command which keeps the crontab in a file,
+// and the file.
+func fakeCrontab(t *testing.T) (string, string) {
+	state := t.TempDir() + "/crontab"
+	crontab, _ := fakeTool(t, "crontab", `if [ "$1" = "-l" ]; then
+	[ -e `+state+` ] || { echo "no crontab for test" >&2; exit 1; }
+	cat `+state+`
+else
+	cp "$1" `+state+`
+fi`)
+	return crontab, state
+}
+
+func TestCronJob(t *testing.T) {
+	crontab, state := fakeCrontab(t)
+	os.WriteFile(state, []byte("0 5 * * * backup\n"), 0600)
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", binaryPath: "/fullpath/to", binaryArgs: "-at 50%",
+		backend: cron{}, toolPath: crontab, unitFilePath: t.TempDir(),
+		triggers: []trigger{OptTimer{OnCalendar: "Mon..Fri *-*-* 09:30", OnBootSec: time.Minute}}}
+	u.addDirective(SectionService, "Environment", "MODE=batch")
+
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	content, _ := os.ReadFile(state)
+	for _, want := range []string{
+		"0 5 * * * backup\n# BEGIN unitard test_unit\n",
+		"\n@reboot sleep 60 && cd /fullpath/to && MODE=batch /fullpath/to/foobar -at '50\\%'\n",
+		"\n30 9 * * 1,2,3,4,5 cd /fullpath/to && MODE=batch /fullpath/to/foobar -at '50\\%'\n",
+		"\n# END unitard test_unit\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("crontab does not contain %q:\n%s", want, content)
+		}
+	}
+
+	// deploying again leaves it alone
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("second deploy failed: %s", err)
+	}
+	if again, _ := os.ReadFile(state); string(again) != string(content) {
+		t.Errorf("crontab changed:\n%s", again)
+	}
+
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if content, _ := os.ReadFile(state); string(content) != "0 5 * * * backup\n" {
+		t.Errorf("entries were not removed:\n%s", content)
+	}
+	if _, err := os.Stat(u.CronFilename()); !os.IsNotExist(err) {
+		t.Error("cron file was not removed")
+	}
+}
+
+func TestCronService(t *testing.T) {
+	crontab, state := fakeCrontab(t)
+	u := Unit{name: "test_unit", binary: "/bin/sleep", binaryPath: "/", binaryArgs: "60",
+		backend: cron{}, toolPath: crontab, unitFilePath: t.TempDir()}
+
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	content, _ := os.ReadFile(state)
+	if !strings.Contains(string(content), "\n@reboot /bin/sh "+u.superviseFilename()+"\n") {
+		t.Errorf("crontab does not start the service:\n%s", content)
+	}
+	started := false
+	for i := 0; i < 50 && !started; i++ {
+		_, err := os.Stat(u.pidFilename())
+		started = err == nil
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !started {
+		t.Error("service was not started")
+	}
+
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if _, err := os.Stat(u.pidFilename()); !os.IsNotExist(err) {
+		t.Error("service was not stopped")
+	}
+}
+
+func TestCronSchedules(t *testing.T) {
+	tests := map[string]OptTimer{
+		"0 0 * * *":    {OnCalendar: "daily"},
+		"0 * * * *":    {OnCalendar: "hourly"},
+		"0 0 1 1 *":    {OnCalendar: "yearly"},
+		"0 0 * * 1":    {OnCalendar: "weekly"},
+		"*/15 * * * *": {OnUnitActiveSec: 15 * time.Minute},
+		"0 */6 * * *":  {OnUnitActiveSec: 6 * time.Hour},
+	}
+	for want, timer := range tests {
+		schedules, err := cronSchedules(timer)
+		if err != nil || len(schedules) != 1 || schedules[0].Schedule != want {
+			t.Errorf("%+v gave %v %v, want %s", timer, schedules, err, want)
+		}
+	}
+	for _, timer := range []OptTimer{{OnUnitActiveSec: 7 * time.Minute}, {OnCalendar: "Mon *-*-01"}, {OnUnitActiveSec: 90 * time.Second}} {
+		if _, err := cronSchedules(timer); err == nil {
+			t.Errorf("%+v should fail", timer)
+		}
+	}
+}
+
+func TestReplaceCronBlock(t *testing.T) {
+	crontab := "a\n# BEGIN unitard x\nold\n# END unitard x\nb"
+	if got := replaceCronBlock(crontab, "x", "new\n"); got != "a\nb\n# BEGIN unitard x\nnew\n# END unitard x\n" {
+		t.Errorf("wrong replacement:\n%s", got)
+	}
+	if got := replaceCronBlock(crontab, "x", ""); got != "a\nb\n" {
+		t.Errorf("wrong removal:\n%s", got)
+	}
+	if got := replaceCronBlock("", "x", "new"); got != "# BEGIN unitard x\nnew\n# END unitard x\n" {
+		t.Errorf("wrong addition:\n%s", got)
+	}
+}
+
+func TestCronServiceUser(t *testing.T) {
+	// the user must be able to read the script
+	dir := t.TempDir()
+	os.Chmod(filepath.Dir(dir), 0755)
+	os.Chmod(dir, 0755)
+	u := Unit{name: "test_unit", binary: "/bin/sleep", binaryPath: "/", binaryArgs: "60",
+		backend: cron{}, scope: ScopeSystem, unitFilePath: dir}
+	u.addDirective(SectionService, "User", "nobody")
+	pidFile := filepath.Join(dir, "test_unit", "test_unit.pid")
+	if u.pidFilename() != pidFile {
+		t.Errorf("wrong pid file %s", u.pidFilename())
+	}
+
+	plan := &Plan{}
+	planned := u
+	planned.plan = plan
+	if err := (cron{}).start(planned, false); err != nil {
+		t.Fatal(err)
+	}
+	want := "install -d -o nobody -m 0755 " + filepath.Dir(pidFile) + "\nsu -s /bin/sh -c "
+	if got := strings.Join(plan.Commands(), "\n"); !strings.HasPrefix(got, want) || !strings.HasSuffix(got, " nobody") {
+		t.Errorf("service is not started as its user:\n%s", got)
+	}
+
+	if os.Geteuid() != 0 {
+		t.Skip("starting as another user needs root")
+	}
+	f, _ := os.Create(u.superviseFilename())
+	u.writeSupervise(f)
+	f.Close()
+	if err := (cron{}).start(u, false); err != nil {
+		t.Fatal(err)
+	}
+	var pid []byte
+	for i := 0; i < 50 && len(pid) == 0; i++ {
+		pid, _ = os.ReadFile(pidFile)
+		time.Sleep(50 * time.Millisecond)
+	}
+	user, err := exec.Command("ps", "-o", "user=", "-p", strings.TrimSpace(string(pid))).Output()
+	if err != nil || strings.TrimSpace(string(user)) != "nobody" {
+		t.Fatalf("service was not started as nobody: %q %v", user, err)
+	}
+	if err := (cron{}).undeploy(u); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Dir(pidFile)); !os.IsNotExist(err) {
+		t.Error("pid directory was not removed")
+	}
+}
@@ -0,0 +1,730 @@
+package unitard
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This is a minimal D-Bus client (see the D-Bus specification), just enough
+// to call methods on the systemd manager and wait for its signals, so that
+// OptDBus needs no dependencies outside the standard library.
+
+// D-Bus message types.
+const (
+	busMethodCall   = 1
+	busMethodReturn = 2
+	busError        = 3
+	busSignal       = 4
+)
+
+// D-Bus header field codes.
+const (
+	busFieldPath        = 1
+	busFieldInterface   = 2
+	busFieldMember      = 3
+	busFieldErrorName   = 4
+	busFieldReplySerial = 5
+	busFieldDestination = 6
+	busFieldSignature   = 8
+)
+
+// busMaxMessage is the largest message the specification allows.
+const busMaxMessage = 128 << 20
+
+// BusError is a D-Bus error returned by systemd, with OptDBus.
+type BusError struct {
+	Name    string // eg org.freedesktop.systemd1.NoSuchUnit
+	Message string
+}
+
+func (e *BusError) Error() string {
+	if e.Message == "" {
+		return e.Name
+	}
+	return e.Name + ": " + e.Message
+}
+
+// busObjectPath is a D-Bus object path (type o).
+type busObjectPath string
+
+// busSignature is a D-Bus type signature (type g).
+type busSignature string
+
+// busVariant is a value along with its signature (type v).
+type busVariant struct {
+	sig   string
+	value interface{}
+}
+
+// busMessage is a D-Bus message.
+type busMessage struct {
+	kind        byte
+	serial      uint32
+	replySerial uint32
+	path        busObjectPath
+	iface       string
+	member      string
+	errorName   string
+	destination string
+	sig         string
+	body        []interface{}
+}
+
+// busConn is a connection to a D-Bus bus.
+type busConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	ctx     context.Context
+	stop    func() bool
+	serial  uint32
+	signals []*busMessage // signals received while waiting for a reply
+}
+
+// busAddress returns the unix socket path of a D-Bus address, such as
+// unix:path=/run/user/1000/bus.
+func busAddress(address string) (string, error) {
+	for _, addr := range strings.Split(address, ";") {
+		if !strings.HasPrefix(addr, "unix:") {
+			continue
+		}
+		for _, kv := range strings.Split(strings.TrimPrefix(addr, "unix:"), ",") {
+			if strings.HasPrefix(kv, "path=") {
+				return busUnescape(strings.TrimPrefix(kv, "path="))
+			}
+			if strings.HasPrefix(kv, "abstract=") {
+				name, err := busUnescape(strings.TrimPrefix(kv, "abstract="))
+				return "@" + name, err
+			}
+		}
+	}
+	return "", fmt.Errorf("unsupported D-Bus address '%s'", address)
+}
+
+// busUnescape undoes the %xx escaping of D-Bus address values.
+func busUnescape(s string) (string, error) {
+	out := []byte{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			out = append(out, s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("bad escape in D-Bus address '%s'", s)
+		}
+		b, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("bad escape in D-Bus address '%s'", s)
+		}
+		out = append(out, b...)
+		i += 2
+	}
+	return string(out), nil
+}
+
+// dialBus connects to the bus at the socket path and authenticates. The
+// connection is abandoned when ctx is done.
+func dialBus(ctx context.Context, socket string) (*busConn, error) {
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, "unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	c := newBusConn(ctx, conn)
+	err = c.auth()
+	if err == nil {
+		_, err = c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello", "")
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func newBusConn(ctx context.Context, conn net.Conn) *busConn {
+	c := &busConn{conn: conn, r: bufio.NewReader(conn), ctx: ctx}
+	c.stop = context.AfterFunc(ctx, func() {
+		// unblock any read or write in progress
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	return c
+}
+
+// Close closes the connection.
+func (c *busConn) Close() error {
+	c.stop()
+	return c.conn.Close()
+}
+
+// err returns the context error if the context is done, otherwise err.
+func (c *busConn) err(err error) error {
+	if ctxErr := c.ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// auth authenticates as the current user with the EXTERNAL mechanism.
+func (c *busConn) auth() error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	_, err := io.WriteString(c.conn, "\x00AUTH EXTERNAL "+uid+"\r\n")
+	if err != nil {
+		return c.err(err)
+	}
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return c.err(err)
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("D-Bus authentication failed: %s", strings.TrimSpace(line))
+	}
+	_, err = io.WriteString(c.conn, "BEGIN\r\n")
+	return c.err(err)
+}
+
+// call calls a method and waits for the reply, returning its body.
+func (c *busConn) call(dest string, path busObjectPath, iface, member, sig string, args ...interface{}) ([]interface{}, error) {
+	c.serial++
+	m := &busMessage{
+		kind:        busMethodCall,
+		serial:      c.serial,
+		path:        path,
+		iface:       iface,
+		member:      member,
+		destination: dest,
+		sig:         sig,
+		body:        args,
+	}
+	err := c.send(m)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		reply, err := c.receive()
+		if err != nil {
+			return nil, err
+		}
+		if reply.kind == busSignal {
+			c.signals = append(c.signals, reply)
+			continue
+		}
+		if reply.replySerial != m.serial {
+			continue
+		}
+		if reply.kind == busError {
+			e := &BusError{Name: reply.errorName}
+			if len(reply.body) > 0 {
+				e.Message, _ = reply.body[0].(string)
+			}
+			return nil, e
+		}
+		return reply.body, nil
+	}
+}
+
+// waitSignal waits for a signal for which match returns true.
+func (c *busConn) waitSignal(match func(*busMessage) bool) (*busMessage, error) {
+	for i, s := range c.signals {
+		if match(s) {
+			c.signals = append(c.signals[:i], c.signals[i+1:]...)
+			return s, nil
+		}
+	}
+	for {
+		m, err := c.receive()
+		if err != nil {
+			return nil, err
+		}
+		if m.kind == busSignal && match(m) {
+			return m, nil
+		}
+	}
+}
+
+// send marshals and writes a message.
+func (c *busConn) send(m *busMessage) error {
+	body := &busEncoder{}
+	sigs := splitSignature(m.sig)
+	if len(sigs) != len(m.body) {
+		return fmt.Errorf("D-Bus signature '%s' does not match %d arguments", m.sig, len(m.body))
+	}
+	for i, sig := range sigs {
+		err := body.encode(sig, m.body[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	fields := []interface{}{}
+	addField := func(code byte, sig string, value interface{}) {
+		fields = append(fields, []interface{}{code, busVariant{sig, value}})
+	}
+	if m.path != "" {
+		addField(busFieldPath, "o", m.path)
+	}
+	if m.iface != "" {
+		addField(busFieldInterface, "s", m.iface)
+	}
+	if m.member != "" {
+		addField(busFieldMember, "s", m.member)
+	}
+	if m.errorName != "" {
+		addField(busFieldErrorName, "s", m.errorName)
+	}
+	if m.replySerial != 0 {
+		addField(busFieldReplySerial, "u", m.replySerial)
+	}
+	if m.destination != "" {
+		addField(busFieldDestination, "s", m.destination)
+	}
+	if m.sig != "" {
+		addField(busFieldSignature, "g", busSignature(m.sig))
+	}
+
+	header := &busEncoder{}
+	err := header.encodeAll("yyyyuua(yv)", []interface{}{
+		byte('l'), m.kind, byte(0), byte(1), uint32(len(body.buf)), m.serial, fields,
+	})
+	if err != nil {
+		return err
+	}
+	header.align(8)
+	_, err = c.conn.Write(append(header.buf, body.buf...))
+	return c.err(err)
+}
+
+// receive reads and unmarshals the next message.
+func (c *busConn) receive() (*busMessage, error) {
+	fixed := make([]byte, 16)
+	_, err := io.ReadFull(c.r, fixed)
+	if err != nil {
+		return nil, c.err(err)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if fixed[0] == 'B' {
+		order = binary.BigEndian
+	} else if fixed[0] != 'l' {
+		return nil, errors.New("bad D-Bus message endianness")
+	}
+	bodyLen := order.Uint32(fixed[4:])
+	fieldsLen := order.Uint32(fixed[12:])
+	headerLen := 16 + int(fieldsLen)
+	padded := (headerLen + 7) &^ 7
+	if uint64(padded)+uint64(bodyLen) > busMaxMessage {
+		return nil, errors.New("D-Bus message too long")
+	}
+	rest := make([]byte, padded-16+int(bodyLen))
+	_, err = io.ReadFull(c.r, rest)
+	if err != nil {
+		return nil, c.err(err)
+	}
+	buf := append(fixed, rest...)
+
+	d := &busDecoder{buf: buf[:headerLen], order: order}
+	values, err := d.decodeAll("yyyyuua(yv)")
+	if err != nil {
+		return nil, err
+	}
+	m := &busMessage{kind: values[1].(byte), serial: values[5].(uint32)}
+	for _, f := range values[6].([]interface{}) {
+		field := f.([]interface{})
+		value := field[1].(busVariant).value
+		switch field[0].(byte) {
+		case busFieldPath:
+			m.path, _ = value.(busObjectPath)
+		case busFieldInterface:
+			m.iface, _ = value.(string)
+		case busFieldMember:
+			m.member, _ = value.(string)
+		case busFieldErrorName:
+			m.errorName, _ = value.(string)
+		case busFieldReplySerial:
+			m.replySerial, _ = value.(uint32)
+		case busFieldDestination:
+			m.destination, _ = value.(string)
+		case busFieldSignature:
+			sig, _ := value.(busSignature)
+			m.sig = string(sig)
+		}
+	}
+
+	body := &busDecoder{buf: buf[padded:], order: order}
+	m.body, err = body.decodeAll(m.sig)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// splitSignature splits a signature into its complete types.
+func splitSignature(sig string) []string {
+	types := []string{}
+	for sig != "" {
+		n := typeLength(sig)
+		if n == 0 {
+			// malformed, let the encoder complain
+			return append(types, sig)
+		}
+		types = append(types, sig[:n])
+		sig = sig[n:]
+	}
+	return types
+}
+
+// typeLength returns the length of the first complete type in sig, or 0
+// if it is malformed.
+func typeLength(sig string) int {
+	if sig == "" {
+		return 0
+	}
+	switch sig[0] {
+	case 'a':
+		n := typeLength(sig[1:])
+		if n == 0 {
+			return 0
+		}
+		return 1 + n
+	case '(', '{':
+		end := byte(')')
+		if sig[0] == '{' {
+			end = '}'
+		}
+		i := 1
+		for i < len(sig) && sig[i] != end {
+			n := typeLength(sig[i:])
+			if n == 0 {
+				return 0
+			}
+			i += n
+		}
+		if i >= len(sig) {
+			return 0
+		}
+		return i + 1
+	}
+	return 1
+}
+
+// alignment returns the alignment of values of a type.
+func alignment(t byte) int {
+	switch t {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 'a', 's', 'o', 'h':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 1
+}
+
+// busEncoder marshals values in little endian order.
+type busEncoder struct {
+	buf []byte
+}
+
+func (e *busEncoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *busEncoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *busEncoder) encodeAll(sig string, values []interface{}) error {
+	sigs := splitSignature(sig)
+	if len(sigs) != len(values) {
+		return fmt.Errorf("D-Bus signature '%s' does not match %d values", sig, len(values))
+	}
+	for i, s := range sigs {
+		err := e.encode(s, values[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode marshals a value of a single complete type.
+func (e *busEncoder) encode(sig string, v interface{}) error {
+	bad := fmt.Errorf("cannot marshal %T as D-Bus type '%s'", v, sig)
+	switch sig[0] {
+	case 'y':
+		b, ok := v.(byte)
+		if !ok {
+			return bad
+		}
+		e.buf = append(e.buf, b)
+	case 'b':
+		b, ok := v.(bool)
+		if !ok {
+			return bad
+		}
+		var u uint32
+		if b {
+			u = 1
+		}
+		e.uint32(u)
+	case 'i':
+		i, ok := v.(int32)
+		if !ok {
+			return bad
+		}
+		e.uint32(uint32(i))
+	case 'u':
+		u, ok := v.(uint32)
+		if !ok {
+			return bad
+		}
+		e.uint32(u)
+	case 'x', 't':
+		var u uint64
+		switch n := v.(type) {
+		case int64:
+			u = uint64(n)
+		case uint64:
+			u = n
+		default:
+			return bad
+		}
+		e.align(8)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, u)
+	case 's', 'o':
+		var s string
+		switch str := v.(type) {
+		case string:
+			s = str
+		case busObjectPath:
+			s = string(str)
+		default:
+			return bad
+		}
+		e.uint32(uint32(len(s)))
+		e.buf = append(append(e.buf, s...), 0)
+	case 'g':
+		s, ok := v.(busSignature)
+		if !ok {
+			return bad
+		}
+		e.buf = append(append(append(e.buf, byte(len(s))), s...), 0)
+	case 'v':
+		variant, ok := v.(busVariant)
+		if !ok {
+			return bad
+		}
+		err := e.encode("g", busSignature(variant.sig))
+		if err != nil {
+			return err
+		}
+		return e.encode(variant.sig, variant.value)
+	case 'a':
+		var items []interface{}
+		switch list := v.(type) {
+		case []interface{}:
+			items = list
+		case []string:
+			for _, s := range list {
+				items = append(items, s)
+			}
+		default:
+			return bad
+		}
+		e.uint32(0)
+		lenAt := len(e.buf) - 4
+		e.align(alignment(sig[1]))
+		start := len(e.buf)
+		for _, item := range items {
+			err := e.encode(sig[1:], item)
+			if err != nil {
+				return err
+			}
+		}
+		binary.LittleEndian.PutUint32(e.buf[lenAt:], uint32(len(e.buf)-start))
+	case '(', '{':
+		fields, ok := v.([]interface{})
+		if !ok {
+			return bad
+		}
+		e.align(8)
+		return e.encodeAll(sig[1:len(sig)-1], fields)
+	default:
+		return bad
+	}
+	return nil
+}
+
+// busDecoder unmarshals values.
+type busDecoder struct {
+	buf   []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+var errBusShort = errors.New("D-Bus message is truncated")
+
+func (d *busDecoder) align(n int) error {
+	for d.pos%n != 0 {
+		d.pos++
+	}
+	if d.pos > len(d.buf) {
+		return errBusShort
+	}
+	return nil
+}
+
+func (d *busDecoder) next(n int) ([]byte, error) {
+	if d.pos+n > len(d.buf) {
+		return nil, errBusShort
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *busDecoder) uint32() (uint32, error) {
+	err := d.align(4)
+	if err != nil {
+		return 0, err
+	}
+	b, err := d.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return d.order.Uint32(b), nil
+}
+
+func (d *busDecoder) decodeAll(sig string) ([]interface{}, error) {
+	values := []interface{}{}
+	for _, s := range splitSignature(sig) {
+		v, err := d.decode(s)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// decode unmarshals a value of a single complete type. Arrays and structs
+// are returned as []interface{}.
+func (d *busDecoder) decode(sig string) (interface{}, error) {
+	switch sig[0] {
+	case 'y':
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'b':
+		u, err := d.uint32()
+		return u != 0, err
+	case 'n', 'q':
+		if err := d.align(2); err != nil {
+			return nil, err
+		}
+		b, err := d.next(2)
+		if err != nil {
+			return nil, err
+		}
+		if sig[0] == 'n' {
+			return int16(d.order.Uint16(b)), nil
+		}
+		return d.order.Uint16(b), nil
+	case 'i':
+		u, err := d.uint32()
+		return int32(u), err
+	case 'u', 'h':
+		return d.uint32()
+	case 'x', 't', 'd':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		u := d.order.Uint64(b)
+		switch sig[0] {
+		case 'x':
+			return int64(u), nil
+		case 'd':
+			return math.Float64frombits(u), nil
+		}
+		return u, nil
+	case 's', 'o':
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(n) + 1)
+		if err != nil {
+			return nil, err
+		}
+		if sig[0] == 'o' {
+			return busObjectPath(b[:n]), nil
+		}
+		return string(b[:n]), nil
+	case 'g':
+		n, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(n[0]) + 1)
+		if err != nil {
+			return nil, err
+		}
+		return busSignature(b[:n[0]]), nil
+	case 'v':
+		s, err := d.decode("g")
+		if err != nil {
+			return nil, err
+		}
+		sig := string(s.(busSignature))
+		if len(splitSignature(sig)) != 1 {
+			return nil, fmt.Errorf("bad D-Bus variant signature '%s'", sig)
+		}
+		v, err := d.decode(sig)
+		return busVariant{sig, v}, err
+	case 'a':
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		if err := d.align(alignment(sig[1])); err != nil {
+			return nil, err
+		}
+		end := d.pos + int(n)
+		if end > len(d.buf) {
+			return nil, errBusShort
+		}
+		items := []interface{}{}
+		for d.pos < end {
+			item, err := d.decode(sig[1:])
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case '(', '{':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		return d.decodeAll(sig[1 : len(sig)-1])
+	}
+	return nil, fmt.Errorf("unsupported D-Bus type '%s'", sig)
+}
@@ -0,0 +1,61 @@
+package unitard
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestBusMarshal(t *testing.T) {
+	sig := "ysbuta(sv)ao"
+	values := []interface{}{
+		byte(7), "hello", true, uint32(42), uint64(1 << 40),
+		[]interface{}{
+			[]interface{}{"one", busVariant{"s", "1"}},
+			[]interface{}{"two", busVariant{"as", []interface{}{"a", "b"}}},
+		},
+		[]interface{}{busObjectPath("/a/b")},
+	}
+	e := &busEncoder{}
+	if err := e.encodeAll(sig, values); err != nil {
+		t.Fatalf("could not marshal: %s", err)
+	}
+	d := &busDecoder{buf: e.buf, order: binary.LittleEndian}
+	decoded, err := d.decodeAll(sig)
+	if err != nil {
+		t.Fatalf("could not unmarshal: %s", err)
+	}
+	if !reflect.DeepEqual(decoded, values) {
+		t.Errorf("round trip changed values:\n%#v\n%#v", values, decoded)
+	}
+
+	if err := e.encodeAll("s", []interface{}{42}); err == nil {
+		t.Error("should not marshal an int as a string")
+	}
+	d = &busDecoder{buf: e.buf[:5], order: binary.LittleEndian}
+	if _, err := d.decodeAll(sig); err == nil {
+		t.Error("should not unmarshal a truncated message")
+	}
+}
+
+func TestSplitSignature(t *testing.T) {
+	got := splitSignature("sa{sv}(ua(ss))as")
+	want := []string{"s", "a{sv}", "(ua(ss))", "as"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong split %v", got)
+	}
+}
+
+func TestBusAddress(t *testing.T) {
+	socket, err := busAddress("unix:path=/run/user/1000/bus")
+	if err != nil || socket != "/run/user/1000/bus" {
+		t.Errorf("wrong socket %s %v", socket, err)
+	}
+	socket, err = busAddress("tcp:host=x;unix:abstract=/tmp/dbus-%41,guid=1")
+	if err != nil || socket != "@/tmp/dbus-A" {
+		t.Errorf("wrong abstract socket %s %v", socket, err)
+	}
+	if _, err := busAddress("tcp:host=localhost,port=1"); err == nil {
+		t.Error("tcp address should not be supported")
+	}
+}
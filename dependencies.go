@@ -0,0 +1,67 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// unitNameRegexp matches a full unit name, such as network-online.target
+// or other@instance.service.
+var unitNameRegexp = regexp.MustCompile(`^[A-Za-z0-9:_.\\@-]+\.[a-z]+$`)
+
+// OptDependencies declares ordering and requirement dependencies on other
+// units, each given as a full unit name such as "network-online.target" or
+// "myapp.target". It can be used more than once.
+//
+// Ordering and requirements are separate in systemd, so a service which
+// needs the network usually wants both:
+//
+//	OptDependencies{After: []string{"network-online.target"}, Wants: []string{"network-online.target"}}
+//
+// User units can only depend on other user units.
+type OptDependencies struct {
+	After     []string // start after these units
+	Before    []string // start before these units
+	Requires  []string // these must start, or this unit fails
+	Wants     []string // start these too, but don't fail without them
+	Requisite []string // these must already be running
+	BindsTo   []string // like Requires, and stop if they stop
+	PartOf    []string // stop and restart along with these units
+}
+
+func (o OptDependencies) Apply(u *Unit) error {
+	deps := []struct {
+		key   string
+		units []string
+	}{
+		{"After", o.After},
+		{"Before", o.Before},
+		{"Requires", o.Requires},
+		{"Wants", o.Wants},
+		{"Requisite", o.Requisite},
+		{"BindsTo", o.BindsTo},
+		{"PartOf", o.PartOf},
+	}
+	set := false
+	for _, d := range deps {
+		if len(d.units) == 0 {
+			continue
+		}
+		for _, unit := range d.units {
+			if !unitNameRegexp.MatchString(unit) {
+				return fmt.Errorf("bad %s unit '%s'", d.key, unit)
+			}
+		}
+		err := u.addDirective(SectionUnit, d.key, strings.Join(d.units, " "))
+		if err != nil {
+			return err
+		}
+		set = true
+	}
+	if !set {
+		return errors.New("OptDependencies needs at least one dependency")
+	}
+	return nil
+}
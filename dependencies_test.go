@@ -0,0 +1,37 @@
+package unitard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDependencies(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar"}
+	opts := []UnitOpts{
+		OptDependencies{After: []string{"network-online.target"}, Wants: []string{"network-online.target"}},
+		OptDependencies{After: []string{"db.service", "cache@main.service"}, PartOf: []string{"myapp.target"}},
+	}
+	for _, o := range opts {
+		if err := o.Apply(&u); err != nil {
+			t.Fatalf("could not apply %v: %s", o, err)
+		}
+	}
+	buff := bytes.NewBuffer(nil)
+	if err := u.writeTemplate(buff); err != nil {
+		t.Fatalf("failed to write template: %s", err)
+	}
+	for _, want := range []string{
+		"After=network-online.target\nWants=network-online.target\nAfter=db.service cache@main.service\nPartOf=myapp.target\n",
+	} {
+		if !strings.Contains(buff.String(), want) {
+			t.Errorf("template does not contain %q:\n%s", want, buff)
+		}
+	}
+
+	for _, bad := range []OptDependencies{{}, {After: []string{"network"}}, {Wants: []string{"a b.service"}}} {
+		if bad.Apply(&Unit{}) == nil {
+			t.Errorf("%v should not apply", bad)
+		}
+	}
+}
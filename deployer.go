@@ -0,0 +1,142 @@
+package unitard
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Deployer manages a program as a service, whatever the service manager.
+// A Unit is a Deployer with any Backend, so code which only renders,
+// installs and controls a service can be written against it.
+type Deployer interface {
+	// Render returns the files the deploy would write.
+	Render() (map[string]string, error)
+	// Deploy installs the service, enables it and starts it.
+	Deploy() error
+	// Enable has the service started at boot (or login).
+	Enable() error
+	// Start starts the service.
+	Start() error
+	// Stop stops the service.
+	Stop() error
+	// Undeploy stops the service and removes it.
+	Undeploy() error
+	// Status returns the state of the service.
+	Status() (Status, error)
+}
+
+var _ Deployer = Unit{}
+
+// Backend is a service manager units can be deployed with.
+type Backend string
+
+const (
+	BackendSystemd        Backend = "systemd"
+	BackendLaunchd        Backend = "launchd"
+	BackendWindowsService Backend = "Windows services"
+	BackendTaskScheduler  Backend = "Task Scheduler"
+	BackendOpenRC         Backend = "OpenRC"
+	BackendRunit          Backend = "runit"
+	BackendS6             Backend = "s6"
+	BackendCron           Backend = "cron"
+)
+
+func (b Backend) String() string {
+	return string(b)
+}
+
+// DetectBackend returns the service manager of the running system, which
+// NewUnit uses unless it is given a backend option: systemd when it is
+// running, launchd on macOS, the Service Control Manager on Windows, and
+// otherwise OpenRC, runit, s6 or cron if one of them is found. Systems
+// where none is found get systemd, so NewUnit reports that systemctl is
+// missing.
+func DetectBackend() Backend {
+	return detectRunningBackend()
+}
+
+// detectRunningBackend finds the backend for DetectBackend, replaced in
+// tests.
+var detectRunningBackend = func() Backend {
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	installed := func(command string) bool {
+		_, err := exec.LookPath(command)
+		return err == nil
+	}
+	return detectBackend(runtime.GOOS, exists, installed)
+}
+
+func detectBackend(goos string, exists, installed func(string) bool) Backend {
+	switch goos {
+	case "windows":
+		return BackendWindowsService
+	case "darwin":
+		return BackendLaunchd
+	}
+	switch {
+	case exists("/run/systemd/system"):
+		// the check sd_booted uses
+		return BackendSystemd
+	case exists("/run/openrc"):
+		return BackendOpenRC
+	case exists(runitServiceDirectory) && installed("runsvdir"):
+		return BackendRunit
+	case exists(s6ServiceDirectory) && installed("s6-svscan"):
+		return BackendS6
+	case !installed("systemctl") && installed("crontab"):
+		return BackendCron
+	}
+	return BackendSystemd
+}
+
+// newBackend returns the implementation of a Backend, or nil for systemd.
+func newBackend(b Backend) (backend, error) {
+	switch b {
+	case BackendSystemd:
+		return nil, nil
+	case BackendLaunchd:
+		return launchd{}, nil
+	case BackendWindowsService:
+		return windowsServiceBackend()
+	case BackendTaskScheduler:
+		return taskScheduler{}, nil
+	case BackendOpenRC:
+		return openrc{}, nil
+	case BackendRunit, BackendS6:
+		return runit{}, nil
+	case BackendCron:
+		return cron{}, nil
+	}
+	return nil, fmt.Errorf("unknown backend '%s'", b)
+}
+
+// OptBackend deploys the unit with a particular Backend, instead of the one
+// DetectBackend finds. The backend's own option (such as OptLaunchd) can
+// be used instead, when it has settings.
+type OptBackend struct {
+	Backend Backend
+}
+
+func (o OptBackend) Apply(u *Unit) error {
+	b, err := newBackend(o.Backend)
+	if err != nil {
+		return err
+	}
+	u.backend = b
+	u.backendChosen = true
+	u.runitS6 = o.Backend == BackendS6
+	return nil
+}
+
+// Backend returns the service manager the unit is deployed with.
+func (u Unit) Backend() Backend {
+	if u.backend == nil {
+		return BackendSystemd
+	}
+	return u.backend.kind(u)
+}
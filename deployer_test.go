@@ -0,0 +1,129 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectBackend(t *testing.T) {
+	tests := []struct {
+		goos     string
+		paths    []string
+		commands []string
+		want     Backend
+	}{
+		{"darwin", nil, nil, BackendLaunchd},
+		{"windows", nil, nil, BackendWindowsService},
+		{"linux", []string{"/run/systemd/system", "/run/openrc"}, nil, BackendSystemd},
+		{"linux", []string{"/run/openrc"}, []string{"systemctl"}, BackendOpenRC},
+		{"linux", []string{"/var/service"}, []string{"runsvdir"}, BackendRunit},
+		{"linux", []string{"/var/service"}, nil, BackendSystemd},
+		{"linux", []string{"/run/service"}, []string{"s6-svscan"}, BackendS6},
+		{"linux", nil, []string{"crontab"}, BackendCron},
+		{"linux", nil, []string{"crontab", "systemctl"}, BackendSystemd},
+		{"linux", nil, nil, BackendSystemd},
+	}
+	for _, test := range tests {
+		has := func(list []string) func(string) bool {
+			return func(s string) bool {
+				for _, l := range list {
+					if l == s {
+						return true
+					}
+				}
+				return false
+			}
+		}
+		if got := detectBackend(test.goos, has(test.paths), has(test.commands)); got != test.want {
+			t.Errorf("%s with %v %v: got %s, want %s", test.goos, test.paths, test.commands, got, test.want)
+		}
+	}
+}
+
+func TestOptBackend(t *testing.T) {
+	for _, b := range []Backend{BackendSystemd, BackendLaunchd, BackendTaskScheduler, BackendOpenRC, BackendRunit, BackendS6, BackendCron} {
+		u := Unit{}
+		if err := (OptBackend{Backend: b}).Apply(&u); err != nil {
+			t.Fatalf("%s: %s", b, err)
+		}
+		if u.Backend() != b {
+			t.Errorf("unit deployed with %s says %s", b, u.Backend())
+		}
+	}
+	if err := (OptBackend{Backend: "upstart"}).Apply(&Unit{}); err == nil {
+		t.Error("unknown backend should fail")
+	}
+
+	u := Unit{}
+	(OptBackend{Backend: BackendSystemd}).Apply(&u)
+	if !u.backendChosen {
+		t.Error("systemd should not be detected again")
+	}
+}
+
+func TestBackendLifecycle(t *testing.T) {
+	tools := t.TempDir()
+	log := filepath.Join(tools, "log")
+	for name, body := range map[string]string{
+		"rc-service": `[ "$2" != status ] || [ -e ` + tools + `/started ]`,
+		"rc-update":  "exit 0",
+	} {
+		os.WriteFile(filepath.Join(tools, name), []byte("#!/bin/sh\necho $(basename $0) \"$@\" >> "+log+"\n"+body+"\n"), 0700)
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "test_unit"), []byte("#!/sbin/openrc-run\n"), 0755)
+	var d Deployer = Unit{name: "test_unit", scope: ScopeSystem, backend: openrc{}, toolPath: tools, unitFilePath: dir}
+
+	for _, f := range []func() error{d.Enable, d.Start, d.Stop} {
+		if err := f(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	status, err := d.Status()
+	if err != nil || status.LoadState != "loaded" || status.ActiveState != "inactive" {
+		t.Errorf("wrong status %+v: %v", status, err)
+	}
+	os.WriteFile(filepath.Join(tools, "started"), nil, 0600)
+	if status, _ := d.Status(); status.ActiveState != "active" {
+		t.Errorf("service should be active: %+v", status)
+	}
+	want := "rc-update add test_unit default\nrc-service test_unit start\nrc-service test_unit stop\n" +
+		"rc-service test_unit status\nrc-service test_unit status\n"
+	if commands, _ := os.ReadFile(log); string(commands) != want {
+		t.Errorf("wrong commands:\n%s", commands)
+	}
+
+	job := Unit{name: "test_unit", backend: cron{}, triggers: []trigger{OptTimer{OnCalendar: "daily"}}}
+	if err := job.Enable(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("enabling a cron job should not be supported, got %v", err)
+	}
+}
+
+func TestDetectBackendSkipped(t *testing.T) {
+	detect := detectRunningBackend
+	defer func() { detectRunningBackend = detect }()
+	detectRunningBackend = func() Backend { return BackendCron }
+
+	client := &recordingClient{dir: t.TempDir()}
+	u, err := NewUnit("test_unit", OptClient{Client: client}, OptSkipVerify{})
+	if err != nil {
+		t.Fatalf("a client should not be replaced by the detected backend: %v", err)
+	}
+	if u.Backend() != BackendSystemd {
+		t.Errorf("unit with a client is deployed with %s", u.Backend())
+	}
+
+	u, err = NewUnit("test_unit", OptDBus{}, OptScope{Scope: ScopeSystem}, OptDryRun{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Backend() != BackendSystemd {
+		t.Errorf("unit using D-Bus is deployed with %s", u.Backend())
+	}
+
+	if u, _ := NewUnit("test_unit", OptDryRun{}); u.Backend() != BackendCron {
+		t.Errorf("backend should be detected otherwise, got %s", u.Backend())
+	}
+}
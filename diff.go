@@ -0,0 +1,179 @@
+package unitard
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// DiffResult compares the installed unit files with those Deploy would
+// write.
+type DiffResult struct {
+	Changed bool   // true if Deploy would change any unit file
+	Unified string // unified diff of the changes, empty if unchanged
+}
+
+// Diff compares the currently installed unit files with the ones Deploy would
+// write, returning a unified diff. Files which are not installed yet are
+// shown as new.
+func (u Unit) Diff() (DiffResult, error) {
+	changed, unified, err := u.diffFiles()
+	if err != nil {
+		return DiffResult{}, err
+	}
+	return DiffResult{Changed: len(changed) > 0, Unified: unified}, nil
+}
+
+// diffFiles returns the unit files which differ from the ones Deploy would
+// write, and a unified diff of them.
+func (u Unit) diffFiles() ([]string, string, error) {
+	rendered, err := u.Render()
+	if err != nil {
+		return nil, "", err
+	}
+
+	changed := []string{}
+	unified := ""
+	for _, f := range u.unitFiles() {
+		current, err := u.readFile(f.name)
+		oldName := f.name
+		if os.IsNotExist(err) {
+			oldName = "/dev/null"
+		} else if err != nil {
+			return nil, "", fmt.Errorf("could not read unit file '%s': %w", f.name, err)
+		}
+		diff := unifiedDiff(oldName, f.name, string(current), rendered[f.name])
+		if diff != "" {
+			changed = append(changed, f.name)
+			unified += diff
+		}
+	}
+	return changed, unified, nil
+}
+
+// diffOp is one line of an edit script.
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// splitLines splits text into lines, without the trailing newlines.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// editScript returns the shortest edit turning a into b, using the longest
+// common subsequence. Unit files are small, so the quadratic cost is fine.
+func editScript(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := []diffOp{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	return ops
+}
+
+// unifiedDiff returns a unified diff between two texts, or an empty string if
+// they are the same.
+func unifiedDiff(oldName, newName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	ops := editScript(splitLines(a), splitLines(b))
+
+	out := strings.Builder{}
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	// line numbers (1 based) in a and b at the start of each op
+	aLine, bLine := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	aLine[0], bLine[0] = 1, 1
+	for k, op := range ops {
+		aLine[k+1], bLine[k+1] = aLine[k], bLine[k]
+		if op.kind != '+' {
+			aLine[k+1]++
+		}
+		if op.kind != '-' {
+			bLine[k+1]++
+		}
+	}
+
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+		// a hunk starts with some context before the change, and continues
+		// until there are more than 2*context unchanged lines
+		start := k - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end += diffContext
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+
+		aCount, bCount := aLine[end]-aLine[start], bLine[end]-bLine[start]
+		aStart, bStart := aLine[start], bLine[start]
+		if aCount == 0 {
+			aStart--
+		}
+		if bCount == 0 {
+			bStart--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(&out, "%c%s\n", op.kind, op.line)
+		}
+		k = end
+	}
+	return out.String()
+}
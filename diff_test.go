@@ -0,0 +1,75 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n"
+
+	want := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ one
+ two
+-three
++THREE
+ four
+ five
+ six
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+`
+	if got := unifiedDiff("a", "b", a, b); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if unifiedDiff("a", "b", a, a) != "" {
+		t.Error("identical texts should have no diff")
+	}
+
+	newFile := unifiedDiff("/dev/null", "b", "", "x\ny\n")
+	if !strings.Contains(newFile, "@@ -0,0 +1,2 @@\n+x\n+y\n") {
+		t.Errorf("wrong diff for new file:\n%s", newFile)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", unitFilePath: t.TempDir()}
+
+	result, err := u.Diff()
+	if err != nil {
+		t.Fatalf("diff failed: %s", err)
+	}
+	if !result.Changed || !strings.HasPrefix(result.Unified, "--- /dev/null\n") {
+		t.Errorf("uninstalled unit should be a new file:\n%s", result.Unified)
+	}
+
+	files, _ := u.Render()
+	for name, content := range files {
+		os.WriteFile(name, []byte(content), 0600)
+	}
+	result, err = u.Diff()
+	if err != nil {
+		t.Fatalf("diff failed: %s", err)
+	}
+	if result.Changed {
+		t.Errorf("installed unit should be unchanged:\n%s", result.Unified)
+	}
+
+	u.binaryArgs = "-verbose"
+	result, _ = u.Diff()
+	if !result.Changed || !strings.Contains(result.Unified, "+ExecStart=/fullpath/to/foobar -verbose") {
+		t.Errorf("changed args should be in the diff:\n%s", result.Unified)
+	}
+	if filepath.Dir(u.UnitFilename()) != u.unitFilePath {
+		t.Error("unexpected unit file location")
+	}
+}
@@ -0,0 +1,112 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Section is a section of a unit file.
+type Section string
+
+const (
+	SectionUnit    Section = "Unit"
+	SectionService Section = "Service"
+	SectionInstall Section = "Install"
+	SectionSlice   Section = "Slice" // only for NewSlice
+)
+
+// Directive is a single Key=Value line in the service unit file.
+type Directive struct {
+	Section Section
+	Key     string
+	Value   string
+}
+
+var directiveKeyRegexp = regexp.MustCompile("^[A-Za-z][A-Za-z0-9-]*$")
+
+// listDirectives are the directives systemd allows to be repeated, adding to
+// a list rather than replacing the earlier value. Keys beginning with
+// Condition or Assert are also lists.
+var listDirectives = map[string]bool{
+	// [Unit]
+	"Documentation": true, "Wants": true, "Requires": true, "Requisite": true,
+	"BindsTo": true, "PartOf": true, "Upholds": true, "Conflicts": true,
+	"Before": true, "After": true, "OnFailure": true, "OnSuccess": true,
+	// [Service]
+	"ExecCondition": true, "ExecStartPre": true, "ExecStart": true, "ExecStartPost": true,
+	"ExecReload": true, "ExecStop": true, "ExecStopPost": true,
+	"Environment": true, "EnvironmentFile": true, "PassEnvironment": true, "UnsetEnvironment": true,
+	"ReadWritePaths": true, "ReadOnlyPaths": true, "InaccessiblePaths": true, "ExecPaths": true,
+	"NoExecPaths": true, "BindPaths": true, "BindReadOnlyPaths": true, "TemporaryFileSystem": true,
+	"StateDirectory": true, "CacheDirectory": true, "LogsDirectory": true, "RuntimeDirectory": true,
+	"ConfigurationDirectory": true, "SupplementaryGroups": true,
+	"SystemCallFilter": true, "RestrictAddressFamilies": true, "CapabilityBoundingSet": true,
+	"AmbientCapabilities": true, "DeviceAllow": true,
+	"LoadCredential": true, "LoadCredentialEncrypted": true, "SetCredential": true, "SetCredentialEncrypted": true,
+	// [Install]
+	"WantedBy": true, "RequiredBy": true, "UpheldBy": true, "Alias": true, "Also": true,
+}
+
+// isListDirective returns true if the directive may be repeated.
+func isListDirective(key string) bool {
+	return listDirectives[key] || strings.HasPrefix(key, "Condition") || strings.HasPrefix(key, "Assert")
+}
+
+// OptDirective adds an arbitrary directive to the service unit file, for
+// settings unitard doesn't provide an option for. It can be used more than
+// once.
+//
+// The systemd rules for repeated directives are followed: directives which
+// are lists (such as ExecStartPre or Environment) are added to, and an empty
+// Value resets the list. Other directives replace any earlier value set by
+// an option. To replace the ExecStart generated by unitard, first add an
+// empty ExecStart, then the new one.
+type OptDirective struct {
+	Section Section
+	Key     string
+	Value   string
+}
+
+func (o OptDirective) Apply(u *Unit) error {
+	if o.Section == "" {
+		return errors.New("directive needs a section")
+	}
+	if !directiveKeyRegexp.MatchString(o.Key) {
+		return fmt.Errorf("sorry, directive key '%s' is not valid", o.Key)
+	}
+	return u.addDirective(o.Section, o.Key, o.Value)
+}
+
+// addDirective adds a directive to the service unit file, replacing an earlier
+// value unless it is a list. Values cannot span multiple lines.
+func (u *Unit) addDirective(section Section, key, value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return errors.New("directive values cannot contain newlines")
+	}
+	if !isListDirective(key) || value == "" {
+		// a later value replaces (or an empty one resets) the earlier ones
+		kept := []Directive{}
+		for _, d := range u.directives {
+			if d.Section != section || d.Key != key {
+				kept = append(kept, d)
+			}
+		}
+		u.directives = kept
+	}
+	u.directives = append(u.directives, Directive{Section: section, Key: key, Value: value})
+	return nil
+}
+
+// sectionDirectives returns the directives for one section, in the order
+// they were added.
+func (u Unit) sectionDirectives(section Section) []Directive {
+	directives := []Directive{}
+	for _, d := range u.directives {
+		if d.Section == section {
+			directives = append(directives, d)
+		}
+	}
+	return directives
+}
@@ -0,0 +1,59 @@
+package unitard
+
+import (
+	"testing"
+)
+
+func TestDirectives(t *testing.T) {
+	u := Unit{}
+	opts := []UnitOpts{
+		OptDirective{SectionService, "ExecStartPre", "/bin/echo one"},
+		OptDirective{SectionService, "ExecStartPre", "/bin/echo two"},
+		OptDirective{SectionService, "Restart", "always"},
+		OptDirective{SectionService, "Restart", "on-failure"},
+		OptDirective{SectionUnit, "ConditionPathExists", "/etc/a"},
+		OptDirective{SectionUnit, "ConditionPathExists", "/etc/b"},
+		OptDirective{SectionService, "Environment", "A=1"},
+		OptDirective{SectionService, "Environment", ""},
+		OptDirective{SectionService, "Environment", "B=2"},
+	}
+	for _, opt := range opts {
+		if err := opt.Apply(&u); err != nil {
+			t.Fatalf("failed to apply %+v: %s", opt, err)
+		}
+	}
+
+	want := []Directive{
+		{SectionService, "ExecStartPre", "/bin/echo one"},
+		{SectionService, "ExecStartPre", "/bin/echo two"},
+		{SectionService, "Restart", "on-failure"},
+		{SectionService, "Environment", ""},
+		{SectionService, "Environment", "B=2"},
+	}
+	got := u.sectionDirectives(SectionService)
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("directive %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if len(u.sectionDirectives(SectionUnit)) != 2 {
+		t.Error("conditions should be a list")
+	}
+}
+
+func TestDirectiveOpts(t *testing.T) {
+	invalid := []OptDirective{
+		{"", "Restart", "always"},
+		{SectionService, "", "always"},
+		{SectionService, "Bad Key", "always"},
+		{SectionService, "Restart", "always\nUser=root"},
+	}
+	for _, i := range invalid {
+		if i.Apply(&Unit{}) == nil {
+			t.Errorf("%+v should not be valid", i)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+)
+
+// OptDynamicUser runs the service as a transient user and group, allocated
+// by systemd when the service starts. It can only be used with system scope.
+// Combine it with OptDirectories to give the service places to persist data.
+type OptDynamicUser struct{}
+
+func (o OptDynamicUser) Apply(u *Unit) error {
+	return u.addDirective(SectionService, "DynamicUser", "yes")
+}
+
+// OptDirectories asks systemd to create (and own) directories for the
+// service. Names are relative to the standard locations - for system units
+// /var/lib, /var/cache, /var/log and /run, for user units ~/.local/state,
+// ~/.cache, ~/.local/state/log and $XDG_RUNTIME_DIR.
+// The resolved paths can be found at runtime with Directories.
+type OptDirectories struct {
+	State   string // StateDirectory=
+	Cache   string // CacheDirectory=
+	Logs    string // LogsDirectory=
+	Runtime string // RuntimeDirectory=, removed when the service stops
+
+	// PurgeOnUndeploy removes the directories (and their contents) when the
+	// unit is undeployed.
+	PurgeOnUndeploy bool
+}
+
+func (o OptDirectories) Apply(u *Unit) error {
+	dirs := []struct {
+		key  string
+		name string
+	}{
+		{"StateDirectory", o.State},
+		{"CacheDirectory", o.Cache},
+		{"LogsDirectory", o.Logs},
+		{"RuntimeDirectory", o.Runtime},
+	}
+	set := false
+	for _, d := range dirs {
+		if d.name == "" {
+			continue
+		}
+		if !checkDirectoryName(d.name) {
+			return fmt.Errorf("%s must be a relative path without '..'", d.key)
+		}
+		err := u.addDirective(SectionService, d.key, d.name)
+		if err != nil {
+			return err
+		}
+		set = true
+	}
+	if !set {
+		return errors.New("OptDirectories needs at least one directory")
+	}
+	u.purgeDirectories = u.purgeDirectories || o.PurgeOnUndeploy
+	return nil
+}
+
+func checkDirectoryName(name string) bool {
+	if path.IsAbs(name) || strings.ContainsAny(name, " \t") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." || part == "." || part == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// purge removes the state, cache, logs and runtime directories of the
+// service, if requested with OptDirectories. The service must be stopped.
+func (u Unit) purge() error {
+	if !u.purgeDirectories {
+		return nil
+	}
+	return u.systemctl("clean", "--what=state", "--what=cache", "--what=logs", "--what=runtime", u.serviceName())
+}
+
+// Purge undeploys the unit and removes everything it left behind, so
+// uninstalling an application leaves nothing: the state, cache, logs and
+// runtime directories from OptDirectories (whether or not PurgeOnUndeploy
+// was given), files written with OptOutput, the files named by
+// EnvironmentFile directives and the copy of the program made by
+// OptInstall. For an Instance only its directories are removed, the files
+// are shared with the other instances.
+func (u Unit) Purge() error {
+	for _, d := range u.sectionDirectives(SectionService) {
+		if strings.HasSuffix(d.Key, "Directory") && d.Key != "WorkingDirectory" {
+			u.purgeDirectories = true
+		}
+	}
+	err := u.Undeploy()
+	if err != nil || u.instance != "" {
+		return err
+	}
+	files := append([]string{}, u.logFiles...)
+	for _, d := range u.sectionDirectives(SectionService) {
+		if d.Key != "EnvironmentFile" {
+			continue
+		}
+		file := strings.TrimPrefix(d.Value, "-")
+		if strings.Contains(file, "%") {
+			u.log(slog.LevelWarn, "not removing environment file with specifiers", "path", file)
+			continue
+		}
+		files = append(files, file)
+	}
+	for _, file := range files {
+		err := u.removeFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RuntimeDirectories are the directories created by systemd for a running
+// service, as requested with OptDirectories.
+type RuntimeDirectories struct {
+	State   []string
+	Cache   []string
+	Logs    []string
+	Runtime []string
+}
+
+// Directories returns the directories systemd created for the running
+// service, from the $STATE_DIRECTORY, $CACHE_DIRECTORY, $LOGS_DIRECTORY and
+// $RUNTIME_DIRECTORY environment variables. Directories which were not
+// requested are empty.
+func Directories() RuntimeDirectories {
+	return RuntimeDirectories{
+		State:   splitPaths(os.Getenv("STATE_DIRECTORY")),
+		Cache:   splitPaths(os.Getenv("CACHE_DIRECTORY")),
+		Logs:    splitPaths(os.Getenv("LOGS_DIRECTORY")),
+		Runtime: splitPaths(os.Getenv("RUNTIME_DIRECTORY")),
+	}
+}
+
+// splitPaths splits a colon separated list of paths
+func splitPaths(paths string) []string {
+	if paths == "" {
+		return nil
+	}
+	return strings.Split(paths, ":")
+}
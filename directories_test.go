@@ -0,0 +1,99 @@
+package unitard
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirectoriesTemplate(t *testing.T) {
+	u := Unit{name: "test_unit"}
+	for _, opt := range []UnitOpts{OptScope{Scope: ScopeSystem}, OptDynamicUser{}, OptDirectories{State: "test_unit", Logs: "test_unit/logs", PurgeOnUndeploy: true}} {
+		err := opt.Apply(&u)
+		if err != nil {
+			t.Fatalf("failed to apply option: %s", err)
+		}
+	}
+	if err := u.validate(); err != nil {
+		t.Errorf("should be valid: %s", err)
+	}
+	if !u.purgeDirectories {
+		t.Error("purge was not set")
+	}
+
+	buff := bytes.NewBuffer(nil)
+	err := u.writeTemplate(buff)
+	if err != nil {
+		t.Errorf("failed to write template: %s", err)
+	}
+	for _, want := range []string{"DynamicUser=yes\n", "StateDirectory=test_unit\n", "LogsDirectory=test_unit/logs\n"} {
+		if !strings.Contains(buff.String(), want) {
+			t.Errorf("template does not contain %q", want)
+		}
+	}
+	if strings.Contains(buff.String(), "CacheDirectory") {
+		t.Error("template contains unset CacheDirectory")
+	}
+}
+
+func TestDirectoriesOpts(t *testing.T) {
+	for _, bad := range []OptDirectories{{}, {State: "/var/lib/foo"}, {Cache: "../foo"}, {Logs: "foo//bar"}} {
+		if bad.Apply(&Unit{}) == nil {
+			t.Errorf("%+v should not be valid", bad)
+		}
+	}
+
+	u := Unit{}
+	_ = OptDynamicUser{}.Apply(&u)
+	if u.validate() == nil {
+		t.Error("dynamic user should not be valid in user scope")
+	}
+}
+
+func TestDirectories(t *testing.T) {
+	t.Setenv("STATE_DIRECTORY", "/var/lib/one:/var/lib/two")
+	t.Setenv("CACHE_DIRECTORY", "")
+
+	dirs := Directories()
+	if len(dirs.State) != 2 || dirs.State[1] != "/var/lib/two" {
+		t.Errorf("wrong state directories %v", dirs.State)
+	}
+	if dirs.Cache != nil {
+		t.Errorf("unexpected cache directories %v", dirs.Cache)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	systemctl, log := fakeSystemctl(t, "")
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "env")
+	output := filepath.Join(dir, "logs", "out.log")
+	os.WriteFile(envFile, []byte("TOKEN=secret\n"), 0600)
+	os.MkdirAll(filepath.Dir(output), 0700)
+	os.WriteFile(output, []byte("hello\n"), 0600)
+
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+	for _, opt := range []UnitOpts{OptDirectories{State: "test_unit"}, OptOutput{Stdout: "append:" + output}} {
+		if err := opt.Apply(&u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u.addDirective(SectionService, "EnvironmentFile", "-"+envFile)
+	u.addDirective(SectionService, "EnvironmentFile", "%h/.config/test_unit.env")
+	os.WriteFile(u.UnitFilename(), nil, 0600)
+
+	if err := u.Purge(); err != nil {
+		t.Fatalf("purge failed: %s", err)
+	}
+	for _, file := range []string{u.UnitFilename(), envFile, output} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", file)
+		}
+	}
+	commands, _ := os.ReadFile(log)
+	if !strings.Contains(string(commands), "--user clean --what=state --what=cache --what=logs --what=runtime test_unit\n") {
+		t.Errorf("directories were not cleaned:\n%s", commands)
+	}
+}
@@ -0,0 +1,91 @@
+package unitard
+
+import "strings"
+
+// DriftReport is what Verify found different from a fresh Deploy.
+type DriftReport struct {
+	Drifted    bool     // true if any of the below are set
+	Files      []string // unit files which are missing, or were changed since Deploy
+	Diff       string   // unified diff of Files against what Deploy would write
+	NotEnabled []string // units which should be enabled, but aren't
+	NotActive  []string // units which should be running, but aren't
+}
+
+func (r DriftReport) String() string {
+	if !r.Drifted {
+		return "no drift"
+	}
+	problems := []string{}
+	if len(r.Files) > 0 {
+		problems = append(problems, "changed files: "+strings.Join(r.Files, ", "))
+	}
+	if len(r.NotEnabled) > 0 {
+		problems = append(problems, "not enabled: "+strings.Join(r.NotEnabled, ", "))
+	}
+	if len(r.NotActive) > 0 {
+		problems = append(problems, "not active: "+strings.Join(r.NotActive, ", "))
+	}
+	return strings.Join(problems, "; ")
+}
+
+// Verify checks that the deployed unit is still as Deploy left it: that the
+// unit files match what Deploy would write now, and that the units are
+// enabled and running, as the OptDeployMode says they should be. It changes
+// nothing, so it can back a health check which reports tampering with the
+// service's own definition. An error means the state couldn't be checked,
+// not that it has drifted.
+func (u Unit) Verify() (DriftReport, error) {
+	report := DriftReport{}
+	files, diff, err := u.diffFiles()
+	if err != nil {
+		return report, err
+	}
+	if len(files) > 0 {
+		report.Files = files
+		report.Diff = diff
+	}
+
+	if !u.isTemplate() && u.root == "" {
+		err = u.verifyState(&report)
+		if err != nil {
+			return report, err
+		}
+	}
+	report.Drifted = len(report.Files) > 0 || len(report.NotEnabled) > 0 || len(report.NotActive) > 0
+	return report, nil
+}
+
+// verifyState adds the units which aren't enabled or running, but should
+// be, to the report.
+func (u Unit) verifyState(report *DriftReport) error {
+	checkEnabled := u.deployMode != DeployStartOnly && u.quadlet == nil
+	checkActive := u.deployMode != DeployEnableOnly
+	if len(u.triggers) == 0 && u.serviceDirective("Type") == "oneshot" && u.serviceDirective("RemainAfterExit") != "yes" {
+		// a job is only running while it runs
+		checkActive = false
+	}
+
+	if u.backend != nil {
+		status, err := u.Status()
+		if err != nil {
+			return err
+		}
+		if checkActive && status.ActiveState != "active" {
+			report.NotActive = append(report.NotActive, u.serviceName())
+		}
+		return nil
+	}
+	for _, unit := range u.activeUnits() {
+		props, err := u.show(unit, "ActiveState", "UnitFileState")
+		if err != nil {
+			return err
+		}
+		if checkEnabled && !strings.HasPrefix(props["UnitFileState"], "enabled") {
+			report.NotEnabled = append(report.NotEnabled, unit)
+		}
+		if checkActive && props["ActiveState"] != "active" {
+			report.NotActive = append(report.NotActive, unit)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyDrift(t *testing.T) {
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state")
+	systemctl := filepath.Join(dir, "systemctl")
+	os.WriteFile(systemctl, []byte("#!/bin/sh\n[ \"$2\" = show ] && cat "+state+"\nexit 0\n"), 0700)
+	os.WriteFile(state, []byte("ActiveState=active\nUnitFileState=enabled\n"), 0600)
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := u.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Drifted {
+		t.Errorf("freshly deployed unit should not have drifted: %s", report)
+	}
+
+	content, _ := os.ReadFile(u.UnitFilename())
+	os.WriteFile(u.UnitFilename(), []byte(strings.Replace(string(content), "/fullpath/to/foobar", "/tmp/evil", 1)), 0644)
+	os.WriteFile(state, []byte("ActiveState=failed\nUnitFileState=disabled\n"), 0600)
+	report, err = u.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Drifted || len(report.Files) != 1 || report.Files[0] != u.UnitFilename() || !strings.Contains(report.Diff, "-ExecStart=/tmp/evil") {
+		t.Errorf("edited unit file not reported: %+v", report)
+	}
+	if len(report.NotEnabled) != 1 || len(report.NotActive) != 1 || report.NotActive[0] != "test_unit" {
+		t.Errorf("unit state not reported: %+v", report)
+	}
+	if s := report.String(); !strings.Contains(s, "not enabled: test_unit") {
+		t.Errorf("wrong report %q", s)
+	}
+
+	// a unit deployed to be enabled only isn't expected to run
+	u.deployMode = DeployEnableOnly
+	os.WriteFile(state, []byte("ActiveState=inactive\nUnitFileState=enabled\n"), 0600)
+	os.WriteFile(u.UnitFilename(), content, 0644)
+	if report, _ := u.Verify(); report.Drifted {
+		t.Errorf("enable only unit should not have drifted: %s", report)
+	}
+}
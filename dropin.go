@@ -0,0 +1,96 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// defaultDropIn is the drop-in file name used if OptDropIn has no Name.
+const defaultDropIn = "unitard"
+
+var dropInNameRegexp = regexp.MustCompile("^[a-zA-Z0-9_-]+$")
+
+// OptDropIn deploys only a drop-in override (name.service.d/Name.conf)
+// for an existing service, instead of the whole service unit file. This lets
+// unitard add settings to a unit installed by a package or an admin.
+//
+// The drop-in contains the directives added by the other options. Set
+// ExecStart to also replace the command the service runs with this binary.
+// Undeploy removes only the drop-in.
+type OptDropIn struct {
+	Name      string // drop-in file name, without .conf, defaults to "unitard"
+	ExecStart bool   // replace ExecStart and WorkingDirectory of the service
+}
+
+func (o OptDropIn) Apply(u *Unit) error {
+	name := o.Name
+	if name == "" {
+		name = defaultDropIn
+	}
+	if !dropInNameRegexp.MatchString(name) {
+		return fmt.Errorf("sorry, drop-in name '%s' is not valid", name)
+	}
+	if u.dropIn != "" {
+		return errors.New("drop-in was already set - use OptDropIn only once")
+	}
+	u.dropIn = name
+	u.dropInExecStart = o.ExecStart
+	return nil
+}
+
+// DropInFilename returns the full path to the drop-in file for units with
+// OptDropIn.
+func (u Unit) DropInFilename() string {
+	return u.UnitFilename() + ".d/" + u.dropIn + ".conf"
+}
+
+// dropInDirectives returns the directives for the drop-in.
+func (u Unit) dropInDirectives() []Directive {
+	if !u.dropInExecStart {
+		return u.directives
+	}
+	directives := []Directive{
+		{Section: SectionService, Key: "WorkingDirectory", Value: u.binaryPath},
+		// an empty ExecStart clears the one in the service file
+		{Section: SectionService, Key: "ExecStart", Value: ""},
+		{Section: SectionService, Key: "ExecStart", Value: strings.TrimSpace(u.binary + " " + u.binaryArgs)},
+	}
+	return append(directives, u.directives...)
+}
+
+func (u Unit) writeDropInTemplate(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	data := u.templateData()
+	d := Unit{directives: u.dropInDirectives()}
+	data.Unit = append(u.markerDirectives(), d.sectionDirectives(SectionUnit)...)
+	data.Service = d.sectionDirectives(SectionService)
+	data.Install = d.sectionDirectives(SectionInstall)
+	return t.ExecuteTemplate(f, "dropin.conf", data)
+}
+
+// undeployDropIn removes the drop-in, and restarts the service if it is
+// running so it goes back to its own configuration.
+func (u Unit) undeployDropIn() error {
+	err := u.removeFile(u.DropInFilename())
+	if err != nil {
+		return err
+	}
+	err = u.systemctl("daemon-reload")
+	if err != nil {
+		return err
+	}
+	return u.systemctl("try-restart", u.serviceName())
+}
+
+// dropInDir returns the directory the drop-in is written to.
+func (u Unit) dropInDir() string {
+	return path.Dir(u.DropInFilename())
+}
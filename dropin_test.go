@@ -0,0 +1,58 @@
+package unitard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDropIn(t *testing.T) {
+	u, err := NewUnit("test_unit", OptDryRun{}, OptDropIn{ExecStart: true}, OptDirective{Section: SectionService, Key: "Nice", Value: "5"})
+	if err != nil {
+		t.Fatalf("could not create unit: %s", err)
+	}
+	if !strings.HasSuffix(u.DropInFilename(), "/test_unit.service.d/unitard.conf") {
+		t.Errorf("wrong drop-in filename %s", u.DropInFilename())
+	}
+
+	plan, err := u.DryRun()
+	if err != nil {
+		t.Fatalf("dry run failed: %s", err)
+	}
+	files := plan.Files()
+	if len(files) != 1 {
+		t.Fatalf("expected only the drop-in to be written, got %v", files)
+	}
+	content := files[u.DropInFilename()]
+	for _, want := range []string{"[Service]\n", "\nExecStart=\nExecStart=" + u.binary + "\n", "\nNice=5"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("drop-in does not contain %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "[Install]") {
+		t.Errorf("drop-in should not contain empty sections:\n%s", content)
+	}
+	if plan.Actions[0].Kind != ActionMkdir {
+		t.Errorf("expected the drop-in directory to be created first, got %s", plan.Actions[0])
+	}
+
+	plan, err = u.DryRunUndeploy()
+	if err != nil {
+		t.Fatalf("dry run undeploy failed: %s", err)
+	}
+	if plan.Actions[0].Kind != ActionRemove || plan.Actions[0].Path != u.DropInFilename() {
+		t.Errorf("expected only the drop-in to be removed, got %v", plan.Actions)
+	}
+	commands := strings.Join(plan.Commands(), "\n")
+	if strings.Contains(commands, "disable") || !strings.Contains(commands, "try-restart test_unit") {
+		t.Errorf("wrong undeploy commands:\n%s", commands)
+	}
+}
+
+func TestDropInOptions(t *testing.T) {
+	if _, err := NewUnit("test_unit", OptDryRun{}, OptDropIn{Name: "../bad"}); err == nil {
+		t.Error("should not accept a bad drop-in name")
+	}
+	if _, err := NewUnit("test_unit", OptDryRun{}, OptDropIn{}, OptTimer{OnCalendar: "daily"}); err == nil {
+		t.Error("should not accept a drop-in with a timer")
+	}
+}
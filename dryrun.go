@@ -0,0 +1,126 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+var errDryRun = errors.New("unit was created with OptDryRun - use DryRun or DryRunUndeploy")
+
+// ActionKind is the type of change an Action makes.
+type ActionKind string
+
+const (
+	ActionWrite  ActionKind = "write"  // create or overwrite a file
+	ActionRemove ActionKind = "remove" // remove a file
+	ActionMkdir  ActionKind = "mkdir"  // create a directory
+	ActionRun    ActionKind = "run"    // run a command
+)
+
+// Action is a single change Deploy or Undeploy makes to the system.
+type Action struct {
+	Kind    ActionKind
+	Path    string   // the file or directory, unless Kind is ActionRun
+	Content string   // the file contents, if Kind is ActionWrite
+	Command []string // the command and its arguments, if Kind is ActionRun
+}
+
+func (a Action) String() string {
+	if a.Kind == ActionRun {
+		return fmt.Sprintf("%s %s", a.Kind, strings.Join(a.Command, " "))
+	}
+	return fmt.Sprintf("%s %s", a.Kind, a.Path)
+}
+
+// Plan is the list of actions Deploy or Undeploy would take, in order.
+type Plan struct {
+	Actions []Action
+}
+
+func (p *Plan) add(a Action) {
+	p.Actions = append(p.Actions, a)
+}
+
+// Files returns the contents of the files which would be written, by path.
+func (p Plan) Files() map[string]string {
+	files := map[string]string{}
+	for _, a := range p.Actions {
+		if a.Kind == ActionWrite {
+			files[a.Path] = a.Content
+		}
+	}
+	return files
+}
+
+// Commands returns the commands which would be run, in order.
+func (p Plan) Commands() []string {
+	commands := []string{}
+	for _, a := range p.Actions {
+		if a.Kind == ActionRun {
+			commands = append(commands, strings.Join(a.Command, " "))
+		}
+	}
+	return commands
+}
+
+// OptDryRun creates a unit which never touches the system - NewUnit does not
+// check for systemctl or create the unit directory, and Deploy and Undeploy
+// return an error. Use DryRun and DryRunUndeploy to see what they would do.
+// This is handy for testing your choice of options.
+type OptDryRun struct{}
+
+func (o OptDryRun) Apply(u *Unit) error {
+	u.dryRun = true
+	return nil
+}
+
+// setupDryRun fills in the environment without checking or changing
+// anything.
+func (u *Unit) setupDryRun() error {
+	u.systemCtlPath = "systemctl"
+	if path, err := exec.LookPath("systemctl"); err == nil {
+		u.systemCtlPath = path
+	}
+	if u.scope == ScopeSystem {
+		u.unitFilePath = u.unitDirectoryOr(systemUnitDirectory)
+		return nil
+	}
+	dir, err := userUnitDirectory()
+	if err != nil {
+		return err
+	}
+	u.unitFilePath = u.unitDirectoryOr(dir)
+	return nil
+}
+
+// Render returns the contents of the unit files Deploy would write, by path.
+func (u Unit) Render() (map[string]string, error) {
+	plan := &Plan{}
+	u.plan = plan
+	for _, f := range u.unitFiles() {
+		err := u.createFile(f.name, f.write)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return plan.Files(), nil
+}
+
+// DryRun returns the actions Deploy would take, without taking them.
+func (u Unit) DryRun() (Plan, error) {
+	plan := &Plan{}
+	u.plan = plan
+	err := u.Deploy()
+	return *plan, err
+}
+
+// DryRunUndeploy returns the actions Undeploy would take, without taking
+// them.
+func (u Unit) DryRunUndeploy() (Plan, error) {
+	plan := &Plan{}
+	u.plan = plan
+	err := u.Undeploy()
+	return *plan, err
+}
@@ -0,0 +1,95 @@
+package unitard
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	u, err := NewUnit("test_unit", OptDryRun{}, OptTimer{OnCalendar: "daily"})
+	if err != nil {
+		t.Fatalf("could not create unit: %s", err)
+	}
+	if u.Deploy() != errDryRun {
+		t.Error("Deploy on a dry run unit should fail")
+	}
+
+	plan, err := u.DryRun()
+	if err != nil {
+		t.Fatalf("dry run failed: %s", err)
+	}
+	for _, a := range plan.Actions {
+		t.Log(a)
+	}
+
+	files := plan.Files()
+	if !strings.Contains(files[u.UnitFilename()], "ExecStart=") {
+		t.Error("plan does not contain the service file")
+	}
+	if !strings.Contains(files[u.unitFilename("timer")], "OnCalendar=daily") {
+		t.Error("plan does not contain the timer file")
+	}
+
+	commands := strings.Join(plan.Commands(), "\n")
+	for _, want := range []string{"--user daemon-reload", "--user enable test_unit.timer", "--user restart test_unit.timer"} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("commands do not contain %q:\n%s", want, commands)
+		}
+	}
+
+	plan, err = u.DryRunUndeploy()
+	if err != nil {
+		t.Fatalf("dry run undeploy failed: %s", err)
+	}
+	removed := 0
+	for _, a := range plan.Actions {
+		if a.Kind == ActionRemove {
+			removed++
+		}
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 files removed, got %d", removed)
+	}
+}
+
+func TestRender(t *testing.T) {
+	u, err := NewUnit("test_unit", OptDryRun{})
+	if err != nil {
+		t.Fatalf("could not create unit: %s", err)
+	}
+	files, err := u.Render()
+	if err != nil {
+		t.Fatalf("could not render: %s", err)
+	}
+	if len(files) != 1 || !strings.Contains(files[u.UnitFilename()], "Description=test_unit") {
+		t.Errorf("wrong rendered files %v", files)
+	}
+}
+
+func TestDeployUnchanged(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", systemCtlPath: "systemctl", unitFilePath: t.TempDir()}
+	files, _ := u.Render()
+	for name, content := range files {
+		os.WriteFile(name, []byte(content), 0600)
+	}
+
+	plan, err := u.DryRun()
+	if err != nil {
+		t.Fatalf("dry run failed: %s", err)
+	}
+	if len(plan.Files()) != 0 {
+		t.Error("unchanged unit files should not be rewritten")
+	}
+	commands := strings.Join(plan.Commands(), "\n")
+	if commands != "systemctl --user enable test_unit\nsystemctl --user start test_unit" {
+		t.Errorf("unchanged unit should only be enabled and started, got:\n%s", commands)
+	}
+
+	u.alwaysRestart = true
+	plan, _ = u.DryRun()
+	commands = strings.Join(plan.Commands(), "\n")
+	if !strings.Contains(commands, "daemon-reload") || !strings.Contains(commands, "restart test_unit") {
+		t.Errorf("OptAlwaysRestart should reload and restart, got:\n%s", commands)
+	}
+}
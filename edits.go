@@ -0,0 +1,169 @@
+package unitard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checksumPrefix starts the comment line at the end of each unit file which
+// records the checksum of the rest of the file, so local edits can be
+// detected on the next Deploy.
+const checksumPrefix = "# unitard-checksum: sha256:"
+
+// LocalEditError is returned by Deploy when installed unit files have been
+// edited since unitard wrote them. Nothing has been changed on the system.
+// Use OptForce to overwrite them anyway.
+type LocalEditError struct {
+	Files []string // the edited unit files
+}
+
+func (e *LocalEditError) Error() string {
+	return "unit files were edited locally: " + strings.Join(e.Files, ", ")
+}
+
+// ForeignUnitError is returned by Deploy when a unit file it would write
+// already exists, but was not created by unitard. Nothing has been changed on
+// the system. Use OptForce to overwrite them anyway.
+type ForeignUnitError struct {
+	Files []string // the unit files unitard did not create
+}
+
+func (e *ForeignUnitError) Error() string {
+	return "unit files were not created by unitard: " + strings.Join(e.Files, ", ")
+}
+
+// OptForce makes Deploy overwrite unit files even if they were edited
+// since unitard installed them, or were not created by unitard at all.
+type OptForce struct{}
+
+func (o OptForce) Apply(u *Unit) error {
+	u.force = true
+	return nil
+}
+
+// withChecksum wraps write, adding a checksum line after the contents.
+func withChecksum(write func(io.Writer) error) func(io.Writer) error {
+	return func(f io.Writer) error {
+		buff := bytes.NewBuffer(nil)
+		err := write(buff)
+		if err != nil {
+			return err
+		}
+		content := buff.String()
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		_, err = io.WriteString(f, content+checksumPrefix+checksum(content)+"\n")
+		return err
+	}
+}
+
+// checksum returns the hex encoded sha256 of content.
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// locallyEdited returns true if content no longer matches its checksum
+// line. Files without one (from older versions of unitard) are assumed to
+// be unedited.
+func locallyEdited(content string) bool {
+	i := strings.LastIndex(content, checksumPrefix)
+	if i < 0 || (i > 0 && content[i-1] != '\n') {
+		return false
+	}
+	return strings.TrimSpace(content[i+len(checksumPrefix):]) != checksum(content[:i])
+}
+
+// createdByUnitard returns true if content is a unit file written by
+// unitard, this or an older version.
+func createdByUnitard(content string) bool {
+	return strings.Contains(content, "\n"+checksumPrefix) ||
+		strings.Contains(content, markerManaged+"=yes") ||
+		strings.Contains(content, "automatically created with github.com/tardisx/unitard")
+}
+
+// LocalEdits returns the installed unit files which have been edited
+// since unitard wrote them.
+func (u Unit) LocalEdits() ([]string, error) {
+	edited := []string{}
+	for _, f := range u.unitFiles() {
+		current, err := u.readFile(f.name)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read unit file '%s': %w", f.name, err)
+		}
+		if locallyEdited(string(current)) {
+			edited = append(edited, f.name)
+		}
+	}
+	return edited, nil
+}
+
+// Overrides returns the drop-in files (name.service.d/*.conf and so on)
+// for the units, which change their configuration without being part of
+// them. Deploy and Undeploy never touch these, so local tweaks made with
+// `systemctl edit` survive a redeploy. For units with OptDropIn, the
+// drop-in unitard writes is not included.
+func (u Unit) Overrides() ([]string, error) {
+	overrides := []string{}
+	names := []string{u.UnitFilename()}
+	if u.dropIn == "" {
+		for _, t := range u.triggers {
+			names = append(names, u.unitFilename(t.unitType()))
+		}
+	}
+	for _, name := range names {
+		matches, err := filepath.Glob(name + ".d/*.conf")
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if u.dropIn != "" && m == u.DropInFilename() {
+				continue
+			}
+			overrides = append(overrides, m)
+		}
+	}
+	return overrides, nil
+}
+
+// checkOverwrite returns a *ForeignUnitError or *LocalEditError if Deploy
+// would overwrite files not created by unitard, or edited since, unless
+// OptForce was given.
+func (u Unit) checkOverwrite() error {
+	if u.force {
+		return nil
+	}
+	foreign := []string{}
+	for _, f := range u.unitFiles() {
+		current, err := u.readFile(f.name)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("could not read unit file '%s': %w", f.name, err)
+		}
+		if !createdByUnitard(string(current)) && !u.isImported(f.name, current) {
+			foreign = append(foreign, f.name)
+		}
+	}
+	if len(foreign) > 0 {
+		return &ForeignUnitError{Files: foreign}
+	}
+
+	edited, err := u.LocalEdits()
+	if err != nil {
+		return err
+	}
+	if len(edited) > 0 {
+		return &LocalEditError{Files: edited}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalEdits(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", systemCtlPath: "systemctl", unitFilePath: t.TempDir()}
+	files, _ := u.Render()
+	for name, content := range files {
+		if locallyEdited(content) {
+			t.Errorf("freshly rendered %s looks edited", name)
+		}
+		os.WriteFile(name, []byte(content), 0600)
+	}
+
+	edited, err := u.LocalEdits()
+	if err != nil || len(edited) != 0 {
+		t.Fatalf("expected no local edits, got %v %v", edited, err)
+	}
+
+	// a change to the options is not a local edit
+	u.binaryArgs = "-new"
+	if _, err := u.DryRun(); err != nil {
+		t.Errorf("dry run failed: %s", err)
+	}
+
+	// but a change to the file is
+	content := files[u.UnitFilename()]
+	os.WriteFile(u.UnitFilename(), []byte("# my tweak\n"+content), 0600)
+	_, err = u.DryRun()
+	var editErr *LocalEditError
+	if !errors.As(err, &editErr) || len(editErr.Files) != 1 {
+		t.Errorf("expected a LocalEditError, got %v", err)
+	}
+	u.force = true
+	if _, err := u.DryRun(); err != nil {
+		t.Errorf("dry run with force failed: %s", err)
+	}
+
+	// files from older versions have no checksum
+	if locallyEdited("[Unit]\nDescription=old\n") {
+		t.Error("file without checksum should not look edited")
+	}
+}
+
+func TestForeignUnit(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", systemCtlPath: "systemctl", unitFilePath: t.TempDir()}
+	os.WriteFile(u.UnitFilename(), []byte("[Unit]\nDescription=someone else's\n"), 0600)
+	_, err := u.DryRun()
+	var foreignErr *ForeignUnitError
+	if !errors.As(err, &foreignErr) || len(foreignErr.Files) != 1 {
+		t.Errorf("expected a ForeignUnitError, got %v", err)
+	}
+
+	// files created by older versions have the header comment
+	os.WriteFile(u.UnitFilename(), []byte("# service file automatically created with github.com/tardisx/unitard\n\n[Unit]\nDescription=old\n"), 0600)
+	if _, err := u.DryRun(); err != nil {
+		t.Errorf("dry run over an old unit failed: %s", err)
+	}
+
+	u.force = true
+	os.WriteFile(u.UnitFilename(), []byte("[Unit]\n"), 0600)
+	if _, err := u.DryRun(); err != nil {
+		t.Errorf("dry run with force failed: %s", err)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	u := Unit{name: "test_unit", unitFilePath: t.TempDir()}
+	dir := u.UnitFilename() + ".d"
+	os.Mkdir(dir, 0700)
+	os.WriteFile(filepath.Join(dir, "override.conf"), []byte("[Service]\nNice=5\n"), 0600)
+
+	overrides, err := u.Overrides()
+	if err != nil || len(overrides) != 1 {
+		t.Errorf("expected one override, got %v %v", overrides, err)
+	}
+
+	u.dropIn = "override"
+	overrides, _ = u.Overrides()
+	if len(overrides) != 0 {
+		t.Errorf("own drop-in should not be an override, got %v", overrides)
+	}
+}
@@ -0,0 +1,70 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrSystemctlNotFound is returned by NewUnit if systemctl is not
+	// installed.
+	ErrSystemctlNotFound = errors.New("could not find systemctl")
+	// ErrRootNotAllowed is returned by NewUnit for a user scope unit when
+	// running as root. Use ScopeSystem instead.
+	ErrRootNotAllowed = errors.New("cannot run as root")
+	// ErrNoUserBus means the user's systemd instance can't be reached,
+	// usually because there is no login session (for instance under cron
+	// or su). A *UserBusError from NewUnit matches it, as does a
+	// *SystemctlError when systemctl fails for this reason.
+	ErrNoUserBus = errors.New("could not connect to the user systemd instance")
+	// ErrUnitNotManaged means a unit file exists but was not created by
+	// unitard. A *ForeignUnitError matches it.
+	ErrUnitNotManaged = errors.New("unit was not created by unitard")
+	// ErrNotSupported is returned when the unit's Backend cannot do what
+	// was asked, such as enabling a cron job.
+	ErrNotSupported = errors.New("not supported by this backend")
+	// ErrLingerDisabled means lingering is not enabled for the user, so
+	// their services stop when they log out. A *LingerError matches it.
+	ErrLingerDisabled = errors.New("lingering is not enabled")
+	// ErrTemporaryBinary means the program is a temporary build, such as
+	// one made by go run, which the unit would stop working without. A
+	// *TemporaryBinaryError matches it.
+	ErrTemporaryBinary = errors.New("program is a temporary build")
+	// ErrSystemdTooOld means the unit needs a directive the installed
+	// systemd doesn't support. A *SystemdVersionError matches it.
+	ErrSystemdTooOld = errors.New("systemd is too old")
+	// ErrDeployInProgress means another deploy of the unit holds its lock.
+	// A *DeployInProgressError matches it.
+	ErrDeployInProgress = errors.New("deploy in progress")
+)
+
+// SystemctlError is returned when a command run by unitard - usually
+// systemctl, perhaps through sudo - fails.
+type SystemctlError struct {
+	Args     []string // the command and its arguments
+	ExitCode int      // -1 if it did not exit normally
+	Stderr   string   // what it wrote to standard error
+	Err      error    // the error from running it
+}
+
+func (e *SystemctlError) Error() string {
+	msg := fmt.Sprintf("problem running '%s': %s", strings.Join(e.Args, " "), e.Err)
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+func (e *SystemctlError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches ErrNoUserBus if systemctl could not connect to the bus.
+func (e *SystemctlError) Is(target error) bool {
+	return target == ErrNoUserBus && strings.Contains(e.Stderr, "Failed to connect to bus")
+}
+
+func (e *ForeignUnitError) Is(target error) bool {
+	return target == ErrUnitNotManaged
+}
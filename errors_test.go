@@ -0,0 +1,33 @@
+package unitard
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSystemctlError(t *testing.T) {
+	u := Unit{}
+	_, err := u.runOutput("sh", "-c", "echo 'Failed to connect to bus: No medium found' >&2; exit 1")
+	var sysErr *SystemctlError
+	if !errors.As(err, &sysErr) {
+		t.Fatalf("expected a SystemctlError, got %v", err)
+	}
+	if sysErr.ExitCode != 1 || sysErr.Stderr != "Failed to connect to bus: No medium found" {
+		t.Errorf("wrong error details %+v", sysErr)
+	}
+	if !errors.Is(err, ErrNoUserBus) {
+		t.Error("bus failure should match ErrNoUserBus")
+	}
+
+	_, err = u.runOutput("sh", "-c", "exit 3")
+	if !errors.As(err, &sysErr) || sysErr.ExitCode != 3 || errors.Is(err, ErrNoUserBus) {
+		t.Errorf("wrong error for plain failure: %v", err)
+	}
+}
+
+func TestForeignUnitIsNotManaged(t *testing.T) {
+	var err error = &ForeignUnitError{Files: []string{"x.service"}}
+	if !errors.Is(err, ErrUnitNotManaged) {
+		t.Error("ForeignUnitError should match ErrUnitNotManaged")
+	}
+}
@@ -0,0 +1,127 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Escalation selects how root privileges are obtained when deploying a
+// system scope unit from a non-root process.
+type Escalation int
+
+const (
+	// EscalateNone does not escalate - the program must already be root.
+	// This is the default.
+	EscalateNone Escalation = iota
+	// EscalateAuto uses the first available method, as found by DetectEscalation.
+	EscalateAuto
+	// EscalateSudo runs privileged commands with sudo.
+	EscalateSudo
+	// EscalatePkexec runs privileged commands with pkexec (polkit).
+	EscalatePkexec
+	// EscalateSystemdRun runs privileged commands with systemd-run --uid=0.
+	EscalateSystemdRun
+)
+
+// escalationOrder is the order in which methods are tried by DetectEscalation.
+var escalationOrder = []Escalation{EscalateSudo, EscalatePkexec, EscalateSystemdRun}
+
+func (e Escalation) String() string {
+	switch e {
+	case EscalateNone:
+		return "none"
+	case EscalateAuto:
+		return "auto"
+	case EscalateSudo:
+		return "sudo"
+	case EscalatePkexec:
+		return "pkexec"
+	case EscalateSystemdRun:
+		return "systemd-run"
+	}
+	return fmt.Sprintf("Escalation(%d)", int(e))
+}
+
+// binary returns the name of the program used for this method.
+func (e Escalation) binary() string {
+	return e.String()
+}
+
+// command returns the command prefix used to run a privileged command with
+// this method, after checking it is available.
+func (e Escalation) command() ([]string, error) {
+	if e == EscalateNone {
+		return nil, errors.New("system scope needs to run as root (or use OptEscalate)")
+	}
+	if e == EscalateAuto {
+		detected, err := DetectEscalation()
+		if err != nil {
+			return nil, err
+		}
+		e = detected
+	}
+	path, err := exec.LookPath(e.binary())
+	if err != nil {
+		return nil, fmt.Errorf("could not find %s: %w", e.binary(), err)
+	}
+	if e == EscalateSystemdRun {
+		return []string{path, "--uid=0", "--pipe", "--wait", "--quiet", "--collect", "--"}, nil
+	}
+	return []string{path}, nil
+}
+
+// DetectEscalation returns the first available method of gaining root
+// privileges, trying sudo, pkexec and systemd-run in that order.
+func DetectEscalation() (Escalation, error) {
+	for _, e := range escalationOrder {
+		if _, err := exec.LookPath(e.binary()); err == nil {
+			return e, nil
+		}
+	}
+	return EscalateNone, errors.New("could not find sudo, pkexec or systemd-run to escalate privileges")
+}
+
+// OptEscalate allows a system scope unit to be deployed by a non-root
+// process, by running the privileged commands (systemctl and writing the
+// unit file) with sudo, pkexec or systemd-run. The user may be prompted
+// for their password, possibly more than once.
+// It has no effect if the program is already running as root.
+type OptEscalate struct {
+	Method Escalation
+}
+
+func (o OptEscalate) Apply(u *Unit) error {
+	if o.Method < EscalateNone || o.Method > EscalateSystemdRun {
+		return errors.New("unknown escalation method")
+	}
+	u.escalation = o.Method
+	return nil
+}
+
+// runEscalated runs a command with root privileges.
+func (u Unit) runEscalated(command string, args ...string) error {
+	args = append(append(append([]string{}, u.escalate[1:]...), command), args...)
+	return u.runExpectZero(u.escalate[0], args...)
+}
+
+// writeFileEscalated writes a root-owned file, by writing it to a temporary
+// file and installing it with root privileges and mode.
+func (u Unit) writeFileEscalated(fileName string, content []byte, mode string) error {
+	tmp, err := os.CreateTemp("", "unitard-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	return u.runEscalated("install", "-m", mode, tmp.Name(), fileName)
+}
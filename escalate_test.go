@@ -0,0 +1,45 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEscalationCommand(t *testing.T) {
+	if _, err := EscalateNone.command(); err == nil {
+		t.Error("no escalation should not provide a command")
+	}
+	if EscalateSystemdRun.String() != "systemd-run" {
+		t.Errorf("unexpected name %s", EscalateSystemdRun)
+	}
+	if (OptEscalate{Method: Escalation(99)}).Apply(&Unit{}) == nil {
+		t.Error("unknown method should not be valid")
+	}
+}
+
+func TestWriteFileEscalated(t *testing.T) {
+	// "env" runs the command unchanged, standing in for sudo
+	u := Unit{escalate: []string{"env"}}
+	fileName := filepath.Join(t.TempDir(), "test_unit.service")
+
+	err := u.createFile(fileName, u.writeTemplate)
+	if err != nil {
+		t.Fatalf("could not write file: %s", err)
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatalf("file was not written: %s", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("file has mode %s", info.Mode().Perm())
+	}
+
+	err = u.removeFile(fileName)
+	if err != nil {
+		t.Errorf("could not remove file: %s", err)
+	}
+	if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+		t.Error("file was not removed")
+	}
+}
@@ -0,0 +1,88 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// OptOnFailure runs a command when the service fails, such as after
+// crashing or being killed, without any external monitoring. A companion
+// unit, name_failure@.service, is deployed with the service and started by
+// systemd's OnFailure=; the name of the failed unit (such as
+// myapp.service) is available to the command as %i.
+//
+// Give either Command, which must start with an absolute path, or Webhook
+// to have curl POST the unit name to a URL.
+type OptOnFailure struct {
+	Command string // the command to run, such as "/usr/local/bin/alert %i"
+	Webhook string // a URL to POST unit=<name> to
+}
+
+func (o OptOnFailure) Apply(u *Unit) error {
+	if (o.Command == "") == (o.Webhook == "") {
+		return errors.New("OptOnFailure needs exactly one of Command or Webhook")
+	}
+	if u.failureHandler != "" {
+		return errors.New("OptOnFailure was already set - use it only once")
+	}
+	command := strings.TrimSpace(o.Command)
+	if o.Webhook != "" {
+		hook, err := url.Parse(o.Webhook)
+		if err != nil || (hook.Scheme != "http" && hook.Scheme != "https") || hook.Host == "" {
+			return fmt.Errorf("sorry, webhook '%s' is not a http or https URL", o.Webhook)
+		}
+		// % is a specifier in unit files
+		command = "/usr/bin/env curl -fsS -m 30 --data-urlencode unit=%i " + strconv.Quote(strings.ReplaceAll(o.Webhook, "%", "%%"))
+	} else if program, _, _ := strings.Cut(command, " "); !filepath.IsAbs(program) {
+		return fmt.Errorf("sorry, command '%s' must start with an absolute path", o.Command)
+	}
+	if strings.ContainsAny(command, "\n") {
+		return errors.New("sorry, the failure command must be on one line")
+	}
+	u.failureHandler = command
+	return u.addDirective(SectionUnit, "OnFailure", u.failureUnit("%n"))
+}
+
+// failureUnit returns the name of the failure handler's instance for the
+// failed unit.
+func (u Unit) failureUnit(failed string) string {
+	return u.name + "_failure@" + failed + ".service"
+}
+
+// FailureFilename returns the full path to the unit file of the failure
+// handler deployed with OptOnFailure.
+func (u Unit) FailureFilename() string {
+	return filepath.Join(u.unitFilePath, u.name+"_failure@.service")
+}
+
+func (u Unit) writeFailureTemplate(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	return t.ExecuteTemplate(f, "failure.service", map[string]interface{}{
+		"Description": "failure handler for " + u.name + " (%i)",
+		"Unit":        append(u.markerDirectives(), Directive{Section: SectionUnit, Key: markerHandlerFor, Value: u.name}),
+		"ExecStart":   u.failureHandler,
+	})
+}
+
+// removeFailureHandler removes the failure handler's unit file, if there
+// is one.
+func (u Unit) removeFailureHandler() error {
+	if u.failureHandler == "" {
+		return nil
+	}
+	err := u.removeFile(u.FailureFilename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package unitard
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestOnFailure(t *testing.T) {
+	systemctl, _ := fakeSystemctl(t, "")
+	dir := t.TempDir()
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: dir}
+	if err := (OptOnFailure{Command: "/usr/local/bin/alert --unit %i"}).Apply(&u); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	service, _ := os.ReadFile(u.UnitFilename())
+	if !strings.Contains(string(service), "\nOnFailure=test_unit_failure@%n.service\n") {
+		t.Errorf("service should have OnFailure:\n%s", service)
+	}
+	handler, err := os.ReadFile(u.FailureFilename())
+	if err != nil {
+		t.Fatalf("failure handler was not written: %s", err)
+	}
+	if !strings.Contains(string(handler), "\nType=oneshot\nExecStart=/usr/local/bin/alert --unit %i\n") {
+		t.Errorf("wrong failure handler:\n%s", handler)
+	}
+
+	units, err := listManagedUnits(ScopeUser, dir)
+	if err != nil || len(units) != 1 || units[0].Name != "test_unit" {
+		t.Errorf("the failure handler should not be listed as a unit: %+v %v", units, err)
+	}
+
+	if err := u.Undeploy(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(u.FailureFilename()); !os.IsNotExist(err) {
+		t.Error("failure handler was not removed")
+	}
+}
+
+func TestOnFailureOpts(t *testing.T) {
+	u := Unit{name: "test_unit"}
+	if err := (OptOnFailure{Webhook: "https://example.com/hook?x=100%25"}).Apply(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.failureHandler != `/usr/bin/env curl -fsS -m 30 --data-urlencode unit=%i "https://example.com/hook?x=100%%25"` {
+		t.Errorf("wrong webhook command %s", u.failureHandler)
+	}
+	for _, bad := range []OptOnFailure{{}, {Command: "alert"}, {Webhook: "ftp://example.com"}, {Command: "/bin/true", Webhook: "https://example.com"}} {
+		if err := bad.Apply(&Unit{name: "test_unit"}); err == nil {
+			t.Errorf("%+v should be refused", bad)
+		}
+	}
+	if err := (OptOnFailure{Command: "/bin/true"}).Apply(&u); err == nil {
+		t.Error("OptOnFailure should only be used once")
+	}
+}
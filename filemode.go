@@ -0,0 +1,127 @@
+package unitard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The default permissions of what Deploy writes, whatever the umask.
+const (
+	defaultFileMode   os.FileMode = 0644
+	defaultSecretMode os.FileMode = 0600
+	defaultDirMode    os.FileMode = 0755
+)
+
+// OptFileMode sets the permissions of the files and directories Deploy
+// writes. By default unit files, and the other configuration unitard
+// writes such as logrotate and sysusers.d files, are 0644, credentials are
+// 0600 and directories are created 0755.
+//
+// In system scope, Owner and Group set who owns the unit files and
+// credentials, such as a group of operators allowed to read them. They
+// are otherwise owned by whoever deployed them, usually root.
+type OptFileMode struct {
+	File   os.FileMode // unit and configuration files
+	Secret os.FileMode // credential files
+	Dir    os.FileMode // directories created
+	Owner  string      // the user owning the files, system scope only
+	Group  string      // the group owning the files, system scope only
+}
+
+func (o OptFileMode) Apply(u *Unit) error {
+	for _, mode := range []os.FileMode{o.File, o.Secret, o.Dir} {
+		if mode&^os.ModePerm != 0 {
+			return fmt.Errorf("sorry, file mode '%s' is not valid", mode)
+		}
+	}
+	for _, name := range []string{o.Owner, o.Group} {
+		if name != "" && !accountNameRegexp.MatchString(name) {
+			return fmt.Errorf("sorry, account name '%s' is not valid", name)
+		}
+	}
+	if o == (OptFileMode{}) {
+		return errors.New("OptFileMode needs a mode, Owner or Group")
+	}
+	u.fileModes = o
+	return nil
+}
+
+// checkFileModes returns an error if OptFileMode sets an owner where it
+// can't be used.
+func (u Unit) checkFileModes() error {
+	if u.fileModes.Owner == "" && u.fileModes.Group == "" {
+		return nil
+	}
+	if u.scope != ScopeSystem {
+		return errors.New("OptFileMode Owner and Group can only be used with system scope")
+	}
+	if u.root != "" {
+		return errors.New("OptFileMode Owner and Group cannot be combined with OptRoot, the package sets the owners")
+	}
+	return nil
+}
+
+// fileMode returns the mode of unit and configuration files.
+func (u Unit) fileMode() os.FileMode {
+	if u.fileModes.File != 0 {
+		return u.fileModes.File
+	}
+	return defaultFileMode
+}
+
+// secretMode returns the mode of credential files.
+func (u Unit) secretMode() os.FileMode {
+	if u.fileModes.Secret != 0 {
+		return u.fileModes.Secret
+	}
+	return defaultSecretMode
+}
+
+// dirMode returns the mode of directories created.
+func (u Unit) dirMode() os.FileMode {
+	if u.fileModes.Dir != 0 {
+		return u.fileModes.Dir
+	}
+	return defaultDirMode
+}
+
+// modeString returns mode as chmod and install take it.
+func modeString(mode os.FileMode) string {
+	return fmt.Sprintf("%04o", mode.Perm())
+}
+
+// writeFile writes a file with mode, wherever the unit is deployed, and
+// gives it the owner from OptFileMode.
+func (u Unit) writeFile(name string, content []byte, mode os.FileMode) error {
+	var err error
+	if u.remote != nil {
+		err = u.writeRemoteFile(name, bytes.NewReader(content), modeString(mode))
+	} else if u.escalate != nil {
+		err = u.writeFileEscalated(name, content, modeString(mode))
+	} else {
+		err = os.WriteFile(name, content, mode)
+		if err == nil {
+			// an existing file keeps its mode otherwise
+			err = os.Chmod(name, mode)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return u.chown(name)
+}
+
+// chown gives a file the owner and group from OptFileMode, if set.
+func (u Unit) chown(name string) error {
+	owner := u.fileModes.Owner
+	if u.fileModes.Group != "" {
+		owner += ":" + u.fileModes.Group
+	}
+	if owner == "" {
+		return nil
+	}
+	command := u.withEscalation("chown", "--", owner, name)
+	return u.runExpectZero(command[0], command[1:]...)
+}
@@ -0,0 +1,91 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMode(t *testing.T) {
+	systemctl, _ := fakeSystemctl(t, "")
+	config := t.TempDir()
+	unitDir := filepath.Join(config, "systemd", "user")
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: unitDir}
+
+	// an existing file is given the default mode
+	os.MkdirAll(unitDir, 0755)
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(u.UnitFilename(), 0666)
+	u.binaryArgs = "-v"
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(u.UnitFilename()); info.Mode().Perm() != 0644 {
+		t.Errorf("unit file has mode %s", info.Mode())
+	}
+
+	for _, o := range []UnitOpts{
+		OptFileMode{File: 0640, Secret: 0400, Dir: 0750},
+		OptCredential{Name: "api-key", Value: []byte("secret")},
+	} {
+		if err := o.Apply(&u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(config, "unitard", "credentials", "test_unit")
+	for name, want := range map[string]os.FileMode{u.UnitFilename(): 0640, filepath.Join(dir, "api-key"): 0400, dir: 0750} {
+		if info, err := os.Stat(name); err != nil {
+			t.Error(err)
+		} else if info.Mode().Perm() != want {
+			t.Errorf("%s should have mode %s, not %s", name, want, info.Mode())
+		}
+	}
+}
+
+func TestFileModeOwner(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, unitFilePath: t.TempDir(), scope: ScopeSystem}
+	(OptFileMode{Owner: "root", Group: "operators"}).Apply(&u)
+	(OptCredential{Name: "api-key", Value: []byte("secret")}).Apply(&u)
+	if err := u.validate(); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := u.DryRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chowns := []string{}
+	for _, a := range plan.Actions {
+		if a.Kind == ActionRun && a.Command[0] == "chown" {
+			chowns = append(chowns, strings.Join(a.Command, " "))
+		}
+	}
+	want := "chown -- root:operators /etc/unitard/credentials/test_unit/api-key\nchown -- root:operators " + u.UnitFilename()
+	if strings.Join(chowns, "\n") != want {
+		t.Errorf("wrong chowns:\n%s", strings.Join(chowns, "\n"))
+	}
+
+	for _, bad := range []struct {
+		u Unit
+		o OptFileMode
+	}{
+		{Unit{}, OptFileMode{}},
+		{Unit{}, OptFileMode{File: os.ModeSetuid | 0755}},
+		{Unit{}, OptFileMode{Owner: "bad name"}},
+		{Unit{scope: ScopeUser}, OptFileMode{Group: "operators"}},
+		{Unit{scope: ScopeSystem, root: "/tmp/pkg"}, OptFileMode{Owner: "root"}},
+	} {
+		err := bad.o.Apply(&bad.u)
+		if err == nil {
+			err = bad.u.validate()
+		}
+		if err == nil {
+			t.Errorf("%+v should be rejected", bad.o)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fleet deploys the same unit to several hosts over ssh (see OptRemote),
+// several at once. By default every host is deployed, even if some fail;
+// in rolling mode no more hosts are started after one fails, so a bad
+// release only reaches the first few.
+type Fleet struct {
+	name     string
+	hosts    []string
+	opts     FleetOpts
+	unitOpts []UnitOpts
+}
+
+// FleetOpts choose how a Fleet deploys to its hosts.
+type FleetOpts struct {
+	Remote   OptRemote // the ssh settings used for every host, Host is ignored
+	Parallel int       // how many hosts are deployed at once, all of them (or one at a time when Rolling) if zero
+	Rolling  bool      // deploy the hosts in order, stopping at the first failure
+}
+
+// HostResult is the outcome of deploying to one host of a Fleet.
+type HostResult struct {
+	Host     string
+	Err      error         // why the host failed, nil if it succeeded
+	Skipped  bool          // not attempted, after an earlier host failed in rolling mode
+	Duration time.Duration // how long the host took
+}
+
+// FleetError is returned when any host of a Fleet failed or was skipped.
+type FleetError struct {
+	Results []HostResult // every host, in the order they were given
+}
+
+func (e *FleetError) Error() string {
+	failed := []string{}
+	skipped := 0
+	for _, r := range e.Results {
+		if r.Skipped {
+			skipped++
+		} else if r.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", r.Host, r.Err))
+		}
+	}
+	msg := fmt.Sprintf("%d of %d hosts failed: %s", len(failed), len(e.Results), strings.Join(failed, "; "))
+	if skipped > 0 {
+		msg += fmt.Sprintf(" (%d skipped)", skipped)
+	}
+	return msg
+}
+
+// Unwrap returns the errors of the failed hosts.
+func (e *FleetError) Unwrap() []error {
+	errs := []error{}
+	for _, r := range e.Results {
+		if r.Err != nil && !r.Skipped {
+			errs = append(errs, r.Err)
+		}
+	}
+	return errs
+}
+
+// errSkipped is the error of hosts skipped in rolling mode.
+var errSkipped = errors.New("skipped after an earlier host failed")
+
+// NewFleet creates a fleet which deploys the unit to each of hosts. The
+// unit is created with unitOpts, as for NewUnit, on each host.
+func NewFleet(unitName string, hosts []string, opts FleetOpts, unitOpts ...UnitOpts) (Fleet, error) {
+	if !checkName(unitName) {
+		return Fleet{}, fmt.Errorf("sorry, name '%s' is not valid", unitName)
+	}
+	if len(hosts) == 0 {
+		return Fleet{}, errors.New("fleet needs at least one host")
+	}
+	if opts.Parallel < 0 {
+		return Fleet{}, errors.New("Parallel cannot be negative")
+	}
+	seen := map[string]bool{}
+	for _, host := range hosts {
+		if seen[host] {
+			return Fleet{}, fmt.Errorf("host '%s' is in the fleet twice", host)
+		}
+		seen[host] = true
+		remote := opts.Remote
+		remote.Host = host
+		err := remote.Apply(&Unit{})
+		if err != nil {
+			return Fleet{}, fmt.Errorf("bad host: %w", err)
+		}
+	}
+	for _, opt := range unitOpts {
+		if _, ok := opt.(OptRemote); ok {
+			return Fleet{}, errors.New("give the fleet's ssh settings in FleetOpts, not OptRemote")
+		}
+	}
+	return Fleet{name: unitName, hosts: append([]string{}, hosts...), opts: opts, unitOpts: unitOpts}, nil
+}
+
+// Hosts returns the hosts of the fleet.
+func (f Fleet) Hosts() []string {
+	return append([]string{}, f.hosts...)
+}
+
+// Deploy deploys the unit to every host, returning the result for each
+// host, and a *FleetError if any failed.
+func (f Fleet) Deploy() ([]HostResult, error) {
+	return f.each(Unit.Deploy)
+}
+
+// Undeploy undeploys the unit from every host, returning the result for
+// each host, and a *FleetError if any failed.
+func (f Fleet) Undeploy() ([]HostResult, error) {
+	return f.each(Unit.Undeploy)
+}
+
+// unit creates the unit for a host.
+func (f Fleet) unit(host string) (Unit, error) {
+	remote := f.opts.Remote
+	remote.Host = host
+	return NewUnit(f.name, append(append([]UnitOpts{}, f.unitOpts...), remote)...)
+}
+
+// each runs action against the unit on every host, Parallel at a time.
+func (f Fleet) each(action func(Unit) error) ([]HostResult, error) {
+	parallel := f.opts.Parallel
+	if parallel == 0 && f.opts.Rolling {
+		parallel = 1
+	} else if parallel == 0 || parallel > len(f.hosts) {
+		parallel = len(f.hosts)
+	}
+
+	results := make([]HostResult, len(f.hosts))
+	slots := make(chan struct{}, parallel)
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	failed := false
+	for i, host := range f.hosts {
+		slots <- struct{}{}
+		mu.Lock()
+		stop := failed && f.opts.Rolling
+		mu.Unlock()
+		if stop {
+			<-slots
+			results[i] = HostResult{Host: host, Err: errSkipped, Skipped: true}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			start := time.Now()
+			u, err := f.unit(host)
+			if err == nil {
+				err = action(u)
+			}
+			results[i] = HostResult{Host: host, Err: err, Duration: time.Since(start)}
+			if err != nil {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(i, host)
+	}
+	wg.Wait()
+
+	if failed {
+		return results, &FleetError{Results: results}
+	}
+	return results, nil
+}
@@ -0,0 +1,96 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeFleet is like fakeRemote, with a home directory for each host under
+// the returned directory. The host "down" can't be reached.
+func fakeFleet(t *testing.T) string {
+	tools := t.TempDir()
+	homes := t.TempDir()
+	for name, body := range map[string]string{
+		"ssh": `while [ "$1" != -- ]; do shift; done
+[ "$2" = down ] && { echo "connection refused" >&2; exit 255; }
+HOME=` + homes + `/$2; export HOME; mkdir -p "$HOME"
+exec sh -c "$3"`,
+		"id":        "echo 1000",
+		"systemctl": "exit 0",
+	} {
+		os.WriteFile(filepath.Join(tools, name), []byte("#!/bin/sh\n"+body+"\n"), 0700)
+	}
+	t.Setenv("PATH", tools+":"+os.Getenv("PATH"))
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	return homes
+}
+
+func TestFleet(t *testing.T) {
+	homes := fakeFleet(t)
+	binary := filepath.Join(t.TempDir(), "foobar")
+	os.WriteFile(binary, []byte("version 1"), 0755)
+	unitFile := func(host string) string {
+		return filepath.Join(homes, host, ".config", "systemd", "user", "test_unit.service")
+	}
+
+	f, err := NewFleet("test_unit", []string{"web1", "web2", "web3"}, FleetOpts{Remote: OptRemote{Binary: binary}, Parallel: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := f.Deploy()
+	if err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	for i, host := range f.Hosts() {
+		if results[i].Host != host || results[i].Err != nil {
+			t.Errorf("wrong result %+v", results[i])
+		}
+		if _, err := os.Stat(unitFile(host)); err != nil {
+			t.Errorf("unit was not deployed to %s", host)
+		}
+	}
+
+	f, _ = NewFleet("test_unit", []string{"web1", "down", "web2"}, FleetOpts{Remote: OptRemote{Binary: binary}})
+	results, err = f.Deploy()
+	var fleetErr *FleetError
+	if !errors.As(err, &fleetErr) || !strings.Contains(err.Error(), "1 of 3 hosts failed: down: ") {
+		t.Fatalf("wrong error %v", err)
+	}
+	if results[1].Err == nil || results[2].Err != nil {
+		t.Errorf("only the host which is down should fail: %+v", results)
+	}
+
+	f, _ = NewFleet("test_unit", []string{"web4", "down", "web5"}, FleetOpts{Remote: OptRemote{Binary: binary}, Rolling: true})
+	results, err = f.Deploy()
+	if err == nil || !results[2].Skipped || results[0].Err != nil {
+		t.Errorf("rolling deploy should stop at the failed host: %+v", results)
+	}
+	if _, err := os.Stat(unitFile("web5")); !os.IsNotExist(err) {
+		t.Error("rolling deploy continued after a failure")
+	}
+
+	f, _ = NewFleet("test_unit", []string{"web1", "web2"}, FleetOpts{Remote: OptRemote{Binary: binary}})
+	if _, err := f.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if _, err := os.Stat(unitFile("web1")); !os.IsNotExist(err) {
+		t.Error("unit was not undeployed")
+	}
+}
+
+func TestNewFleet(t *testing.T) {
+	for _, hosts := range [][]string{nil, {"web1", "web1"}, {"-x"}} {
+		if _, err := NewFleet("test_unit", hosts, FleetOpts{}); err == nil {
+			t.Errorf("hosts %q should be rejected", hosts)
+		}
+	}
+	if _, err := NewFleet("test_unit", []string{"web1"}, FleetOpts{}, OptRemote{Host: "web2"}); err == nil {
+		t.Error("OptRemote should be rejected")
+	}
+	if _, err := NewFleet("test_unit", []string{"web1"}, FleetOpts{Parallel: -1}); err == nil {
+		t.Error("negative Parallel should be rejected")
+	}
+}
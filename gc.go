@@ -0,0 +1,101 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// triggerTypes are the unit types a trigger option can deploy.
+var triggerTypes = []string{"timer", "socket", "path"}
+
+// existingTrigger stands in for the option that deployed a trigger, so an
+// orphaned unit can be undeployed without knowing its options.
+type existingTrigger string
+
+func (t existingTrigger) unitType() string                     { return string(t) }
+func (t existingTrigger) templateData() map[string]interface{} { return map[string]interface{}{} }
+
+// Orphaned returns true if the binary the unit runs no longer exists.
+// Drop-ins which don't set ExecStart are never orphaned.
+func (m ManagedUnit) Orphaned() bool {
+	fields := strings.Fields(m.ExecStart)
+	if len(fields) == 0 {
+		return false
+	}
+	// strip the special executable prefixes (see systemd.service(5))
+	binary := strings.TrimLeft(fields[0], "@-:+!")
+	_, err := os.Stat(binary)
+	return os.IsNotExist(err)
+}
+
+// OrphanedUnits returns the units deployed by unitard in the scope whose
+// binary no longer exists, usually because it was moved or renamed.
+func OrphanedUnits(scope Scope) ([]ManagedUnit, error) {
+	units, err := ListManagedUnits(scope)
+	if err != nil {
+		return nil, err
+	}
+	orphans := []ManagedUnit{}
+	for _, m := range units {
+		if m.Orphaned() {
+			orphans = append(orphans, m)
+		}
+	}
+	return orphans, nil
+}
+
+// GarbageCollect undeploys the orphaned units in the scope, along with any
+// for which stale returns true (for instance to remove units deployed by an
+// old version), and returns them. stale may be nil. unitOpts are used to
+// create each unit, for instance to pass OptEscalate.
+func GarbageCollect(scope Scope, stale func(ManagedUnit) bool, unitOpts ...UnitOpts) ([]ManagedUnit, error) {
+	units, err := ListManagedUnits(scope)
+	if err != nil {
+		return nil, err
+	}
+	removed := []ManagedUnit{}
+	for _, m := range units {
+		if !m.Orphaned() && (stale == nil || !stale(m)) {
+			continue
+		}
+		u, err := m.unit(unitOpts...)
+		if err != nil {
+			return removed, err
+		}
+		err = u.Undeploy()
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, m)
+	}
+	return removed, nil
+}
+
+// unit returns a Unit which can undeploy the managed unit.
+func (m ManagedUnit) unit(unitOpts ...UnitOpts) (Unit, error) {
+	opts := []UnitOpts{OptScope{Scope: m.Scope}}
+	if m.Instances {
+		opts = append(opts, OptInstances{})
+	}
+	if m.DropIn {
+		opts = append(opts, OptDropIn{Name: strings.TrimSuffix(filepath.Base(m.File), ".conf")})
+	}
+	u, err := NewUnit(m.Name, append(opts, unitOpts...)...)
+	if err != nil {
+		return u, err
+	}
+	// undeploy the files where they were found
+	u.unitFilePath = filepath.Dir(m.File)
+	if m.DropIn {
+		u.unitFilePath = filepath.Dir(u.unitFilePath)
+	}
+	if !m.DropIn && !m.Instances {
+		for _, t := range triggerTypes {
+			if _, err := os.Stat(u.unitFilename(t)); err == nil {
+				u.triggers = append(u.triggers, existingTrigger(t))
+			}
+		}
+	}
+	return u, nil
+}
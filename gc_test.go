@@ -0,0 +1,49 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOrphaned(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "app")
+	os.WriteFile(binary, []byte("#!/bin/sh\n"), 0700)
+
+	if (ManagedUnit{ExecStart: binary + " -flag"}).Orphaned() {
+		t.Error("unit with existing binary should not be orphaned")
+	}
+	if !(ManagedUnit{ExecStart: "-" + binary + ".old"}).Orphaned() {
+		t.Error("unit with missing binary should be orphaned")
+	}
+	if (ManagedUnit{DropIn: true}).Orphaned() {
+		t.Error("drop-in without ExecStart should not be orphaned")
+	}
+}
+
+func TestGarbageCollectUnit(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "test_unit.timer"), nil, 0600)
+	m := ManagedUnit{Name: "test_unit", File: filepath.Join(dir, "test_unit.service")}
+	u, err := m.unit(OptDryRun{})
+	if err != nil {
+		t.Fatalf("could not create unit: %s", err)
+	}
+
+	plan, err := u.DryRunUndeploy()
+	if err != nil {
+		t.Fatalf("dry run undeploy failed: %s", err)
+	}
+	commands := strings.Join(plan.Commands(), "\n")
+	if !strings.Contains(commands, "disable test_unit.timer") {
+		t.Errorf("timer is not undeployed:\n%s", commands)
+	}
+	removed := false
+	for _, a := range plan.Actions {
+		removed = removed || (a.Kind == ActionRemove && a.Path == filepath.Join(dir, "test_unit.timer"))
+	}
+	if !removed {
+		t.Errorf("timer file is not removed: %v", plan.Actions)
+	}
+}
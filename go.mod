@@ -1,3 +1,5 @@
 module github.com/tardisx/unitard
 
-go 1.17
+go 1.21
+
+require golang.org/x/sys v0.26.0
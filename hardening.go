@@ -0,0 +1,132 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Hardening is a preset of systemd sandboxing directives.
+type Hardening int
+
+const (
+	// HardeningBasic restricts privilege escalation and access to the kernel,
+	// and should not break most programs.
+	HardeningBasic Hardening = iota + 1
+	// HardeningStrict additionally makes the filesystem read-only (apart from
+	// directories from OptDirectories), hides devices, and restricts system
+	// calls and network address families. Programs may need some
+	// directives disabled to work.
+	HardeningStrict
+)
+
+var hardeningBasic = []Directive{
+	{SectionService, "NoNewPrivileges", "yes"},
+	{SectionService, "PrivateTmp", "yes"},
+	{SectionService, "ProtectSystem", "full"},
+	{SectionService, "ProtectKernelTunables", "yes"},
+	{SectionService, "ProtectKernelModules", "yes"},
+	{SectionService, "ProtectControlGroups", "yes"},
+	{SectionService, "RestrictSUIDSGID", "yes"},
+	{SectionService, "LockPersonality", "yes"},
+}
+
+var hardeningStrict = []Directive{
+	{SectionService, "NoNewPrivileges", "yes"},
+	{SectionService, "PrivateTmp", "yes"},
+	{SectionService, "ProtectSystem", "strict"},
+	{SectionService, "ProtectHome", "read-only"},
+	{SectionService, "PrivateDevices", "yes"},
+	{SectionService, "ProtectKernelTunables", "yes"},
+	{SectionService, "ProtectKernelModules", "yes"},
+	{SectionService, "ProtectKernelLogs", "yes"},
+	{SectionService, "ProtectControlGroups", "yes"},
+	{SectionService, "ProtectClock", "yes"},
+	{SectionService, "ProtectHostname", "yes"},
+	{SectionService, "RestrictSUIDSGID", "yes"},
+	{SectionService, "RestrictNamespaces", "yes"},
+	{SectionService, "RestrictRealtime", "yes"},
+	{SectionService, "LockPersonality", "yes"},
+	{SectionService, "MemoryDenyWriteExecute", "yes"},
+	{SectionService, "RestrictAddressFamilies", "AF_UNIX AF_INET AF_INET6"},
+	{SectionService, "SystemCallArchitectures", "native"},
+	{SectionService, "SystemCallFilter", "@system-service"},
+	{SectionService, "CapabilityBoundingSet", ""},
+}
+
+// directives returns the directives for the preset.
+func (h Hardening) directives() ([]Directive, error) {
+	switch h {
+	case HardeningBasic:
+		return hardeningBasic, nil
+	case HardeningStrict:
+		return hardeningStrict, nil
+	}
+	return nil, errors.New("unknown hardening profile")
+}
+
+// OptHardening adds a preset of sandboxing directives to the service.
+// Individual directives from the preset can be removed with Disable (by
+// name, eg "MemoryDenyWriteExecute") or changed or added with Set, for when
+// a preset breaks the program.
+//
+// For user units, most of the sandboxing relies on unprivileged user
+// namespaces being available.
+type OptHardening struct {
+	Profile Hardening
+	Disable []string          // directives to remove from the preset
+	Set     map[string]string // directives to change or add
+}
+
+func (o OptHardening) Apply(u *Unit) error {
+	preset, err := o.Profile.directives()
+	if err != nil {
+		return err
+	}
+
+	disabled := map[string]bool{}
+	for _, key := range o.Disable {
+		found := false
+		for _, d := range preset {
+			if d.Key == key {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("cannot disable '%s', it is not part of the profile", key)
+		}
+		disabled[key] = true
+	}
+
+	// the preset in order, followed by any additions in a stable order
+	remaining := map[string]string{}
+	for k, v := range o.Set {
+		remaining[k] = v
+	}
+	for _, d := range preset {
+		if disabled[d.Key] {
+			continue
+		}
+		value := d.Value
+		if v, ok := remaining[d.Key]; ok {
+			value = v
+			delete(remaining, d.Key)
+		}
+		err := u.addDirective(d.Section, d.Key, value)
+		if err != nil {
+			return err
+		}
+	}
+	keys := []string{}
+	for k := range remaining {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		err := u.addDirective(SectionService, k, remaining[k])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
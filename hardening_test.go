@@ -0,0 +1,47 @@
+package unitard
+
+import (
+	"testing"
+)
+
+func TestHardening(t *testing.T) {
+	u := Unit{}
+	err := OptHardening{
+		Profile: HardeningStrict,
+		Disable: []string{"MemoryDenyWriteExecute"},
+		Set:     map[string]string{"ProtectHome": "tmpfs", "PrivateNetwork": "yes"},
+	}.Apply(&u)
+	if err != nil {
+		t.Fatalf("failed to apply hardening: %s", err)
+	}
+
+	got := map[string]string{}
+	for _, d := range u.sectionDirectives(SectionService) {
+		got[d.Key] = d.Value
+	}
+	if _, ok := got["MemoryDenyWriteExecute"]; ok {
+		t.Error("disabled directive is present")
+	}
+	if got["ProtectHome"] != "tmpfs" {
+		t.Errorf("ProtectHome was not changed, got %q", got["ProtectHome"])
+	}
+	if got["PrivateNetwork"] != "yes" {
+		t.Error("PrivateNetwork was not added")
+	}
+	if got["NoNewPrivileges"] != "yes" {
+		t.Error("preset directive is missing")
+	}
+	last := u.directives[len(u.directives)-1]
+	if last.Key != "PrivateNetwork" {
+		t.Errorf("added directives should come after the preset, got %s last", last.Key)
+	}
+}
+
+func TestHardeningOpts(t *testing.T) {
+	if (OptHardening{}).Apply(&Unit{}) == nil {
+		t.Error("missing profile should not be valid")
+	}
+	if (OptHardening{Profile: HardeningBasic, Disable: []string{"NoSuchThing"}}).Apply(&Unit{}) == nil {
+		t.Error("disabling an unknown directive should not be valid")
+	}
+}
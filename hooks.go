@@ -0,0 +1,48 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OptHooks adds commands systemd runs around the service: StartPre before
+// it starts (such as database migrations), StartPost once it has started,
+// Stop to stop it instead of sending SIGTERM, and StopPost after it has
+// stopped, for cleaning up. Each list is run in order, and all but StopPost
+// stop at the first failure; prefix a command with "-" to ignore its
+// failure. It can be used more than once to add more commands.
+type OptHooks struct {
+	StartPre  []string // ExecStartPre=
+	StartPost []string // ExecStartPost=
+	Stop      []string // ExecStop=
+	StopPost  []string // ExecStopPost=
+}
+
+func (o OptHooks) Apply(u *Unit) error {
+	hooks := []struct {
+		key      string
+		commands []string
+	}{
+		{"ExecStartPre", o.StartPre},
+		{"ExecStartPost", o.StartPost},
+		{"ExecStop", o.Stop},
+		{"ExecStopPost", o.StopPost},
+	}
+	set := false
+	for _, h := range hooks {
+		for _, command := range h.commands {
+			if command == "" {
+				return fmt.Errorf("can't set an empty %s command", h.key)
+			}
+			err := u.addDirective(SectionService, h.key, command)
+			if err != nil {
+				return err
+			}
+			set = true
+		}
+	}
+	if !set {
+		return errors.New("OptHooks needs at least one command")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package unitard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", unitFilePath: t.TempDir()}
+	opts := []UnitOpts{
+		OptHooks{StartPre: []string{"/fullpath/to/foobar migrate", "-/bin/mkdir -p /tmp/foobar"}, Stop: []string{"/fullpath/to/foobar stop"}},
+		OptHooks{StartPost: []string{"/bin/echo started"}, StopPost: []string{"/bin/rm -rf /tmp/foobar"}},
+	}
+	for _, opt := range opts {
+		if err := opt.Apply(&u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := u.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ExecStartPre=/fullpath/to/foobar migrate\nExecStartPre=-/bin/mkdir -p /tmp/foobar\nExecStop=/fullpath/to/foobar stop\nExecStartPost=/bin/echo started\nExecStopPost=/bin/rm -rf /tmp/foobar\n"
+	if !strings.Contains(files[u.UnitFilename()], want) {
+		t.Errorf("hooks are missing or out of order:\n%s", files[u.UnitFilename()])
+	}
+
+	for _, bad := range []OptHooks{{}, {Stop: []string{""}}, {StartPre: []string{"/bin/true\n/bin/false"}}} {
+		if err := bad.Apply(&Unit{}); err == nil {
+			t.Errorf("%+v should be refused", bad)
+		}
+	}
+}
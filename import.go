@@ -0,0 +1,143 @@
+package unitard
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tardisx/unitard/unitfile"
+)
+
+// NewUnitFromFile creates a unit from an installed service unit file, such
+// as one written by hand, so that Deploy manages it from then on. The unit
+// is named after the file, and its directives are kept: ExecStart and
+// WantedBy become the unit's command and targets, and everything else is
+// passed through as an OptDirective. Comments are not kept.
+//
+// The options are applied after the imported directives, so they can
+// adjust them; giving OptExecStart, OptBinary or OptProgramArgs replaces
+// the imported command. The unit must end up deployed to the same file,
+// which is found in the unit directory of its scope (or with
+// OptUnitDirectory otherwise), so a system unit needs OptScope unless it
+// is in /etc/systemd/system.
+//
+// Deploy overwrites the file, which was not created by unitard, without
+// needing OptForce, as long as it hasn't changed since it was imported.
+func NewUnitFromFile(path string, unitOpts ...UnitOpts) (Unit, error) {
+	name, ok := strings.CutSuffix(filepath.Base(path), ".service")
+	if !ok || strings.HasSuffix(name, "@") {
+		return Unit{}, fmt.Errorf("sorry, '%s' is not a service unit file which can be imported", path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return Unit{}, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Unit{}, fmt.Errorf("could not read unit file: %w", err)
+	}
+	f, err := unitfile.Parse(bytes.NewReader(content))
+	if err != nil {
+		return Unit{}, fmt.Errorf("could not parse unit file '%s': %w", path, err)
+	}
+
+	opts, err := importOptions(name, f, !setsCommand(unitOpts))
+	if err != nil {
+		return Unit{}, fmt.Errorf("could not import '%s': %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	if dir == systemUnitDirectory {
+		opts = append([]UnitOpts{OptScope{Scope: ScopeSystem}}, opts...)
+	} else if userDir, err := userUnitDirectory(); err != nil || dir != userDir {
+		opts = append([]UnitOpts{OptUnitDirectory{Dir: dir}}, opts...)
+	}
+
+	u, err := NewUnit(name, append(opts, unitOpts...)...)
+	if err != nil {
+		return Unit{}, err
+	}
+	if u.UnitFilename() != path {
+		return Unit{}, fmt.Errorf("sorry, the imported unit would be deployed to '%s', not '%s'", u.UnitFilename(), path)
+	}
+	u.imported = content
+	return u, nil
+}
+
+// setsCommand returns true if the options choose the command the service
+// runs.
+func setsCommand(unitOpts []UnitOpts) bool {
+	for _, o := range unitOpts {
+		switch o.(type) {
+		case OptExecStart, OptBinary, OptProgramArgs:
+			return true
+		}
+	}
+	return false
+}
+
+// importOptions returns the options which recreate the unit file f, for
+// the unit name. The command it runs is left out unless command is set.
+func importOptions(name string, f *unitfile.File, command bool) ([]UnitOpts, error) {
+	opts := []UnitOpts{}
+	for _, s := range f.Sections {
+		section := Section(s.Name)
+		if s.Name == "" {
+			// comments before the first section
+			continue
+		}
+		if section != SectionUnit && section != SectionService && section != SectionInstall {
+			return nil, fmt.Errorf("sorry, section [%s] can't be imported", s.Name)
+		}
+		for _, l := range s.Lines {
+			switch {
+			case l.Key == "" || strings.HasPrefix(l.Key, "X-Unitard-"):
+				continue
+			case section == SectionUnit && l.Key == "Description" && l.Value == name:
+				// as unitard writes it anyway
+				continue
+			case section == SectionService && (l.Key == "ExecStart" || l.Key == "WorkingDirectory"):
+				continue
+			case section == SectionInstall && l.Key == "WantedBy":
+				continue
+			}
+			opts = append(opts, OptDirective{Section: section, Key: l.Key, Value: l.Value})
+		}
+	}
+
+	wantedBy := strings.Fields(strings.Join(f.Values("Install", "WantedBy"), " "))
+	if len(wantedBy) > 0 {
+		opts = append(opts, OptWantedBy{WantedBy: wantedBy})
+	}
+	if !command {
+		return opts, nil
+	}
+
+	execStart := f.Values("Service", "ExecStart")
+	workingDir, _ := f.Get("Service", "WorkingDirectory")
+	binary, _, _ := strings.Cut(strings.TrimSpace(strings.Join(execStart, "")), " ")
+	if len(execStart) == 1 && filepath.IsAbs(binary) {
+		opts = append(opts, OptExecStart{Command: execStart[0]})
+		if workingDir == filepath.Dir(binary) {
+			workingDir = ""
+		}
+	} else if len(execStart) > 0 {
+		// several commands, or with prefixes such as "-", replace the one
+		// unitard writes
+		opts = append(opts, OptDirective{Section: SectionService, Key: "ExecStart"})
+		for _, e := range execStart {
+			opts = append(opts, OptDirective{Section: SectionService, Key: "ExecStart", Value: e})
+		}
+	}
+	if workingDir != "" {
+		opts = append(opts, OptDirective{Section: SectionService, Key: "WorkingDirectory", Value: workingDir})
+	}
+	return opts, nil
+}
+
+// isImported returns true if content is the file imported by
+// NewUnitFromFile, unchanged, so it can be overwritten.
+func (u Unit) isImported(name string, content []byte) bool {
+	return u.imported != nil && name == u.UnitFilename() && bytes.Equal(content, u.imported)
+}
@@ -0,0 +1,95 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const handWrittenUnit = `# my agent, installed by hand
+[Unit]
+Description=My agent
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+ExecStart=/bin/sleep 1000
+WorkingDirectory=/var/lib/agent
+Environment=A=1
+Environment=B=2
+Restart=on-failure
+
+[Install]
+WantedBy=default.target graphical-session.target
+`
+
+func TestNewUnitFromFile(t *testing.T) {
+	client := &recordingClient{dir: t.TempDir()}
+	path := filepath.Join(client.dir, "agent.service")
+	os.WriteFile(path, []byte(handWrittenUnit), 0644)
+
+	u, err := NewUnitFromFile(path, OptClient{Client: client}, OptSkipVerify{}, OptDirective{Section: SectionService, Key: "Restart", Value: "always"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.name != "agent" || u.binary != "/bin/sleep" || u.binaryArgs != "1000" {
+		t.Errorf("wrong unit %s running %s %s", u.name, u.binary, u.binaryArgs)
+	}
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("imported unit should be deployed without OptForce: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	for _, want := range []string{
+		"Description=My agent\n", "After=network-online.target\n", "Wants=network-online.target\n",
+		"ExecStart=/bin/sleep 1000\n", "WorkingDirectory=/var/lib/agent\n",
+		"Environment=A=1\nEnvironment=B=2\n", "Restart=always\n",
+		"WantedBy=default.target graphical-session.target\n", markerManaged + "=yes\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("unit file is missing %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(string(content), "on-failure") {
+		t.Errorf("option should replace the imported Restart:\n%s", content)
+	}
+
+	// a file changed since it was imported is not overwritten
+	os.WriteFile(path, []byte(handWrittenUnit), 0644)
+	u, err = NewUnitFromFile(path, OptClient{Client: client}, OptSkipVerify{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte(handWrittenUnit+"# edited\n"), 0644)
+	if err := u.Deploy(); !errors.Is(err, ErrUnitNotManaged) {
+		t.Errorf("expected ErrUnitNotManaged, got %v", err)
+	}
+}
+
+func TestImportOptions(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"agent.timer":    "[Timer]\nOnCalendar=daily\n",
+		"agent@.service": "[Service]\nExecStart=/bin/true\n",
+		"agent.service":  "[Service]\nExecStart=/bin/true\n[Socket]\nListenStream=80\n",
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		if _, err := NewUnitFromFile(path, OptDryRun{}); err == nil {
+			t.Errorf("%s should not be imported", name)
+		}
+	}
+
+	// prefixed commands replace the generated ExecStart
+	path := filepath.Join(dir, "job.service")
+	os.WriteFile(path, []byte("[Service]\nType=oneshot\nExecStart=-/bin/true\nExecStart=/bin/echo done\n"), 0644)
+	u, err := NewUnitFromFile(path, OptDryRun{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := u.Render()
+	if !strings.Contains(files[path], "\nExecStart=\nExecStart=-/bin/true\nExecStart=/bin/echo done\n") {
+		t.Errorf("commands not imported:\n%s", files[path])
+	}
+}
@@ -0,0 +1,160 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// systemBinaryDirectory is where OptInstall copies the program for system
+// scope.
+const systemBinaryDirectory = "/usr/local/bin"
+
+// OptInstall copies the running program to a stable location on Deploy and
+// points the unit at the copy, so the service keeps working after the
+// original is deleted or rebuilt, or if it was run from a temporary
+// directory. The copy goes in Dir, ~/.local/bin by default (or
+// /usr/local/bin for system scope), named after the unit. It is only
+// rewritten when the program has changed, and Undeploy removes the copy of
+// the running build.
+//
+// With Versioned, each build is kept under its own name (name-<hash>), so a
+// deploy which fails and is rolled back leaves the previous build running.
+type OptInstall struct {
+	Dir       string // the directory to install the program in
+	Versioned bool   // install each build under a new name
+}
+
+func (o OptInstall) Apply(u *Unit) error {
+	if o.Dir != "" && !filepath.IsAbs(o.Dir) {
+		return fmt.Errorf("sorry, install directory '%s' must be absolute", o.Dir)
+	}
+	u.install = &o
+	return nil
+}
+
+// TemporaryBinaryError is returned by Deploy when the program was built by
+// go run, in a temporary directory which is removed when it exits. Build it
+// with go build (or go install) first, or use OptInstall.
+type TemporaryBinaryError struct {
+	Binary string
+}
+
+func (e *TemporaryBinaryError) Error() string {
+	return fmt.Sprintf("%s: %s was built by go run and will be removed - use go build, or OptInstall to copy it", ErrTemporaryBinary, e.Binary)
+}
+
+func (e *TemporaryBinaryError) Is(target error) bool {
+	return target == ErrTemporaryBinary
+}
+
+// temporaryBinary returns true if binary was built by go run, which puts
+// it in $GOTMPDIR/go-build.../exe.
+func temporaryBinary(binary string) bool {
+	dir := filepath.Dir(binary)
+	return filepath.Base(dir) == "exe" && strings.HasPrefix(filepath.Base(filepath.Dir(filepath.Dir(dir))), "go-build")
+}
+
+// checkTemporaryBinary returns a *TemporaryBinaryError if the unit would
+// run a temporary build. The copy made by OptInstall is fine, and so is a
+// binary uploaded to another host.
+func (u Unit) checkTemporaryBinary() error {
+	if u.installFrom != "" || u.remote != nil || u.root != "" || !temporaryBinary(u.binary) {
+		return nil
+	}
+	return &TemporaryBinaryError{Binary: u.binary}
+}
+
+// setupInstall points the unit at the installed copy of the program.
+func (u *Unit) setupInstall() error {
+	if u.install == nil {
+		return nil
+	}
+	dir := u.install.Dir
+	if dir == "" && u.scope == ScopeSystem {
+		dir = systemBinaryDirectory
+	} else if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("could not find users home dir: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "bin")
+	}
+	name := u.installName()
+	if u.install.Versioned {
+		if u.binaryHash == "" {
+			return errors.New("cannot install a versioned copy, the program could not be read")
+		}
+		name += "-" + u.binaryHash[:12]
+	}
+	u.installFrom = u.binary
+	u.binary = filepath.Join(dir, name+filepath.Ext(u.installFrom))
+	u.binaryPath = dir
+	return nil
+}
+
+// installChanged returns true if the installed copy of the program is
+// missing or out of date.
+func (u Unit) installChanged() bool {
+	return u.installFrom != "" && fileHash(u.binary) != u.binaryHash
+}
+
+// installBinary copies the program to where the unit runs it from.
+func (u Unit) installBinary() error {
+	err := u.mkdirAll(u.binaryPath)
+	if err != nil {
+		return err
+	}
+	if u.escalate != nil {
+		return u.runEscalated("install", "-m", "0755", u.installFrom, u.binary)
+	}
+	if u.step(Action{Kind: ActionRun, Command: []string{"install", "-m", "0755", u.installFrom, u.binary}}) {
+		return nil
+	}
+	err = copyExecutable(u.installFrom, u.binary)
+	if err != nil {
+		return fmt.Errorf("could not install the program: %w", err)
+	}
+	return nil
+}
+
+// copyExecutable copies the program src to dst, by writing a new file and
+// renaming it, in case the old one is running.
+func copyExecutable(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(dst+".new", dst)
+	}
+	if err != nil {
+		os.Remove(dst + ".new")
+	}
+	return err
+}
+
+// removeInstalled removes the installed copy of the program, if there is
+// one.
+func (u Unit) removeInstalled() error {
+	if u.installFrom == "" {
+		return nil
+	}
+	err := u.removeFile(u.binary)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstall(t *testing.T) {
+	systemctl, log := fakeSystemctl(t, "")
+	src := filepath.Join(t.TempDir(), "foobar")
+	os.WriteFile(src, []byte("build 1"), 0700)
+	dir := filepath.Join(t.TempDir(), "bin")
+	u := Unit{name: "test_unit", binary: src, binaryHash: fileHash(src), skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+	(OptInstall{Dir: dir}).Apply(&u)
+	if err := u.setupInstall(); err != nil {
+		t.Fatal(err)
+	}
+	installed := filepath.Join(dir, "test_unit")
+	if u.binary != installed || u.binaryPath != dir {
+		t.Fatalf("unit should run the installed copy, not %s", u.binary)
+	}
+
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	if content, err := os.ReadFile(installed); err != nil || string(content) != "build 1" {
+		t.Fatalf("program was not installed: %v %q", err, content)
+	}
+	if info, _ := os.Stat(installed); info.Mode().Perm() != 0755 {
+		t.Errorf("installed program has mode %s", info.Mode())
+	}
+	content, _ := os.ReadFile(u.UnitFilename())
+	if !strings.Contains(string(content), "ExecStart="+installed) {
+		t.Errorf("unit does not run the installed copy:\n%s", content)
+	}
+
+	// a rebuild is installed and restarted, without changing the unit file
+	os.Truncate(log, 0)
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	if commands, _ := os.ReadFile(log); strings.Contains(string(commands), "restart") {
+		t.Errorf("unchanged program should not be restarted: %s", commands)
+	}
+	os.WriteFile(src, []byte("build 2"), 0700)
+	u.binaryHash = fileHash(src)
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(installed); string(content) != "build 2" {
+		t.Errorf("rebuilt program was not installed: %q", content)
+	}
+	if commands, _ := os.ReadFile(log); !strings.Contains(string(commands), "restart test_unit") {
+		t.Errorf("rebuilt program should be restarted: %s", commands)
+	}
+
+	if err := u.Undeploy(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(installed); !os.IsNotExist(err) {
+		t.Error("installed program was not removed")
+	}
+}
+
+func TestInstallVersioned(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "foobar")
+	os.WriteFile(src, []byte("build 1"), 0700)
+	u := Unit{name: "test_unit", binary: src, binaryHash: fileHash(src)}
+	(OptInstall{Dir: "/opt/app", Versioned: true}).Apply(&u)
+	if err := u.setupInstall(); err != nil {
+		t.Fatal(err)
+	}
+	if u.binary != "/opt/app/test_unit-"+u.binaryHash[:12] {
+		t.Errorf("wrong versioned path %s", u.binary)
+	}
+
+	if err := (OptInstall{Dir: "relative"}).Apply(&u); err == nil {
+		t.Error("relative directory should be refused")
+	}
+	if _, err := NewUnit("test_unit", OptInstall{}, OptRoot{Dir: t.TempDir()}); err == nil {
+		t.Error("OptInstall should not be combined with OptRoot")
+	}
+}
+
+func TestTemporaryBinary(t *testing.T) {
+	for binary, want := range map[string]bool{
+		"/tmp/go-build1234/b001/exe/app":       true,
+		"/home/me/tmp/go-build99/b001/exe/app": true,
+		"/tmp/go-build1234/b001/app.test":      false,
+		"/usr/local/bin/app":                   false,
+		"/home/me/exe/app":                     false,
+	} {
+		if temporaryBinary(binary) != want {
+			t.Errorf("temporaryBinary(%s) should be %t", binary, want)
+		}
+	}
+
+	u := Unit{name: "test_unit", binary: "/tmp/go-build1234/b001/exe/app", unitFilePath: t.TempDir()}
+	err := u.Deploy()
+	var tempErr *TemporaryBinaryError
+	if !errors.As(err, &tempErr) || !errors.Is(err, ErrTemporaryBinary) || tempErr.Binary != u.binary {
+		t.Errorf("expected a TemporaryBinaryError, got %v", err)
+	}
+	u.installFrom = u.binary
+	u.binary = "/usr/local/bin/app"
+	if err := u.checkTemporaryBinary(); err != nil {
+		t.Errorf("an installed copy should be allowed: %s", err)
+	}
+}
@@ -0,0 +1,47 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OptInstances deploys the unit as a systemd template unit (name@.service),
+// so the same program can be run several times with different instance
+// names. Use Instance to choose an instance to deploy, start or stop.
+//
+// The instance name is available as %i in OptProgramArgs, for instance
+// OptProgramArgs{Args: "-config %i.conf"}.
+type OptInstances struct{}
+
+func (o OptInstances) Apply(u *Unit) error {
+	if u.instances {
+		return errors.New("instances were already set - use OptInstances only once")
+	}
+	u.instances = true
+	return nil
+}
+
+// Instance returns the named instance of a unit created with OptInstances.
+// Deploy on the returned unit installs the template unit file if needed,
+// then enables and starts the instance.
+func (u Unit) Instance(instance string) Unit {
+	u.instance = instance
+	return u
+}
+
+// checkInstance returns an error if the instance name is not usable.
+func (u Unit) checkInstance() error {
+	if !u.instances {
+		return errors.New("unit was not created with OptInstances")
+	}
+	if u.instance != "" && !checkName(u.instance) {
+		return fmt.Errorf("sorry, instance name '%s' is not valid", u.instance)
+	}
+	return nil
+}
+
+// isTemplate returns true if this refers to the template unit itself, rather
+// than an instance of it.
+func (u Unit) isTemplate() bool {
+	return u.instances && u.instance == ""
+}
@@ -0,0 +1,57 @@
+package unitard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestInstances(t *testing.T) {
+	u := Unit{name: "test_unit", unitFilePath: "/units"}
+	err := OptInstances{}.Apply(&u)
+	if err != nil {
+		t.Fatalf("failed to apply instances: %s", err)
+	}
+
+	if u.UnitFilename() != "/units/test_unit@.service" {
+		t.Errorf("wrong template filename %s", u.UnitFilename())
+	}
+	if !u.isTemplate() {
+		t.Error("unit without instance should be the template")
+	}
+	if u.Start() == nil {
+		t.Error("should not be able to start the template")
+	}
+
+	blue := u.Instance("blue")
+	if blue.isTemplate() {
+		t.Error("instance should not be the template")
+	}
+	if blue.UnitFilename() != u.UnitFilename() {
+		t.Error("instances should share the template unit file")
+	}
+	if active := blue.activeUnits(); len(active) != 1 || active[0] != "test_unit@blue" {
+		t.Errorf("wrong active units %v", active)
+	}
+	if u.Instance("not valid").checkInstance() == nil {
+		t.Error("instance name with a space should not be valid")
+	}
+
+	buff := bytes.NewBuffer(nil)
+	err = u.writeTemplate(buff)
+	if err != nil {
+		t.Errorf("failed to write template: %s", err)
+	}
+	if !strings.Contains(buff.String(), "Description=test_unit %i") {
+		t.Error("template description does not contain instance")
+	}
+}
+
+func TestInstancesWithTrigger(t *testing.T) {
+	u := Unit{}
+	_ = OptInstances{}.Apply(&u)
+	_ = OptTimer{OnCalendar: "daily"}.Apply(&u)
+	if u.validate() == nil {
+		t.Error("instances with a timer should not be valid")
+	}
+}
@@ -0,0 +1,153 @@
+// Package journal provides leveled logging to the systemd journal, for
+// programs deployed as services with unitard.
+//
+// journald reads a service's standard output and error, and treats a
+// "<N>" prefix on each line as the syslog priority of that line (see
+// sd-daemon(3)). Writer adds those prefixes, so that log output is leveled
+// correctly in journalctl instead of all showing as info.
+package journal
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+)
+
+// Priority is a syslog priority, as used by the journal.
+type Priority int
+
+const (
+	PriEmerg Priority = iota
+	PriAlert
+	PriCrit
+	PriErr
+	PriWarning
+	PriNotice
+	PriInfo
+	PriDebug
+)
+
+// prefix returns the sd-daemon prefix for the priority.
+func (p Priority) prefix() []byte {
+	return []byte("<" + strconv.Itoa(int(p)) + ">")
+}
+
+// Writer is an io.Writer which prefixes every line written to it with a
+// priority. It is safe for concurrent use.
+type Writer struct {
+	mu       sync.Mutex
+	w        io.Writer
+	priority Priority
+	midLine  bool // the last write did not end with a newline
+}
+
+// NewWriter returns a Writer which writes lines to w (usually os.Stdout or
+// os.Stderr) at priority p. It can be used with log.New, for instance.
+func NewWriter(w io.Writer, p Priority) *Writer {
+	return &Writer{w: w, priority: p}
+}
+
+// Write writes p, adding the priority prefix at the start of each line.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.write(p, w.priority)
+}
+
+// write writes p with the given priority, the lock must be held.
+func (w *Writer) write(p []byte, priority Priority) (int, error) {
+	out := make([]byte, 0, len(p)+4)
+	for _, c := range p {
+		if !w.midLine {
+			out = append(out, priority.prefix()...)
+			w.midLine = true
+		}
+		out = append(out, c)
+		if c == '\n' {
+			w.midLine = false
+		}
+	}
+	_, err := w.w.Write(out)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// LevelPriority returns the journal priority for a slog level.
+func LevelPriority(level slog.Level) Priority {
+	switch {
+	case level >= slog.LevelError:
+		return PriErr
+	case level >= slog.LevelWarn:
+		return PriWarning
+	case level >= slog.LevelInfo:
+		return PriInfo
+	}
+	return PriDebug
+}
+
+// Handler is a slog.Handler which writes records in the slog text format,
+// prefixed with the journal priority matching the record's level. The time
+// and level attributes are omitted, since the journal records both.
+type Handler struct {
+	inner slog.Handler
+	out   *levelWriter
+}
+
+// levelWriter passes writes to a Writer at the priority of the record
+// currently being handled.
+type levelWriter struct {
+	mu       sync.Mutex
+	w        *Writer
+	priority Priority
+}
+
+func (l *levelWriter) Write(p []byte) (int, error) {
+	l.w.mu.Lock()
+	defer l.w.mu.Unlock()
+	return l.w.write(p, l.priority)
+}
+
+// NewHandler returns a Handler writing to w (usually os.Stderr). opts may be
+// nil.
+func NewHandler(w io.Writer, opts *slog.HandlerOptions) *Handler {
+	textOpts := slog.HandlerOptions{}
+	if opts != nil {
+		textOpts = *opts
+	}
+	replace := textOpts.ReplaceAttr
+	textOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+			return slog.Attr{}
+		}
+		if replace != nil {
+			return replace(groups, a)
+		}
+		return a
+	}
+
+	out := &levelWriter{w: NewWriter(w, PriInfo)}
+	return &Handler{inner: slog.NewTextHandler(out, &textOpts), out: out}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	h.out.priority = LevelPriority(r.Level)
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{inner: h.inner.WithAttrs(attrs), out: h.out}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{inner: h.inner.WithGroup(name), out: h.out}
+}
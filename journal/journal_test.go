@@ -0,0 +1,59 @@
+package journal
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	buff := bytes.NewBuffer(nil)
+	w := NewWriter(buff, PriWarning)
+
+	l := log.New(w, "", 0)
+	l.Print("first")
+	l.Print("second\nthird")
+
+	want := "<4>first\n<4>second\n<4>third\n"
+	if buff.String() != want {
+		t.Errorf("got %q, want %q", buff.String(), want)
+	}
+
+	// a partial line is only prefixed once
+	buff.Reset()
+	w.Write([]byte("part"))
+	w.Write([]byte("ial\n"))
+	if buff.String() != "<4>partial\n" {
+		t.Errorf("got %q", buff.String())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	buff := bytes.NewBuffer(nil)
+	logger := slog.New(NewHandler(buff, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	logger.Error("broken", "code", 42)
+	logger.With("component", "db").Debug("detail")
+	logger.Info("fine")
+
+	want := "<3>msg=broken code=42\n<7>msg=detail component=db\n<6>msg=fine\n"
+	if buff.String() != want {
+		t.Errorf("got %q, want %q", buff.String(), want)
+	}
+}
+
+func TestLevelPriority(t *testing.T) {
+	tests := map[slog.Level]Priority{
+		slog.LevelError + 4: PriErr,
+		slog.LevelError:     PriErr,
+		slog.LevelWarn:      PriWarning,
+		slog.LevelInfo:      PriInfo,
+		slog.LevelDebug:     PriDebug,
+	}
+	for level, want := range tests {
+		if got := LevelPriority(level); got != want {
+			t.Errorf("LevelPriority(%s) = %d, want %d", level, got, want)
+		}
+	}
+}
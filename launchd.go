@@ -0,0 +1,250 @@
+package unitard
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	// launchDaemonDirectory is where system scope jobs are installed.
+	launchDaemonDirectory = "/Library/LaunchDaemons"
+	// launchAgentDirectory is where user scope jobs are installed, under
+	// the home directory.
+	launchAgentDirectory = "Library/LaunchAgents"
+)
+
+// launchdDirectives are the directives the launchd backend maps to the
+// plist. Others are ignored, with a warning logged.
+var launchdDirectives = map[string]bool{
+	markerManaged: true, "Environment": true, "Restart": true, "User": true, "Group": true,
+	"StandardOutput": true, "StandardError": true,
+}
+
+var launchdLabelRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// OptLaunchd deploys the unit as a launchd job, which is the default on
+// macOS. User scope units are installed as a LaunchAgent in
+// ~/Library/LaunchAgents, and system scope units as a LaunchDaemon in
+// /Library/LaunchDaemons.
+//
+// The program arguments, Environment, User and Group, Restart (always or
+// on-failure become KeepAlive) and file output from OptOutput are mapped to
+// the plist. Timers, sockets, paths and most other systemd features are not
+// supported.
+type OptLaunchd struct {
+	Label string // the job label, such as com.example.myapp; defaults to the unit name
+}
+
+func (o OptLaunchd) Apply(u *Unit) error {
+	if o.Label != "" && !launchdLabelRegexp.MatchString(o.Label) {
+		return fmt.Errorf("sorry, label '%s' is not valid", o.Label)
+	}
+	u.backend = launchd{}
+	u.launchdLabel = o.Label
+	return nil
+}
+
+// launchd is the backend for macOS.
+type launchd struct{}
+
+func (launchd) setup(u *Unit) error {
+	err := u.checkBackendOptions("launchd")
+	if err != nil {
+		return err
+	}
+	if len(u.triggers) > 0 {
+		return errors.New("timers, sockets and paths cannot be used with launchd")
+	}
+	u.warnUnsupported("launchd", launchdDirectives)
+	if u.launchdLabel == "" {
+		u.launchdLabel = u.name
+	}
+
+	u.toolPath = "launchctl"
+	if path, err := exec.LookPath("launchctl"); err == nil {
+		u.toolPath = path
+	} else if !u.dryRun {
+		return fmt.Errorf("launchctl not found: %w", err)
+	}
+
+	if u.scope == ScopeSystem {
+		u.unitFilePath = launchDaemonDirectory
+		if os.Getuid() != 0 && !u.dryRun {
+			escalate, err := u.escalation.command()
+			if err != nil {
+				return err
+			}
+			u.escalate = escalate
+		}
+		return nil
+	}
+	if os.Getuid() == 0 && !u.dryRun {
+		return ErrRootNotAllowed
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("could not find users home dir: %w", err)
+	}
+	u.unitFilePath = filepath.Join(home, launchAgentDirectory)
+	if u.dryRun {
+		return nil
+	}
+	err = os.MkdirAll(u.unitFilePath, 0755)
+	if err != nil {
+		return fmt.Errorf("cannot create the LaunchAgents path '%s': %w", u.unitFilePath, err)
+	}
+	return nil
+}
+
+// PlistFilename returns the full path to the launchd plist of a unit
+// deployed with launchd.
+func (u Unit) PlistFilename() string {
+	return filepath.Join(u.unitFilePath, u.launchdLabel+".plist")
+}
+
+func (launchd) files(u Unit) []unitFile {
+	return []unitFile{{u.PlistFilename(), u.writePlist}}
+}
+
+// plistData is the data for the launchd plist template. All the strings
+// are XML escaped.
+type plistData struct {
+	Label             string
+	ProgramArguments  []string
+	WorkingDirectory  string
+	Environment       []envVar
+	UserName          string
+	GroupName         string
+	StandardOutPath   string
+	StandardErrorPath string
+	KeepAlive         string // always, on-failure or empty
+}
+
+func (u Unit) writePlist(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	data := plistData{
+		Label:             xmlEscape(u.launchdLabel),
+		WorkingDirectory:  xmlEscape(u.binaryPath),
+		UserName:          xmlEscape(u.serviceDirective("User")),
+		GroupName:         xmlEscape(u.serviceDirective("Group")),
+		StandardOutPath:   xmlEscape(launchdOutputPath(u.serviceDirective("StandardOutput"))),
+		StandardErrorPath: xmlEscape(launchdOutputPath(u.serviceDirective("StandardError"))),
+	}
+	for _, arg := range u.command() {
+		data.ProgramArguments = append(data.ProgramArguments, xmlEscape(arg))
+	}
+	for _, v := range u.environment() {
+		data.Environment = append(data.Environment, envVar{Key: xmlEscape(v.Key), Value: xmlEscape(v.Value)})
+	}
+	switch u.serviceDirective("Restart") {
+	case "always":
+		data.KeepAlive = "always"
+	case "on-failure", "on-abnormal", "on-abort", "on-watchdog":
+		data.KeepAlive = "on-failure"
+	}
+	return t.ExecuteTemplate(f, "launchd.plist", data)
+}
+
+// launchdOutputPath returns the file for a StandardOutput= or
+// StandardError= value, or an empty string to leave it unset.
+func launchdOutputPath(value string) string {
+	if value == "null" {
+		return "/dev/null"
+	}
+	file, _ := outputFile(value)
+	return file
+}
+
+// xmlEscape escapes a string for use as XML text.
+func xmlEscape(s string) string {
+	b := strings.Builder{}
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// launchdDomain returns the launchctl domain the job is loaded into.
+func (u Unit) launchdDomain() string {
+	if u.scope == ScopeSystem {
+		return "system"
+	}
+	return "gui/" + strconv.Itoa(os.Getuid())
+}
+
+// launchctl runs launchctl with the given arguments.
+func (u Unit) launchctl(args ...string) error {
+	return u.runPrivileged(u.toolPath, args...)
+}
+
+// launchdLoaded returns true if the job is loaded.
+func (u Unit) launchdLoaded() bool {
+	return u.launchctl("print", u.launchdDomain()+"/"+u.launchdLabel) == nil
+}
+
+func (launchd) start(u Unit, changed bool) error {
+	loaded := u.launchdLoaded()
+	if loaded && (changed || u.alwaysRestart) {
+		// launchd only reads the plist when the job is loaded
+		err := u.launchctl("bootout", u.launchdDomain()+"/"+u.launchdLabel)
+		if err != nil {
+			return err
+		}
+		loaded = false
+	}
+	if loaded {
+		return nil
+	}
+	return u.launchctl("bootstrap", u.launchdDomain(), u.PlistFilename())
+}
+
+func (launchd) undeploy(u Unit) error {
+	if u.launchdLoaded() {
+		err := u.launchctl("bootout", u.launchdDomain()+"/"+u.launchdLabel)
+		if err != nil {
+			return err
+		}
+	}
+	err := u.removeFile(u.PlistFilename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (launchd) control(u Unit, command string) error {
+	target := u.launchdDomain() + "/" + u.launchdLabel
+	switch command {
+	case "enable", "disable":
+		return u.launchctl(command, target)
+	case "start":
+		return u.launchctl("kickstart", target)
+	case "stop":
+		return u.launchctl("kill", "SIGTERM", target)
+	case "restart":
+		return u.launchctl("kickstart", "-k", target)
+	}
+	return notSupported(BackendLaunchd, command)
+}
+
+func (launchd) status(u Unit) (Status, error) {
+	out, err := u.runOutput(u.toolPath, "print", u.launchdDomain()+"/"+u.launchdLabel)
+	if err != nil {
+		return backendStatus(false, false), nil
+	}
+	return backendStatus(true, strings.Contains(out, "state = running")), nil
+}
+
+func (launchd) kind(u Unit) Backend {
+	return BackendLaunchd
+}
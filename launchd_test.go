@@ -0,0 +1,83 @@
+package unitard
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLaunchd(t *testing.T) {
+	// print fails until the job has been bootstrapped
+	dir := t.TempDir()
+	launchctl, log := fakeTool(t, "launchctl", `case "$1" in
+print) [ -e `+dir+`/loaded ] ;;
+bootstrap) touch `+dir+`/loaded ;;
+bootout) rm `+dir+`/loaded ;;
+esac`)
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", binaryPath: "/fullpath/to", binaryArgs: `-name "a & b"`,
+		backend: launchd{}, launchdLabel: "com.example.test", toolPath: launchctl, unitFilePath: dir}
+	u.addDirective(SectionService, "Environment", "FOO=bar")
+	u.addDirective(SectionService, "Restart", "on-failure")
+	u.addDirective(SectionService, "StandardOutput", "append:/var/log/test.log")
+
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	content, err := os.ReadFile(dir + "/com.example.test.plist")
+	if err != nil {
+		t.Fatalf("plist was not written: %s", err)
+	}
+	for _, want := range []string{
+		"<string>com.example.test</string>",
+		"<string>/fullpath/to/foobar</string>\n\t\t<string>-name</string>\n\t\t<string>a &amp; b</string>",
+		"<key>FOO</key>\n\t\t<string>bar</string>",
+		"<key>SuccessfulExit</key>",
+		"<key>StandardOutPath</key>\n\t<string>/var/log/test.log</string>",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("plist does not contain %q:\n%s", want, content)
+		}
+	}
+
+	// unchanged, and already loaded
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("second deploy failed: %s", err)
+	}
+	u.binaryArgs = "-changed"
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("third deploy failed: %s", err)
+	}
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if _, err := os.Stat(u.PlistFilename()); !os.IsNotExist(err) {
+		t.Error("plist was not removed")
+	}
+
+	domain := "gui/" + strconv.Itoa(os.Getuid())
+	job := domain + "/com.example.test"
+	want := strings.Join([]string{
+		"print " + job, "bootstrap " + domain + " " + u.PlistFilename(),
+		"print " + job,
+		"print " + job, "bootout " + job, "bootstrap " + domain + " " + u.PlistFilename(),
+		"print " + job, "bootout " + job,
+	}, "\n") + "\n"
+	if commands, _ := os.ReadFile(log); string(commands) != want {
+		t.Errorf("wrong commands:\n%s", commands)
+	}
+}
+
+func TestLaunchdOptions(t *testing.T) {
+	if err := (OptLaunchd{Label: "bad label"}).Apply(&Unit{}); err == nil {
+		t.Error("bad label should fail")
+	}
+	u := Unit{name: "test_unit", dryRun: true, triggers: []trigger{OptTimer{OnCalendar: "daily"}}}
+	if err := (launchd{}).setup(&u); err == nil {
+		t.Error("timers should fail")
+	}
+	u = Unit{name: "test_unit", dryRun: true}
+	if err := (launchd{}).setup(&u); err != nil || u.launchdLabel != "test_unit" || !strings.HasSuffix(u.unitFilePath, "Library/LaunchAgents") {
+		t.Errorf("wrong setup %v %+v", err, u)
+	}
+}
@@ -0,0 +1,88 @@
+package unitard
+
+import (
+	"fmt"
+)
+
+// Enable enables the service (or its triggers) to start at boot, without
+// starting it.
+func (u Unit) Enable() error {
+	return u.eachActiveUnit("enable")
+}
+
+// Disable stops the service (or its triggers) from starting at boot, without
+// stopping it.
+func (u Unit) Disable() error {
+	return u.eachActiveUnit("disable")
+}
+
+// Start starts the service, or its triggers if it has any.
+func (u Unit) Start() error {
+	return u.eachActiveUnit("start")
+}
+
+// Stop stops the service. If it has triggers, they are stopped first so
+// that the service is not started again.
+func (u Unit) Stop() error {
+	err := u.eachActiveUnit("stop")
+	if err != nil {
+		return err
+	}
+	if len(u.triggers) > 0 && u.backend == nil {
+		return u.systemctl("stop", u.serviceName())
+	}
+	return nil
+}
+
+// Restart restarts the service, or its triggers if it has any.
+func (u Unit) Restart() error {
+	return u.eachActiveUnit("restart")
+}
+
+// Reload asks the running service to reload its configuration. The service
+// must support reloading (with an ExecReload= directive).
+func (u Unit) Reload() error {
+	err := u.checkRunnable("reload")
+	if err != nil {
+		return err
+	}
+	if u.backend != nil {
+		return u.backend.control(u, "reload")
+	}
+	return u.systemctl("reload", u.serviceName())
+}
+
+// checkRunnable returns an error if the unit is a template, which cannot
+// be started or stopped itself.
+func (u Unit) checkRunnable(command string) error {
+	if !u.instances {
+		return nil
+	}
+	err := u.checkInstance()
+	if err != nil {
+		return err
+	}
+	if u.isTemplate() {
+		return fmt.Errorf("cannot %s a template unit - choose an Instance", command)
+	}
+	return nil
+}
+
+// eachActiveUnit runs a systemctl command against each active unit, or
+// has the backend run it.
+func (u Unit) eachActiveUnit(command string) error {
+	err := u.checkRunnable(command)
+	if err != nil {
+		return err
+	}
+	if u.backend != nil {
+		return u.backend.control(u, command)
+	}
+	for _, unit := range u.activeUnits() {
+		err := u.systemctl(command, unit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
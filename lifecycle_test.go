@@ -0,0 +1,26 @@
+package unitard
+
+import (
+	"testing"
+)
+
+func TestCheckRunnable(t *testing.T) {
+	if err := (Unit{name: "test_unit"}).checkRunnable("start"); err != nil {
+		t.Errorf("plain unit should be runnable: %s", err)
+	}
+
+	u := Unit{name: "test_unit"}
+	_ = OptInstances{}.Apply(&u)
+	if u.Reload() == nil {
+		t.Error("should not be able to reload the template")
+	}
+	if u.Restart() == nil {
+		t.Error("should not be able to restart the template")
+	}
+	if err := u.Instance("blue").checkRunnable("start"); err != nil {
+		t.Errorf("instance should be runnable: %s", err)
+	}
+	if u.Instance("a/b").checkRunnable("start") == nil {
+		t.Error("badly named instance should not be runnable")
+	}
+}
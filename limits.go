@@ -0,0 +1,117 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// OptResourceLimits caps the resources the service may use. Empty fields
+// are left at the systemd defaults. Limits are validated before they are
+// rendered into the unit.
+//
+// Memory and CPU limits on user units need cgroup delegation, which most
+// modern distributions enable by default.
+type OptResourceLimits struct {
+	MemoryMax   string // bytes with optional K, M, G or T suffix, a percentage of RAM, or "infinity"
+	CPUQuota    string // percentage of one CPU, eg "50%" or "200%"
+	TasksMax    string // number of tasks, a percentage of the system limit, or "infinity"
+	LimitNOFILE string // number of open files, "infinity", or "soft:hard"
+}
+
+var (
+	bytesRegexp   = regexp.MustCompile(`^[0-9]+[KMGT]?$`)
+	percentRegexp = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?)%$`)
+	numberRegexp  = regexp.MustCompile(`^[0-9]+$`)
+)
+
+func (o OptResourceLimits) Apply(u *Unit) error {
+	limits := []struct {
+		key      string
+		value    string
+		validate func(string) error
+	}{
+		{"MemoryMax", o.MemoryMax, validateMemory},
+		{"CPUQuota", o.CPUQuota, validateCPUQuota},
+		{"TasksMax", o.TasksMax, validateTasks},
+		{"LimitNOFILE", o.LimitNOFILE, validateNOFILE},
+	}
+	set := false
+	for _, l := range limits {
+		if l.value == "" {
+			continue
+		}
+		err := l.validate(l.value)
+		if err != nil {
+			return fmt.Errorf("bad %s '%s': %w", l.key, l.value, err)
+		}
+		err = u.addDirective(SectionService, l.key, l.value)
+		if err != nil {
+			return err
+		}
+		set = true
+	}
+	if !set {
+		return errors.New("OptResourceLimits needs at least one limit")
+	}
+	return nil
+}
+
+// validatePercent checks a percentage is within range, if it is one.
+// It returns false if the value is not a percentage at all.
+func validatePercent(value string, max float64) (bool, error) {
+	m := percentRegexp.FindStringSubmatch(value)
+	if m == nil {
+		return false, nil
+	}
+	p, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return true, err
+	}
+	if p <= 0 || (max > 0 && p > max) {
+		return true, fmt.Errorf("percentage out of range")
+	}
+	return true, nil
+}
+
+func validateMemory(value string) error {
+	if value == "infinity" || bytesRegexp.MatchString(value) {
+		return nil
+	}
+	if ok, err := validatePercent(value, 100); ok {
+		return err
+	}
+	return errors.New("must be bytes (with optional K, M, G or T suffix), a percentage or infinity")
+}
+
+func validateCPUQuota(value string) error {
+	if ok, err := validatePercent(value, 0); ok {
+		return err
+	}
+	return errors.New("must be a percentage")
+}
+
+func validateTasks(value string) error {
+	if value == "infinity" || numberRegexp.MatchString(value) {
+		return nil
+	}
+	if ok, err := validatePercent(value, 100); ok {
+		return err
+	}
+	return errors.New("must be a number, a percentage or infinity")
+}
+
+func validateNOFILE(value string) error {
+	parts := strings.Split(value, ":")
+	if len(parts) > 2 {
+		return errors.New("must be a number, infinity or soft:hard")
+	}
+	for _, p := range parts {
+		if p != "infinity" && !numberRegexp.MatchString(p) {
+			return errors.New("must be a number, infinity or soft:hard")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package unitard
+
+import (
+	"testing"
+)
+
+func TestResourceLimits(t *testing.T) {
+	valid := []OptResourceLimits{
+		{MemoryMax: "512M"},
+		{MemoryMax: "1073741824"},
+		{MemoryMax: "25%"},
+		{MemoryMax: "infinity"},
+		{CPUQuota: "50%"},
+		{CPUQuota: "250%"},
+		{TasksMax: "100"},
+		{TasksMax: "10.5%"},
+		{LimitNOFILE: "65536"},
+		{LimitNOFILE: "1024:infinity"},
+	}
+	invalid := []OptResourceLimits{
+		{},
+		{MemoryMax: "512MB"},
+		{MemoryMax: "150%"},
+		{CPUQuota: "50"},
+		{CPUQuota: "0%"},
+		{TasksMax: "lots"},
+		{LimitNOFILE: "1:2:3"},
+		{LimitNOFILE: "-1"},
+	}
+
+	for _, v := range valid {
+		if err := v.Apply(&Unit{}); err != nil {
+			t.Errorf("%+v should be valid: %s", v, err)
+		}
+	}
+	for _, i := range invalid {
+		if i.Apply(&Unit{}) == nil {
+			t.Errorf("%+v should not be valid", i)
+		}
+	}
+
+	u := Unit{}
+	_ = OptResourceLimits{MemoryMax: "1G", CPUQuota: "20%"}.Apply(&u)
+	d := u.sectionDirectives(SectionService)
+	if len(d) != 2 || d[0].Key != "MemoryMax" || d[1].Value != "20%" {
+		t.Errorf("wrong directives %+v", d)
+	}
+}
@@ -0,0 +1,96 @@
+package unitard
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// LingerError is returned by NewUnit with OptLinger when lingering is not
+// enabled for the user, so their services are stopped when they log out.
+// It matches ErrLingerDisabled.
+type LingerError struct {
+	UID string // the user's id
+}
+
+func (e *LingerError) Error() string {
+	return fmt.Sprintf("lingering is not enabled for user %s, so their services stop when they log out; run 'loginctl enable-linger' (or use OptLinger with Enable)", e.UID)
+}
+
+func (e *LingerError) Is(target error) bool {
+	return target == ErrLingerDisabled
+}
+
+// OptLinger makes sure a user scope service keeps running after the user
+// logs out (and starts at boot, rather than at login), which needs
+// lingering to be enabled for the user with loginctl. Without Enable,
+// NewUnit returns a *LingerError if it isn't; with Enable, NewUnit enables
+// it. Lingering is only checked where systemd-logind is running.
+//
+// Without OptLinger, a warning is logged (see OptLogger) if lingering is
+// disabled.
+type OptLinger struct {
+	Enable bool // enable lingering if it is disabled, instead of returning an error
+}
+
+func (o OptLinger) Apply(u *Unit) error {
+	u.linger = true
+	u.enableLinger = o.Enable
+	return nil
+}
+
+// loginctlPath is the loginctl command, found on the remote host with
+// OptRemote.
+func (u Unit) loginctlPath() (string, bool) {
+	if u.remote != nil {
+		return "loginctl", true
+	}
+	path, err := exec.LookPath("loginctl")
+	return path, err == nil
+}
+
+// lingering returns whether lingering is enabled for the user, and false
+// for known if it can't be told.
+func (u Unit) lingering(uid string) (enabled, known bool) {
+	loginctl, ok := u.loginctlPath()
+	if !ok {
+		return false, false
+	}
+	out, err := u.runOutput(loginctl, "show-user", uid, "--property=Linger")
+	if err != nil {
+		return false, false
+	}
+	linger, ok := parseProperties(out)["Linger"]
+	return linger == "yes", ok
+}
+
+// checkLinger checks lingering is enabled for a user scope unit, enabling
+// it with OptLinger's Enable. uid is the user, or the current user if
+// empty.
+func (u Unit) checkLinger(uid string) error {
+	if !u.linger && u.logger == nil {
+		return nil
+	}
+	if uid == "" {
+		uid = strconv.Itoa(os.Getuid())
+	}
+	enabled, known := u.lingering(uid)
+	if enabled || !known {
+		return nil
+	}
+	switch {
+	case !u.linger:
+		u.log(slog.LevelWarn, "lingering is disabled, the service will stop when you log out", "uid", uid)
+		return nil
+	case !u.enableLinger:
+		return &LingerError{UID: uid}
+	}
+	loginctl, _ := u.loginctlPath()
+	err := u.runExpectZero(loginctl, "enable-linger", uid)
+	if err != nil {
+		return fmt.Errorf("could not enable lingering: %w", err)
+	}
+	return nil
+}
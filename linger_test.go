@@ -0,0 +1,63 @@
+package unitard
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLinger(t *testing.T) {
+	tools := t.TempDir()
+	log := filepath.Join(tools, "log")
+	os.WriteFile(filepath.Join(tools, "loginctl"), []byte(`#!/bin/sh
+echo "$@" >> `+log+`
+[ "$1" = enable-linger ] && touch `+tools+`/lingering
+[ -e `+tools+`/lingering ] && echo Linger=yes || echo Linger=no
+`), 0700)
+	t.Setenv("PATH", tools+":"+os.Getenv("PATH"))
+
+	u := Unit{name: "test_unit"}
+	if err := u.checkLinger("1000"); err != nil {
+		t.Errorf("lingering should not be checked without OptLinger or a logger: %s", err)
+	}
+	if _, err := os.Stat(log); !os.IsNotExist(err) {
+		t.Error("loginctl was run")
+	}
+
+	logged := bytes.NewBuffer(nil)
+	u.logger = slog.New(slog.NewTextHandler(logged, nil))
+	if err := u.checkLinger("1000"); err != nil || !strings.Contains(logged.String(), "lingering is disabled") {
+		t.Errorf("a warning should be logged: %v %q", err, logged)
+	}
+
+	u = Unit{name: "test_unit"}
+	(OptLinger{}).Apply(&u)
+	err := u.checkLinger("1000")
+	var lingerErr *LingerError
+	if !errors.As(err, &lingerErr) || !errors.Is(err, ErrLingerDisabled) || lingerErr.UID != "1000" {
+		t.Errorf("expected a *LingerError, got %v", err)
+	}
+
+	(OptLinger{Enable: true}).Apply(&u)
+	if err := u.checkLinger("1000"); err != nil {
+		t.Errorf("could not enable lingering: %s", err)
+	}
+	if err := u.checkLinger("1000"); err != nil {
+		t.Errorf("lingering should now be enabled: %s", err)
+	}
+	want := "show-user 1000 --property=Linger\nshow-user 1000 --property=Linger\nshow-user 1000 --property=Linger\nenable-linger 1000\nshow-user 1000 --property=Linger\n"
+	if commands, _ := os.ReadFile(log); string(commands) != want {
+		t.Errorf("wrong commands:\n%s", commands)
+	}
+
+	// no logind, no check
+	os.WriteFile(filepath.Join(tools, "loginctl"), []byte("#!/bin/sh\nexit 1\n"), 0700)
+	os.Remove(filepath.Join(tools, "lingering"))
+	if err := u.checkLinger("1000"); err != nil {
+		t.Errorf("lingering can't be checked without logind: %s", err)
+	}
+}
@@ -0,0 +1,172 @@
+package unitard
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockPollInterval is how often a locked unit is tried again, with
+// OptLockWait.
+const lockPollInterval = 100 * time.Millisecond
+
+// DeployInProgressError is returned by Deploy, Undeploy and Upgrade when
+// another deploy of the same unit, by this or another process, holds its
+// lock. It matches ErrDeployInProgress.
+type DeployInProgressError struct {
+	Unit string // the unit's name
+	Lock string // the lock file
+	PID  int    // the process holding the lock, 0 if unknown
+}
+
+func (e *DeployInProgressError) Error() string {
+	msg := fmt.Sprintf("another deploy of '%s' is in progress", e.Unit)
+	if e.PID != 0 {
+		msg += fmt.Sprintf(" (by process %d)", e.PID)
+	}
+	return msg
+}
+
+func (e *DeployInProgressError) Is(target error) bool {
+	return target == ErrDeployInProgress
+}
+
+// OptLockWait makes Deploy, Undeploy and Upgrade wait up to Timeout for
+// another deploy of the unit to finish, instead of returning a
+// *DeployInProgressError straight away.
+type OptLockWait struct {
+	Timeout time.Duration
+}
+
+func (o OptLockWait) Apply(u *Unit) error {
+	if o.Timeout <= 0 {
+		return errors.New("OptLockWait needs a Timeout")
+	}
+	u.lockWait = o.Timeout
+	return nil
+}
+
+// systemLockDir is where root locks units, when /run exists.
+const systemLockDir = "/run/unitard"
+
+// lockDir returns a directory for lock files which only the deploying user
+// can write to, so nobody else can hold or replace the lock: /run/unitard
+// for root, the runtime directory for systemd user scope, or else a
+// unitard directory in the user's cache directory. As a result, a system
+// unit deployed by a user through sudo is only locked against that user's
+// other deploys.
+func (u Unit) lockDir() (string, error) {
+	dir := ""
+	if _, err := os.Stat(filepath.Dir(systemLockDir)); err == nil && os.Geteuid() == 0 {
+		dir = systemLockDir
+	} else if u.backend == nil && u.scope == ScopeUser && u.runtimeDir != "" {
+		dir = u.runtimeDir
+	} else if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); u.backend == nil && u.scope == ScopeUser && runtimeDir != "" {
+		dir = runtimeDir
+	} else {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("no directory for the lock file: %w", err)
+		}
+		dir = filepath.Join(cache, "unitard")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() || info.Mode().Perm()&0022 != 0 || !ownedByUs(info) {
+		return "", fmt.Errorf("sorry, lock directory '%s' can be written by other users", dir)
+	}
+	return dir, nil
+}
+
+// lockFilename returns the advisory lock file for the unit, shared by
+// every process of the user deploying it. The unit directory is part of
+// the name, so units of the same name deployed elsewhere don't share it.
+func (u Unit) lockFilename() (string, error) {
+	dir, err := u.lockDir()
+	if err != nil {
+		return "", err
+	}
+	unitType := "service"
+	if u.target {
+		unitType = "target"
+	} else if u.slice {
+		unitType = "slice"
+	}
+	sum := sha256.Sum256([]byte(u.unitFilePath))
+	return filepath.Join(dir, fmt.Sprintf("unitard-%s.%s-%x.lock", u.name, unitType, sum[:4])), nil
+}
+
+// lock takes the unit's lock for a deploy, returning the func which
+// releases it. Nothing is locked for dry runs, OptRoot (which only writes
+// files) or OptRemote (as the lock would be on the wrong host).
+func (u Unit) lock() (func(), error) {
+	if u.plan != nil || u.root != "" || u.remote != nil {
+		return func() {}, nil
+	}
+	name, err := u.lockFilename()
+	if err != nil {
+		return nil, fmt.Errorf("could not lock the unit: %w", err)
+	}
+	// never follow a link planted in place of the lock file
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|openNoFollow, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open lock file '%s': %w", name, err)
+	}
+	if info, err := f.Stat(); err != nil || !ownedByUs(info) {
+		f.Close()
+		return nil, fmt.Errorf("sorry, lock file '%s' is owned by another user", name)
+	}
+
+	deadline := time.Now().Add(u.lockWait)
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("could not lock '%s': %w", name, err)
+		}
+		if locked {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			pid, _ := readLockPID(f)
+			f.Close()
+			return nil, &DeployInProgressError{Unit: u.name, Lock: name, PID: pid}
+		}
+		select {
+		case <-u.context().Done():
+			f.Close()
+			return nil, u.context().Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	// record who holds it, for the error of the next deploy
+	if f.Truncate(0) == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return func() {
+		f.Truncate(0)
+		unlock(f)
+		f.Close()
+	}, nil
+}
+
+// readLockPID returns the process recorded in a lock file.
+func readLockPID(f *os.File) (int, error) {
+	buf := make([]byte, 32)
+	n, err := f.ReadAt(buf, 0)
+	if n == 0 {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package unitard
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openNoFollow makes OpenFile fail if the file is a symbolic link.
+const openNoFollow = syscall.O_NOFOLLOW
+
+// ownedByUs returns true if the file is owned by the effective user.
+func ownedByUs(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Geteuid()
+}
+
+// tryLock takes an exclusive flock on f, returning false if another open
+// file holds it.
+func tryLock(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlock releases the lock taken by tryLock.
+func unlock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
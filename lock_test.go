@@ -0,0 +1,88 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	systemctl, _ := fakeSystemctl(t, "")
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+
+	release, err := u.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = u.Deploy()
+	var lockErr *DeployInProgressError
+	if !errors.Is(err, ErrDeployInProgress) || !errors.As(err, &lockErr) || lockErr.PID != os.Getpid() {
+		t.Fatalf("expected a DeployInProgressError with our pid, got %v", err)
+	}
+	if err := u.Undeploy(); !errors.Is(err, ErrDeployInProgress) {
+		t.Errorf("undeploy should be locked too, got %v", err)
+	}
+
+	// the same unit elsewhere isn't locked
+	other := u
+	other.unitFilePath = t.TempDir()
+	if err := other.Deploy(); err != nil {
+		t.Errorf("unit in another directory should not be locked: %v", err)
+	}
+
+	// waiting for the lock
+	(OptLockWait{Timeout: 5 * time.Second}).Apply(&u)
+	go func() {
+		time.Sleep(2 * lockPollInterval)
+		release()
+	}()
+	if err := u.Deploy(); err != nil {
+		t.Errorf("deploy should have waited for the lock: %v", err)
+	}
+
+	if err := (OptLockWait{}).Apply(&u); err == nil {
+		t.Error("OptLockWait without a Timeout should be rejected")
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	u := Unit{name: "test_unit", unitFilePath: t.TempDir(), backend: cron{}}
+	name, err := u.lockFilename()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(name, os.TempDir()+string(os.PathSeparator)+"unitard-") {
+		t.Errorf("lock file %s is shared with other users", name)
+	}
+
+	// a link planted in place of the lock file is not followed
+	target := filepath.Join(t.TempDir(), "target")
+	os.WriteFile(target, []byte("keep"), 0644)
+	if err := os.Symlink(target, name); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(name)
+	if _, err := u.lock(); err == nil {
+		t.Error("lock should not follow a link")
+	}
+	if content, _ := os.ReadFile(target); string(content) != "keep" {
+		t.Errorf("link was followed: %q", content)
+	}
+
+	// nor is a lock file someone else created used
+	if os.Geteuid() != 0 {
+		return
+	}
+	os.Remove(name)
+	os.WriteFile(name, nil, 0666)
+	os.Chown(name, 1000, 1000)
+	if _, err := u.lock(); err == nil {
+		t.Error("lock file of another user should be refused")
+	}
+}
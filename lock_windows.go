@@ -0,0 +1,34 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// openNoFollow is not needed on Windows, where the lock files are in the
+// user's own directory.
+const openNoFollow = 0
+
+// ownedByUs returns true, as the lock directory in the user's profile is
+// private.
+func ownedByUs(info os.FileInfo) bool {
+	return true
+}
+
+// tryLock takes an exclusive lock on f, returning false if another open
+// file holds it.
+func tryLock(f *os.File) (bool, error) {
+	ol := new(windows.Overlapped)
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlock releases the lock taken by tryLock.
+func unlock(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, new(windows.Overlapped))
+}
@@ -0,0 +1,156 @@
+package unitard
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// LogOptions selects which journal entries Logs returns.
+type LogOptions struct {
+	Follow bool      // keep the stream open, returning new entries as they are logged
+	Since  time.Time // only entries at or after this time, if set
+	Until  time.Time // only entries at or before this time, if set
+	Lines  int       // only the last n entries, if set
+	JSON   bool      // one JSON object per line, for use with NewLogDecoder
+}
+
+// journalTimeFormat is a timestamp format understood by journalctl.
+const journalTimeFormat = "2006-01-02 15:04:05"
+
+// Logs returns the service's journal, as read by journalctl. Closing the
+// returned reader stops journalctl, which is required when following.
+// With OptRemote, journalctl is run on the host.
+func (u Unit) Logs(opts LogOptions) (io.ReadCloser, error) {
+	if u.isTemplate() {
+		return nil, errors.New("cannot get the logs of a template unit - choose an Instance")
+	}
+	journalctl, err := u.lookTool("journalctl")
+	if err != nil {
+		return nil, fmt.Errorf("could not find journalctl: %w", err)
+	}
+
+	command, args := journalctl, u.logArgs(opts)
+	if u.remote != nil {
+		command, args = u.remote.command(command, args)
+	}
+	cmd := exec.CommandContext(u.context(), command, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("could not start journalctl: %w", err)
+	}
+	return &logReader{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+// logArgs returns the journalctl arguments for the options.
+func (u Unit) logArgs(opts LogOptions) []string {
+	args := []string{"-u", u.serviceName(), "--no-pager", "--quiet"}
+	if u.scope == ScopeUser {
+		args = append([]string{"--user"}, args...)
+	}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if !opts.Since.IsZero() {
+		args = append(args, "--since", opts.Since.Local().Format(journalTimeFormat))
+	}
+	if !opts.Until.IsZero() {
+		args = append(args, "--until", opts.Until.Local().Format(journalTimeFormat))
+	}
+	if opts.Lines > 0 {
+		args = append(args, "--lines", strconv.Itoa(opts.Lines))
+	}
+	if opts.JSON {
+		args = append(args, "--output", "json")
+	}
+	return args
+}
+
+// logReader stops journalctl when it is closed.
+type logReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (l *logReader) Close() error {
+	_ = l.cmd.Process.Kill()
+	l.ReadCloser.Close()
+	_ = l.cmd.Wait()
+	return nil
+}
+
+// LogEntry is a single journal entry.
+type LogEntry struct {
+	Time     time.Time
+	Message  string
+	Priority int // syslog priority, 0 (emerg) to 7 (debug)
+	PID      int
+	Fields   map[string]string // all fields of the entry
+}
+
+// LogDecoder reads journal entries from the output of Logs with
+// LogOptions.JSON set.
+type LogDecoder struct {
+	r *bufio.Reader
+}
+
+// NewLogDecoder returns a decoder reading from r.
+func NewLogDecoder(r io.Reader) *LogDecoder {
+	return &LogDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode returns the next entry, or io.EOF when there are no more.
+func (d *LogDecoder) Decode() (LogEntry, error) {
+	line, err := d.r.ReadBytes('\n')
+	if len(line) == 0 && err != nil {
+		return LogEntry{}, err
+	}
+	return parseLogEntry(line)
+}
+
+func parseLogEntry(line []byte) (LogEntry, error) {
+	raw := map[string]json.RawMessage{}
+	err := json.Unmarshal(line, &raw)
+	if err != nil {
+		return LogEntry{}, fmt.Errorf("bad journal entry: %w", err)
+	}
+
+	entry := LogEntry{Fields: map[string]string{}}
+	for k, v := range raw {
+		entry.Fields[k] = journalField(v)
+	}
+	entry.Message = entry.Fields["MESSAGE"]
+	entry.Priority, _ = strconv.Atoi(entry.Fields["PRIORITY"])
+	entry.PID, _ = strconv.Atoi(entry.Fields["_PID"])
+	if us, err := strconv.ParseInt(entry.Fields["__REALTIME_TIMESTAMP"], 10, 64); err == nil {
+		entry.Time = time.UnixMicro(us)
+	}
+	return entry, nil
+}
+
+// journalField converts a field from journalctl's JSON output. Fields are
+// usually strings, but non-printable values are arrays of bytes.
+func journalField(v json.RawMessage) string {
+	var s string
+	if json.Unmarshal(v, &s) == nil {
+		return s
+	}
+	var b []byte
+	var ints []int
+	if json.Unmarshal(v, &ints) == nil {
+		for _, i := range ints {
+			b = append(b, byte(i))
+		}
+		return string(b)
+	}
+	return string(v)
+}
@@ -0,0 +1,53 @@
+package unitard
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogArgs(t *testing.T) {
+	u := Unit{name: "test_unit"}
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	args := strings.Join(u.logArgs(LogOptions{Follow: true, Since: since, Lines: 20, JSON: true}), " ")
+
+	for _, want := range []string{"--user -u test_unit", "--follow", "--since 2026-01-02 03:04:05", "--lines 20", "--output json"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args %q do not contain %q", args, want)
+		}
+	}
+	if strings.Contains(args, "--until") {
+		t.Error("args contain unset --until")
+	}
+}
+
+func TestLogDecoder(t *testing.T) {
+	in := `{"__REALTIME_TIMESTAMP":"1700000000123456","MESSAGE":"hello","PRIORITY":"3","_PID":"42"}
+{"MESSAGE":[104,105,7],"PRIORITY":"6"}
+`
+	d := NewLogDecoder(strings.NewReader(in))
+
+	e, err := d.Decode()
+	if err != nil {
+		t.Fatalf("could not decode: %s", err)
+	}
+	if e.Message != "hello" || e.Priority != 3 || e.PID != 42 {
+		t.Errorf("wrong entry %+v", e)
+	}
+	if e.Time.UnixMicro() != 1700000000123456 {
+		t.Errorf("wrong time %s", e.Time)
+	}
+
+	e, err = d.Decode()
+	if err != nil {
+		t.Fatalf("could not decode: %s", err)
+	}
+	if e.Message != "hi\a" {
+		t.Errorf("wrong binary message %q", e.Message)
+	}
+
+	if _, err = d.Decode(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
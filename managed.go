@@ -0,0 +1,165 @@
+package unitard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/tardisx/unitard/unitfile"
+)
+
+// Keys of the marker unitard adds to the [Unit] section of the files it
+// writes. systemd ignores keys starting with X-.
+const (
+	markerManaged    = "X-Unitard-Managed"
+	markerVersion    = "X-Unitard-Version"
+	markerBinaryHash = "X-Unitard-Binary-Hash"
+	// markerHandlerFor marks a unit which is part of another, such as its
+	// OptOnFailure handler, so isn't listed itself
+	markerHandlerFor = "X-Unitard-Handler-For"
+)
+
+// ManagedUnit is a unit deployed by unitard, found by ListManagedUnits.
+type ManagedUnit struct {
+	Name       string // the name passed to NewUnit
+	File       string // full path to the service unit file, or the drop-in
+	DropIn     bool   // File is a drop-in created with OptDropIn
+	Instances  bool   // deployed with OptInstances
+	Scope      Scope
+	ExecStart  string // the command the service runs, if set by unitard
+	Version    string // version of the program that deployed it
+	BinaryHash string // sha256 of the binary when it was deployed
+}
+
+// OptVersion sets the version recorded in the unit files. By default the
+// main module version from the build info is used.
+type OptVersion struct {
+	Version string
+}
+
+func (o OptVersion) Apply(u *Unit) error {
+	if strings.ContainsAny(o.Version, "\r\n") {
+		return fmt.Errorf("sorry, version '%s' is not valid", o.Version)
+	}
+	u.version = o.Version
+	return nil
+}
+
+// buildVersion returns the version of the main module, if known.
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	return info.Main.Version
+}
+
+// fileHash returns the hex encoded sha256 of a file, or an empty string if
+// it can't be read.
+func fileHash(name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// markerDirectives returns the [Unit] directives which mark a file as
+// managed by unitard.
+func (u Unit) markerDirectives() []Directive {
+	directives := []Directive{{Section: SectionUnit, Key: markerManaged, Value: "yes"}}
+	if u.version != "" {
+		directives = append(directives, Directive{Section: SectionUnit, Key: markerVersion, Value: u.version})
+	}
+	if u.binaryHash != "" {
+		directives = append(directives, Directive{Section: SectionUnit, Key: markerBinaryHash, Value: u.binaryHash})
+	}
+	return directives
+}
+
+// ListManagedUnits returns the units deployed by unitard in the unit
+// directory of the scope, sorted by name.
+func ListManagedUnits(scope Scope) ([]ManagedUnit, error) {
+	dir, err := scope.unitDirectory()
+	if err != nil {
+		return nil, err
+	}
+	return listManagedUnits(scope, dir)
+}
+
+func listManagedUnits(scope Scope, dir string) ([]ManagedUnit, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.service"))
+	if err != nil {
+		return nil, err
+	}
+	dropIns, err := filepath.Glob(filepath.Join(dir, "*.service.d", "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+
+	units := []ManagedUnit{}
+	for _, name := range append(files, dropIns...) {
+		m, ok, err := readManagedUnit(name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m.Scope = scope
+			units = append(units, m)
+		}
+	}
+	sort.SliceStable(units, func(i, j int) bool { return units[i].Name < units[j].Name })
+	return units, nil
+}
+
+// readManagedUnit reads the marker from a unit file, returning false if it
+// is not managed by unitard.
+func readManagedUnit(name string) (ManagedUnit, bool, error) {
+	r, err := os.Open(name)
+	if os.IsNotExist(err) {
+		// a dangling symlink, or removed since the directory was read
+		return ManagedUnit{}, false, nil
+	} else if err != nil {
+		return ManagedUnit{}, false, fmt.Errorf("could not read unit file '%s': %w", name, err)
+	}
+	defer r.Close()
+	f, err := unitfile.Parse(r)
+	if err != nil {
+		// not one of ours, which are always valid
+		return ManagedUnit{}, false, nil
+	}
+	if v, _ := f.Get("Unit", markerManaged); v != "yes" {
+		return ManagedUnit{}, false, nil
+	}
+	if _, ok := f.Get("Unit", markerHandlerFor); ok {
+		// part of another unit
+		return ManagedUnit{}, false, nil
+	}
+
+	m := ManagedUnit{File: name}
+	service := filepath.Base(name)
+	if strings.HasSuffix(name, ".conf") {
+		m.DropIn = true
+		service = filepath.Base(filepath.Dir(name))
+	}
+	service = strings.TrimSuffix(strings.TrimSuffix(service, ".d"), ".service")
+	m.Instances = strings.HasSuffix(service, "@")
+	m.Name = strings.TrimSuffix(service, "@")
+	m.Version, _ = f.Get("Unit", markerVersion)
+	m.BinaryHash, _ = f.Get("Unit", markerBinaryHash)
+	if execStart := f.Values("Service", "ExecStart"); len(execStart) > 0 {
+		m.ExecStart = execStart[len(execStart)-1]
+	}
+	return m, true, nil
+}
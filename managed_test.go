@@ -0,0 +1,35 @@
+package unitard
+
+import (
+	"os"
+	"testing"
+)
+
+func TestListManagedUnits(t *testing.T) {
+	dir := t.TempDir()
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", binaryArgs: "-x", version: "v1.2.3", binaryHash: "abc123", systemCtlPath: "systemctl", unitFilePath: dir}
+	d := Unit{name: "other", binary: "/fullpath/to/other", dropIn: "unitard", dropInExecStart: true, systemCtlPath: "systemctl", unitFilePath: dir}
+	for _, unit := range []Unit{u, d} {
+		files, _ := unit.Render()
+		for name, content := range files {
+			os.MkdirAll(dir+"/other.service.d", 0700)
+			os.WriteFile(name, []byte(content), 0600)
+		}
+	}
+	os.WriteFile(dir+"/foreign.service", []byte("[Unit]\nDescription=not ours\n"), 0600)
+
+	units, err := listManagedUnits(ScopeUser, dir)
+	if err != nil {
+		t.Fatalf("could not list units: %s", err)
+	}
+	if len(units) != 2 {
+		t.Fatalf("expected 2 managed units, got %v", units)
+	}
+	other, test := units[0], units[1]
+	if test.Name != "test_unit" || test.Version != "v1.2.3" || test.BinaryHash != "abc123" || test.ExecStart != "/fullpath/to/foobar -x" || test.DropIn {
+		t.Errorf("wrong managed unit %+v", test)
+	}
+	if other.Name != "other" || !other.DropIn || other.ExecStart != "/fullpath/to/other" {
+		t.Errorf("wrong managed drop-in %+v", other)
+	}
+}
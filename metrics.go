@@ -0,0 +1,174 @@
+package unitard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// metricsProperties are the properties Metrics reads with systemctl show.
+var metricsProperties = []string{"ActiveState", "NRestarts", "MemoryCurrent", "CPUUsageNSec", "TasksCurrent"}
+
+// Metrics are resource and health figures for the service, from systemd.
+// Accounting figures are zero if systemd isn't tracking them, as for some
+// user units without cgroup delegation, and for other backends.
+type Metrics struct {
+	Unit          string    // the service
+	ActiveState   string    // eg "active" or "failed"
+	NRestarts     int       // number of automatic restarts
+	MemoryCurrent uint64    // bytes of memory in use
+	CPUUsageNSec  uint64    // nanoseconds of CPU time used
+	TasksCurrent  uint64    // number of processes and threads
+	Sampled       time.Time // when the figures were read
+}
+
+// Metrics reads the service's current metrics.
+func (u Unit) Metrics() (Metrics, error) {
+	if u.isTemplate() {
+		return Metrics{}, errors.New("cannot get the metrics of a template unit - choose an Instance")
+	}
+	m := Metrics{Unit: u.serviceName(), Sampled: time.Now()}
+	if u.backend != nil {
+		status, err := u.Status()
+		m.ActiveState, m.NRestarts = status.ActiveState, status.NRestarts
+		return m, err
+	}
+	props, err := u.show(u.serviceName(), metricsProperties...)
+	if err != nil {
+		return m, err
+	}
+	m.ActiveState = props["ActiveState"]
+	if v := props["NRestarts"]; v != "" {
+		m.NRestarts, err = strconv.Atoi(v)
+		if err != nil {
+			return m, fmt.Errorf("bad NRestarts '%s' from systemctl: %w", v, err)
+		}
+	}
+	counters := []struct {
+		key string
+		val *uint64
+	}{
+		{"MemoryCurrent", &m.MemoryCurrent},
+		{"CPUUsageNSec", &m.CPUUsageNSec},
+		{"TasksCurrent", &m.TasksCurrent},
+	}
+	for _, c := range counters {
+		v, err := strconv.ParseUint(props[c.key], 10, 64)
+		if err != nil || v == ^uint64(0) {
+			// "[not set]", or the value D-Bus means it by
+			continue
+		}
+		*c.val = v
+	}
+	return m, nil
+}
+
+// WritePrometheus writes the metrics in the Prometheus text format, with
+// a unit label.
+func (m Metrics) WritePrometheus(w io.Writer) error {
+	label := fmt.Sprintf("{unit=%q}", m.Unit)
+	active := 0
+	if m.ActiveState == "active" {
+		active = 1
+	}
+	_, err := fmt.Fprintf(w, `# HELP unitard_unit_active Whether the unit is active.
+# TYPE unitard_unit_active gauge
+unitard_unit_active%s %d
+# HELP unitard_unit_restarts_total Automatic restarts of the unit.
+# TYPE unitard_unit_restarts_total counter
+unitard_unit_restarts_total%s %d
+# HELP unitard_unit_memory_bytes Memory used by the unit.
+# TYPE unitard_unit_memory_bytes gauge
+unitard_unit_memory_bytes%s %d
+# HELP unitard_unit_cpu_seconds_total CPU time used by the unit.
+# TYPE unitard_unit_cpu_seconds_total counter
+unitard_unit_cpu_seconds_total%s %s
+# HELP unitard_unit_tasks Processes and threads in the unit.
+# TYPE unitard_unit_tasks gauge
+unitard_unit_tasks%s %d
+`, label, active, label, m.NRestarts, label, m.MemoryCurrent,
+		label, strconv.FormatFloat(float64(m.CPUUsageNSec)/1e9, 'f', -1, 64), label, m.TasksCurrent)
+	return err
+}
+
+// MetricsCollector samples a unit's Metrics in the background. It is an
+// http.Handler serving them in the Prometheus text format, for a /metrics
+// endpoint, and an expvar.Var, so it can be given to expvar.Publish.
+type MetricsCollector struct {
+	mu      sync.Mutex
+	metrics Metrics
+	err     error
+}
+
+// CollectMetrics samples the unit's Metrics every interval (15 seconds, if
+// zero) until ctx is done.
+func (u Unit) CollectMetrics(ctx context.Context, interval time.Duration) *MetricsCollector {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	u = u.withContext(ctx)
+	c := &MetricsCollector{}
+	c.sample(u)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.sample(u)
+			}
+		}
+	}()
+	return c
+}
+
+func (c *MetricsCollector) sample(u Unit) {
+	m, err := u.Metrics()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics, c.err = m, err
+}
+
+// Latest returns the last sample, and the error reading it if it failed.
+func (c *MetricsCollector) Latest() (Metrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.metrics, c.err
+}
+
+func (c *MetricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m, err := c.Latest()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WritePrometheus(w)
+}
+
+// String returns the last sample as JSON, for expvar.
+func (c *MetricsCollector) String() string {
+	m, err := c.Latest()
+	v := map[string]interface{}{
+		"unit":           m.Unit,
+		"active_state":   m.ActiveState,
+		"restarts":       m.NRestarts,
+		"memory_bytes":   m.MemoryCurrent,
+		"cpu_usage_nsec": m.CPUUsageNSec,
+		"tasks":          m.TasksCurrent,
+		"sampled":        m.Sampled,
+	}
+	if err != nil {
+		v["error"] = err.Error()
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
@@ -0,0 +1,48 @@
+package unitard
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	systemctl := filepath.Join(t.TempDir(), "systemctl")
+	os.WriteFile(systemctl, []byte("#!/bin/sh\necho ActiveState=active\necho NRestarts=2\necho MemoryCurrent=1048576\necho CPUUsageNSec=1500000000\necho 'TasksCurrent=[not set]'\n"), 0700)
+	u := Unit{name: "test_unit", systemCtlPath: systemctl}
+
+	m, err := u.Metrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Unit != "test_unit" || m.ActiveState != "active" || m.NRestarts != 2 || m.MemoryCurrent != 1048576 || m.CPUUsageNSec != 1500000000 || m.TasksCurrent != 0 {
+		t.Errorf("wrong metrics %+v", m)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := u.CollectMetrics(ctx, 0)
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, want := range []string{
+		`unitard_unit_active{unit="test_unit"} 1`,
+		`unitard_unit_restarts_total{unit="test_unit"} 2`,
+		`unitard_unit_memory_bytes{unit="test_unit"} 1048576`,
+		`unitard_unit_cpu_seconds_total{unit="test_unit"} 1.5`,
+	} {
+		if !strings.Contains(rec.Body.String(), want+"\n") {
+			t.Errorf("metrics are missing %s:\n%s", want, rec.Body)
+		}
+	}
+
+	var _ expvar.Var = c
+	v := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(c.String()), &v); err != nil || v["restarts"] != 2.0 {
+		t.Errorf("wrong expvar %s: %v", c.String(), err)
+	}
+}
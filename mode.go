@@ -0,0 +1,35 @@
+package unitard
+
+import (
+	"errors"
+)
+
+// DeployMode selects whether Deploy enables the unit to start at boot,
+// starts it now, or both.
+type DeployMode int
+
+const (
+	// DeployEnableAndStart enables the unit, then starts (or restarts) it.
+	// This is the default.
+	DeployEnableAndStart DeployMode = iota
+	// DeployEnableOnly enables the unit to start at boot, without starting it now.
+	DeployEnableOnly
+	// DeployStartOnly starts (or restarts) the unit now, without enabling it at boot.
+	DeployStartOnly
+	// DeployEnableNow uses `systemctl enable --now`, which starts the unit if
+	// it is not running, but does not restart it if it is.
+	DeployEnableNow
+)
+
+// OptDeployMode changes how Deploy enables and starts the unit.
+type OptDeployMode struct {
+	Mode DeployMode
+}
+
+func (o OptDeployMode) Apply(u *Unit) error {
+	if o.Mode < DeployEnableAndStart || o.Mode > DeployEnableNow {
+		return errors.New("unknown deploy mode")
+	}
+	u.deployMode = o.Mode
+	return nil
+}
@@ -0,0 +1,34 @@
+package unitard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeployModes(t *testing.T) {
+	tests := map[DeployMode]string{
+		DeployEnableAndStart: "enable test_unit\nrestart test_unit",
+		DeployEnableOnly:     "enable test_unit",
+		DeployStartOnly:      "restart test_unit",
+		DeployEnableNow:      "enable --now test_unit",
+	}
+	for mode, want := range tests {
+		u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", systemCtlPath: "systemctl", unitFilePath: t.TempDir()}
+		if err := (OptDeployMode{Mode: mode}).Apply(&u); err != nil {
+			t.Fatalf("failed to apply mode: %s", err)
+		}
+		plan, err := u.DryRun()
+		if err != nil {
+			t.Fatalf("dry run failed: %s", err)
+		}
+		commands := strings.ReplaceAll(strings.Join(plan.Commands(), "\n"), "systemctl --user ", "")
+		want = "daemon-reload\n" + want
+		if commands != want {
+			t.Errorf("mode %d: got\n%s\nwant\n%s", mode, commands, want)
+		}
+	}
+
+	if (OptDeployMode{Mode: DeployMode(42)}).Apply(&Unit{}) == nil {
+		t.Error("unknown mode should not be valid")
+	}
+}
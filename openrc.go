@@ -0,0 +1,236 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// openrcDirectory is where OpenRC init scripts are installed.
+const openrcDirectory = "/etc/init.d"
+
+// openrcRunlevel is the runlevel services are added to.
+const openrcRunlevel = "default"
+
+// openrcDirectives are the directives the OpenRC backend maps to the init
+// script. Others are ignored, with a warning logged.
+var openrcDirectives = map[string]bool{
+	markerManaged: true, "Environment": true, "Restart": true, "RestartSec": true, "User": true, "Group": true,
+	"StandardOutput": true, "StandardError": true,
+	"After": true, "Before": true, "Requires": true, "BindsTo": true, "Wants": true,
+}
+
+// openrcDependencies maps dependency directives to OpenRC's depend()
+// keywords.
+var openrcDependencies = []struct {
+	key     string
+	keyword string
+}{
+	{"Requires", "need"}, {"BindsTo", "need"}, {"Wants", "use"}, {"After", "after"}, {"Before", "before"},
+}
+
+// OptOpenRC deploys the unit as an OpenRC service, for Alpine, Gentoo and
+// other distributions without systemd. An init script is installed in
+// /etc/init.d and added to the default runlevel, so OpenRC services are
+// system wide and need OptScope with ScopeSystem.
+//
+// The program arguments, Environment, User and Group, file output from
+// OptOutput and the dependencies from OptDependencies are mapped to the
+// script (network.target and network-online.target become net). With
+// Restart set the service is run under supervise-daemon, which restarts it
+// if it exits. Timers, sockets and paths are not supported.
+type OptOpenRC struct{}
+
+func (o OptOpenRC) Apply(u *Unit) error {
+	u.backend = openrc{}
+	return nil
+}
+
+// openrc is the backend for OpenRC.
+type openrc struct{}
+
+func (openrc) setup(u *Unit) error {
+	err := u.checkBackendOptions("OpenRC")
+	if err != nil {
+		return err
+	}
+	if len(u.triggers) > 0 {
+		return errors.New("timers, sockets and paths cannot be used with OpenRC")
+	}
+	if u.scope != ScopeSystem {
+		return errors.New("OpenRC services are system wide, use OptScope with ScopeSystem")
+	}
+	u.warnUnsupported("OpenRC", openrcDirectives)
+
+	u.toolPath = "/sbin"
+	if path, err := exec.LookPath("rc-service"); err == nil {
+		u.toolPath = filepath.Dir(path)
+	} else if !u.dryRun {
+		return fmt.Errorf("rc-service not found: %w", err)
+	}
+	u.unitFilePath = openrcDirectory
+	if os.Getuid() != 0 && !u.dryRun {
+		escalate, err := u.escalation.command()
+		if err != nil {
+			return err
+		}
+		u.escalate = escalate
+	}
+	return nil
+}
+
+// InitScriptFilename returns the full path to the init script of a unit
+// deployed with OptOpenRC.
+func (u Unit) InitScriptFilename() string {
+	return filepath.Join(u.unitFilePath, u.name)
+}
+
+func (openrc) files(u Unit) []unitFile {
+	return []unitFile{{u.InitScriptFilename(), withChecksum(u.writeInitScript)}}
+}
+
+func (u Unit) writeInitScript(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	user := u.serviceDirective("User")
+	if group := u.serviceDirective("Group"); group != "" {
+		user += ":" + group
+	}
+	environment := []string{}
+	for _, v := range u.environment() {
+		environment = append(environment, v.Key+"="+shellQuote(v.Value))
+	}
+	respawnDelay := u.serviceDirective("RestartSec")
+	if respawnDelay == "" {
+		respawnDelay = "1"
+	}
+	output := func(key string) string {
+		file, _ := outputFile(u.serviceDirective(key))
+		if file == "" {
+			return ""
+		}
+		return shellQuote(file)
+	}
+	data := map[string]interface{}{
+		"Description":      shellQuote(u.name),
+		"Command":          shellQuote(u.binary),
+		"Args":             shellQuote(u.binaryArgs),
+		"WorkingDirectory": shellQuote(u.binaryPath),
+		"User":             "",
+		"Supervise":        u.serviceDirective("Restart") != "" && u.serviceDirective("Restart") != "no",
+		"RespawnDelay":     shellQuote(respawnDelay),
+		"OutputLog":        output("StandardOutput"),
+		"ErrorLog":         output("StandardError"),
+		"Environment":      environment,
+		"Depend":           u.openrcDepend(),
+	}
+	if user != "" {
+		data["User"] = shellQuote(user)
+	}
+	return t.ExecuteTemplate(f, "openrc", data)
+}
+
+// openrcDepend returns the lines of the init script's depend function.
+func (u Unit) openrcDepend() []string {
+	lines := []string{}
+	for _, dep := range openrcDependencies {
+		names := []string{}
+		for _, d := range u.sectionDirectives(SectionUnit) {
+			if d.Key != dep.key {
+				continue
+			}
+			for _, unit := range strings.Fields(d.Value) {
+				if name, ok := openrcService(unit); ok {
+					names = append(names, name)
+				}
+			}
+		}
+		if len(names) > 0 {
+			lines = append(lines, dep.keyword+" "+strings.Join(names, " "))
+		}
+	}
+	return lines
+}
+
+// openrcService returns the OpenRC service for a systemd unit name, if
+// there is one.
+func openrcService(unit string) (string, bool) {
+	switch unit {
+	case "network.target", "network-online.target":
+		return "net", true
+	}
+	if strings.HasSuffix(unit, ".service") {
+		return strings.TrimSuffix(unit, ".service"), true
+	}
+	return "", false
+}
+
+// rcCommand runs one of the OpenRC commands.
+func (u Unit) rcCommand(command string, args ...string) error {
+	return u.runPrivileged(filepath.Join(u.toolPath, command), args...)
+}
+
+func (openrc) start(u Unit, changed bool) error {
+	if changed {
+		// init scripts must be executable
+		err := u.runPrivileged("chmod", "0755", u.InitScriptFilename())
+		if err != nil {
+			return err
+		}
+	}
+	err := u.rcCommand("rc-update", "add", u.name, openrcRunlevel)
+	if err != nil {
+		return err
+	}
+	if !changed && !u.alwaysRestart && u.rcCommand("rc-service", u.name, "status") == nil {
+		return nil
+	}
+	// restart starts the service if it is stopped
+	return u.rcCommand("rc-service", u.name, "restart")
+}
+
+func (openrc) undeploy(u Unit) error {
+	if u.rcCommand("rc-service", u.name, "status") == nil {
+		err := u.rcCommand("rc-service", u.name, "stop")
+		if err != nil {
+			return err
+		}
+	}
+	// fails if the service was never added
+	u.rcCommand("rc-update", "del", u.name, openrcRunlevel)
+	err := u.removeFile(u.InitScriptFilename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (openrc) control(u Unit, command string) error {
+	switch command {
+	case "enable":
+		return u.rcCommand("rc-update", "add", u.name, openrcRunlevel)
+	case "disable":
+		return u.rcCommand("rc-update", "del", u.name, openrcRunlevel)
+	case "start", "stop", "restart", "reload":
+		return u.rcCommand("rc-service", u.name, command)
+	}
+	return notSupported(BackendOpenRC, command)
+}
+
+func (openrc) status(u Unit) (Status, error) {
+	_, err := os.Stat(u.InitScriptFilename())
+	loaded := err == nil
+	_, err = u.runOutput(filepath.Join(u.toolPath, "rc-service"), u.name, "status")
+	return backendStatus(loaded, loaded && err == nil), nil
+}
+
+func (openrc) kind(u Unit) Backend {
+	return BackendOpenRC
+}
@@ -0,0 +1,74 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenRC(t *testing.T) {
+	// rc-service status fails until the service has been restarted
+	tools := t.TempDir()
+	log := filepath.Join(tools, "log")
+	for name, body := range map[string]string{
+		"rc-service": `[ "$2" = status ] && { [ -e ` + tools + `/started ] || exit 3; }
+[ "$2" = restart ] && touch ` + tools + `/started
+[ "$2" = stop ] && rm ` + tools + `/started
+exit 0`,
+		"rc-update": "exit 0",
+	} {
+		os.WriteFile(filepath.Join(tools, name), []byte("#!/bin/sh\necho $(basename $0) \"$@\" >> "+log+"\n"+body+"\n"), 0700)
+	}
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", binaryPath: "/fullpath/to", binaryArgs: "-x 'y z'",
+		scope: ScopeSystem, backend: openrc{}, toolPath: tools, unitFilePath: t.TempDir()}
+	u.addDirective(SectionUnit, "After", "network-online.target db.service")
+	u.addDirective(SectionUnit, "Wants", "cache.service sockets.target")
+	u.addDirective(SectionService, "Restart", "on-failure")
+	u.addDirective(SectionService, "User", "nobody")
+
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	content, err := os.ReadFile(u.InitScriptFilename())
+	if err != nil {
+		t.Fatalf("init script was not written: %s", err)
+	}
+	for _, want := range []string{
+		"#!/sbin/openrc-run\n",
+		"\ncommand=/fullpath/to/foobar\ncommand_args='-x '\\''y z'\\'''\n",
+		"\ncommand_user=nobody\n",
+		"\nsupervisor=supervise-daemon\n",
+		"\tuse cache\n\tafter net db\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("init script does not contain %q:\n%s", want, content)
+		}
+	}
+	if info, _ := os.Stat(u.InitScriptFilename()); info.Mode()&0111 == 0 {
+		t.Error("init script is not executable")
+	}
+
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("second deploy failed: %s", err)
+	}
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if _, err := os.Stat(u.InitScriptFilename()); !os.IsNotExist(err) {
+		t.Error("init script was not removed")
+	}
+	want := strings.Join([]string{
+		"rc-update add test_unit default", "rc-service test_unit restart",
+		"rc-update add test_unit default", "rc-service test_unit status",
+		"rc-service test_unit status", "rc-service test_unit stop", "rc-update del test_unit default",
+	}, "\n") + "\n"
+	if commands, _ := os.ReadFile(log); string(commands) != want {
+		t.Errorf("wrong commands:\n%s", commands)
+	}
+
+	user := Unit{name: "test_unit", dryRun: true}
+	if err := (openrc{}).setup(&user); err == nil {
+		t.Error("user scope should fail")
+	}
+}
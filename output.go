@@ -0,0 +1,135 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// logrotateDirectory is where logrotate configuration is written for
+// OptOutput with Logrotate set.
+const logrotateDirectory = "/etc/logrotate.d"
+
+// OptOutput directs the service's standard output and/or error. Each may be
+// "journal" (the default), "null", or a file as "append:/path/to/file",
+// "file:/path/to/file" or "truncate:/path/to/file". The directory for a
+// file is created on Deploy if it does not exist.
+//
+// Setting Logrotate (system scope only) also installs a logrotate
+// configuration for the files, using copytruncate so the service does not
+// need to reopen them.
+type OptOutput struct {
+	Stdout    string
+	Stderr    string
+	Logrotate bool
+}
+
+// outputPrefixes are the StandardOutput= values which take a file path.
+var outputPrefixes = []string{"append:", "file:", "truncate:"}
+
+func (o OptOutput) Apply(u *Unit) error {
+	if o.Stdout == "" && o.Stderr == "" {
+		return errors.New("OptOutput needs Stdout or Stderr")
+	}
+	outputs := []struct {
+		key   string
+		value string
+	}{
+		{"StandardOutput", o.Stdout},
+		{"StandardError", o.Stderr},
+	}
+	for _, out := range outputs {
+		if out.value == "" {
+			continue
+		}
+		file, err := outputFile(out.value)
+		if err != nil {
+			return fmt.Errorf("bad %s '%s': %w", out.key, out.value, err)
+		}
+		if file != "" {
+			u.logFiles = append(u.logFiles, file)
+		}
+		err = u.addDirective(SectionService, out.key, out.value)
+		if err != nil {
+			return err
+		}
+	}
+	if o.Logrotate {
+		if len(u.logFiles) == 0 {
+			return errors.New("Logrotate needs output to a file")
+		}
+		u.logrotate = true
+	}
+	return nil
+}
+
+// outputFile validates an output, returning the file it writes to, if any.
+func outputFile(value string) (string, error) {
+	if value == "journal" || value == "null" {
+		return "", nil
+	}
+	for _, prefix := range outputPrefixes {
+		if strings.HasPrefix(value, prefix) {
+			file := strings.TrimPrefix(value, prefix)
+			if !filepath.IsAbs(file) {
+				return "", errors.New("path must be absolute")
+			}
+			return filepath.Clean(file), nil
+		}
+	}
+	return "", errors.New("must be journal, null, append:, file: or truncate:")
+}
+
+// logrotateFilename returns the path of the logrotate configuration.
+func (u Unit) logrotateFilename() string {
+	return u.rooted(filepath.Join(logrotateDirectory, u.name))
+}
+
+// createLogFiles creates the directories for file outputs, and the logrotate
+// configuration if requested.
+func (u Unit) createLogFiles() error {
+	for _, file := range u.logFiles {
+		err := u.mkdirAll(u.rooted(filepath.Dir(file)))
+		if err != nil {
+			return fmt.Errorf("could not create log directory: %w", err)
+		}
+	}
+	if u.logrotate && u.root != "" {
+		err := u.mkdirAll(filepath.Dir(u.logrotateFilename()))
+		if err != nil {
+			return err
+		}
+	}
+	if u.logrotate {
+		return u.createFile(u.logrotateFilename(), u.writeLogrotateTemplate)
+	}
+	return nil
+}
+
+// removeLogFiles removes the logrotate configuration. The logs themselves
+// are left alone.
+func (u Unit) removeLogFiles() error {
+	if !u.logrotate {
+		return nil
+	}
+	err := u.removeFile(u.logrotateFilename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (u Unit) writeLogrotateTemplate(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"paths": u.logFiles,
+	}
+	return t.ExecuteTemplate(f, "logrotate.conf", data)
+}
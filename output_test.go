@@ -0,0 +1,57 @@
+package unitard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOutputOpts(t *testing.T) {
+	u := Unit{name: "test_unit", scope: ScopeSystem}
+	err := OptOutput{Stdout: "append:/var/log/test_unit/out.log", Stderr: "journal", Logrotate: true}.Apply(&u)
+	if err != nil {
+		t.Fatalf("failed to apply output: %s", err)
+	}
+	if err := u.validate(); err != nil {
+		t.Errorf("should be valid: %s", err)
+	}
+	if len(u.logFiles) != 1 || u.logFiles[0] != "/var/log/test_unit/out.log" {
+		t.Errorf("wrong log files %v", u.logFiles)
+	}
+	d := u.sectionDirectives(SectionService)
+	if len(d) != 2 || d[0].Value != "append:/var/log/test_unit/out.log" || d[1].Key != "StandardError" {
+		t.Errorf("wrong directives %+v", d)
+	}
+
+	buff := bytes.NewBuffer(nil)
+	err = u.writeLogrotateTemplate(buff)
+	if err != nil {
+		t.Errorf("failed to write logrotate template: %s", err)
+	}
+	if !strings.Contains(buff.String(), "/var/log/test_unit/out.log {") || !strings.Contains(buff.String(), "copytruncate") {
+		t.Errorf("bad logrotate config:\n%s", buff.String())
+	}
+	if u.logrotateFilename() != "/etc/logrotate.d/test_unit" {
+		t.Errorf("wrong logrotate file %s", u.logrotateFilename())
+	}
+}
+
+func TestOutputOptsInvalid(t *testing.T) {
+	invalid := []OptOutput{
+		{},
+		{Stdout: "syslog"},
+		{Stdout: "append:relative.log"},
+		{Stderr: "journal", Logrotate: true},
+	}
+	for _, i := range invalid {
+		if i.Apply(&Unit{}) == nil {
+			t.Errorf("%+v should not be valid", i)
+		}
+	}
+
+	u := Unit{}
+	_ = OptOutput{Stdout: "file:/tmp/out.log", Logrotate: true}.Apply(&u)
+	if u.validate() == nil {
+		t.Error("logrotate should not be valid in user scope")
+	}
+}
@@ -0,0 +1,45 @@
+package unitard
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// OptPath deploys a path unit alongside the service, so that the service is
+// started when files or directories change - for instance to process files
+// as they arrive in a drop directory. All paths must be absolute.
+type OptPath struct {
+	PathExists        []string // start when any of these paths exist
+	PathModified      []string // start when any of these paths are written to
+	DirectoryNotEmpty []string // start when any of these directories contain files
+}
+
+func (o OptPath) Apply(u *Unit) error {
+	if len(o.PathExists) == 0 && len(o.PathModified) == 0 && len(o.DirectoryNotEmpty) == 0 {
+		return errors.New("path needs at least one of PathExists, PathModified or DirectoryNotEmpty")
+	}
+	for _, paths := range [][]string{o.PathExists, o.PathModified, o.DirectoryNotEmpty} {
+		for _, p := range paths {
+			if !filepath.IsAbs(p) {
+				return errors.New("paths must be absolute")
+			}
+			if strings.ContainsAny(p, "\r\n") {
+				return errors.New("directive values cannot contain newlines")
+			}
+		}
+	}
+	return u.addTrigger(o)
+}
+
+func (o OptPath) unitType() string {
+	return "path"
+}
+
+func (o OptPath) templateData() map[string]interface{} {
+	return map[string]interface{}{
+		"pathExists":        o.PathExists,
+		"pathModified":      o.PathModified,
+		"directoryNotEmpty": o.DirectoryNotEmpty,
+	}
+}
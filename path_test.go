@@ -0,0 +1,44 @@
+package unitard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPathTemplate(t *testing.T) {
+	u := Unit{name: "test_unit"}
+	err := OptPath{PathModified: []string{"/tmp/config"}, DirectoryNotEmpty: []string{"/tmp/incoming"}}.Apply(&u)
+	if err != nil {
+		t.Fatalf("failed to apply path: %s", err)
+	}
+
+	buff := bytes.NewBuffer(nil)
+	err = u.writeTriggerTemplate(buff, u.triggers[0])
+	if err != nil {
+		t.Errorf("failed to write template: %s", err)
+	}
+	t.Logf("template:\n%s", buff.String())
+
+	for _, want := range []string{"PathModified=/tmp/config\n", "DirectoryNotEmpty=/tmp/incoming\n", "WantedBy=paths.target"} {
+		if !strings.Contains(buff.String(), want) {
+			t.Errorf("template does not contain %q", want)
+		}
+	}
+	if strings.Contains(buff.String(), "PathExists") {
+		t.Error("template contains unset PathExists")
+	}
+}
+
+func TestPathOpts(t *testing.T) {
+	u := Unit{}
+	if (OptPath{}).Apply(&u) == nil {
+		t.Error("empty path should not be valid")
+	}
+	if (OptPath{PathExists: []string{"relative/path"}}).Apply(&u) == nil {
+		t.Error("relative path should not be valid")
+	}
+	if (OptPath{DirectoryNotEmpty: []string{"/tmp/in\nExecStart=/bin/evil"}}).Apply(&u) == nil {
+		t.Error("path with a newline should not be valid")
+	}
+}
@@ -0,0 +1,71 @@
+package unitard
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+)
+
+// OptLogger logs each step Deploy and Undeploy take (files written,
+// commands run and so on) to logger, at info level.
+type OptLogger struct {
+	Logger *slog.Logger
+}
+
+func (o OptLogger) Apply(u *Unit) error {
+	if o.Logger == nil {
+		return errors.New("OptLogger needs a Logger")
+	}
+	u.logger = o.Logger
+	return nil
+}
+
+// OptProgress calls Func before each step Deploy and Undeploy take, for
+// instance to show progress to the user. The steps are the same actions a
+// dry run returns.
+type OptProgress struct {
+	Func func(Action)
+}
+
+func (o OptProgress) Apply(u *Unit) error {
+	if o.Func == nil {
+		return errors.New("OptProgress needs a Func")
+	}
+	u.progress = o.Func
+	return nil
+}
+
+// step records an action in the plan of a dry run, returning true, or
+// logs and reports it before it is taken.
+func (u Unit) step(a Action) bool {
+	if u.plan != nil {
+		u.plan.add(a)
+		return true
+	}
+	if u.recorder != nil {
+		u.recorder.add(a)
+	}
+	if u.logger != nil {
+		switch a.Kind {
+		case ActionWrite:
+			u.logger.Info("writing file", "unit", u.name, "path", a.Path)
+		case ActionRemove:
+			u.logger.Info("removing file", "unit", u.name, "path", a.Path)
+		case ActionMkdir:
+			u.logger.Info("creating directory", "unit", u.name, "path", a.Path)
+		case ActionRun:
+			u.logger.Info("running command", "unit", u.name, "command", strings.Join(a.Command, " "))
+		}
+	}
+	if u.progress != nil {
+		u.progress(a)
+	}
+	return false
+}
+
+// log logs a message about the unit, if there is a logger.
+func (u Unit) log(level slog.Level, msg string, args ...interface{}) {
+	if u.logger != nil {
+		u.logger.Log(u.context(), level, msg, append([]interface{}{"unit", u.name}, args...)...)
+	}
+}
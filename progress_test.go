@@ -0,0 +1,38 @@
+package unitard
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	client := &recordingClient{dir: t.TempDir()}
+	logs := bytes.NewBuffer(nil)
+	steps := []Action{}
+	u, err := NewUnit("test_unit", OptClient{Client: client}, OptSkipVerify{},
+		OptLogger{Logger: slog.New(slog.NewTextHandler(logs, nil))},
+		OptProgress{Func: func(a Action) { steps = append(steps, a) }})
+	if err != nil {
+		t.Fatalf("could not create unit: %s", err)
+	}
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+
+	if len(steps) != 4 || steps[0].Kind != ActionWrite || steps[3].String() != "run systemctl restart test_unit" {
+		t.Errorf("wrong steps %v", steps)
+	}
+	for _, want := range []string{"msg=\"writing file\" unit=test_unit path=", "command=\"systemctl daemon-reload\"", "msg=\"unit deployed\""} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs do not contain %q:\n%s", want, logs)
+		}
+	}
+
+	// dry runs are not reported
+	steps = nil
+	if _, err := u.DryRun(); err != nil || len(steps) != 0 {
+		t.Errorf("dry run should not report steps, got %v %v", steps, err)
+	}
+}
@@ -0,0 +1,204 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+const (
+	// quadletSystemDirectory is where system Quadlet files are installed.
+	quadletSystemDirectory = "/etc/containers/systemd"
+	// quadletVendorDirectory is where packages install Quadlet files,
+	// and quadletVendorUserDirectory those for users.
+	quadletVendorDirectory     = "usr/share/containers/systemd"
+	quadletVendorUserDirectory = "usr/share/containers/systemd/users"
+)
+
+// volumeNameRegexp matches the name of a podman volume, as opposed to a
+// host path.
+var volumeNameRegexp = regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9_.-]*$")
+
+// OptQuadlet deploys the program as a container, with podman's Quadlet
+// systemd integration (podman 4.4 or later). A .container file is written
+// to ~/.config/containers/systemd (or /etc/containers/systemd for system
+// scope), which systemd turns into name.service when it is reloaded; that
+// is then started like any other service.
+//
+// Volumes are "source:destination[:options]", as for podman run -v. A
+// source which is a name rather than a path is a named volume, which gets
+// a .volume file of its own (name-source.volume) deployed with the unit.
+// OptProgramArgs become the container's command arguments, and Environment
+// directives are set in the container; other [Service] directives, such as
+// Restart, apply to the service running podman.
+type OptQuadlet struct {
+	Image   string   // the image to run
+	Ports   []string // published ports, such as "8080:80"
+	Volumes []string // mounted volumes, such as "data:/var/lib/app" or "/srv/app:/srv:ro"
+}
+
+// quadlet is the container deployed with OptQuadlet.
+type quadlet struct {
+	image   string
+	ports   []string
+	volumes []quadletVolume
+}
+
+// quadletVolume is a volume mounted in the container.
+type quadletVolume struct {
+	named  bool   // a named volume, with its own .volume file
+	source string // the volume name or host path
+	mount  string // the destination and any options
+}
+
+func (o OptQuadlet) Apply(u *Unit) error {
+	if o.Image == "" || strings.ContainsAny(o.Image, " \t\n") {
+		return fmt.Errorf("sorry, image '%s' is not valid", o.Image)
+	}
+	q := &quadlet{image: o.Image}
+	for _, p := range o.Ports {
+		if p == "" || strings.ContainsAny(p, " \t\n") {
+			return fmt.Errorf("sorry, port '%s' is not valid", p)
+		}
+		q.ports = append(q.ports, p)
+	}
+	for _, v := range o.Volumes {
+		source, mount, ok := strings.Cut(v, ":")
+		if !ok || source == "" || !strings.HasPrefix(mount, "/") || strings.ContainsAny(v, "\n") {
+			return fmt.Errorf("sorry, volume '%s' is not valid, use source:destination", v)
+		}
+		named := !strings.ContainsAny(source, "/") && source != "." && source != ".."
+		if named && !volumeNameRegexp.MatchString(source) {
+			return fmt.Errorf("sorry, volume name '%s' is not valid", source)
+		}
+		q.volumes = append(q.volumes, quadletVolume{named: named, source: source, mount: mount})
+	}
+	u.quadlet = q
+	return nil
+}
+
+// quadletDir returns the directory Quadlet files are installed in.
+func (u Unit) quadletDir() string {
+	switch {
+	case u.root != "" && u.scope == ScopeSystem:
+		return u.rooted(quadletVendorDirectory)
+	case u.root != "":
+		return u.rooted(quadletVendorUserDirectory)
+	case u.scope == ScopeSystem:
+		return quadletSystemDirectory
+	}
+	if configHome, err := userConfigHome(); err == nil && u.unitDirectory != "" && u.remote == nil {
+		return filepath.Join(configHome, "containers", "systemd")
+	}
+	// $XDG_CONFIG_HOME/containers/systemd, next to $XDG_CONFIG_HOME/systemd/user
+	return filepath.Join(filepath.Dir(filepath.Dir(u.unitFilePath)), "containers", "systemd")
+}
+
+// ContainerFilename returns the full path to the .container file of a unit
+// deployed with OptQuadlet.
+func (u Unit) ContainerFilename() string {
+	return filepath.Join(u.quadletDir(), u.name+".container")
+}
+
+// volumeUnit returns the name of the .volume file of a named volume.
+func (u Unit) volumeUnit(v quadletVolume) string {
+	return u.name + "-" + v.source + ".volume"
+}
+
+// quadletFiles returns the .container file and the .volume files.
+func (u Unit) quadletFiles() []unitFile {
+	files := []unitFile{{u.ContainerFilename(), withChecksum(u.writeContainer)}}
+	for _, v := range u.quadlet.volumes {
+		if v.named {
+			v := v
+			files = append(files, unitFile{filepath.Join(u.quadletDir(), u.volumeUnit(v)), withChecksum(func(f io.Writer) error {
+				return u.writeVolume(f, v)
+			})})
+		}
+	}
+	return files
+}
+
+func (u Unit) writeContainer(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	container := []Directive{}
+	for _, p := range u.quadlet.ports {
+		container = append(container, Directive{Section: "Container", Key: "PublishPort", Value: p})
+	}
+	for _, v := range u.quadlet.volumes {
+		source := v.source
+		if v.named {
+			source = u.volumeUnit(v)
+		}
+		container = append(container, Directive{Section: "Container", Key: "Volume", Value: source + ":" + v.mount})
+	}
+	service := []Directive{}
+	for _, d := range u.sectionDirectives(SectionService) {
+		if d.Key == "Environment" {
+			container = append(container, Directive{Section: "Container", Key: d.Key, Value: d.Value})
+		} else {
+			service = append(service, d)
+		}
+	}
+	if u.binaryArgs != "" {
+		container = append(container, Directive{Section: "Container", Key: "Exec", Value: u.binaryArgs})
+	}
+	data := u.templateData()
+	data.Service = service
+	return t.ExecuteTemplate(f, "quadlet.container", map[string]interface{}{
+		"Description": data.Description,
+		"Unit":        data.Unit,
+		"Image":       u.quadlet.image,
+		"Container":   container,
+		"Service":     data.Service,
+		"WantedBy":    data.WantedBy,
+		"Install":     data.Install,
+	})
+}
+
+func (u Unit) writeVolume(f io.Writer, v quadletVolume) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	return t.ExecuteTemplate(f, "quadlet.volume", map[string]interface{}{
+		"Description": u.name + " volume " + v.source,
+		"Unit":        u.markerDirectives(),
+	})
+}
+
+// checkQuadlet returns an error if OptQuadlet is combined with options it
+// can't be used with.
+func (u Unit) checkQuadlet() error {
+	if u.quadlet == nil {
+		return nil
+	}
+	if u.instances || u.dropIn != "" || u.serviceTemplate != nil || u.backend != nil {
+		return errors.New("OptQuadlet cannot be combined with instances, OptDropIn, OptTemplate or other backends")
+	}
+	return nil
+}
+
+// undeployQuadlet stops the container's service and removes its files.
+// The generated service can't be disabled, it goes when the files do.
+func (u Unit) undeployQuadlet() error {
+	err := u.systemctl("stop", u.serviceName())
+	if err != nil {
+		return err
+	}
+	for _, f := range u.quadletFiles() {
+		err := u.removeFile(f.name)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return u.systemctl("daemon-reload")
+}
@@ -0,0 +1,82 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQuadlet(t *testing.T) {
+	systemctl, log := fakeSystemctl(t, "")
+	home := t.TempDir()
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", binaryArgs: "--listen :80", systemCtlPath: systemctl,
+		unitFilePath: filepath.Join(home, ".config", "systemd", "user")}
+	err := OptQuadlet{Image: "ghcr.io/me/app:1.2", Ports: []string{"8080:80"}, Volumes: []string{"data:/var/lib/app", "/srv/app:/srv:ro"}}.Apply(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.addDirective(SectionService, "Environment", "MODE=prod")
+	u.addDirective(SectionService, "Restart", "always")
+
+	if u.ContainerFilename() != filepath.Join(home, ".config", "containers", "systemd", "test_unit.container") {
+		t.Errorf("wrong container file %s", u.ContainerFilename())
+	}
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	content, err := os.ReadFile(u.ContainerFilename())
+	if err != nil {
+		t.Fatalf("container file was not written: %s", err)
+	}
+	for _, want := range []string{
+		"\n[Container]\nImage=ghcr.io/me/app:1.2\nPublishPort=8080:80\nVolume=test_unit-data.volume:/var/lib/app\nVolume=/srv/app:/srv:ro\nEnvironment=MODE=prod\nExec=--listen :80\n",
+		"\n[Service]\nRestart=always\n",
+		"\nWantedBy=default.target\n",
+		"X-Unitard-Managed=yes",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("container file does not contain %q:\n%s", want, content)
+		}
+	}
+	volume := filepath.Join(filepath.Dir(u.ContainerFilename()), "test_unit-data.volume")
+	if content, err := os.ReadFile(volume); err != nil || !strings.Contains(string(content), "\n[Volume]\n") {
+		t.Errorf("volume file was not written: %s\n%s", err, content)
+	}
+
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	for _, name := range []string{u.ContainerFilename(), volume} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+	want := "--user daemon-reload\n--user restart test_unit\n--user stop test_unit\n--user daemon-reload\n"
+	if commands, _ := os.ReadFile(log); string(commands) != want {
+		t.Errorf("wrong commands:\n%s", commands)
+	}
+}
+
+func TestOptQuadlet(t *testing.T) {
+	for _, o := range []OptQuadlet{
+		{},
+		{Image: "bad image"},
+		{Image: "app", Volumes: []string{"data"}},
+		{Image: "app", Volumes: []string{"data:relative"}},
+		{Image: "app", Volumes: []string{"-x:/data"}},
+		{Image: "app", Ports: []string{""}},
+	} {
+		if err := o.Apply(&Unit{}); err == nil {
+			t.Errorf("%+v should be rejected", o)
+		}
+	}
+	u := Unit{name: "test_unit", scope: ScopeSystem, instances: true}
+	(OptQuadlet{Image: "app"}).Apply(&u)
+	if err := u.validate(); err == nil {
+		t.Error("OptQuadlet with instances should be rejected")
+	}
+	if u.ContainerFilename() != "/etc/containers/systemd/test_unit.container" {
+		t.Errorf("wrong system container file %s", u.ContainerFilename())
+	}
+}
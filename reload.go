@@ -0,0 +1,48 @@
+package unitard
+
+import (
+	"errors"
+)
+
+// OptExecReload sets the command systemd runs to make the service reload its
+// configuration, for Reload and OptReloadOnDeploy. For a program which
+// reloads on SIGHUP, use "/bin/kill -HUP $MAINPID".
+type OptExecReload struct {
+	Command string
+}
+
+func (o OptExecReload) Apply(u *Unit) error {
+	if o.Command == "" {
+		return errors.New("can't set an empty reload command")
+	}
+	return u.addDirective(SectionService, "ExecReload", o.Command)
+}
+
+// OptReloadOnDeploy makes Deploy reload the running service when its unit
+// files have changed, instead of restarting it, avoiding downtime for
+// services which can reload their configuration. If OrRestart is set,
+// `systemctl reload-or-restart` is used, so services which can't reload
+// are restarted instead.
+// It cannot be used with timers, sockets or paths.
+type OptReloadOnDeploy struct {
+	OrRestart bool
+}
+
+func (o OptReloadOnDeploy) Apply(u *Unit) error {
+	u.reloadOnDeploy = true
+	u.reloadOrRestart = o.OrRestart
+	return nil
+}
+
+// restartCommands returns the systemctl commands used on Deploy to pick up
+// changed unit files in a running service.
+func (u Unit) restartCommands() []string {
+	switch {
+	case u.reloadOnDeploy && u.reloadOrRestart:
+		return []string{"reload-or-restart"}
+	case u.reloadOnDeploy:
+		// reload fails if the service is not running, so start it first
+		return []string{"start", "reload"}
+	}
+	return []string{"restart"}
+}
@@ -0,0 +1,37 @@
+package unitard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReloadOnDeploy(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", systemCtlPath: "systemctl", unitFilePath: t.TempDir()}
+	for _, opt := range []UnitOpts{OptExecReload{Command: "/bin/kill -HUP $MAINPID"}, OptReloadOnDeploy{}} {
+		if err := opt.Apply(&u); err != nil {
+			t.Fatalf("failed to apply option: %s", err)
+		}
+	}
+
+	plan, err := u.DryRun()
+	if err != nil {
+		t.Fatalf("dry run failed: %s", err)
+	}
+	if !strings.Contains(plan.Files()[u.UnitFilename()], "ExecReload=/bin/kill -HUP $MAINPID\n") {
+		t.Error("unit file does not contain ExecReload")
+	}
+	commands := strings.Join(plan.Commands(), "\n")
+	if !strings.Contains(commands, "start test_unit\nsystemctl --user reload test_unit") || strings.Contains(commands, "restart") {
+		t.Errorf("should start and reload, got:\n%s", commands)
+	}
+
+	u.reloadOrRestart = true
+	if c := u.restartCommands(); len(c) != 1 || c[0] != "reload-or-restart" {
+		t.Errorf("wrong restart commands %v", c)
+	}
+
+	_ = OptSocket{ListenStream: []string{"80"}}.Apply(&u)
+	if u.validate() == nil {
+		t.Error("reload with a socket should not be valid")
+	}
+}
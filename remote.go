@@ -0,0 +1,222 @@
+package unitard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// remoteMissing is the exit code of the remote commands which read or
+// remove a file when the file does not exist.
+const remoteMissing = 66
+
+// OptRemote deploys the unit to another host over ssh, instead of the
+// machine the program is running on. The program (or Binary) is uploaded
+// to the host, so it must be built for the host's platform, and the unit
+// files are written and systemctl run there. Deploy only uploads the binary
+// when it has changed, and restarts the service when it does.
+//
+// ssh is run in batch mode, so the host must accept your key without a
+// prompt; ~/.ssh/config is used as usual. For system scope, log in as root
+// or use OptEscalate, which runs sudo -n on the host.
+//
+// The remote host must run systemd, and OptRemote cannot be combined with
+// other backends, OptDBus or OptClient.
+type OptRemote struct {
+	Host         string // as given to ssh: host, user@host or a Host from ~/.ssh/config
+	Port         int    // the ssh port, if not the default
+	IdentityFile string // the ssh private key, if not the default
+	Binary       string // the local binary to upload, the running program by default
+	Path         string // where the binary is installed on the host, ~/.local/bin (or /usr/local/bin for system scope) by default
+}
+
+// remote is a host units are deployed to over ssh.
+type remote struct {
+	ssh    []string // the ssh command, up to the host
+	upload string   // the local binary to upload
+}
+
+func (o OptRemote) Apply(u *Unit) error {
+	if o.Host == "" || strings.HasPrefix(o.Host, "-") {
+		return fmt.Errorf("sorry, host '%s' is not valid", o.Host)
+	}
+	if o.Path != "" && !path.IsAbs(o.Path) {
+		return fmt.Errorf("sorry, remote path '%s' must be absolute", o.Path)
+	}
+	ssh := []string{"ssh", "-o", "BatchMode=yes"}
+	if o.Port != 0 {
+		ssh = append(ssh, "-p", strconv.Itoa(o.Port))
+	}
+	if o.IdentityFile != "" {
+		ssh = append(ssh, "-i", o.IdentityFile)
+	}
+	u.remote = &remote{ssh: append(ssh, "--", o.Host), upload: o.Binary}
+	u.remoteBinary = o.Path
+	return nil
+}
+
+// command returns the ssh command to run a command on the host.
+func (r *remote) command(command string, args []string) (string, []string) {
+	words := []string{shellQuote(command)}
+	for _, a := range args {
+		words = append(words, shellQuote(a))
+	}
+	return r.ssh[0], append(append([]string{}, r.ssh[1:]...), strings.Join(words, " "))
+}
+
+// lookTool returns the command to run a tool such as journalctl with. With
+// OptRemote it is run on the host, and found on the PATH there.
+func (u Unit) lookTool(name string) (string, error) {
+	if u.remote != nil {
+		return name, nil
+	}
+	return exec.LookPath(name)
+}
+
+// setupRemote fills in the environment from the remote host, in place of
+// setupEnvironment.
+func (u *Unit) setupRemote() error {
+	if u.backend != nil || u.dbus || u.client != nil {
+		return errors.New("OptRemote can only be used with systemctl")
+	}
+	if _, err := exec.LookPath(u.remote.ssh[0]); err != nil {
+		return fmt.Errorf("ssh not found: %w", err)
+	}
+	if u.remote.upload == "" {
+		u.remote.upload = u.binary
+	}
+	u.binaryHash = fileHash(u.remote.upload)
+
+	out, err := u.runOutput("sh", "-c", `id -u; echo "$HOME"; echo "$XDG_RUNTIME_DIR"; command -v systemctl || true`)
+	if err != nil {
+		return fmt.Errorf("could not reach the remote host: %w", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) < 4 {
+		lines = append(lines, make([]string, 4-len(lines))...)
+	}
+	uid, home, runtimeDir, systemctl := lines[0], lines[1], lines[2], lines[3]
+	if systemctl == "" {
+		return fmt.Errorf("%w: not installed on the remote host", ErrSystemctlNotFound)
+	}
+	u.systemCtlPath = systemctl
+
+	if u.scope == ScopeSystem {
+		if uid != "0" {
+			if u.escalation != EscalateSudo && u.escalation != EscalateAuto {
+				return errors.New("system scope on a remote host needs to log in as root (or use OptEscalate with sudo)")
+			}
+			u.escalate = []string{"sudo", "-n"}
+		}
+		u.unitFilePath = u.unitDirectoryOr(systemUnitDirectory)
+		if u.remoteBinary == "" {
+			u.remoteBinary = path.Join("/usr/local/bin", filepath.Base(u.remote.upload))
+		}
+	} else {
+		if uid == "0" {
+			return ErrRootNotAllowed
+		}
+		if runtimeDir == "" {
+			return &UserBusError{UID: uid, Reason: "XDG_RUNTIME_DIR is not set on the remote host"}
+		}
+		u.unitFilePath = u.unitDirectoryOr(path.Join(home, ".config", "systemd", "user"))
+		if u.remoteBinary == "" {
+			u.remoteBinary = path.Join(home, ".local", "bin", filepath.Base(u.remote.upload))
+		}
+	}
+	u.binary = u.remoteBinary
+	u.binaryPath = path.Dir(u.remoteBinary)
+	err = u.runExpectZero("mkdir", "-p", "--", u.unitFilePath)
+	if err != nil || u.scope == ScopeSystem {
+		return err
+	}
+	return u.checkLinger(uid)
+}
+
+// remoteFile runs a shell script on the host against a file, given as $1,
+// returning a *os.PathError matching os.ErrNotExist if the script exits
+// with remoteMissing.
+func (u Unit) remoteFile(op, name string, stdin io.Reader, script string, args ...string) (string, error) {
+	command := append([]string{"sh", "-c", script, "sh", name}, args...)
+	if u.escalate != nil {
+		command = append(append([]string{}, u.escalate...), command...)
+	}
+	out, err := u.runInput(stdin, command[0], command[1:]...)
+	var sysErr *SystemctlError
+	if errors.As(err, &sysErr) && sysErr.ExitCode == remoteMissing {
+		return "", &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return out, err
+}
+
+// readFile reads a file, from the remote host with OptRemote.
+func (u Unit) readFile(name string) ([]byte, error) {
+	if u.remote == nil {
+		return os.ReadFile(name)
+	}
+	out, err := u.remoteFile("open", name, nil, `[ -e "$1" ] || exit `+strconv.Itoa(remoteMissing)+`; cat -- "$1"`)
+	return []byte(out), err
+}
+
+// writeRemoteFile writes a file on the remote host.
+func (u Unit) writeRemoteFile(name string, content io.Reader, mode string) error {
+	_, err := u.remoteFile("write", name, content, `cat > "$1" && chmod "$2" "$1"`, mode)
+	return err
+}
+
+// removeRemoteFile removes a file on the remote host.
+func (u Unit) removeRemoteFile(name string) error {
+	_, err := u.remoteFile("remove", name, nil, `[ -e "$1" ] || exit `+strconv.Itoa(remoteMissing)+`; rm -- "$1"`)
+	return err
+}
+
+// binaryChanged returns true if the binary on the remote host is missing or
+// differs from the one to upload.
+func (u Unit) binaryChanged() (bool, error) {
+	f, err := os.Open(u.remote.upload)
+	if err != nil {
+		return false, fmt.Errorf("could not read the binary to upload: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	out, err := u.remoteFile("open", u.remoteBinary, nil, `[ -e "$1" ] || exit `+strconv.Itoa(remoteMissing)+`; sha256sum -- "$1"`)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return !strings.HasPrefix(out, hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// uploadBinary copies the binary to the remote host.
+func (u Unit) uploadBinary() error {
+	if u.step(Action{Kind: ActionRun, Command: []string{"install", "-m", "0755", u.remote.upload, u.remoteBinary}}) {
+		return nil
+	}
+	f, err := os.Open(u.remote.upload)
+	if err != nil {
+		return fmt.Errorf("could not read the binary to upload: %w", err)
+	}
+	defer f.Close()
+	_, err = u.remoteFile("write", u.remoteBinary, nil, `mkdir -p -- "$(dirname -- "$1")"`)
+	if err != nil {
+		return err
+	}
+	// write a new file, in case the old one is running
+	_, err = u.remoteFile("write", u.remoteBinary, f, `cat > "$1.new" && chmod 0755 "$1.new" && mv -f -- "$1.new" "$1"`)
+	if err != nil {
+		return fmt.Errorf("could not upload the binary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package unitard
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRemote puts an ssh on the path which runs the remote command locally,
+// with HOME as the remote home directory, and returns the log of the ssh
+// and systemctl commands.
+func fakeRemote(t *testing.T, uid string) (string, string) {
+	tools := t.TempDir()
+	log := filepath.Join(tools, "log")
+	for name, body := range map[string]string{
+		"ssh":       `echo ssh "$@" >> ` + log + "\nwhile [ \"$1\" != -- ]; do shift; done\nexec sh -c \"$3\"",
+		"id":        "echo " + uid,
+		"systemctl": "echo systemctl \"$@\" >> " + log,
+	} {
+		os.WriteFile(filepath.Join(tools, name), []byte("#!/bin/sh\n"+body+"\n"), 0700)
+	}
+	home := t.TempDir()
+	t.Setenv("PATH", tools+":"+os.Getenv("PATH"))
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/"+uid)
+	return home, log
+}
+
+func TestRemote(t *testing.T) {
+	home, log := fakeRemote(t, "1000")
+	binary := filepath.Join(t.TempDir(), "foobar")
+	os.WriteFile(binary, []byte("version 1"), 0755)
+
+	u, err := NewUnit("test_unit", OptRemote{Host: "prod1", Port: 2222, Binary: binary})
+	if err != nil {
+		t.Fatalf("could not set up the remote unit: %s", err)
+	}
+	uploaded := filepath.Join(home, ".local", "bin", "foobar")
+	if u.UnitFilename() != filepath.Join(home, ".config", "systemd", "user", "test_unit.service") || u.binary != uploaded {
+		t.Errorf("wrong paths %s and %s", u.UnitFilename(), u.binary)
+	}
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	if content, _ := os.ReadFile(uploaded); string(content) != "version 1" {
+		t.Errorf("binary was not uploaded: %q", content)
+	}
+	if info, err := os.Stat(uploaded); err != nil || info.Mode()&0111 == 0 {
+		t.Error("uploaded binary is not executable")
+	}
+	content, err := os.ReadFile(u.UnitFilename())
+	if err != nil || !strings.Contains(string(content), "ExecStart="+uploaded) {
+		t.Errorf("unit file was not written on the host: %s\n%s", err, content)
+	}
+	commands, _ := os.ReadFile(log)
+	if !strings.Contains(string(commands), "ssh -o BatchMode=yes -p 2222 -- prod1 ") ||
+		!strings.Contains(string(commands), "systemctl --user daemon-reload\n") {
+		t.Errorf("wrong commands:\n%s", commands)
+	}
+
+	// nothing to upload or write the second time
+	os.Truncate(log, 0)
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("second deploy failed: %s", err)
+	}
+	if commands, _ := os.ReadFile(log); strings.Contains(string(commands), "daemon-reload") || strings.Contains(string(commands), "cat >") {
+		t.Errorf("unchanged deploy wrote files:\n%s", commands)
+	}
+
+	// a new binary restarts the service
+	os.WriteFile(binary, []byte("version 2"), 0755)
+	os.Truncate(log, 0)
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("third deploy failed: %s", err)
+	}
+	if content, _ := os.ReadFile(uploaded); string(content) != "version 2" {
+		t.Errorf("new binary was not uploaded: %q", content)
+	}
+	if commands, _ := os.ReadFile(log); !strings.Contains(string(commands), "systemctl --user restart test_unit\n") {
+		t.Errorf("service was not restarted:\n%s", commands)
+	}
+
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if _, err := os.Stat(u.UnitFilename()); !os.IsNotExist(err) {
+		t.Error("unit file was not removed from the host")
+	}
+}
+
+func TestRemoteSetup(t *testing.T) {
+	fakeRemote(t, "0")
+	if _, err := NewUnit("test_unit", OptRemote{Host: "prod1"}); !errors.Is(err, ErrRootNotAllowed) {
+		t.Errorf("user scope as root should fail, got %v", err)
+	}
+	u, err := NewUnit("test_unit", OptRemote{Host: "prod1", Path: "/opt/app/foobar"}, OptScope{Scope: ScopeSystem})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.UnitFilename() != "/etc/systemd/system/test_unit.service" || u.binaryPath != "/opt/app" || u.escalate != nil {
+		t.Errorf("wrong remote system unit %s %s %v", u.UnitFilename(), u.binaryPath, u.escalate)
+	}
+
+	fakeRemote(t, "1000")
+	if _, err := NewUnit("test_unit", OptRemote{Host: "prod1"}, OptScope{Scope: ScopeSystem}); err == nil {
+		t.Error("system scope without root or sudo should fail")
+	}
+	u, err = NewUnit("test_unit", OptRemote{Host: "prod1"}, OptScope{Scope: ScopeSystem}, OptEscalate{Method: EscalateSudo})
+	if err != nil || strings.Join(u.escalate, " ") != "sudo -n" {
+		t.Errorf("remote escalation should use sudo -n: %v %v", u.escalate, err)
+	}
+
+	for _, host := range []string{"", "-oProxyCommand=x"} {
+		if err := (OptRemote{Host: host}).Apply(&Unit{}); err == nil {
+			t.Errorf("host %q should be rejected", host)
+		}
+	}
+}
+
+func TestRemoteTools(t *testing.T) {
+	_, log := fakeRemote(t, "1000")
+	tools := t.TempDir()
+	for name, out := range map[string]string{
+		"journalctl":      "hello from the host",
+		"systemd-analyze": "→ Overall exposure level for test_unit.service: 4.2 OK",
+	} {
+		os.WriteFile(filepath.Join(tools, name), []byte("#!/bin/sh\necho '"+out+"'\n"), 0700)
+	}
+	t.Setenv("PATH", tools+":"+os.Getenv("PATH"))
+
+	u, err := NewUnit("test_unit", OptRemote{Host: "prod1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := u.Logs(LogOptions{Lines: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(logs)
+	logs.Close()
+	if string(content) != "hello from the host\n" {
+		t.Errorf("wrong logs %q", content)
+	}
+	if report, err := u.Security(); err != nil || report.Exposure != 4.2 {
+		t.Errorf("wrong report %+v: %v", report, err)
+	}
+	commands, _ := os.ReadFile(log)
+	for _, want := range []string{"prod1 journalctl --user -u test_unit", "prod1 systemd-analyze --user security"} {
+		if !strings.Contains(string(commands), want) {
+			t.Errorf("%s was not run on the host:\n%s", want, commands)
+		}
+	}
+	if _, err := u.SecurityPreview(); err == nil {
+		t.Error("SecurityPreview of a remote unit should fail")
+	}
+}
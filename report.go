@@ -0,0 +1,118 @@
+package unitard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Report is a record of what Deploy, Undeploy or Upgrade did, for audit
+// logs. It marshals to JSON.
+type Report struct {
+	Unit      string        // the unit's name
+	Operation string        // "deploy", "undeploy" or "upgrade"
+	Started   time.Time     // when it started
+	Duration  time.Duration // how long it took
+	Before    *Status       // the state of the service beforehand, if known
+	After     *Status       // and afterwards
+	Steps     []ReportStep  // the steps taken, in order
+	Error     string        // why it failed, if it did
+}
+
+// ReportStep is one step in a Report.
+type ReportStep struct {
+	Action
+	SHA256   string        // the hex encoded sha256 of the file written, if Kind is ActionWrite
+	Started  time.Time     // when the step started
+	Duration time.Duration // until the next step started, or the end
+}
+
+// OptReport calls Func with a Report after each Deploy, Undeploy and
+// Upgrade, whether or not it succeeded, including those of a Batch (each
+// unit of which is reported) or Fleet. File contents are left out of the report,
+// only their checksums are kept.
+type OptReport struct {
+	Func func(Report)
+}
+
+func (o OptReport) Apply(u *Unit) error {
+	if o.Func == nil {
+		return errors.New("OptReport needs a Func")
+	}
+	u.reportFunc = o.Func
+	return nil
+}
+
+// reportRecorder collects the steps for a Report.
+type reportRecorder struct {
+	mu    sync.Mutex
+	steps []ReportStep
+}
+
+func (r *reportRecorder) add(a Action) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	step := ReportStep{Action: a, Started: time.Now()}
+	if a.Kind == ActionWrite && a.Content != "" {
+		sum := sha256.Sum256([]byte(a.Content))
+		step.SHA256 = hex.EncodeToString(sum[:])
+	}
+	step.Content = ""
+	r.steps = append(r.steps, step)
+}
+
+// reports returns true if the unit should record a Report for OptReport,
+// which it isn't already doing.
+func (u Unit) reports() bool {
+	return u.reportFunc != nil && u.recorder == nil && u.plan == nil
+}
+
+// reported runs operation on the unit, recording a Report for OptReport.
+func (u Unit) reported(operation string, run func(Unit) error) error {
+	u, done := u.startReport(operation)
+	err := run(u)
+	done(err)
+	return err
+}
+
+// startReport starts recording a Report of operation, returning the unit
+// to run it with, and the func which sends the Report when it is over.
+func (u Unit) startReport(operation string) (Unit, func(error)) {
+	r := &reportRecorder{}
+	u.recorder = r
+	report := Report{Unit: u.serviceName(), Operation: operation, Before: u.reportStatus()}
+	report.Started = time.Now()
+	return u, func(err error) {
+		end := time.Now()
+		report.Duration = end.Sub(report.Started)
+		report.After = u.reportStatus()
+		if err != nil {
+			report.Error = err.Error()
+		}
+
+		report.Steps = r.steps
+		for i := range report.Steps {
+			next := end
+			if i+1 < len(report.Steps) {
+				next = report.Steps[i+1].Started
+			}
+			report.Steps[i].Duration = next.Sub(report.Steps[i].Started)
+		}
+		u.reportFunc(report)
+	}
+}
+
+// reportStatus returns the state of the service for a Report, or nil if
+// there isn't one to get.
+func (u Unit) reportStatus() *Status {
+	if u.root != "" || u.isTemplate() || u.slice || u.target {
+		return nil
+	}
+	status, err := u.Status()
+	if err != nil {
+		return nil
+	}
+	return &status
+}
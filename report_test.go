@@ -0,0 +1,92 @@
+package unitard
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	systemctl, _ := fakeSystemctl(t, "")
+	reports := []Report{}
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+	(OptReport{Func: func(r Report) { reports = append(reports, r) }}).Apply(&u)
+
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Undeploy(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || reports[0].Operation != "deploy" || reports[1].Operation != "undeploy" {
+		t.Fatalf("expected a deploy and an undeploy report, got %+v", reports)
+	}
+
+	r := reports[0]
+	if r.Unit != "test_unit" || r.Error != "" || r.Before == nil || r.After == nil || r.Started.IsZero() {
+		t.Errorf("wrong report %+v", r)
+	}
+	if len(r.Steps) == 0 || r.Steps[0].Kind != ActionWrite || r.Steps[0].Path != u.UnitFilename() || len(r.Steps[0].SHA256) != 64 || r.Steps[0].Content != "" {
+		t.Errorf("unit file write not reported: %+v", r.Steps)
+	}
+	commands := []string{}
+	for _, s := range r.Steps {
+		if s.Kind == ActionRun {
+			commands = append(commands, strings.Join(s.Command[1:], " "))
+		}
+	}
+	if strings.Join(commands, "\n") != "--user daemon-reload\n--user enable test_unit\n--user restart test_unit" {
+		t.Errorf("wrong commands reported:\n%s", strings.Join(commands, "\n"))
+	}
+
+	out, err := json.Marshal(r)
+	if err != nil || !strings.Contains(string(out), `"Operation":"deploy"`) {
+		t.Errorf("report does not marshal: %v %s", err, out)
+	}
+
+	// a dry run is not reported
+	if _, err := u.DryRun(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 {
+		t.Errorf("dry run should not be reported")
+	}
+}
+
+func TestReportBatchUpgrade(t *testing.T) {
+	reports := []Report{}
+	report := OptReport{Func: func(r Report) { reports = append(reports, r) }}
+	client := &recordingClient{dir: t.TempDir()}
+	one := Unit{name: "one", binary: "/fullpath/to/one", skipVerify: true, client: client, unitFilePath: client.dir}
+	two := Unit{name: "two", binary: "/fullpath/to/two", skipVerify: true, client: client, unitFilePath: client.dir}
+	report.Apply(&one)
+	report.Apply(&two)
+	b, _ := NewBatch(one, two)
+	if err := b.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || reports[0].Unit != "one" || reports[1].Unit != "two" || reports[1].Operation != "deploy" {
+		t.Fatalf("expected a report for each unit of the batch, got %+v", reports)
+	}
+	if steps := reports[1].Steps; len(steps) == 0 || steps[0].Path != two.UnitFilename() || steps[len(steps)-1].Command[1] != "restart" {
+		t.Errorf("wrong steps for two: %+v", steps)
+	}
+
+	reports = nil
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "foobar")
+	os.WriteFile(binary, []byte("#!/bin/sh\n# build 1\n"), 0755)
+	systemctl, _ := fakeUpgradeSystemctl(t, binary)
+	u := Unit{name: "test_unit", binary: binary, binaryPath: dir, binaryHash: fileHash(binary), skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+	report.Apply(&u)
+	build2 := filepath.Join(t.TempDir(), "foobar")
+	os.WriteFile(build2, []byte("#!/bin/sh\n# build 2\n"), 0755)
+	if err := u.Upgrade(build2, UpgradeOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Operation != "upgrade" || reports[0].Error != "" || len(reports[0].Steps) == 0 {
+		t.Errorf("expected an upgrade report, got %+v", reports)
+	}
+}
@@ -0,0 +1,157 @@
+package unitard
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// RollbackError is returned by Deploy when the new unit could not be
+// started. The unit files that were installed before have been restored
+// (or the new ones removed, for a first deploy) and started again.
+type RollbackError struct {
+	Err         error // why the deploy failed
+	RollbackErr error // why the rollback failed, nil if it succeeded
+}
+
+func (e *RollbackError) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("deploy failed: %s; rollback also failed: %s", e.Err, e.RollbackErr)
+	}
+	return fmt.Sprintf("deploy failed and was rolled back: %s", e.Err)
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// backup is the previous contents of a unit file, or of a credential.
+type backup struct {
+	name    string
+	content []byte
+	existed bool
+	secret  bool // a credential, restored with the mode of secrets
+}
+
+// backup saves the currently installed unit files, so they can be restored
+// if the deploy fails.
+func (u Unit) backup() ([]backup, error) {
+	backups := []backup{}
+	for _, f := range u.unitFiles() {
+		content, err := u.readFile(f.name)
+		if os.IsNotExist(err) {
+			backups = append(backups, backup{name: f.name})
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("could not back up unit file '%s': %w", f.name, err)
+		}
+		backups = append(backups, backup{name: f.name, content: content, existed: true})
+	}
+	return backups, nil
+}
+
+// rollback restores the backed up unit files after a failed deploy, and
+// starts the previous version again, enabling it again if reenable is set.
+// If there was no previous version, the new unit is stopped, disabled and
+// removed.
+func (u Unit) rollback(backups []backup, reenable bool, deployErr error) error {
+	// roll back even if the deploy was cancelled
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(u.context()), rollbackTimeout)
+	defer cancel()
+	rollbackErr := u.withContext(ctx).restore(backups, reenable)
+	return &RollbackError{Err: deployErr, RollbackErr: rollbackErr}
+}
+
+func (u Unit) restore(backups []backup, reenable bool) error {
+	if u.backend != nil {
+		return u.restoreBackend(backups)
+	}
+	existed := existedBefore(backups)
+	if !existed && !u.isTemplate() {
+		for _, unit := range u.activeUnits() {
+			// errors are expected, the unit may never have started
+			if u.quadlet != nil {
+				u.systemctl("stop", unit)
+			} else {
+				u.disableAndStop(unit)
+			}
+		}
+	}
+
+	err := u.restoreFiles(backups)
+	if err != nil {
+		return err
+	}
+	err = u.systemctl("daemon-reload")
+	if err != nil || !existed || u.isTemplate() {
+		return err
+	}
+	if reenable && u.deployMode != DeployStartOnly {
+		// the deploy disabled the service to change its links, so enable
+		// it again with the old [Install] section
+		for _, unit := range u.activeUnits() {
+			err := u.systemctl("disable", unit)
+			if err == nil {
+				err = u.systemctl("enable", unit)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	restart := "restart"
+	if u.target {
+		restart = "start"
+	}
+	for _, unit := range u.activeUnits() {
+		err := u.systemctl(restart, unit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// existedBefore returns true if the unit was deployed before the backups
+// were taken. It was if its unit files were not backed up, as only the
+// credentials changed.
+func existedBefore(backups []backup) bool {
+	files := false
+	for _, b := range backups {
+		if b.secret {
+			continue
+		}
+		if b.existed {
+			return true
+		}
+		files = true
+	}
+	return !files
+}
+
+// restoreFiles puts back the backed up files, removing those which did not
+// exist before.
+func (u Unit) restoreFiles(backups []backup) error {
+	for _, b := range backups {
+		var err error
+		if b.existed && b.secret {
+			err = u.writeFile(b.name, b.content, u.secretMode())
+		} else if b.existed {
+			content := b.content
+			err = u.createFile(b.name, func(f io.Writer) error {
+				_, err := f.Write(content)
+				return err
+			})
+		} else {
+			err = u.removeFile(b.name)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSystemctl writes a systemctl replacement which logs its arguments,
+// and fails when asked to run failCommand.
+func fakeSystemctl(t *testing.T, failCommand string) (string, string) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	script := filepath.Join(dir, "systemctl")
+	os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" >> "+log+"\n[ \"$2\" = \""+failCommand+"\" ] && [ ! -e "+dir+"/ok ] && touch "+dir+"/ok && exit 1\nexit 0\n"), 0700)
+	return script, log
+}
+
+func TestRollback(t *testing.T) {
+	systemctl, log := fakeSystemctl(t, "restart")
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+	files, _ := u.Render()
+	old := files[u.UnitFilename()]
+	os.WriteFile(u.UnitFilename(), []byte(old), 0600)
+
+	u.binaryArgs = "-broken"
+	err := u.Deploy()
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) || rollbackErr.RollbackErr != nil {
+		t.Fatalf("expected a successful rollback, got %v", err)
+	}
+	if content, _ := os.ReadFile(u.UnitFilename()); string(content) != old {
+		t.Errorf("old unit file was not restored:\n%s", content)
+	}
+	commands, _ := os.ReadFile(log)
+	if !strings.HasSuffix(string(commands), "--user daemon-reload\n--user restart test_unit\n") {
+		t.Errorf("previous version was not restarted:\n%s", commands)
+	}
+}
+
+func TestRollbackFirstDeploy(t *testing.T) {
+	systemctl, log := fakeSystemctl(t, "restart")
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+
+	err := u.Deploy()
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) || rollbackErr.RollbackErr != nil {
+		t.Fatalf("expected a successful rollback, got %v", err)
+	}
+	if _, err := os.Stat(u.UnitFilename()); !os.IsNotExist(err) {
+		t.Error("new unit file was not removed")
+	}
+	commands, _ := os.ReadFile(log)
+	if !strings.Contains(string(commands), "--user disable test_unit\n") {
+		t.Errorf("new unit was not disabled:\n%s", commands)
+	}
+}
+
+func TestRollbackReenable(t *testing.T) {
+	systemctl, log := fakeSystemctl(t, "restart")
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+	files, _ := u.Render()
+	os.WriteFile(u.UnitFilename(), []byte(files[u.UnitFilename()]), 0600)
+
+	(OptWantedBy{Alias: []string{"test_alias.service"}}).Apply(&u)
+	err := u.Deploy()
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) || rollbackErr.RollbackErr != nil {
+		t.Fatalf("expected a successful rollback, got %v", err)
+	}
+	commands, _ := os.ReadFile(log)
+	if !strings.HasSuffix(string(commands), "--user daemon-reload\n--user disable test_unit\n--user enable test_unit\n--user restart test_unit\n") {
+		t.Errorf("previous version was not enabled again:\n%s", commands)
+	}
+}
@@ -0,0 +1,81 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// vendorUnitDirectory and vendorUserUnitDirectory are where packages
+	// install unit files, relative to the root.
+	vendorUnitDirectory     = "usr/lib/systemd/system"
+	vendorUserUnitDirectory = "usr/lib/systemd/user"
+)
+
+// OptRoot stages the unit files under another root directory instead of
+// installing them, for building .deb or .rpm packages and container images.
+// The files are written to usr/lib/systemd/system (or usr/lib/systemd/user
+// for user scope) under Dir, with any logrotate configuration and log
+// directories, and systemctl is never run, so Deploy only writes the files
+// and Undeploy only removes them. Enabling the unit is left to the package's
+// install scripts (or a preset).
+//
+// Binary is where the program is installed on the target system, such as
+// /usr/bin/myapp; otherwise the unit points at the running program.
+type OptRoot struct {
+	Dir    string // the root directory, such as ./pkgroot
+	Binary string // the path of the program on the target system
+}
+
+func (o OptRoot) Apply(u *Unit) error {
+	if o.Dir == "" {
+		return errors.New("OptRoot needs a directory")
+	}
+	if o.Binary != "" && !filepath.IsAbs(o.Binary) {
+		return fmt.Errorf("sorry, binary '%s' must be absolute", o.Binary)
+	}
+	dir, err := filepath.Abs(o.Dir)
+	if err != nil {
+		return err
+	}
+	u.root = dir
+	if o.Binary != "" {
+		u.binary = o.Binary
+		u.binaryPath = filepath.Dir(o.Binary)
+	}
+	return nil
+}
+
+// setupRoot creates the unit directory under the root, in place of
+// setupEnvironment.
+func (u *Unit) setupRoot() error {
+	if u.backend != nil || u.remote != nil || u.client != nil {
+		return errors.New("OptRoot can only be used with systemd on this machine")
+	}
+	u.unitFilePath = u.rooted(vendorUnitDirectory)
+	if u.scope == ScopeUser {
+		u.unitFilePath = u.rooted(vendorUserUnitDirectory)
+	}
+	if u.unitDirectory != "" {
+		u.unitFilePath = u.rooted(u.unitDirectory)
+	}
+	if u.dryRun {
+		return nil
+	}
+	err := os.MkdirAll(u.unitFilePath, 0755)
+	if err != nil {
+		return fmt.Errorf("cannot create the unit directory '%s': %w", u.unitFilePath, err)
+	}
+	return nil
+}
+
+// rooted returns where a file on the target system is written, which is
+// under the root with OptRoot.
+func (u Unit) rooted(name string) string {
+	if u.root == "" {
+		return name
+	}
+	return filepath.Join(u.root, name)
+}
@@ -0,0 +1,53 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoot(t *testing.T) {
+	// systemctl must not be needed
+	t.Setenv("PATH", "")
+	root := t.TempDir()
+	u, err := NewUnit("test_unit", OptRoot{Dir: root, Binary: "/usr/bin/foobar"}, OptScope{Scope: ScopeSystem},
+		OptOutput{Stdout: "append:/var/log/foobar/out.log", Logrotate: true})
+	if err != nil {
+		t.Fatalf("could not create staged unit: %s", err)
+	}
+	if u.UnitFilename() != filepath.Join(root, "usr/lib/systemd/system/test_unit.service") {
+		t.Errorf("wrong unit file %s", u.UnitFilename())
+	}
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	content, err := os.ReadFile(u.UnitFilename())
+	if err != nil || !strings.Contains(string(content), "ExecStart=/usr/bin/foobar") ||
+		!strings.Contains(string(content), "WorkingDirectory=/usr/bin\n") {
+		t.Errorf("unit file was not staged: %s\n%s", err, content)
+	}
+	for _, name := range []string{"etc/logrotate.d/test_unit", "var/log/foobar"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Errorf("%s was not staged: %s", name, err)
+		}
+	}
+
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if _, err := os.Stat(u.UnitFilename()); !os.IsNotExist(err) {
+		t.Error("unit file was not removed")
+	}
+
+	u, err = NewUnit("test_unit", OptRoot{Dir: root})
+	if err != nil || u.UnitFilename() != filepath.Join(root, "usr/lib/systemd/user/test_unit.service") {
+		t.Errorf("wrong user unit file %s: %v", u.UnitFilename(), err)
+	}
+	if _, err := NewUnit("test_unit", OptRoot{Dir: root}, OptRemote{Host: "prod1"}); err == nil {
+		t.Error("OptRoot with OptRemote should fail")
+	}
+	if err := (OptRoot{Dir: root, Binary: "bin/foobar"}).Apply(&Unit{}); err == nil {
+		t.Error("relative binary should be rejected")
+	}
+}
@@ -0,0 +1,284 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const (
+	// runitDirectory is where runit service directories are created.
+	runitDirectory = "/etc/sv"
+	// runitServiceDirectory is the directory runsvdir scans on Void Linux.
+	runitServiceDirectory = "/var/service"
+	// s6Directory is where s6 service directories are created.
+	s6Directory = "/etc/s6/sv"
+	// s6ServiceDirectory is the directory s6-svscan usually scans.
+	s6ServiceDirectory = "/run/service"
+)
+
+// runitDirectives are the directives the runit backend maps to the run
+// script. Others are ignored, with a warning logged.
+var runitDirectives = map[string]bool{
+	markerManaged: true, "Environment": true, "User": true, "Group": true,
+	"StandardOutput": true, "StandardError": true,
+}
+
+// OptRunit deploys the unit as a runit service, for Void Linux and
+// containers which use runit (or, with S6, s6) as their supervisor. A
+// service directory with a run script is created in /etc/sv (or
+// /etc/s6/sv), and linked into the directory the supervisor scans, which
+// starts it and restarts it whenever it exits.
+//
+// The program arguments, Environment, User and Group (with chpst, or
+// s6-setuidgid) and file output from OptOutput are mapped to the run
+// script; otherwise the output goes wherever the supervisor sends it.
+// Timers, sockets and paths are not supported.
+//
+// For user scope, ServiceDir must be the directory your own runsvdir or
+// s6-svscan scans, and the service directory is created in ~/.config/sv.
+type OptRunit struct {
+	S6         bool   // use s6 instead of runit
+	ServiceDir string // the directory the supervisor scans, /var/service (runit) or /run/service (s6) by default
+}
+
+func (o OptRunit) Apply(u *Unit) error {
+	if o.ServiceDir != "" && !filepath.IsAbs(o.ServiceDir) {
+		return fmt.Errorf("sorry, service directory '%s' must be absolute", o.ServiceDir)
+	}
+	u.backend = runit{}
+	u.runitS6 = o.S6
+	u.runitServiceDir = o.ServiceDir
+	return nil
+}
+
+// runit is the backend for runit and s6.
+type runit struct{}
+
+func (runit) setup(u *Unit) error {
+	name := "runit"
+	tool := "sv"
+	if u.runitS6 {
+		name = "s6"
+		tool = "s6-svc"
+	}
+	err := u.checkBackendOptions(name)
+	if err != nil {
+		return err
+	}
+	if len(u.triggers) > 0 {
+		return fmt.Errorf("timers, sockets and paths cannot be used with %s", name)
+	}
+	u.warnUnsupported(name, runitDirectives)
+
+	u.toolPath = "/usr/bin"
+	if path, err := exec.LookPath(tool); err == nil {
+		u.toolPath = filepath.Dir(path)
+	} else if !u.dryRun {
+		return fmt.Errorf("%s not found: %w", tool, err)
+	}
+
+	if u.scope == ScopeSystem {
+		u.unitFilePath = runitDirectory
+		if u.runitS6 {
+			u.unitFilePath = s6Directory
+		}
+		if u.runitServiceDir == "" {
+			u.runitServiceDir = runitServiceDirectory
+			if u.runitS6 {
+				u.runitServiceDir = s6ServiceDirectory
+			}
+		}
+		if os.Getuid() != 0 && !u.dryRun {
+			escalate, err := u.escalation.command()
+			if err != nil {
+				return err
+			}
+			u.escalate = escalate
+		}
+		return nil
+	}
+	if u.runitServiceDir == "" {
+		return fmt.Errorf("user scope %s services need the ServiceDir your supervisor scans", name)
+	}
+	if os.Getuid() == 0 && !u.dryRun {
+		return ErrRootNotAllowed
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	u.unitFilePath = filepath.Join(dir, "sv")
+	return nil
+}
+
+// ServiceDirectory returns the full path to the service directory of a unit
+// deployed with OptRunit.
+func (u Unit) ServiceDirectory() string {
+	return filepath.Join(u.unitFilePath, u.name)
+}
+
+// runitLink returns the link to the service directory in the directory the
+// supervisor scans.
+func (u Unit) runitLink() string {
+	return filepath.Join(u.runitServiceDir, u.name)
+}
+
+func (runit) files(u Unit) []unitFile {
+	return []unitFile{{filepath.Join(u.ServiceDirectory(), "run"), withChecksum(u.writeRunScript)}}
+}
+
+func (u Unit) writeRunScript(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	environment := []string{}
+	for _, v := range u.environment() {
+		environment = append(environment, v.Key+"="+shellQuote(v.Value))
+	}
+	redirects := []string{}
+	for _, out := range []struct{ fd, key string }{{"", "StandardOutput"}, {"2", "StandardError"}} {
+		value := u.serviceDirective(out.key)
+		file, _ := outputFile(value)
+		switch {
+		case file == "":
+		case strings.HasPrefix(value, "append:"):
+			redirects = append(redirects, out.fd+">>"+shellQuote(file))
+		default:
+			redirects = append(redirects, out.fd+">"+shellQuote(file))
+		}
+	}
+
+	user := ""
+	if owner := u.serviceDirective("User"); owner != "" && u.runitS6 {
+		// s6-setuidgid uses the user's primary group
+		user = "s6-setuidgid " + shellQuote(owner) + " "
+	} else if owner != "" {
+		if group := u.serviceDirective("Group"); group != "" {
+			owner += ":" + group
+		}
+		user = "chpst -u " + shellQuote(owner) + " "
+	}
+	return t.ExecuteTemplate(f, "run", map[string]interface{}{
+		"Output":           strings.Join(redirects, " "),
+		"WorkingDirectory": shellQuote(u.binaryPath),
+		"Environment":      environment,
+		"User":             user,
+		"Command":          shellCommand(u.command()),
+	})
+}
+
+// svCommand runs sv, or the s6 equivalent, for the service.
+func (u Unit) svCommand(command ...string) error {
+	if u.runitS6 {
+		return u.runPrivileged(filepath.Join(u.toolPath, "s6-svc"), append(command, u.runitLink())...)
+	}
+	return u.runPrivileged(filepath.Join(u.toolPath, "sv"), append(command, u.runitLink())...)
+}
+
+// rescan tells s6-svscan to look for new or removed services; runsvdir
+// looks every five seconds by itself.
+func (u Unit) rescan() error {
+	if !u.runitS6 {
+		return nil
+	}
+	return u.runPrivileged(filepath.Join(u.toolPath, "s6-svscanctl"), "-a", u.runitServiceDir)
+}
+
+func (runit) start(u Unit, changed bool) error {
+	if changed {
+		// run scripts must be executable
+		err := u.runPrivileged("chmod", "0755", filepath.Join(u.ServiceDirectory(), "run"))
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := os.Lstat(u.runitLink()); err != nil {
+		// the supervisor starts it once it is linked
+		err := u.runPrivileged("ln", "-s", "--", u.ServiceDirectory(), u.runitLink())
+		if err != nil {
+			return err
+		}
+		return u.rescan()
+	}
+	if !changed && !u.alwaysRestart {
+		return nil
+	}
+	if u.runitS6 {
+		return u.svCommand("-r")
+	}
+	return u.svCommand("restart")
+}
+
+func (runit) undeploy(u Unit) error {
+	if _, err := os.Lstat(u.runitLink()); err == nil {
+		// stop the service and its supervisor before unlinking it
+		if u.runitS6 {
+			err = u.svCommand("-wD", "-d")
+		} else {
+			err = u.svCommand("stop")
+			if err == nil {
+				err = u.svCommand("exit")
+			}
+		}
+		if err != nil {
+			return err
+		}
+		err = u.runPrivileged("rm", "--", u.runitLink())
+		if err != nil {
+			return err
+		}
+		err = u.rescan()
+		if err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	// the supervisor keeps its state in the service directory too
+	return u.runPrivileged("rm", "-rf", "--", u.ServiceDirectory())
+}
+
+func (runit) control(u Unit, command string) error {
+	down := filepath.Join(u.ServiceDirectory(), "down")
+	switch command {
+	case "enable":
+		// a down file stops the supervisor starting the service itself
+		return u.runPrivileged("rm", "-f", "--", down)
+	case "disable":
+		return u.runPrivileged("touch", down)
+	}
+	s6Flags := map[string]string{"start": "-u", "stop": "-d", "restart": "-r"}
+	switch {
+	case u.runitS6 && s6Flags[command] != "":
+		return u.svCommand(s6Flags[command])
+	case !u.runitS6 && (command == "start" || command == "stop" || command == "restart"):
+		return u.svCommand(command)
+	}
+	return notSupported(u.Backend(), command)
+}
+
+func (runit) status(u Unit) (Status, error) {
+	if _, err := os.Lstat(u.runitLink()); err != nil {
+		return backendStatus(false, false), nil
+	}
+	if u.runitS6 {
+		out, err := u.runOutput(filepath.Join(u.toolPath, "s6-svstat"), u.runitLink())
+		return backendStatus(true, err == nil && strings.HasPrefix(out, "up ")), nil
+	}
+	out, err := u.runOutput(filepath.Join(u.toolPath, "sv"), "status", u.runitLink())
+	return backendStatus(true, err == nil && strings.HasPrefix(out, "run: ")), nil
+}
+
+func (runit) kind(u Unit) Backend {
+	if u.runitS6 {
+		return BackendS6
+	}
+	return BackendRunit
+}
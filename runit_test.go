@@ -0,0 +1,104 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunit(t *testing.T) {
+	tools := t.TempDir()
+	log := filepath.Join(tools, "log")
+	for _, name := range []string{"sv", "s6-svc", "s6-svscanctl"} {
+		os.WriteFile(filepath.Join(tools, name), []byte("#!/bin/sh\necho $(basename $0) \"$@\" >> "+log+"\n"), 0700)
+	}
+	active := t.TempDir()
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", binaryPath: "/fullpath/to", binaryArgs: "-x 'y z'",
+		scope: ScopeSystem, backend: runit{}, toolPath: tools, unitFilePath: t.TempDir(), runitServiceDir: active}
+	u.addDirective(SectionService, "Environment", `"GREETING=hello world"`)
+	u.addDirective(SectionService, "User", "nobody")
+	u.addDirective(SectionService, "Group", "nogroup")
+	u.addDirective(SectionService, "StandardOutput", "append:/var/log/test.log")
+
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	run := filepath.Join(u.ServiceDirectory(), "run")
+	content, err := os.ReadFile(run)
+	if err != nil {
+		t.Fatalf("run script was not written: %s", err)
+	}
+	for _, want := range []string{
+		"#!/bin/sh\n",
+		"\nexec >>/var/log/test.log\n",
+		"\ncd /fullpath/to || exit 1\n",
+		"\nexport GREETING='hello world'\n",
+		"\nexec chpst -u nobody:nogroup /fullpath/to/foobar -x 'y z'\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("run script does not contain %q:\n%s", want, content)
+		}
+	}
+	if info, _ := os.Stat(run); info.Mode()&0111 == 0 {
+		t.Error("run script is not executable")
+	}
+	if target, _ := os.Readlink(u.runitLink()); target != u.ServiceDirectory() {
+		t.Errorf("service is linked to %q", target)
+	}
+
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("second deploy failed: %s", err)
+	}
+	u.alwaysRestart = true
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("third deploy failed: %s", err)
+	}
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if _, err := os.Lstat(u.runitLink()); !os.IsNotExist(err) {
+		t.Error("link was not removed")
+	}
+	if _, err := os.Stat(u.ServiceDirectory()); !os.IsNotExist(err) {
+		t.Error("service directory was not removed")
+	}
+	link := u.runitLink()
+	want := "sv restart " + link + "\nsv stop " + link + "\nsv exit " + link + "\n"
+	if commands, _ := os.ReadFile(log); string(commands) != want {
+		t.Errorf("wrong commands:\n%s", commands)
+	}
+
+	user := Unit{name: "test_unit", dryRun: true}
+	if err := (runit{}).setup(&user); err == nil {
+		t.Error("user scope without a service directory should fail")
+	}
+}
+
+func TestS6(t *testing.T) {
+	tools := t.TempDir()
+	log := filepath.Join(tools, "log")
+	for _, name := range []string{"s6-svc", "s6-svscanctl"} {
+		os.WriteFile(filepath.Join(tools, name), []byte("#!/bin/sh\necho $(basename $0) \"$@\" >> "+log+"\n"), 0700)
+	}
+	active := t.TempDir()
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", binaryPath: "/fullpath/to",
+		scope: ScopeSystem, backend: runit{}, toolPath: tools, unitFilePath: t.TempDir(), runitS6: true, runitServiceDir: active}
+	u.addDirective(SectionService, "User", "nobody")
+
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(u.ServiceDirectory(), "run"))
+	if !strings.Contains(string(content), "\nexec s6-setuidgid nobody /fullpath/to/foobar\n") {
+		t.Errorf("run script does not use s6-setuidgid:\n%s", content)
+	}
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	link := u.runitLink()
+	want := "s6-svscanctl -a " + active + "\ns6-svc -wD -d " + link + "\ns6-svscanctl -a " + active + "\n"
+	if commands, _ := os.ReadFile(log); string(commands) != want {
+		t.Errorf("wrong commands:\n%s", commands)
+	}
+}
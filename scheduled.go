@@ -0,0 +1,46 @@
+package unitard
+
+import (
+	"errors"
+	"time"
+)
+
+// OptJob makes the service a job which runs to completion (Type=oneshot),
+// as for a program run on a schedule, instead of a long-running service.
+// MaxRuntime stops a run which takes longer (RuntimeMaxSec=), so a stuck
+// job doesn't hold up the next one.
+type OptJob struct {
+	MaxRuntime time.Duration // how long a run may take, unlimited if zero
+}
+
+func (o OptJob) Apply(u *Unit) error {
+	if o.MaxRuntime < 0 {
+		return errors.New("job runtime cannot be negative")
+	}
+	err := u.addDirective(SectionService, "Type", "oneshot")
+	if err != nil || o.MaxRuntime == 0 {
+		return err
+	}
+	return u.addDirective(SectionService, "RuntimeMaxSec", timespan(o.MaxRuntime))
+}
+
+// DeployScheduled creates and deploys a job run on a schedule, the common
+// replacement for a cron entry, in one call. The program is run as an
+// OptJob by a persistent timer, so a run missed while the machine was off
+// happens when it is next up, with schedule as its OnCalendar (such as
+// "daily" or "Mon *-*-* 09:00:00"). Pass an OptJob to limit its runtime;
+// for other timers, use NewUnit with OptTimer.
+//
+// The timer is enabled and started and the unit returned, so the job can
+// be removed with its Undeploy, which removes the timer and the service.
+func DeployScheduled(name, schedule string, opts ...UnitOpts) (Unit, error) {
+	if schedule == "" {
+		return Unit{}, errors.New("DeployScheduled needs a schedule")
+	}
+	opts = append([]UnitOpts{OptJob{}, OptTimer{OnCalendar: schedule, Persistent: true}}, opts...)
+	u, err := NewUnit(name, opts...)
+	if err != nil {
+		return Unit{}, err
+	}
+	return u, u.Deploy()
+}
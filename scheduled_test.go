@@ -0,0 +1,48 @@
+package unitard
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeployScheduled(t *testing.T) {
+	t.Setenv("PATH", "")
+	root := t.TempDir()
+	u, err := DeployScheduled("test_unit", "daily", OptRoot{Dir: root, Binary: "/usr/bin/foobar"}, OptJob{MaxRuntime: time.Hour})
+	if err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	service, _ := os.ReadFile(u.UnitFilename())
+	for _, want := range []string{"Type=oneshot\n", "RuntimeMaxSec=3600s\n"} {
+		if !strings.Contains(string(service), want) {
+			t.Errorf("service is missing %q:\n%s", want, service)
+		}
+	}
+	timer, err := os.ReadFile(u.unitFilename("timer"))
+	if err != nil {
+		t.Fatalf("timer was not deployed: %s", err)
+	}
+	for _, want := range []string{"OnCalendar=daily\n", "Persistent=true\n"} {
+		if !strings.Contains(string(timer), want) {
+			t.Errorf("timer is missing %q:\n%s", want, timer)
+		}
+	}
+
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	for _, name := range []string{u.UnitFilename(), u.unitFilename("timer")} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+
+	if _, err := DeployScheduled("test_unit", "", OptRoot{Dir: root}); err == nil {
+		t.Error("expected an error without a schedule")
+	}
+	if err := (OptJob{MaxRuntime: -time.Second}).Apply(&Unit{}); err == nil {
+		t.Error("a negative runtime should be rejected")
+	}
+}
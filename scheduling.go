@@ -0,0 +1,93 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cpuRangeRegexp matches a CPU or range of CPUs, such as 3 or 0-3.
+var cpuRangeRegexp = regexp.MustCompile(`^[0-9]+(-[0-9]+)?$`)
+
+// OptScheduling tunes how the kernel schedules the service, for instance to
+// keep a background job out of the way of interactive work, or to protect
+// an important service from the OOM killer. Empty fields are left at the
+// systemd defaults, and values are validated before they are rendered into
+// the unit.
+//
+// Raising the priority (a negative Nice or OOMScoreAdjust, or the realtime
+// IO class) needs system scope.
+type OptScheduling struct {
+	Nice           string // -20 (most favourable) to 19 (least)
+	CPUAffinity    string // CPUs to run on, such as "0 1" or "0-3,6"
+	IOClass        string // IOSchedulingClass=: realtime, best-effort or idle
+	IOPriority     string // IOSchedulingPriority=: 0 (highest) to 7 (lowest)
+	OOMScoreAdjust string // -1000 (never killed) to 1000 (killed first)
+}
+
+func (o OptScheduling) Apply(u *Unit) error {
+	settings := []struct {
+		key      string
+		value    string
+		validate func(string) error
+	}{
+		{"Nice", o.Nice, validateRange(-20, 19)},
+		{"CPUAffinity", o.CPUAffinity, validateCPUAffinity},
+		{"IOSchedulingClass", o.IOClass, validateIOClass},
+		{"IOSchedulingPriority", o.IOPriority, validateRange(0, 7)},
+		{"OOMScoreAdjust", o.OOMScoreAdjust, validateRange(-1000, 1000)},
+	}
+	set := false
+	for _, s := range settings {
+		if s.value == "" {
+			continue
+		}
+		err := s.validate(s.value)
+		if err != nil {
+			return fmt.Errorf("bad %s '%s': %w", s.key, s.value, err)
+		}
+		err = u.addDirective(SectionService, s.key, s.value)
+		if err != nil {
+			return err
+		}
+		set = true
+	}
+	if !set {
+		return errors.New("OptScheduling needs at least one setting")
+	}
+	return nil
+}
+
+// validateRange returns a validator for an integer from low to high.
+func validateRange(low, high int) func(string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < low || n > high {
+			return fmt.Errorf("must be a number from %d to %d", low, high)
+		}
+		return nil
+	}
+}
+
+func validateCPUAffinity(value string) error {
+	cpus := strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(cpus) == 0 {
+		return errors.New("must list CPUs")
+	}
+	for _, cpu := range cpus {
+		if !cpuRangeRegexp.MatchString(cpu) {
+			return errors.New("must be CPU numbers or ranges, such as 0-3")
+		}
+	}
+	return nil
+}
+
+func validateIOClass(value string) error {
+	switch value {
+	case "realtime", "best-effort", "idle":
+		return nil
+	}
+	return errors.New("must be realtime, best-effort or idle")
+}
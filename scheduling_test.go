@@ -0,0 +1,36 @@
+package unitard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScheduling(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", unitFilePath: t.TempDir()}
+	err := OptScheduling{Nice: "10", CPUAffinity: "0-3,6", IOClass: "idle", OOMScoreAdjust: "500"}.Apply(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := u.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(files[u.UnitFilename()], "\nNice=10\nCPUAffinity=0-3,6\nIOSchedulingClass=idle\nOOMScoreAdjust=500\n") {
+		t.Errorf("scheduling settings are missing:\n%s", files[u.UnitFilename()])
+	}
+
+	for _, bad := range []OptScheduling{
+		{},
+		{Nice: "20"},
+		{Nice: "low"},
+		{CPUAffinity: "all"},
+		{CPUAffinity: ","},
+		{IOClass: "fast"},
+		{IOPriority: "8"},
+		{OOMScoreAdjust: "-1001"},
+	} {
+		if err := bad.Apply(&Unit{}); err == nil {
+			t.Errorf("%+v should be refused", bad)
+		}
+	}
+}
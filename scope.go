@@ -0,0 +1,156 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Scope determines whether a unit is deployed for the current user, or for
+// the whole system.
+type Scope int
+
+const (
+	// ScopeUser deploys to the user's systemd instance, in ~/.config/systemd/user
+	// (or $XDG_CONFIG_HOME/systemd/user).
+	// This is the default.
+	ScopeUser Scope = iota
+	// ScopeSystem deploys a machine-wide service into /etc/systemd/system.
+	// It must be run as root.
+	ScopeSystem
+)
+
+const systemUnitDirectory = "/etc/systemd/system"
+
+// systemOnlyDirectives are the directives which only make sense for system
+// scope units.
+var systemOnlyDirectives = map[string]bool{
+	"User":        true,
+	"Group":       true,
+	"DynamicUser": true,
+
+	"SupplementaryGroups": true,
+}
+
+// wantedBy returns the target the unit should be installed into.
+func (s Scope) wantedBy() string {
+	if s == ScopeSystem {
+		return "multi-user.target"
+	}
+	return "default.target"
+}
+
+// unitDirectory returns the directory unit files for the scope are
+// installed in.
+func (s Scope) unitDirectory() (string, error) {
+	if s == ScopeSystem {
+		return systemUnitDirectory, nil
+	}
+	return userUnitDirectory()
+}
+
+// OptUnitDirectory installs the unit files in Dir instead of the scope's
+// usual directory (/etc/systemd/system, or $XDG_CONFIG_HOME/systemd/user).
+// It must be a directory systemd loads units from, such as
+// /run/systemd/system or ~/.local/share/systemd/user; it is created if it
+// doesn't exist.
+type OptUnitDirectory struct {
+	Dir string
+}
+
+func (o OptUnitDirectory) Apply(u *Unit) error {
+	if !filepath.IsAbs(o.Dir) {
+		return fmt.Errorf("sorry, unit directory '%s' must be absolute", o.Dir)
+	}
+	u.unitDirectory = filepath.Clean(o.Dir)
+	return nil
+}
+
+// unitDirectoryOr returns the directory given with OptUnitDirectory, or dir
+// if there wasn't one.
+func (u Unit) unitDirectoryOr(dir string) string {
+	if u.unitDirectory != "" {
+		return u.unitDirectory
+	}
+	return dir
+}
+
+// OptScope selects the scope the unit is deployed in.
+type OptScope struct {
+	Scope Scope
+}
+
+func (o OptScope) Apply(u *Unit) error {
+	if o.Scope != ScopeUser && o.Scope != ScopeSystem {
+		return errors.New("unknown scope")
+	}
+	u.scope = o.Scope
+	return nil
+}
+
+// accountNameRegexp matches the user and group names systemd accepts.
+var accountNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]{0,30}$`)
+
+// OptUser sets the user and/or group the service runs as, and any
+// SupplementaryGroups for extra access (such as "adm" to read logs). It can
+// only be used with system scope, user units always run as the user.
+//
+// With Check, Deploy fails if the accounts don't exist. With Create, they
+// are created if they don't: a sysusers.d configuration is written and
+// systemd-sysusers run, so User is a system account with no login shell.
+// Undeploy removes the configuration but leaves the accounts, as they may
+// own files. Under OptRoot the configuration is staged in
+// usr/lib/sysusers.d for the package to apply on install.
+type OptUser struct {
+	User                string
+	Group               string
+	SupplementaryGroups []string
+	Check               bool // fail if the accounts don't exist
+	Create              bool // create the accounts with sysusers.d
+}
+
+func (o OptUser) Apply(u *Unit) error {
+	if o.User == "" && o.Group == "" {
+		return errors.New("OptUser needs a user or group")
+	}
+	if o.Check && o.Create {
+		return errors.New("OptUser cannot both Check and Create the accounts")
+	}
+	names := append([]string{}, o.SupplementaryGroups...)
+	if o.User != "" {
+		names = append(names, o.User)
+	}
+	if o.Group != "" {
+		names = append(names, o.Group)
+	}
+	for _, name := range names {
+		if !accountNameRegexp.MatchString(name) {
+			return fmt.Errorf("sorry, account name '%s' is not valid", name)
+		}
+	}
+	if o.User != "" {
+		err := u.addDirective(SectionService, "User", o.User)
+		if err != nil {
+			return err
+		}
+	}
+	if o.Group != "" {
+		err := u.addDirective(SectionService, "Group", o.Group)
+		if err != nil {
+			return err
+		}
+	}
+	if len(o.SupplementaryGroups) > 0 {
+		err := u.addDirective(SectionService, "SupplementaryGroups", strings.Join(o.SupplementaryGroups, " "))
+		if err != nil {
+			return err
+		}
+	}
+	if o.Check || o.Create {
+		o.SupplementaryGroups = names[:len(o.SupplementaryGroups)]
+		u.account = &o
+	}
+	return nil
+}
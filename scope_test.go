@@ -0,0 +1,72 @@
+package unitard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSystemScopeTemplate(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/usr/local/bin/foobar"}
+	for _, opt := range []UnitOpts{OptScope{Scope: ScopeSystem}, OptUser{User: "nobody", Group: "nogroup"}} {
+		err := opt.Apply(&u)
+		if err != nil {
+			t.Fatalf("failed to apply option: %s", err)
+		}
+	}
+	if err := u.validate(); err != nil {
+		t.Errorf("system unit with user should be valid: %s", err)
+	}
+
+	buff := bytes.NewBuffer(nil)
+	err := u.writeTemplate(buff)
+	if err != nil {
+		t.Errorf("failed to write template: %s", err)
+	}
+	t.Logf("template:\n%s", buff.String())
+
+	for _, want := range []string{"User=nobody\n", "Group=nogroup\n", "WantedBy=multi-user.target"} {
+		if !strings.Contains(buff.String(), want) {
+			t.Errorf("template does not contain %q", want)
+		}
+	}
+}
+
+func TestUserScopeWithUser(t *testing.T) {
+	u := Unit{}
+	_ = OptUser{User: "nobody"}.Apply(&u)
+	if u.validate() == nil {
+		t.Error("user scope with OptUser should not be valid")
+	}
+}
+
+func TestUserUnitDirectory(t *testing.T) {
+	t.Setenv("HOME", "/home/test")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	if dir, err := userUnitDirectory(); err != nil || dir != "/home/test/.config/systemd/user" {
+		t.Errorf("wrong default directory %s %v", dir, err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", "/home/test/conf")
+	if dir, err := userUnitDirectory(); err != nil || dir != "/home/test/conf/systemd/user" {
+		t.Errorf("XDG_CONFIG_HOME should be used, got %s %v", dir, err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", "relative")
+	if dir, err := userUnitDirectory(); err != nil || dir != "/home/test/.config/systemd/user" {
+		t.Errorf("a relative XDG_CONFIG_HOME should be ignored, got %s %v", dir, err)
+	}
+}
+
+func TestOptUnitDirectory(t *testing.T) {
+	if _, err := NewUnit("test_unit", OptUnitDirectory{Dir: "relative"}); err == nil {
+		t.Error("a relative directory should be refused")
+	}
+	for _, scope := range []Scope{ScopeUser, ScopeSystem} {
+		u, err := NewUnit("test_unit", OptScope{Scope: scope}, OptDryRun{}, OptUnitDirectory{Dir: "/run/systemd/units/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.UnitFilename() != "/run/systemd/units/test_unit.service" {
+			t.Errorf("unit should be in the given directory, not %s", u.UnitFilename())
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SecurityReport is the result of analysing a unit with
+// `systemd-analyze security`.
+type SecurityReport struct {
+	Exposure float64 // overall exposure, from 0.0 (most secure) to 10.0
+	Rating   string  // systemd's rating of the exposure, eg "OK", "MEDIUM" or "UNSAFE"
+	Findings []SecurityFinding
+}
+
+// SecurityFinding is a single check from the security analysis.
+type SecurityFinding struct {
+	Name        string  // the setting checked, eg "PrivateTmp="
+	Description string  // what the current configuration means
+	Exposure    float64 // how much this contributes to the overall exposure
+	Passed      bool    // true if the setting is already secure
+}
+
+var (
+	securityFindingRegexp = regexp.MustCompile(`^(✓|✗|\?)\s+(\S+)\s+(.*?)(?:\s+([0-9]+\.[0-9]+))?\s*$`)
+	securityOverallRegexp = regexp.MustCompile(`Overall exposure level for \S+: ([0-9]+\.[0-9]+) (\S+)`)
+)
+
+// OptMaxExposure makes Deploy fail if, once deployed, the service's overall
+// exposure level (from `systemd-analyze security`) is above the maximum.
+// The unit is left deployed, so it can be inspected.
+type OptMaxExposure struct {
+	Exposure float64 // maximum acceptable exposure, from 0.0 to 10.0
+}
+
+func (o OptMaxExposure) Apply(u *Unit) error {
+	if o.Exposure <= 0 || o.Exposure > 10 {
+		return errors.New("exposure must be greater than 0 and at most 10")
+	}
+	u.maxExposure = o.Exposure
+	return nil
+}
+
+// Security analyses the deployed service with `systemd-analyze security`.
+func (u Unit) Security() (SecurityReport, error) {
+	if u.isTemplate() {
+		return SecurityReport{}, errors.New("cannot analyse a template unit - choose an Instance")
+	}
+	return u.analyzeSecurity(u.serviceName() + ".service")
+}
+
+// SecurityPreview analyses the service as it would be deployed, without
+// deploying it. It needs systemd 252 or later, and can't be used with
+// OptRemote, as the files are rendered on this machine.
+func (u Unit) SecurityPreview() (SecurityReport, error) {
+	if u.remote != nil {
+		return SecurityReport{}, errors.New("SecurityPreview cannot be used with OptRemote - use Security once deployed")
+	}
+	dir, err := os.MkdirTemp("", "unitard-")
+	if err != nil {
+		return SecurityReport{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	preview, err := u.renderTo(dir)
+	if err != nil {
+		return SecurityReport{}, err
+	}
+	return u.analyzeSecurity("--offline=true", preview.UnitFilename())
+}
+
+// checkExposure returns an error if the deployed service is more exposed
+// than allowed by OptMaxExposure.
+func (u Unit) checkExposure() error {
+	if u.maxExposure == 0 || u.isTemplate() {
+		return nil
+	}
+	report, err := u.Security()
+	if err != nil {
+		return err
+	}
+	if report.Exposure > u.maxExposure {
+		return fmt.Errorf("exposure level %.1f (%s) is above the maximum of %.1f", report.Exposure, report.Rating, u.maxExposure)
+	}
+	return nil
+}
+
+func (u Unit) analyzeSecurity(args ...string) (SecurityReport, error) {
+	analyze, err := u.lookTool("systemd-analyze")
+	if err != nil {
+		return SecurityReport{}, fmt.Errorf("could not find systemd-analyze: %w", err)
+	}
+	args = append([]string{"security", "--no-pager"}, args...)
+	if u.scope == ScopeUser {
+		args = append([]string{"--user"}, args...)
+	}
+	out, err := u.runOutput(analyze, args...)
+	if err != nil {
+		return SecurityReport{}, err
+	}
+	return parseSecurity(out)
+}
+
+// parseSecurity parses the output of `systemd-analyze security`.
+func parseSecurity(out string) (SecurityReport, error) {
+	report := SecurityReport{}
+	found := false
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if m := securityOverallRegexp.FindStringSubmatch(line); m != nil {
+			report.Exposure, _ = strconv.ParseFloat(m[1], 64)
+			report.Rating = m[2]
+			found = true
+			continue
+		}
+		m := securityFindingRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		finding := SecurityFinding{
+			Name:        m[2],
+			Description: m[3],
+			Passed:      m[1] == "✓",
+		}
+		if m[4] != "" {
+			finding.Exposure, _ = strconv.ParseFloat(m[4], 64)
+		}
+		report.Findings = append(report.Findings, finding)
+	}
+	if !found {
+		return report, errors.New("could not find overall exposure level in systemd-analyze output")
+	}
+	return report, nil
+}
@@ -0,0 +1,51 @@
+package unitard
+
+import (
+	"testing"
+)
+
+const securityOutput = `  NAME                                                        DESCRIPTION                                                       EXPOSURE
+✗ RemoveIPC=                                                  Service user may be able to create IPC objects                         0.1
+✓ User=/DynamicUser=                                          Service runs under a static non-root user identity
+✗ SystemCallFilter=~@clock                                    Service does not filter system calls                                   0.2
+? PrivateNetwork=                                             Service has access to the host's network
+
+→ Overall exposure level for test_unit.service: 8.3 EXPOSED 🙁
+`
+
+func TestParseSecurity(t *testing.T) {
+	report, err := parseSecurity(securityOutput)
+	if err != nil {
+		t.Fatalf("could not parse: %s", err)
+	}
+	if report.Exposure != 8.3 || report.Rating != "EXPOSED" {
+		t.Errorf("wrong overall exposure %f %s", report.Exposure, report.Rating)
+	}
+	if len(report.Findings) != 4 {
+		t.Fatalf("expected 4 findings, got %d", len(report.Findings))
+	}
+
+	f := report.Findings[2]
+	if f.Name != "SystemCallFilter=~@clock" || f.Exposure != 0.2 || f.Passed {
+		t.Errorf("wrong finding %+v", f)
+	}
+	if f.Description != "Service does not filter system calls" {
+		t.Errorf("wrong description %q", f.Description)
+	}
+	if !report.Findings[1].Passed || report.Findings[1].Exposure != 0 {
+		t.Errorf("wrong passed finding %+v", report.Findings[1])
+	}
+
+	if _, err := parseSecurity("nonsense"); err == nil {
+		t.Error("expected an error without an overall exposure")
+	}
+}
+
+func TestMaxExposureOpts(t *testing.T) {
+	if (OptMaxExposure{Exposure: 11}).Apply(&Unit{}) == nil {
+		t.Error("exposure above 10 should not be valid")
+	}
+	if (OptMaxExposure{}).Apply(&Unit{}) == nil {
+		t.Error("zero exposure should not be valid")
+	}
+}
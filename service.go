@@ -0,0 +1,16 @@
+package unitard
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runUntilSignal runs run, cancelling it on an interrupt or SIGTERM, which
+// is how systemd, launchd and the rest ask a service to stop.
+func runUntilSignal(run func(ctx context.Context) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return run(ctx)
+}
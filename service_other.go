@@ -0,0 +1,22 @@
+//go:build !windows
+
+package unitard
+
+import (
+	"context"
+	"errors"
+)
+
+// windowsServiceBackend returns the backend for Windows services, which
+// can only be used on Windows.
+func windowsServiceBackend() (backend, error) {
+	return nil, errors.New("Windows services can only be deployed on Windows")
+}
+
+// RunService runs the program's main loop, cancelling ctx when the service
+// manager asks it to stop (with an interrupt or SIGTERM), and returns the
+// error run returns. Programs deployed as a Windows service must use it to
+// answer the Service Control Manager; elsewhere it is a convenience.
+func RunService(name string, run func(ctx context.Context) error) error {
+	return runUntilSignal(run)
+}
@@ -0,0 +1,20 @@
+package unitard
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRunService(t *testing.T) {
+	want := errors.New("finished")
+	err := RunService("test_unit", func(ctx context.Context) error {
+		if ctx.Err() != nil {
+			t.Error("context was cancelled early")
+		}
+		return want
+	})
+	if err != want {
+		t.Errorf("wrong error %v", err)
+	}
+}
@@ -0,0 +1,407 @@
+package unitard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// windowsServiceDirectives are the directives the Windows backend maps to
+// the service configuration. Others are ignored, with a warning logged.
+var windowsServiceDirectives = map[string]bool{
+	markerManaged: true, "Environment": true, "Restart": true, "RestartSec": true, "User": true,
+}
+
+// windowsServiceTimeout is how long to wait for a service to start or stop,
+// unless OptWaitActive gives a timeout.
+const windowsServiceTimeout = 30 * time.Second
+
+// windowsService is the backend for Windows, which installs the program as
+// a service with the Service Control Manager. The program must call
+// RunService so it can answer the SCM. Units with a timer use the Task
+// Scheduler instead.
+type windowsService struct{}
+
+// windowsServiceBackend returns the backend for Windows services.
+func windowsServiceBackend() (backend, error) {
+	return windowsService{}, nil
+}
+
+func (windowsService) setup(u *Unit) error {
+	if len(u.triggers) > 0 {
+		// scheduled jobs are tasks rather than services
+		u.backend = taskScheduler{}
+		return u.backend.setup(u)
+	}
+	err := u.checkBackendOptions("Windows services")
+	if err != nil {
+		return err
+	}
+	if u.scope != ScopeSystem {
+		return errors.New("Windows services are system wide, use OptScope with ScopeSystem")
+	}
+	u.warnUnsupported("Windows services", windowsServiceDirectives)
+	if !u.dryRun && !windows.GetCurrentProcessToken().IsElevated() {
+		return errors.New("Windows services can only be deployed by an administrator")
+	}
+	return nil
+}
+
+// files returns nothing, the service configuration lives in the SCM.
+func (windowsService) files(u Unit) []unitFile {
+	return nil
+}
+
+// serviceConfig returns the configuration of the service, as CreateService
+// would set it.
+func (u Unit) serviceConfig() mgr.Config {
+	binaryPathName := syscall.EscapeArg(u.binary)
+	for _, arg := range splitQuoted(u.binaryArgs) {
+		binaryPathName += " " + syscall.EscapeArg(arg)
+	}
+	return mgr.Config{
+		ServiceType:      windows.SERVICE_WIN32_OWN_PROCESS,
+		StartType:        mgr.StartAutomatic,
+		ErrorControl:     mgr.ErrorNormal,
+		BinaryPathName:   binaryPathName,
+		DisplayName:      u.name,
+		Description:      "automatically created with github.com/tardisx/unitard",
+		ServiceStartName: u.serviceDirective("User"),
+	}
+}
+
+func (windowsService) start(u Unit, changed bool) error {
+	want := u.serviceConfig()
+	if u.plan != nil {
+		// the SCM can't be asked without an administrator, so assume
+		// the service needs creating
+		u.step(Action{Kind: ActionRun, Command: []string{"sc.exe", "create", u.name, "binPath=", want.BinaryPathName, "start=", "auto"}})
+		u.step(Action{Kind: ActionRun, Command: []string{"sc.exe", "start", u.name}})
+		return nil
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("could not connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(u.name)
+	if err != nil {
+		u.step(Action{Kind: ActionRun, Command: []string{"sc.exe", "create", u.name, "binPath=", want.BinaryPathName, "start=", "auto"}})
+		s, err = m.CreateService(u.name, u.binary, want, splitQuoted(u.binaryArgs)...)
+		if err != nil {
+			return fmt.Errorf("could not create service '%s': %w", u.name, err)
+		}
+		changed = true
+	} else {
+		current, err := s.Config()
+		if err != nil {
+			s.Close()
+			return err
+		}
+		if current.BinaryPathName != want.BinaryPathName || current.ServiceStartName != want.ServiceStartName ||
+			current.StartType != want.StartType || current.Description != want.Description {
+			u.step(Action{Kind: ActionRun, Command: []string{"sc.exe", "config", u.name, "binPath=", want.BinaryPathName, "start=", "auto"}})
+			current.BinaryPathName = want.BinaryPathName
+			current.ServiceStartName = want.ServiceStartName
+			current.StartType = want.StartType
+			current.Description = want.Description
+			err = s.UpdateConfig(current)
+			if err != nil {
+				s.Close()
+				return fmt.Errorf("could not update service '%s': %w", u.name, err)
+			}
+			changed = true
+		}
+	}
+	defer s.Close()
+
+	envChanged, err := u.setServiceEnvironment()
+	if err != nil {
+		return err
+	}
+	err = s.SetRecoveryActions(u.recoveryActions(), 0)
+	if err != nil {
+		return err
+	}
+
+	status, err := s.Query()
+	if err != nil {
+		return err
+	}
+	if status.State != svc.Stopped && (changed || envChanged || u.alwaysRestart) {
+		err = u.stopService(s)
+		if err != nil {
+			return err
+		}
+		status.State = svc.Stopped
+	}
+	if status.State != svc.Stopped {
+		return nil
+	}
+	u.step(Action{Kind: ActionRun, Command: []string{"sc.exe", "start", u.name}})
+	err = s.Start()
+	if err != nil {
+		return fmt.Errorf("could not start service '%s': %w", u.name, err)
+	}
+	return u.waitService(s, svc.Running)
+}
+
+// setServiceEnvironment sets the service's environment variables in the
+// registry, returning true if they changed.
+func (u Unit) setServiceEnvironment() (bool, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+u.name, registry.QUERY_VALUE|registry.SET_VALUE)
+	if err != nil {
+		return false, err
+	}
+	defer k.Close()
+
+	want := []string{}
+	for _, v := range u.environment() {
+		want = append(want, v.Key+"="+v.Value)
+	}
+	current, _, err := k.GetStringsValue("Environment")
+	if err != nil {
+		current = []string{}
+	}
+	if reflect.DeepEqual(current, want) {
+		return false, nil
+	}
+	if len(want) == 0 {
+		return true, k.DeleteValue("Environment")
+	}
+	return true, k.SetStringsValue("Environment", want)
+}
+
+// recoveryActions maps Restart and RestartSec to the service's recovery
+// actions.
+func (u Unit) recoveryActions() []mgr.RecoveryAction {
+	switch u.serviceDirective("Restart") {
+	case "always", "on-failure", "on-abnormal", "on-abort", "on-watchdog":
+	default:
+		return []mgr.RecoveryAction{{Type: mgr.NoAction}}
+	}
+	delay := 100 * time.Millisecond
+	if sec := u.serviceDirective("RestartSec"); sec != "" {
+		if n, err := strconv.ParseFloat(sec, 64); err == nil {
+			delay = time.Duration(n * float64(time.Second))
+		} else if d, err := time.ParseDuration(strings.ReplaceAll(sec, " ", "")); err == nil {
+			delay = d
+		}
+	}
+	return []mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: delay}}
+}
+
+// stopService stops a running service and waits for it to stop.
+func (u Unit) stopService(s *mgr.Service) error {
+	u.step(Action{Kind: ActionRun, Command: []string{"sc.exe", "stop", u.name}})
+	_, err := s.Control(svc.Stop)
+	if err != nil {
+		return fmt.Errorf("could not stop service '%s': %w", u.name, err)
+	}
+	return u.waitService(s, svc.Stopped)
+}
+
+// waitService waits for the service to reach a state.
+func (u Unit) waitService(s *mgr.Service, state svc.State) error {
+	timeout := windowsServiceTimeout
+	if u.waitTimeout > 0 {
+		timeout = u.waitTimeout
+	}
+	ctx, cancel := context.WithTimeout(u.context(), timeout)
+	defer cancel()
+	for {
+		status, err := s.Query()
+		if err != nil {
+			return err
+		}
+		if status.State == state {
+			return nil
+		}
+		if state == svc.Running && status.State == svc.Stopped {
+			return fmt.Errorf("service '%s' stopped with exit code %d", u.name, status.Win32ExitCode)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for service '%s': %w", u.name, ctx.Err())
+		case <-time.After(300 * time.Millisecond):
+		}
+	}
+}
+
+func (windowsService) undeploy(u Unit) error {
+	if u.plan != nil {
+		u.step(Action{Kind: ActionRun, Command: []string{"sc.exe", "stop", u.name}})
+		u.step(Action{Kind: ActionRun, Command: []string{"sc.exe", "delete", u.name}})
+		return nil
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("could not connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(u.name)
+	if err != nil {
+		// not installed
+		return nil
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return err
+	}
+	if status.State != svc.Stopped {
+		err = u.stopService(s)
+		if err != nil {
+			return err
+		}
+	}
+	u.step(Action{Kind: ActionRun, Command: []string{"sc.exe", "delete", u.name}})
+	return s.Delete()
+}
+
+// RunService runs the program's main loop, cancelling ctx when the service
+// manager asks it to stop, and returns the error run returns. It should be
+// called by programs deployed as a Windows service, which must answer the
+// Service Control Manager or be killed. When the program is not running as
+// a service, or on other systems, run is cancelled on an interrupt (or
+// SIGTERM).
+func RunService(name string, run func(ctx context.Context) error) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		return runUntilSignal(run)
+	}
+	h := &serviceHandler{run: run}
+	err = svc.Run(name, h)
+	if err != nil {
+		return err
+	}
+	return h.err
+}
+
+// serviceHandler answers the SCM while run runs.
+type serviceHandler struct {
+	run func(ctx context.Context) error
+	err error
+}
+
+func (h *serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- h.run(ctx)
+	}()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case h.err = <-done:
+			if h.err != nil {
+				return false, 1
+			}
+			return false, 0
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
+
+func (windowsService) control(u Unit, command string) error {
+	if u.plan != nil {
+		u.step(Action{Kind: ActionRun, Command: []string{"sc.exe", command, u.name}})
+		return nil
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("could not connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(u.name)
+	if err != nil {
+		return fmt.Errorf("could not open service '%s': %w", u.name, err)
+	}
+	defer s.Close()
+
+	switch command {
+	case "enable", "disable":
+		config, err := s.Config()
+		if err != nil {
+			return err
+		}
+		config.StartType = mgr.StartAutomatic
+		if command == "disable" {
+			config.StartType = mgr.StartManual
+		}
+		return s.UpdateConfig(config)
+	case "stop":
+		return u.stopService(s)
+	case "start", "restart":
+		if command == "restart" {
+			status, err := s.Query()
+			if err != nil {
+				return err
+			}
+			if status.State != svc.Stopped {
+				err = u.stopService(s)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		u.step(Action{Kind: ActionRun, Command: []string{"sc.exe", "start", u.name}})
+		err = s.Start()
+		if err != nil {
+			return fmt.Errorf("could not start service '%s': %w", u.name, err)
+		}
+		return u.waitService(s, svc.Running)
+	}
+	return notSupported(BackendWindowsService, command)
+}
+
+func (windowsService) status(u Unit) (Status, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return Status{}, fmt.Errorf("could not connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(u.name)
+	if err != nil {
+		return backendStatus(false, false), nil
+	}
+	defer s.Close()
+	status, err := s.Query()
+	if err != nil {
+		return Status{}, err
+	}
+	result := backendStatus(true, status.State == svc.Running)
+	result.MainPID = int(status.ProcessId)
+	result.ExecMainStatus = int(status.Win32ExitCode)
+	return result, nil
+}
+
+func (windowsService) kind(u Unit) Backend {
+	return BackendWindowsService
+}
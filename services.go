@@ -0,0 +1,58 @@
+package unitard
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// Service is one of the services run by a program, for NewServices.
+type Service struct {
+	Name string     // the unit name, as for NewUnit
+	Args string     // the program arguments, such as the subcommand to run
+	Opts []UnitOpts // options for this service only
+}
+
+// NewServices creates a unit for each of several services run by this
+// program, such as "myapp web" and "myapp worker", and returns them as a
+// Batch to deploy and undeploy together. opts are given to every unit,
+// before the service's own Opts.
+//
+// With OptInstall in opts, the program is installed once for all of them,
+// named after the program rather than a unit, and removed when the batch is
+// undeployed (but not when just one of the units is).
+func NewServices(services []Service, opts ...UnitOpts) (Batch, error) {
+	if len(services) == 0 {
+		return Batch{}, errors.New("NewServices needs at least one service")
+	}
+	units := []Unit{}
+	for _, s := range services {
+		unitOpts := append(append([]UnitOpts{}, opts...), sharedInstall{})
+		if s.Args != "" {
+			unitOpts = append(unitOpts, OptProgramArgs{Args: s.Args})
+		}
+		u, err := NewUnit(s.Name, append(unitOpts, s.Opts...)...)
+		if err != nil {
+			return Batch{}, err
+		}
+		units = append(units, u)
+	}
+	return NewBatch(units...)
+}
+
+// sharedInstall makes OptInstall install the program under its own name,
+// once for all the units of NewServices.
+type sharedInstall struct{}
+
+func (o sharedInstall) Apply(u *Unit) error {
+	u.installShared = true
+	return nil
+}
+
+// installName returns the name OptInstall installs the program as.
+func (u Unit) installName() string {
+	if !u.installShared {
+		return u.name
+	}
+	return strings.TrimSuffix(filepath.Base(u.binary), filepath.Ext(u.binary))
+}
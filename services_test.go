@@ -0,0 +1,45 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewServices(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewServices([]Service{
+		{Name: "myapp_web", Args: "web -port 8080"},
+		{Name: "myapp_worker", Args: "worker", Opts: []UnitOpts{OptDependencies{After: []string{"myapp_web.service"}}}},
+	}, OptScope{Scope: ScopeSystem}, OptDryRun{}, OptInstall{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := b.Units()
+	if len(units) != 2 || units[0].name != "myapp_web" || units[1].binaryArgs != "worker" {
+		t.Fatalf("wrong units %+v", units)
+	}
+	program, _ := os.Executable()
+	installed := filepath.Join(dir, filepath.Base(program))
+	if units[0].binary != installed || units[1].binary != installed {
+		t.Errorf("units should share the installed program %s, not %s and %s", installed, units[0].binary, units[1].binary)
+	}
+
+	plan, err := b.DryRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	installs := 0
+	for _, a := range plan.Actions {
+		if a.Kind == ActionRun && a.Command[0] == "install" {
+			installs++
+		}
+	}
+	if installs != 1 {
+		t.Errorf("program should be installed once, not %d times:\n%+v", installs, plan.Actions)
+	}
+
+	if _, err := NewServices(nil); err == nil {
+		t.Error("no services should be refused")
+	}
+}
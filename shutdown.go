@@ -0,0 +1,59 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// killModes are the values systemd accepts for KillMode=.
+var killModes = map[string]bool{"control-group": true, "mixed": true, "process": true, "none": true}
+
+// signalRegexp matches a signal name, such as SIGINT or SIGRTMIN+3.
+var signalRegexp = regexp.MustCompile(`^SIG[A-Z0-9]+(\+[0-9]+)?$`)
+
+// OptShutdown sets how systemd stops the service. Signal is sent first
+// (SIGTERM by default), then after Timeout (90 seconds by default) anything
+// still running is killed with SIGKILL. KillMode chooses which processes
+// are signalled: "control-group" (the default) all of them, "mixed" only
+// the main process for Signal but all of them for SIGKILL, or "process"
+// only the main process.
+type OptShutdown struct {
+	Signal   string        // KillSignal=, such as "SIGINT"
+	KillMode string        // KillMode=
+	Timeout  time.Duration // TimeoutStopSec=
+}
+
+func (o OptShutdown) Apply(u *Unit) error {
+	if o.Signal == "" && o.KillMode == "" && o.Timeout == 0 {
+		return errors.New("OptShutdown needs a Signal, KillMode or Timeout")
+	}
+	if o.Signal != "" && !signalRegexp.MatchString(o.Signal) {
+		return fmt.Errorf("sorry, signal '%s' is not valid, use a name such as SIGINT", o.Signal)
+	}
+	if o.KillMode != "" && !killModes[o.KillMode] {
+		return fmt.Errorf("sorry, kill mode '%s' is not valid, use control-group, mixed or process", o.KillMode)
+	}
+	if o.Timeout < 0 {
+		return errors.New("stop timeout cannot be negative")
+	}
+	settings := []struct {
+		key   string
+		value string
+	}{
+		{"KillSignal", o.Signal},
+		{"KillMode", o.KillMode},
+		{"TimeoutStopSec", timespan(o.Timeout)},
+	}
+	for _, s := range settings {
+		if s.value == "" {
+			continue
+		}
+		err := u.addDirective(SectionService, s.key, s.value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
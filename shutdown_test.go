@@ -0,0 +1,30 @@
+package unitard
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", unitFilePath: t.TempDir()}
+	if err := (OptShutdown{Signal: "SIGINT", KillMode: "mixed", Timeout: 90 * time.Second}).Apply(&u); err != nil {
+		t.Fatal(err)
+	}
+	files, err := u.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(files[u.UnitFilename()], "\nKillSignal=SIGINT\nKillMode=mixed\nTimeoutStopSec=90s\n") {
+		t.Errorf("shutdown settings are missing:\n%s", files[u.UnitFilename()])
+	}
+
+	if err := (OptShutdown{Signal: "SIGRTMIN+3"}).Apply(&Unit{}); err != nil {
+		t.Errorf("real time signals should be allowed: %s", err)
+	}
+	for _, bad := range []OptShutdown{{}, {Signal: "INT"}, {Signal: "sigint"}, {KillMode: "all"}, {Timeout: -time.Second}} {
+		if err := bad.Apply(&Unit{}); err == nil {
+			t.Errorf("%+v should be refused", bad)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/template"
+)
+
+// OptSlice puts the service in name.slice, so the units of an application
+// can share the resource limits of a slice created with NewSlice. A slice
+// which hasn't been deployed is created by systemd without any limits.
+type OptSlice struct {
+	Name string
+}
+
+func (o OptSlice) Apply(u *Unit) error {
+	if !checkName(o.Name) {
+		return fmt.Errorf("sorry, slice name '%s' is not valid", o.Name)
+	}
+	return u.addDirective(SectionService, "Slice", o.Name+".slice")
+}
+
+// NewSlice creates a unit which deploys name.slice with resource limits
+// shared by all the units in it (see OptSlice). It is created like any
+// other unit, with unitOpts such as OptScope, and put in a Batch with the
+// units it holds is deployed before them. LimitNOFILE is per process, so
+// can't be set for a slice.
+func NewSlice(name string, limits OptResourceLimits, unitOpts ...UnitOpts) (Unit, error) {
+	if limits.LimitNOFILE != "" {
+		return Unit{}, errors.New("LimitNOFILE cannot be set for a slice, set it on each unit")
+	}
+	return NewUnit(name, append(append([]UnitOpts{}, unitOpts...), sliceLimits{limits})...)
+}
+
+// sliceLimits makes the unit a slice, with limits.
+type sliceLimits struct {
+	limits OptResourceLimits
+}
+
+func (o sliceLimits) Apply(u *Unit) error {
+	// the limits are validated as they are for a service
+	limited := Unit{}
+	err := o.limits.Apply(&limited)
+	if err != nil {
+		return err
+	}
+	for _, d := range limited.directives {
+		err := u.addDirective(SectionSlice, d.Key, d.Value)
+		if err != nil {
+			return err
+		}
+	}
+	u.slice = true
+	return nil
+}
+
+// SliceFilename returns the full path to the slice unit file of a unit
+// created by NewSlice.
+func (u Unit) SliceFilename() string {
+	return u.unitFilename("slice")
+}
+
+func (u Unit) writeSliceTemplate(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	return t.ExecuteTemplate(f, "basic.slice", map[string]interface{}{
+		"Description": u.name + " slice",
+		"Unit":        append(u.markerDirectives(), u.sectionDirectives(SectionUnit)...),
+		"Slice":       u.sectionDirectives(SectionSlice),
+	})
+}
+
+// undeploySlice removes the slice. Any units still in it keep running,
+// without its limits.
+func (u Unit) undeploySlice() error {
+	err := u.removeFile(u.SliceFilename())
+	if err != nil {
+		return err
+	}
+	return u.systemctl("daemon-reload")
+}
@@ -0,0 +1,65 @@
+package unitard
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSlice(t *testing.T) {
+	systemctl, log := fakeSystemctl(t, "")
+	dir := t.TempDir()
+	slice := Unit{name: "myapp", skipVerify: true, systemCtlPath: systemctl, unitFilePath: dir}
+	if err := (sliceLimits{OptResourceLimits{MemoryMax: "2G", CPUQuota: "150%"}}).Apply(&slice); err != nil {
+		t.Fatal(err)
+	}
+	web := Unit{name: "web", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: dir}
+	if err := (OptSlice{Name: "myapp"}).Apply(&web); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := NewBatch(web, slice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if units := b.Units(); units[0].name != "myapp" {
+		t.Errorf("slice should be deployed first, not %s", units[0].name)
+	}
+	if err := b.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(slice.SliceFilename())
+	if err != nil {
+		t.Fatalf("slice was not written: %s", err)
+	}
+	if !strings.Contains(string(content), "\n[Slice]\nMemoryMax=2G\nCPUQuota=150%\n") {
+		t.Errorf("slice does not have the limits:\n%s", content)
+	}
+	service, _ := os.ReadFile(web.UnitFilename())
+	if !strings.Contains(string(service), "\nSlice=myapp.slice\n") {
+		t.Errorf("service is not in the slice:\n%s", service)
+	}
+	commands, _ := os.ReadFile(log)
+	if strings.Contains(string(commands), "myapp.slice") {
+		t.Errorf("the slice should not be enabled or started:\n%s", commands)
+	}
+
+	if err := b.Undeploy(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(slice.SliceFilename()); !os.IsNotExist(err) {
+		t.Error("slice was not removed")
+	}
+}
+
+func TestSliceOpts(t *testing.T) {
+	if err := (OptSlice{Name: "my-app"}).Apply(&Unit{}); err == nil {
+		t.Error("bad slice name should be refused")
+	}
+	if _, err := NewSlice("myapp", OptResourceLimits{LimitNOFILE: "1024"}); err == nil {
+		t.Error("LimitNOFILE should be refused for a slice")
+	}
+	if err := (sliceLimits{}).Apply(&Unit{}); err == nil {
+		t.Error("a slice needs limits")
+	}
+}
@@ -0,0 +1,119 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OptSocket deploys a socket unit alongside the service, so that systemd
+// listens on the given addresses and starts the service on demand when the
+// first connection arrives. The program should use Listeners (and/or
+// PacketConns) to retrieve the sockets, rather than opening its own.
+//
+// Addresses are in any form systemd understands, eg "8080", "127.0.0.1:8080"
+// or "/run/user/1000/myapp.sock".
+type OptSocket struct {
+	ListenStream   []string // stream (TCP or unix) addresses
+	ListenDatagram []string // datagram (UDP or unix) addresses
+}
+
+func (o OptSocket) Apply(u *Unit) error {
+	if len(o.ListenStream) == 0 && len(o.ListenDatagram) == 0 {
+		return errors.New("socket needs at least one ListenStream or ListenDatagram address")
+	}
+	for _, addrs := range [][]string{o.ListenStream, o.ListenDatagram} {
+		for _, addr := range addrs {
+			if addr == "" || strings.Contains(addr, "\n") {
+				return errors.New("socket addresses cannot be empty or contain newlines")
+			}
+		}
+	}
+	return u.addTrigger(o)
+}
+
+func (o OptSocket) unitType() string {
+	return "socket"
+}
+
+func (o OptSocket) templateData() map[string]interface{} {
+	return map[string]interface{}{
+		"listenStream":   o.ListenStream,
+		"listenDatagram": o.ListenDatagram,
+	}
+}
+
+// listenFdsStart is the first file descriptor passed by systemd socket
+// activation, as per sd_listen_fds(3).
+const listenFdsStart = 3
+
+var (
+	activationOnce sync.Once
+	listeners      []net.Listener
+	packetConns    []net.PacketConn
+	activationErr  error
+)
+
+// Listeners returns the stream sockets passed to the program by systemd
+// socket activation. If the program was not started by socket activation,
+// an empty slice is returned.
+// The sockets are only retrieved once, subsequent calls return the same
+// listeners.
+func Listeners() ([]net.Listener, error) {
+	activationOnce.Do(activate)
+	return listeners, activationErr
+}
+
+// PacketConns returns the datagram sockets passed to the program by systemd
+// socket activation. If the program was not started by socket activation,
+// an empty slice is returned.
+// The sockets are only retrieved once, subsequent calls return the same
+// connections.
+func PacketConns() ([]net.PacketConn, error) {
+	activationOnce.Do(activate)
+	return packetConns, activationErr
+}
+
+// activate collects the file descriptors passed via LISTEN_FDS, and removes
+// the activation variables from the environment so that they are not
+// inherited by child processes.
+func activate() {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		// not for us
+		return
+	}
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	for i := 0; i < nfds; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(listenFdsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(listenFdsStart+i), name)
+
+		// the net package duplicates the descriptor, so we always close ours
+		if l, err := net.FileListener(f); err == nil {
+			listeners = append(listeners, l)
+		} else if pc, err := net.FilePacketConn(f); err == nil {
+			packetConns = append(packetConns, pc)
+		} else if activationErr == nil {
+			activationErr = fmt.Errorf("could not use activated socket '%s': %w", name, err)
+		}
+		f.Close()
+	}
+}
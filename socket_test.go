@@ -0,0 +1,60 @@
+package unitard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSocketTemplate(t *testing.T) {
+	u := Unit{name: "test_unit"}
+	err := OptSocket{ListenStream: []string{"8080", "/tmp/test.sock"}, ListenDatagram: []string{"5353"}}.Apply(&u)
+	if err != nil {
+		t.Fatalf("failed to apply socket: %s", err)
+	}
+
+	buff := bytes.NewBuffer(nil)
+	err = u.writeTriggerTemplate(buff, u.triggers[0])
+	if err != nil {
+		t.Errorf("failed to write template: %s", err)
+	}
+	t.Logf("template:\n%s", buff.String())
+
+	for _, want := range []string{"ListenStream=8080\n", "ListenStream=/tmp/test.sock\n", "ListenDatagram=5353\n", "WantedBy=sockets.target"} {
+		if !strings.Contains(buff.String(), want) {
+			t.Errorf("template does not contain %q", want)
+		}
+	}
+}
+
+func TestSocketOpts(t *testing.T) {
+	u := Unit{}
+	if (OptSocket{}).Apply(&u) == nil {
+		t.Error("empty socket should not be valid")
+	}
+	if (OptSocket{ListenStream: []string{""}}).Apply(&u) == nil {
+		t.Error("empty address should not be valid")
+	}
+	if (OptSocket{ListenStream: []string{"80"}}).Apply(&u) != nil {
+		t.Error("socket should be valid")
+	}
+	if (OptTimer{OnCalendar: "daily"}).Apply(&u) != nil {
+		t.Error("timer and socket together should be valid")
+	}
+	if len(u.activeUnits()) != 2 {
+		t.Errorf("expected socket and timer to be active, got %v", u.activeUnits())
+	}
+}
+
+func TestListenersNotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+
+	l, err := Listeners()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if len(l) != 0 {
+		t.Errorf("got listeners for another pid: %v", l)
+	}
+}
@@ -0,0 +1,118 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the state of a deployed service, as reported by systemd. Other
+// backends fill in LoadState and ActiveState, and what else they can.
+type Status struct {
+	LoadState     string // eg "loaded" or "not-found"
+	ActiveState   string // eg "active", "inactive" or "failed"
+	SubState      string // eg "running", "dead" or "auto-restart"
+	UnitFileState string // eg "enabled" or "disabled"
+	Result        string // eg "success" or "exit-code"
+
+	MainPID                int       // zero if not running
+	NRestarts              int       // number of automatic restarts
+	ExecMainStartTimestamp time.Time // when the main process last started, zero if never
+	ExecMainStatus         int       // exit code (or signal) of the last main process
+}
+
+// statusProperties are the properties requested from systemctl show.
+var statusProperties = []string{
+	"LoadState", "ActiveState", "SubState", "UnitFileState", "Result",
+	"MainPID", "NRestarts", "ExecMainStartTimestamp", "ExecMainStatus",
+}
+
+// systemdTimestamp is the format systemctl show uses for timestamps.
+const systemdTimestamp = "Mon 2006-01-02 15:04:05 MST"
+
+// Status returns the current state of the service.
+func (u Unit) Status() (Status, error) {
+	if u.isTemplate() {
+		return Status{}, errors.New("cannot get the status of a template unit - choose an Instance")
+	}
+	if u.backend != nil {
+		return u.backend.status(u)
+	}
+	props, err := u.show(u.serviceName(), statusProperties...)
+	if err != nil {
+		return Status{}, err
+	}
+	return parseStatus(props)
+}
+
+// show returns properties of a unit, with `systemctl show`.
+func (u Unit) show(unit string, properties ...string) (map[string]string, error) {
+	if u.client != nil {
+		return u.client.Show(u.context(), u.scope, unit, properties...)
+	}
+	if u.dbus {
+		return u.busShow(unit, properties...)
+	}
+	args := []string{"show", unit, "--property=" + strings.Join(properties, ",")}
+	if u.scope == ScopeUser {
+		args = append([]string{"--user"}, args...)
+	}
+	out, err := u.runOutput(u.systemCtlPath, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseProperties(out), nil
+}
+
+// parseProperties parses the Key=Value output of systemctl show.
+func parseProperties(out string) map[string]string {
+	props := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) == 2 {
+			props[kv[0]] = kv[1]
+		}
+	}
+	return props
+}
+
+func parseStatus(props map[string]string) (Status, error) {
+	s := Status{
+		LoadState:     props["LoadState"],
+		ActiveState:   props["ActiveState"],
+		SubState:      props["SubState"],
+		UnitFileState: props["UnitFileState"],
+		Result:        props["Result"],
+	}
+
+	ints := []struct {
+		key string
+		val *int
+	}{
+		{"MainPID", &s.MainPID},
+		{"NRestarts", &s.NRestarts},
+		{"ExecMainStatus", &s.ExecMainStatus},
+	}
+	for _, i := range ints {
+		if props[i.key] == "" {
+			continue
+		}
+		v, err := strconv.Atoi(props[i.key])
+		if err != nil {
+			return s, fmt.Errorf("bad %s '%s' from systemctl: %w", i.key, props[i.key], err)
+		}
+		*i.val = v
+	}
+
+	ts := props["ExecMainStartTimestamp"]
+	if ts != "" && ts != "n/a" {
+		t, err := time.ParseInLocation(systemdTimestamp, ts, time.Local)
+		if err != nil {
+			return s, fmt.Errorf("bad ExecMainStartTimestamp '%s' from systemctl: %w", ts, err)
+		}
+		s.ExecMainStartTimestamp = t
+	}
+	return s, nil
+}
@@ -0,0 +1,45 @@
+package unitard
+
+import (
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	out := `LoadState=loaded
+ActiveState=active
+SubState=running
+UnitFileState=enabled
+Result=success
+MainPID=1234
+NRestarts=2
+ExecMainStartTimestamp=Wed 2026-10-14 07:12:01 UTC
+ExecMainStatus=0
+`
+	s, err := parseStatus(parseProperties(out))
+	if err != nil {
+		t.Fatalf("could not parse status: %s", err)
+	}
+	if s.ActiveState != "active" || s.SubState != "running" || s.UnitFileState != "enabled" {
+		t.Errorf("wrong states %+v", s)
+	}
+	if s.MainPID != 1234 || s.NRestarts != 2 {
+		t.Errorf("wrong numbers %+v", s)
+	}
+	if s.ExecMainStartTimestamp.Year() != 2026 || s.ExecMainStartTimestamp.Minute() != 12 {
+		t.Errorf("wrong timestamp %s", s.ExecMainStartTimestamp)
+	}
+}
+
+func TestParseStatusNeverStarted(t *testing.T) {
+	s, err := parseStatus(parseProperties("ActiveState=inactive\nMainPID=0\nExecMainStartTimestamp=\n"))
+	if err != nil {
+		t.Fatalf("could not parse status: %s", err)
+	}
+	if !s.ExecMainStartTimestamp.IsZero() {
+		t.Errorf("expected zero timestamp, got %s", s.ExecMainStartTimestamp)
+	}
+
+	if _, err := parseStatus(parseProperties("MainPID=lots\n")); err == nil {
+		t.Error("expected an error for a bad MainPID")
+	}
+}
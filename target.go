@@ -0,0 +1,112 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// NewTarget creates a batch of units grouped under a name.target unit,
+// so the whole application can be controlled with, for instance,
+// systemctl --user start name.target. The target wants each of the units
+// (or their triggers), and the services are made PartOf it, so stopping or
+// restarting the target stops or restarts them all.
+//
+// Deploy writes, enables and starts the target after the units, and
+// Undeploy stops and removes it before them.
+func NewTarget(name string, units ...Unit) (Batch, error) {
+	if !checkName(name) {
+		return Batch{}, fmt.Errorf("sorry, name '%s' is not valid", name)
+	}
+	if len(units) == 0 {
+		return Batch{}, errors.New("target needs at least one unit")
+	}
+	wants := []string{}
+	members := []Unit{}
+	for _, u := range units {
+		if u.backend != nil {
+			return Batch{}, errors.New("targets can only be used with systemd")
+		}
+		if u.isTemplate() {
+			return Batch{}, fmt.Errorf("unit '%s' is a template, add an Instance of it to the target", u.name)
+		}
+		for _, unit := range u.activeUnits() {
+			wants = append(wants, fullUnitName(unit))
+		}
+		// don't share the directives with the caller's unit
+		u.directives = append([]Directive{}, u.directives...)
+		err := u.addDirective(SectionUnit, "PartOf", name+".target")
+		if err != nil {
+			return Batch{}, err
+		}
+		members = append(members, u)
+	}
+	b, err := NewBatch(members...)
+	if err != nil {
+		return Batch{}, err
+	}
+
+	// the target is deployed where and how the units are, but has none of
+	// their service settings
+	first := b.units[0]
+	t := Unit{
+		name:       name,
+		target:     true,
+		directives: []Directive{{Section: SectionUnit, Key: "Wants", Value: strings.Join(wants, " ")}},
+
+		scope:            first.scope,
+		escalation:       first.escalation,
+		escalate:         first.escalate,
+		force:            first.force,
+		version:          first.version,
+		ctx:              first.ctx,
+		logger:           first.logger,
+		progress:         first.progress,
+		reportFunc:       first.reportFunc,
+		dryRun:           first.dryRun,
+		dbus:             first.dbus,
+		client:           first.client,
+		remote:           first.remote,
+		root:             first.root,
+		deriveRuntimeDir: first.deriveRuntimeDir,
+		runtimeDir:       first.runtimeDir,
+		unitDirectory:    first.unitDirectory,
+		fileModes:        first.fileModes,
+		lockWait:         first.lockWait,
+		systemdVersion:   first.systemdVersion,
+		systemCtlPath:    first.systemCtlPath,
+		unitFilePath:     first.unitFilePath,
+	}
+	b.units = append(b.units, t)
+	return b, nil
+}
+
+// TargetFilename returns the full path to the target unit file of a unit
+// created by NewTarget.
+func (u Unit) TargetFilename() string {
+	return u.unitFilename("target")
+}
+
+func (u Unit) writeTargetTemplate(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	return t.ExecuteTemplate(f, "basic.target", u.templateData())
+}
+
+// undeployTarget stops, disables and removes the target. This also stops
+// the services which are PartOf it.
+func (u Unit) undeployTarget() error {
+	err := u.disableAndStop(u.name + ".target")
+	if err != nil {
+		return err
+	}
+	err = u.removeFile(u.TargetFilename())
+	if err != nil {
+		return err
+	}
+	return u.systemctl("daemon-reload")
+}
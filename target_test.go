@@ -0,0 +1,107 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTarget(t *testing.T) {
+	client := &recordingClient{dir: t.TempDir()}
+	web := Unit{name: "web", binary: "/fullpath/to/web", skipVerify: true, client: client, unitFilePath: client.dir}
+	(OptWantedBy{WantedBy: []string{"multi-user.target"}}).Apply(&web)
+	job := Unit{name: "job", binary: "/fullpath/to/job", skipVerify: true, client: client, unitFilePath: client.dir,
+		triggers: []trigger{OptTimer{OnCalendar: "daily"}}}
+	b, err := NewTarget("myapp", web, job)
+	if err != nil {
+		t.Fatalf("could not create target: %s", err)
+	}
+	if len(web.directives) != 0 {
+		t.Error("the caller's unit was changed")
+	}
+
+	if err := b.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	content, err := os.ReadFile(client.dir + "/myapp.target")
+	if err != nil {
+		t.Fatalf("target was not written: %s", err)
+	}
+	// not wanted by the targets of its first unit
+	if !strings.Contains(string(content), "Wants=web.service job.timer\n") || !strings.Contains(string(content), "WantedBy=default.target\n") ||
+		strings.Contains(string(content), "multi-user") {
+		t.Errorf("wrong target file:\n%s", content)
+	}
+	content, _ = os.ReadFile(web.UnitFilename())
+	if !strings.Contains(string(content), "PartOf=myapp.target\n") {
+		t.Errorf("service is not part of the target:\n%s", content)
+	}
+	want := "enable myapp.target\nstart myapp.target"
+	if got := strings.Join(client.commands, "\n"); !strings.HasSuffix(got, want) {
+		t.Errorf("target was not started:\n%s", got)
+	}
+
+	client.commands = nil
+	if err := b.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if !strings.HasPrefix(strings.Join(client.commands, "\n"), "disable myapp.target\nstop myapp.target\ndaemon-reload") {
+		t.Errorf("target was not undeployed first:\n%s", strings.Join(client.commands, "\n"))
+	}
+	if _, err := os.Stat(client.dir + "/myapp.target"); !os.IsNotExist(err) {
+		t.Error("target file was not removed")
+	}
+
+	if _, err := NewTarget("my app", web); err == nil {
+		t.Error("bad target name should fail")
+	}
+	if _, err := NewTarget("myapp", Unit{name: "tmpl", instances: true}); err == nil {
+		t.Error("template unit should fail")
+	}
+}
+
+func TestTargetEnvironment(t *testing.T) {
+	// the target is staged under the root, without systemctl
+	path := os.Getenv("PATH")
+	t.Setenv("PATH", "")
+	root := t.TempDir()
+	web, err := NewUnit("web", OptRoot{Dir: root, Binary: "/usr/bin/web"}, OptScope{Scope: ScopeSystem})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewTarget("myapp", web)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Deploy(); err != nil {
+		t.Fatalf("deploy under a root failed: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "usr/lib/systemd/system/myapp.target")); err != nil {
+		t.Errorf("target was not staged: %s", err)
+	}
+
+	// and written on the remote host
+	t.Setenv("PATH", path)
+	home, log := fakeRemote(t, "1000")
+	binary := filepath.Join(t.TempDir(), "web")
+	os.WriteFile(binary, []byte("version 1"), 0755)
+	web, err = NewUnit("web", OptRemote{Host: "prod1", Binary: binary})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = NewTarget("myapp", web)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Deploy(); err != nil {
+		t.Fatalf("remote deploy failed: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config/systemd/user/myapp.target")); err != nil {
+		t.Errorf("target was not written on the host: %s", err)
+	}
+	commands, _ := os.ReadFile(log)
+	if !strings.Contains(string(commands), "systemctl --user enable myapp.target\n") || strings.Count(string(commands), "cat > ") != 3 {
+		t.Errorf("wrong commands:\n%s", commands)
+	}
+}
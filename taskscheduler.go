@@ -0,0 +1,252 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// taskSchedulerDirectives are the directives the Task Scheduler backend
+// uses. Others are ignored, with a warning logged.
+var taskSchedulerDirectives = map[string]bool{markerManaged: true}
+
+// OptTaskScheduler deploys a unit with an OptTimer as a Windows Task
+// Scheduler task, which is the default on Windows for units with a timer.
+// OnCalendar must be a shorthand such as daily, or a simple expression
+// like "Mon..Fri *-*-* 09:30"; OnBootSec runs the task after boot (or
+// after logon, for user scope), and Persistent catches up on missed runs.
+//
+// User scope tasks run as the current user when they are logged on, and
+// system scope tasks run as SYSTEM, which needs an administrator to deploy.
+// The task definition is kept in a unitard directory under %AppData% (or
+// %ProgramData% for system scope) so Deploy can tell when it has changed.
+type OptTaskScheduler struct{}
+
+func (o OptTaskScheduler) Apply(u *Unit) error {
+	u.backend = taskScheduler{}
+	return nil
+}
+
+// taskScheduler is the backend for scheduled jobs on Windows.
+type taskScheduler struct{}
+
+func (taskScheduler) setup(u *Unit) error {
+	err := u.checkBackendOptions("Task Scheduler")
+	if err != nil {
+		return err
+	}
+	timer, ok := u.timer()
+	if !ok || len(u.triggers) != 1 {
+		return errors.New("Task Scheduler needs an OptTimer, and cannot be used with sockets or paths")
+	}
+	_, err = taskTriggers(timer, u.scope)
+	if err != nil {
+		return err
+	}
+	u.warnUnsupported("Task Scheduler", taskSchedulerDirectives)
+
+	u.toolPath = "schtasks"
+	if path, err := exec.LookPath("schtasks"); err == nil {
+		u.toolPath = path
+	} else if !u.dryRun {
+		return fmt.Errorf("schtasks not found: %w", err)
+	}
+
+	dir := os.Getenv("ProgramData")
+	if u.scope != ScopeSystem {
+		dir, err = os.UserConfigDir()
+		if err != nil {
+			return err
+		}
+	}
+	u.unitFilePath = filepath.Join(dir, "unitard")
+	if u.dryRun {
+		return nil
+	}
+	err = os.MkdirAll(u.unitFilePath, 0755)
+	if err != nil {
+		return fmt.Errorf("cannot create the task directory '%s': %w", u.unitFilePath, err)
+	}
+	return nil
+}
+
+// timer returns the unit's OptTimer, if it has one.
+func (u Unit) timer() (OptTimer, bool) {
+	for _, t := range u.triggers {
+		if timer, ok := t.(OptTimer); ok {
+			return timer, true
+		}
+	}
+	return OptTimer{}, false
+}
+
+// TaskFilename returns the full path to the Task Scheduler definition of a
+// unit deployed with OptTaskScheduler.
+func (u Unit) TaskFilename() string {
+	return filepath.Join(u.unitFilePath, u.name+".xml")
+}
+
+func (taskScheduler) files(u Unit) []unitFile {
+	return []unitFile{{u.TaskFilename(), u.writeTask}}
+}
+
+// taskData is the data for the task template. All the strings are XML
+// escaped.
+type taskData struct {
+	Description        string
+	Triggers           []taskTrigger
+	System             bool
+	StartWhenAvailable bool
+	Command            string
+	Arguments          string
+	WorkingDirectory   string
+}
+
+// taskTrigger is a trigger of a Task Scheduler task.
+type taskTrigger struct {
+	Kind          string // boot, logon, time (repeating) or calendar
+	Delay         string // for boot and logon
+	StartBoundary string
+	Interval      string // repetition interval
+	Duration      string // how long to repeat for, for calendar
+	DaysOfWeek    []string
+	DayOfMonth    int
+	Months        []string
+}
+
+func (u Unit) writeTask(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	timer, _ := u.timer()
+	triggers, err := taskTriggers(timer, u.scope)
+	if err != nil {
+		return err
+	}
+	data := taskData{
+		Description:        xmlEscape(u.name),
+		Triggers:           triggers,
+		System:             u.scope == ScopeSystem,
+		StartWhenAvailable: timer.Persistent,
+		Command:            xmlEscape(u.binary),
+		Arguments:          xmlEscape(u.binaryArgs),
+		WorkingDirectory:   xmlEscape(u.binaryPath),
+	}
+	return t.ExecuteTemplate(f, "task.xml", data)
+}
+
+// taskTriggers maps a timer to Task Scheduler triggers.
+func taskTriggers(timer OptTimer, scope Scope) ([]taskTrigger, error) {
+	triggers := []taskTrigger{}
+	if timer.OnBootSec > 0 {
+		kind := "logon"
+		if scope == ScopeSystem {
+			kind = "boot"
+		}
+		triggers = append(triggers, taskTrigger{Kind: kind, Delay: isoDuration(timer.OnBootSec)})
+	}
+	if timer.OnUnitActiveSec > 0 {
+		if timer.OnUnitActiveSec < time.Minute || timer.OnUnitActiveSec%time.Second != 0 {
+			return nil, errors.New("Task Scheduler needs OnUnitActiveSec to be whole seconds, and at least a minute")
+		}
+		triggers = append(triggers, taskTrigger{Kind: "time", StartBoundary: "2000-01-01T00:00:00", Interval: isoDuration(timer.OnUnitActiveSec)})
+	}
+	if timer.OnCalendar == "" {
+		return triggers, nil
+	}
+
+	spec, err := parseCalendar(timer.OnCalendar)
+	if err != nil {
+		return nil, err
+	}
+	if len(spec.weekdays) > 0 && (spec.day != 0 || spec.month != 0) {
+		return nil, fmt.Errorf("calendar expression '%s' is not supported: Task Scheduler can't combine weekdays with a date", timer.OnCalendar)
+	}
+	if len(spec.weekdays) == 0 && spec.day == 0 && spec.month != 0 {
+		return nil, fmt.Errorf("calendar expression '%s' is not supported: Task Scheduler needs a day of the month", timer.OnCalendar)
+	}
+	trigger := taskTrigger{Kind: "calendar", DayOfMonth: spec.day}
+	hour, minute := spec.hour, spec.minute
+	switch {
+	case hour == -1 && minute == -1:
+		trigger.Interval, trigger.Duration = "PT1M", "P1D"
+		hour, minute = 0, 0
+	case hour == -1:
+		trigger.Interval, trigger.Duration = "PT1H", "P1D"
+		hour = 0
+	case minute == -1:
+		trigger.Interval, trigger.Duration = "PT1M", "PT1H"
+		minute = 0
+	}
+	trigger.StartBoundary = fmt.Sprintf("2000-01-01T%02d:%02d:00", hour, minute)
+	for _, d := range spec.weekdays {
+		trigger.DaysOfWeek = append(trigger.DaysOfWeek, d.String())
+	}
+	if spec.day != 0 {
+		for m := time.January; m <= time.December; m++ {
+			if spec.month == 0 || int(m) == spec.month {
+				trigger.Months = append(trigger.Months, m.String())
+			}
+		}
+	}
+	return append(triggers, trigger), nil
+}
+
+// isoDuration formats a duration as an ISO 8601 duration in seconds.
+func isoDuration(d time.Duration) string {
+	return fmt.Sprintf("PT%dS", d/time.Second)
+}
+
+func (taskScheduler) start(u Unit, changed bool) error {
+	if !changed && !u.alwaysRestart && u.runExpectZero(u.toolPath, "/Query", "/TN", u.name) == nil {
+		return nil
+	}
+	return u.runExpectZero(u.toolPath, "/Create", "/TN", u.name, "/XML", u.TaskFilename(), "/F")
+}
+
+func (taskScheduler) undeploy(u Unit) error {
+	if u.runExpectZero(u.toolPath, "/Query", "/TN", u.name) == nil {
+		err := u.runExpectZero(u.toolPath, "/Delete", "/TN", u.name, "/F")
+		if err != nil {
+			return err
+		}
+	}
+	err := u.removeFile(u.TaskFilename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (taskScheduler) control(u Unit, command string) error {
+	switch command {
+	case "enable":
+		return u.runExpectZero(u.toolPath, "/Change", "/TN", u.name, "/ENABLE")
+	case "disable":
+		return u.runExpectZero(u.toolPath, "/Change", "/TN", u.name, "/DISABLE")
+	case "start":
+		return u.runExpectZero(u.toolPath, "/Run", "/TN", u.name)
+	case "stop":
+		return u.runExpectZero(u.toolPath, "/End", "/TN", u.name)
+	case "restart":
+		u.runExpectZero(u.toolPath, "/End", "/TN", u.name)
+		return u.runExpectZero(u.toolPath, "/Run", "/TN", u.name)
+	}
+	return notSupported(BackendTaskScheduler, command)
+}
+
+func (taskScheduler) status(u Unit) (Status, error) {
+	out, err := u.runOutput(u.toolPath, "/Query", "/TN", u.name, "/FO", "LIST")
+	return backendStatus(err == nil, err == nil && strings.Contains(out, "Running")), nil
+}
+
+func (taskScheduler) kind(u Unit) Backend {
+	return BackendTaskScheduler
+}
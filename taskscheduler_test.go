@@ -0,0 +1,79 @@
+package unitard
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaskScheduler(t *testing.T) {
+	dir := t.TempDir()
+	schtasks, log := fakeTool(t, "schtasks", `case "$1" in
+/Query) [ -e `+dir+`/created ] ;;
+/Create) touch `+dir+`/created ;;
+/Delete) rm `+dir+`/created ;;
+esac`)
+	u := Unit{name: "test_unit", binary: `C:\fullpath\to\foobar.exe`, binaryPath: `C:\fullpath\to`, binaryArgs: "-x <y>",
+		backend: taskScheduler{}, toolPath: schtasks, unitFilePath: dir,
+		triggers: []trigger{OptTimer{OnCalendar: "Mon..Fri *-*-* 09:30", Persistent: true}}}
+
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	content, err := os.ReadFile(u.TaskFilename())
+	if err != nil {
+		t.Fatalf("task was not written: %s", err)
+	}
+	for _, want := range []string{
+		"<StartBoundary>2000-01-01T09:30:00</StartBoundary>",
+		"<Monday />\n          <Tuesday />\n          <Wednesday />\n          <Thursday />\n          <Friday />\n",
+		"<StartWhenAvailable>true</StartWhenAvailable>",
+		`<Command>C:\fullpath\to\foobar.exe</Command>`,
+		"<Arguments>-x &lt;y&gt;</Arguments>",
+		"<LogonType>InteractiveToken</LogonType>",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("task does not contain %q:\n%s", want, content)
+		}
+	}
+
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("second deploy failed: %s", err)
+	}
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if _, err := os.Stat(u.TaskFilename()); !os.IsNotExist(err) {
+		t.Error("task file was not removed")
+	}
+	want := "/Create /TN test_unit /XML " + u.TaskFilename() + " /F\n/Query /TN test_unit\n/Query /TN test_unit\n/Delete /TN test_unit /F\n"
+	if commands, _ := os.ReadFile(log); string(commands) != want {
+		t.Errorf("wrong commands:\n%s", commands)
+	}
+}
+
+func TestTaskTriggers(t *testing.T) {
+	triggers, err := taskTriggers(OptTimer{OnBootSec: 2 * time.Minute, OnUnitActiveSec: time.Hour}, ScopeSystem)
+	if err != nil || len(triggers) != 2 || triggers[0].Kind != "boot" || triggers[0].Delay != "PT120S" || triggers[1].Interval != "PT3600S" {
+		t.Errorf("wrong triggers %+v %v", triggers, err)
+	}
+	triggers, err = taskTriggers(OptTimer{OnCalendar: "hourly"}, ScopeUser)
+	if err != nil || triggers[0].Interval != "PT1H" || triggers[0].Duration != "P1D" {
+		t.Errorf("wrong hourly trigger %+v %v", triggers, err)
+	}
+	triggers, err = taskTriggers(OptTimer{OnCalendar: "yearly"}, ScopeUser)
+	if err != nil || triggers[0].DayOfMonth != 1 || len(triggers[0].Months) != 1 || triggers[0].Months[0] != "January" {
+		t.Errorf("wrong yearly trigger %+v %v", triggers, err)
+	}
+	if _, err := taskTriggers(OptTimer{OnCalendar: "Mon *-*-01"}, ScopeUser); err == nil {
+		t.Error("weekdays with a date should fail")
+	}
+	if _, err := taskTriggers(OptTimer{OnUnitActiveSec: time.Second}, ScopeUser); err == nil {
+		t.Error("short interval should fail")
+	}
+	u := Unit{name: "test_unit", backend: taskScheduler{}, dryRun: true}
+	if err := (taskScheduler{}).setup(&u); err == nil {
+		t.Error("unit without a timer should fail")
+	}
+}
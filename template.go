@@ -0,0 +1,75 @@
+package unitard
+
+import (
+	"errors"
+	"text/template"
+)
+
+// TemplateData is the data the service unit file template is executed with.
+// The embedded template (templates/basic.service in this package) is a good
+// starting point for your own.
+type TemplateData struct {
+	Name             string // the unit name, as passed to NewUnit
+	Instance         string // the instance name, for an Instance of a unit with OptInstances
+	Description      string // human readable description, includes %i for instances
+	ExecStart        string // full path to the binary
+	ExecStartArgs    string // arguments from OptProgramArgs
+	WorkingDirectory string // the directory the binary is in
+	WantedBy         string // the target the unit is installed into
+	Scope            Scope  // user or system scope
+
+	// Directives from options, for each section, in the order they should
+	// appear. Render each as {{ .Key }}={{ .Value }}. Unit starts with the
+	// X-Unitard-* marker used by ListManagedUnits.
+	Unit    []Directive
+	Service []Directive
+	Install []Directive
+}
+
+// templateData returns the data for the service template.
+func (u Unit) templateData() TemplateData {
+	description := u.name
+	if u.instances {
+		description += " %i"
+	}
+	return TemplateData{
+		Name:             u.name,
+		Instance:         u.instance,
+		Description:      description,
+		ExecStart:        u.binary,
+		ExecStartArgs:    u.binaryArgs,
+		WorkingDirectory: u.binaryPath,
+		WantedBy:         u.installTargets(),
+		Scope:            u.scope,
+		Unit:             append(u.markerDirectives(), u.sectionDirectives(SectionUnit)...),
+		Service:          append(u.sectionDirectives(SectionService), u.credentialDirectives()...),
+		Install:          u.sectionDirectives(SectionInstall),
+	}
+}
+
+// OptTemplate replaces the embedded service unit file template with your
+// own, given either as text or as an already parsed template (for instance
+// to add your own functions). It is executed with a TemplateData.
+type OptTemplate struct {
+	Text     string
+	Template *template.Template
+}
+
+func (o OptTemplate) Apply(u *Unit) error {
+	if (o.Text == "") == (o.Template == nil) {
+		return errors.New("OptTemplate needs exactly one of Text or Template")
+	}
+	if u.serviceTemplate != nil {
+		return errors.New("template was already set - use OptTemplate only once")
+	}
+	t := o.Template
+	if t == nil {
+		var err error
+		t, err = template.New("service").Parse(o.Text)
+		if err != nil {
+			return err
+		}
+	}
+	u.serviceTemplate = t
+	return nil
+}
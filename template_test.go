@@ -0,0 +1,44 @@
+package unitard
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+)
+
+func TestCustomTemplate(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar"}
+	_ = OptUser{User: "nobody"}.Apply(&u)
+	err := OptTemplate{Text: "[Service]\nExecStart={{ .ExecStart }}\n{{ range .Service }}{{ .Key }}={{ .Value }}\n{{ end }}"}.Apply(&u)
+	if err != nil {
+		t.Fatalf("failed to apply template: %s", err)
+	}
+
+	buff := bytes.NewBuffer(nil)
+	err = u.writeTemplate(buff)
+	if err != nil {
+		t.Fatalf("failed to write template: %s", err)
+	}
+	want := "[Service]\nExecStart=/fullpath/to/foobar\nUser=nobody\n"
+	if buff.String() != want {
+		t.Errorf("got %q, want %q", buff.String(), want)
+	}
+}
+
+func TestCustomTemplateOpts(t *testing.T) {
+	if (OptTemplate{}).Apply(&Unit{}) == nil {
+		t.Error("empty template should not be valid")
+	}
+	if (OptTemplate{Text: "x", Template: template.New("x")}).Apply(&Unit{}) == nil {
+		t.Error("both text and template should not be valid")
+	}
+	if (OptTemplate{Text: "{{ .Broken"}).Apply(&Unit{}) == nil {
+		t.Error("unparseable template should not be valid")
+	}
+
+	u := Unit{}
+	_ = OptTemplate{Text: "{{ .NoSuchField }}"}.Apply(&u)
+	if u.writeTemplate(bytes.NewBuffer(nil)) == nil {
+		t.Error("unknown field should fail to render")
+	}
+}
@@ -0,0 +1,20 @@
+#!/bin/sh
+# supervise script automatically created with github.com/tardisx/unitard
+# runs {{ .Name }}, starting it again whenever it exits
+pidfile={{ .PidFile }}
+if [ -e "$pidfile" ] && kill -0 "$(cat "$pidfile")" 2>/dev/null; then
+	# already running
+	exit 0
+fi
+echo $$ > "$pidfile"
+trap 'kill "$child" 2>/dev/null; rm -f "$pidfile"; exit 0' TERM INT
+cd {{ .WorkingDirectory }} || exit 1
+{{- range .Environment }}
+export {{ . }}
+{{- end }}
+while true; do
+	{{ .Command }} {{ .Output }} &
+	child=$!
+	wait "$child"
+	sleep {{ .RestartSec }}
+done
@@ -0,0 +1,59 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OptTimer deploys a timer unit alongside the service, so that the program
+// is run on a schedule instead of being a long-running service. At least one
+// of OnCalendar, OnBootSec or OnUnitActiveSec must be set.
+type OptTimer struct {
+	OnCalendar      string        // calendar expression, eg "daily" or "Mon *-*-* 09:00:00"
+	OnBootSec       time.Duration // run this long after boot
+	OnUnitActiveSec time.Duration // run this long after the last run started
+	Persistent      bool          // run immediately if a run was missed while the system was down
+}
+
+func (o OptTimer) Apply(u *Unit) error {
+	if o.OnCalendar == "" && o.OnBootSec == 0 && o.OnUnitActiveSec == 0 {
+		return errors.New("timer needs at least one of OnCalendar, OnBootSec or OnUnitActiveSec")
+	}
+	if o.OnBootSec < 0 || o.OnUnitActiveSec < 0 {
+		return errors.New("timer durations cannot be negative")
+	}
+	if strings.ContainsAny(o.OnCalendar, "\r\n") {
+		return errors.New("directive values cannot contain newlines")
+	}
+	return u.addTrigger(o)
+}
+
+func (o OptTimer) unitType() string {
+	return "timer"
+}
+
+func (o OptTimer) templateData() map[string]interface{} {
+	return map[string]interface{}{
+		"onCalendar":      o.OnCalendar,
+		"onBootSec":       timespan(o.OnBootSec),
+		"onUnitActiveSec": timespan(o.OnUnitActiveSec),
+		"persistent":      o.Persistent,
+	}
+}
+
+// timespan formats a duration as a systemd time span. A zero duration
+// is returned as an empty string.
+func timespan(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	if d%time.Second == 0 {
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	if d%time.Millisecond == 0 {
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	}
+	return fmt.Sprintf("%dus", d/time.Microsecond)
+}
@@ -0,0 +1,74 @@
+package unitard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimerTemplate(t *testing.T) {
+	u := Unit{
+		name:          "test_unit",
+		binary:        "/fullpath/to/foobar",
+		systemCtlPath: "/who/cares",
+		unitFilePath:  "/doesnt/matter",
+	}
+	err := OptTimer{OnCalendar: "daily", OnBootSec: 5 * time.Minute, Persistent: true}.Apply(&u)
+	if err != nil {
+		t.Fatalf("failed to apply timer: %s", err)
+	}
+
+	buff := bytes.NewBuffer(nil)
+	err = u.writeTriggerTemplate(buff, u.triggers[0])
+	if err != nil {
+		t.Errorf("failed to write template: %s", err)
+	}
+	t.Logf("template:\n%s", buff.String())
+
+	for _, want := range []string{"OnCalendar=daily\n", "OnBootSec=300s\n", "Persistent=true\n", "WantedBy=timers.target"} {
+		if !strings.Contains(buff.String(), want) {
+			t.Errorf("template does not contain %q", want)
+		}
+	}
+	if strings.Contains(buff.String(), "OnUnitActiveSec") {
+		t.Error("template contains unset OnUnitActiveSec")
+	}
+
+	if active := u.activeUnits(); len(active) != 1 || active[0] != "test_unit.timer" {
+		t.Errorf("active units are %v, not the timer", active)
+	}
+}
+
+func TestTimerOpts(t *testing.T) {
+	u := Unit{}
+	if (OptTimer{}).Apply(&u) == nil {
+		t.Error("empty timer should not be valid")
+	}
+	if (OptTimer{OnUnitActiveSec: -time.Second}).Apply(&u) == nil {
+		t.Error("negative duration should not be valid")
+	}
+	if (OptTimer{OnCalendar: "daily\nExecStart=/bin/evil"}).Apply(&u) == nil {
+		t.Error("calendar expression with a newline should not be valid")
+	}
+	if (OptTimer{OnCalendar: "hourly"}).Apply(&u) != nil {
+		t.Error("timer should be valid")
+	}
+	if (OptTimer{OnCalendar: "hourly"}).Apply(&u) == nil {
+		t.Error("second timer should not be valid")
+	}
+}
+
+func TestTimespan(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                       "",
+		90 * time.Second:        "90s",
+		1500 * time.Millisecond: "1500ms",
+		1500 * time.Microsecond: "1500us",
+	}
+	for d, want := range tests {
+		if got := timespan(d); got != want {
+			t.Errorf("timespan(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
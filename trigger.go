@@ -0,0 +1,58 @@
+package unitard
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+)
+
+// trigger is implemented by options which deploy an extra unit that
+// activates the service, such as OptTimer.
+type trigger interface {
+	unitType() string                     // unit type, also used as the file extension
+	templateData() map[string]interface{} // data for the basic.<type> template
+}
+
+// addTrigger adds a trigger to the unit, checking that only one of each type
+// is used.
+func (u *Unit) addTrigger(t trigger) error {
+	for _, existing := range u.triggers {
+		if existing.unitType() == t.unitType() {
+			return fmt.Errorf("%s was already set - use it only once", t.unitType())
+		}
+	}
+	u.triggers = append(u.triggers, t)
+	return nil
+}
+
+// activeUnits returns the names of the units which should be enabled and
+// started - the triggers if there are any, otherwise the service itself.
+func (u Unit) activeUnits() []string {
+	if u.target {
+		return []string{u.name + ".target"}
+	}
+	if u.slice {
+		// started by the units in it
+		return nil
+	}
+	if len(u.triggers) == 0 {
+		return []string{u.serviceName()}
+	}
+	units := []string{}
+	for _, t := range u.triggers {
+		units = append(units, u.name+"."+t.unitType())
+	}
+	return units
+}
+
+func (u Unit) writeTriggerTemplate(f io.Writer, t trigger) error {
+	tmpl, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+
+	data := t.templateData()
+	data["description"] = u.name
+	err = tmpl.ExecuteTemplate(f, "basic."+t.unitType(), data)
+	return err
+}
@@ -3,19 +3,24 @@
 package unitard
 
 import (
+	"bytes"
+	"context"
 	"embed"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"text/template"
+	"time"
 )
 
-//go:embed templates/*.service
+//go:embed templates/*
 var fs embed.FS
 
 type Unit struct {
@@ -24,6 +29,100 @@ type Unit struct {
 	binaryPath string
 	binaryArgs string
 
+	binaryChosen bool // the binary was set with OptBinary or OptExecStart
+	execStart    bool // the command line was set with OptExecStart
+
+	triggers []trigger // units which activate the service (timer, socket etc)
+
+	instances bool   // deploy as a template unit (name@.service)
+	instance  string // the instance of a template unit this refers to
+
+	scope      Scope       // user or system wide
+	directives []Directive // additional directives for the service unit file
+
+	escalation Escalation // how to gain root for system scope
+	escalate   []string   // command prefix to run privileged commands, if needed
+
+	purgeDirectories bool // remove state/cache/logs directories on undeploy
+
+	maxExposure float64 // fail Deploy if the security exposure is higher
+	skipVerify  bool    // don't check units with systemd-analyze verify
+
+	waitTimeout  time.Duration // wait this long for the unit to start on Deploy
+	journalLines int           // journal lines to include in errors
+
+	logFiles  []string // files the service output is written to
+	logrotate bool     // install a logrotate config for logFiles
+
+	alwaysRestart   bool // restart on Deploy even if the unit files are unchanged
+	reloadOnDeploy  bool // reload instead of restarting on Deploy
+	reloadOrRestart bool // reload, or restart if the service can't reload
+	deployMode      DeployMode
+
+	serviceTemplate *template.Template // replaces the embedded basic.service
+
+	target bool // a .target grouping other units, see NewTarget
+
+	dropIn          string // deploy only a drop-in override with this name
+	dropInExecStart bool   // the drop-in replaces ExecStart
+
+	force bool // overwrite unit files even if they were edited locally
+
+	version    string // program version recorded in the unit files
+	binaryHash string // sha256 of the binary, recorded in the unit files
+
+	ctx context.Context // commands are run with this, if set
+
+	logger   *slog.Logger // steps are logged here, if set
+	progress func(Action) // called before each step, if set
+
+	reportFunc func(Report)    // called with a Report after Deploy and Undeploy, if set
+	recorder   *reportRecorder // records the steps for reportFunc
+
+	dryRun bool  // created with OptDryRun, never touch the system
+	plan   *Plan // if set, record changes here instead of making them
+
+	dbus   bool          // talk to systemd over D-Bus instead of running systemctl
+	client SystemdClient // used instead of systemctl, if set
+
+	backend         backend // used instead of systemd, if set
+	backendChosen   bool    // OptBackend chose the backend, so it is not detected
+	toolPath        string  // the command the backend runs (launchctl and so on), or the directory of its commands
+	launchdLabel    string  // label of the launchd job
+	runitS6         bool    // whether OptRunit uses s6
+	runitServiceDir string  // the directory the runit or s6 supervisor scans
+
+	remote       *remote // deploy to this host over ssh, if set
+	remoteBinary string  // where the binary is uploaded to on the remote host
+	root         string  // stage the files under this directory, if set
+
+	quadlet *quadlet // run as a podman container, if set
+
+	linger       bool // OptLinger was given
+	enableLinger bool // enable lingering if it is disabled
+
+	deriveRuntimeDir bool   // set XDG_RUNTIME_DIR if it is missing
+	runtimeDir       string // the XDG_RUNTIME_DIR commands are run with, if derived
+	unitDirectory    string // OptUnitDirectory, in place of the scope's directory
+
+	install       *OptInstall // copy the program to a stable location
+	installFrom   string      // the program being installed, with OptInstall
+	installShared bool        // the installed program is shared by the units of NewServices
+
+	failureHandler string // the command run by the OptOnFailure handler
+	slice          bool   // deploy a slice, for NewSlice
+
+	credentials []credential // passed to the service with OptCredential
+	account     *OptUser     // the accounts OptUser checks or creates, if set
+	wantedBy    []string     // OptWantedBy targets, in place of the scope default
+	replaces    []string     // units disabled by Deploy, with OptConflicts Replace
+	fileModes   OptFileMode  // the permissions and owner of files written
+	imported    []byte       // the unit file imported by NewUnitFromFile
+
+	lockWait time.Duration // how long to wait for another deploy, with OptLockWait
+
+	systemdVersion int // the major version of systemd, 0 if unknown
+
 	systemCtlPath string // path to systemctl command
 	unitFilePath  string
 }
@@ -41,8 +140,8 @@ func (o OptProgramArgs) Apply(u *Unit) error {
 	if o.Args == "" {
 		return errors.New("can't set an empty args option")
 	}
-	if u.binaryArgs != "" {
-		return errors.New("args were already set - use OptProgramArgs only once")
+	if u.binaryArgs != "" || u.execStart {
+		return errors.New("args were already set - use OptProgramArgs only once, and not with OptExecStart")
 	}
 	u.binaryArgs = o.Args
 	return nil
@@ -64,101 +163,698 @@ func NewUnit(unitName string, unitOpts ...UnitOpts) (Unit, error) {
 		name:       unitName,
 		binary:     binPath,
 		binaryPath: path,
+		version:    buildVersion(),
 	}
 
 	for _, opt := range unitOpts {
 		err := opt.Apply(&u)
 		if err != nil {
-			return Unit{}, fmt.Errorf("bad option: %s", err)
+			return Unit{}, fmt.Errorf("bad option: %w", err)
 		}
 	}
+	err := u.validate()
+	if err != nil {
+		return Unit{}, fmt.Errorf("bad option: %w", err)
+	}
+	u.binaryHash = fileHash(u.binary)
 
-	err := u.setupEnvironment()
+	err = u.setupEnvironment()
+	if err != nil {
+		return Unit{}, err
+	}
+	err = u.setupSystemdVersion()
 	if err != nil {
 		return Unit{}, err
 	}
 	return u, nil
 }
 
+// OptAlwaysRestart makes Deploy reload systemd and restart the service even
+// when the installed unit files are unchanged. This is useful if the binary
+// has been replaced in place.
+type OptAlwaysRestart struct{}
+
+func (o OptAlwaysRestart) Apply(u *Unit) error {
+	u.alwaysRestart = true
+	return nil
+}
+
+// validate checks that the options applied to the unit make sense together.
+func (u Unit) validate() error {
+	if u.instances && len(u.triggers) > 0 {
+		return errors.New("instances cannot be combined with timers, sockets or paths")
+	}
+	if u.reloadOnDeploy && len(u.triggers) > 0 {
+		return errors.New("OptReloadOnDeploy cannot be combined with timers, sockets or paths")
+	}
+	if u.dropIn != "" && (len(u.triggers) > 0 || u.instances) {
+		return errors.New("OptDropIn cannot be combined with instances, timers, sockets or paths")
+	}
+	if u.dropIn != "" && len(u.wantedBy) > 0 {
+		return errors.New("OptDropIn cannot be combined with OptWantedBy")
+	}
+	if u.dropIn != "" && u.serviceTemplate != nil {
+		return errors.New("OptDropIn cannot be combined with OptTemplate")
+	}
+	if err := u.checkQuadlet(); err != nil {
+		return err
+	}
+	if u.slice && (len(u.triggers) > 0 || u.instances || u.dropIn != "" || u.quadlet != nil || u.backend != nil || u.failureHandler != "") {
+		return errors.New("a slice cannot be combined with other kinds of unit")
+	}
+	if u.failureHandler != "" && (u.dropIn != "" || u.quadlet != nil || u.backend != nil) {
+		return errors.New("OptOnFailure can only be used with systemd, not with OptDropIn or OptQuadlet")
+	}
+	if u.account != nil && u.backend != nil {
+		return errors.New("OptUser can only Check or Create accounts with systemd")
+	}
+	if err := u.checkCredentials(); err != nil {
+		return err
+	}
+	if err := u.checkFileModes(); err != nil {
+		return err
+	}
+	if u.install != nil && (u.root != "" || u.remote != nil) {
+		return errors.New("OptInstall cannot be combined with OptRoot or OptRemote, which set the binary themselves")
+	}
+	if u.logrotate && u.scope != ScopeSystem {
+		return errors.New("Logrotate can only be used with system scope")
+	}
+	if u.scope == ScopeUser {
+		for _, d := range u.directives {
+			if systemOnlyDirectives[d.Key] {
+				return fmt.Errorf("%s can only be used with system scope", d.Key)
+			}
+		}
+	}
+	return nil
+}
+
 // UnitFilename returns the full path to the systemd unit file that will be used for
 // Deploy or Undeploy.
 func (u Unit) UnitFilename() string {
-	return fmt.Sprintf("%s%c%s.service", u.unitFilePath, os.PathSeparator, u.name)
+	return u.unitFilename("service")
+}
+
+// unitFilename returns the full path to the unit file of the given type
+// (service, timer and so on).
+func (u Unit) unitFilename(unitType string) string {
+	name := u.name
+	if u.instances {
+		name += "@"
+	}
+	return fmt.Sprintf("%s%c%s.%s", u.unitFilePath, os.PathSeparator, name, unitType)
+}
+
+// serviceName returns the name of the service as systemd knows it.
+func (u Unit) serviceName() string {
+	if u.instances {
+		return u.name + "@" + u.instance
+	}
+	return u.name
 }
 
 // Deploy creates/overwrites the unit file, enables and starts it running.
+// If the installed unit files are already up to date, they are not rewritten
+// and a running service is not restarted (see OptAlwaysRestart). Unit files
+// which were not created by unitard, or were edited since they were
+// installed, are not overwritten unless OptForce is given; a
+// *ForeignUnitError or *LocalEditError is returned instead.
+// If the unit fails to start, the previous unit files are restored and
+// started again, and a *RollbackError is returned.
+// If the unit has triggers (a timer, socket and so on) their unit files are
+// created as well, and the triggers are enabled and started instead of the
+// service.
+// For units with OptInstances, Deploy on an Instance enables and starts that
+// instance, otherwise only the template unit file is installed.
 func (u Unit) Deploy() error {
+	if u.reports() {
+		return u.reported("deploy", Unit.Deploy)
+	}
+	release, err := u.lock()
+	if err != nil {
+		return err
+	}
+	defer release()
+	d, err := u.prepareDeploy()
+	if err != nil {
+		return err
+	}
+	return d.run()
+}
 
-	// create/overwrite the unit file
-	unitFileName := u.UnitFilename()
-	f, err := os.Create(unitFileName)
+// run writes the files and starts the unit, rolling back if it fails.
+func (d deploy) run() error {
+	u := d.u
+	if d.upgrade != "" {
+		previous, err := u.swapBinary(d.upgrade)
+		d.previous = previous
+		if err != nil {
+			return d.rollback(err)
+		}
+	}
+	err := d.writeFiles()
+	if err == nil && d.changed && u.backend == nil {
+		err = u.systemctl("daemon-reload")
+	}
+	if err == nil {
+		err = d.start()
+	}
 	if err != nil {
-		return fmt.Errorf("could not create unit file '%s': %s", unitFileName, err)
+		return d.rollback(err)
+	}
+	if d.previous {
+		err := u.removeFile(u.oldBinary())
+		if err != nil {
+			u.log(slog.LevelWarn, "could not remove the previous binary", "err", err)
+		}
 	}
-	defer f.Close()
+	return d.finish()
+}
+
+// deploy is a Deploy in progress.
+type deploy struct {
+	u        Unit
+	write    bool     // the unit files need writing
+	upload   bool     // the binary needs uploading to the remote host
+	install  bool     // the binary needs installing, with OptInstall
+	secrets  bool     // the credentials need writing, with OptCredential
+	upgrade  string   // the new binary, for Upgrade
+	previous bool     // the binary replaced by Upgrade was kept
+	changed  bool     // the unit needs restarting
+	reenable bool     // the [Install] section changed, so disable before enabling
+	backups  []backup // the unit files and credentials before the deploy, if written
+}
 
-	err = u.writeTemplate(f)
+// prepareDeploy checks the unit can be deployed, and works out what needs
+// to change, without changing anything.
+func (u Unit) prepareDeploy() (deploy, error) {
+	if u.dryRun && u.plan == nil {
+		return deploy{}, errDryRun
+	}
+	if u.instances {
+		err := u.checkInstance()
+		if err != nil {
+			return deploy{}, err
+		}
+	}
+
+	// check the units before touching the running system
+	err := u.checkTemporaryBinary()
 	if err != nil {
-		return err
+		return deploy{}, err
+	}
+	err = u.checkAccounts()
+	if err != nil {
+		return deploy{}, err
+	}
+	err = u.checkAliases()
+	if err != nil {
+		return deploy{}, err
+	}
+	if u.plan == nil {
+		err := u.verify()
+		if err != nil {
+			return deploy{}, err
+		}
 	}
 
-	// and start it up
-	err = u.enableAndStartUnit()
+	// skip the restart if nothing has changed
+	diff, err := u.Diff()
 	if err != nil {
-		return err
+		return deploy{}, err
+	}
+	d := deploy{u: u, write: diff.Changed, changed: diff.Changed || u.alwaysRestart}
+	if u.remote != nil && !u.target {
+		// targets run nothing, the units in them upload the binary
+		d.upload, err = u.binaryChanged()
+		if err != nil {
+			return deploy{}, err
+		}
+		d.changed = d.changed || d.upload
+	}
+	if u.installChanged() {
+		d.install = true
+		d.changed = true
+	}
+	var secrets []backup
+	if u.credentialsChanged() {
+		d.secrets = true
+		d.changed = true
+		secrets, err = u.backupCredentials()
+		if err != nil {
+			return deploy{}, err
+		}
+	}
+	if !diff.Changed {
+		u.log(slog.LevelInfo, "unit files are up to date")
+		d.backups = secrets
+		return d, nil
 	}
 
-	return nil
+	err = u.checkOverwrite()
+	if err != nil {
+		return deploy{}, err
+	}
+	d.backups, err = u.backup()
+	if err != nil {
+		return deploy{}, err
+	}
+	d.backups = append(d.backups, secrets...)
+	d.reenable = u.dropIn == "" && u.quadlet == nil && u.backend == nil && u.installSectionChanged(d.backups)
+	return d, nil
 }
 
-func (u Unit) writeTemplate(f io.Writer) error {
-	t, err := template.New("").ParseFS(fs, "templates/*.service")
+// writeFiles writes the unit files if they have changed, and creates any
+// log files.
+func (d deploy) writeFiles() error {
+	u := d.u
+	if d.upload {
+		err := u.uploadBinary()
+		if err != nil {
+			return err
+		}
+	}
+	if d.install {
+		err := u.installBinary()
+		if err != nil {
+			return err
+		}
+	}
+	if d.secrets {
+		err := u.writeCredentials()
+		if err != nil {
+			return err
+		}
+	}
+	if d.write {
+		if u.dropIn != "" {
+			err := u.mkdirAll(u.dropInDir())
+			if err != nil {
+				return err
+			}
+		}
+		if u.unitDirectory != "" && u.backend == nil {
+			err := u.mkdirAll(u.unitFilePath)
+			if err != nil {
+				return err
+			}
+		}
+		if u.quadlet != nil {
+			err := u.mkdirAll(u.quadletDir())
+			if err != nil {
+				return err
+			}
+		}
+		for _, f := range u.unitFiles() {
+			if u.backend != nil {
+				// backends may keep their files anywhere
+				err := u.mkdirAll(path.Dir(f.name))
+				if err != nil {
+					return err
+				}
+			}
+			err := u.createFile(f.name, f.write)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err := u.createAccounts()
+	if err != nil {
+		return err
+	}
+	return u.createLogFiles()
+}
+
+// start enables and starts the unit, after systemd has been reloaded,
+// waiting for it to become active if OptWaitActive was given.
+func (d deploy) start() error {
+	u := d.u
+	if u.backend != nil {
+		return u.backend.start(u, d.changed)
+	}
+	if d.reenable && !u.isTemplate() && u.deployMode != DeployStartOnly {
+		// remove the links from the old [Install] section
+		err := u.systemctl("disable", u.serviceName())
+		if err != nil {
+			return err
+		}
+	}
+	err := u.startUnit(d.changed)
 	if err != nil {
 		return err
 	}
+	if u.waitTimeout > 0 && u.plan == nil && !u.isTemplate() && u.deployMode != DeployEnableOnly {
+		err = u.WaitUntilActive(u.waitTimeout)
+		if err != nil {
+			return err
+		}
+	}
+	return u.disableReplaced()
+}
 
-	data := map[string]string{
-		"description":      u.name,
-		"execStart":        u.binary,
-		"execStartArgs":    u.binaryArgs,
-		"workingDirectory": u.binaryPath,
+// rollback restores the previous unit files after the deploy failed with
+// err, if any were written.
+func (d deploy) rollback(err error) error {
+	if d.u.plan != nil {
+		return err
+	}
+	if d.upgrade != "" {
+		// the previous unit has to be started with the previous binary
+		rollbackErr := d.u.restoreBinary(d.previous)
+		if rollbackErr == nil && d.backups == nil {
+			// the unit files didn't change, but the service was restarted
+			for _, unit := range d.u.activeUnits() {
+				if rollbackErr == nil {
+					rollbackErr = d.u.systemctl("restart", unit)
+				}
+			}
+		}
+		if rollbackErr != nil || d.backups == nil {
+			return &RollbackError{Err: err, RollbackErr: rollbackErr}
+		}
+	}
+	if d.backups == nil {
+		return err
+	}
+	d.u.log(slog.LevelWarn, "deploy failed, rolling back", "err", err)
+	return d.u.rollback(d.backups, d.reenable, err)
+}
+
+// finish checks the deployed unit.
+func (d deploy) finish() error {
+	u := d.u
+	if u.plan != nil {
+		// nothing was started, so there is nothing to check
+		return nil
+	}
+	err := u.checkExposure()
+	if err == nil {
+		u.log(slog.LevelInfo, "unit deployed")
 	}
-	err = t.ExecuteTemplate(f, "basic.service", data)
 	return err
 }
 
-func (u Unit) enableAndStartUnit() error {
-	err := u.runExpectZero(u.systemCtlPath, "--user", "daemon-reload")
+// unitFile is a unit file written by Deploy.
+type unitFile struct {
+	name  string                // full path to the file
+	write func(io.Writer) error // writes the file contents
+}
+
+// unitFiles returns the service unit file and those of any triggers, or
+// just the drop-in for units with OptDropIn, or the target for NewTarget.
+func (u Unit) unitFiles() []unitFile {
+	if u.backend != nil {
+		return u.backend.files(u)
+	}
+	if u.target {
+		return []unitFile{{u.TargetFilename(), withChecksum(u.writeTargetTemplate)}}
+	}
+	if u.slice {
+		return []unitFile{{u.SliceFilename(), withChecksum(u.writeSliceTemplate)}}
+	}
+	if u.quadlet != nil {
+		return u.quadletFiles()
+	}
+	if u.dropIn != "" {
+		return []unitFile{{u.DropInFilename(), withChecksum(u.writeDropInTemplate)}}
+	}
+	files := []unitFile{{u.UnitFilename(), u.writeTemplate}}
+	for _, t := range u.triggers {
+		t := t
+		files = append(files, unitFile{u.unitFilename(t.unitType()), func(f io.Writer) error {
+			return u.writeTriggerTemplate(f, t)
+		}})
+	}
+	if u.failureHandler != "" {
+		files = append(files, unitFile{u.FailureFilename(), u.writeFailureTemplate})
+	}
+	for i := range files {
+		files[i].write = withChecksum(files[i].write)
+	}
+	return files
+}
+
+// renderTo writes the unit files into another directory instead of the
+// unit directory, returning the unit as it would be found there.
+func (u Unit) renderTo(dir string) (Unit, error) {
+	u.unitFilePath = dir
+	u.escalate = nil
+	u.remote = nil
+	u.plan = nil
+	u.logger = nil
+	u.progress = nil
+	for _, f := range u.unitFiles() {
+		err := u.mkdirAll(path.Dir(f.name))
+		if err != nil {
+			return u, err
+		}
+		err = u.createFile(f.name, f.write)
+		if err != nil {
+			return u, err
+		}
+	}
+	return u, nil
+}
+
+// createFile creates/overwrites a unit file, with the contents generated by write.
+func (u Unit) createFile(fileName string, write func(io.Writer) error) error {
+	buff := bytes.NewBuffer(nil)
+	err := write(buff)
 	if err != nil {
 		return err
 	}
-	err = u.runExpectZero(u.systemCtlPath, "--user", "enable", u.name)
+
+	if u.step(Action{Kind: ActionWrite, Path: fileName, Content: buff.String()}) {
+		return u.chown(fileName)
+	}
+	err = u.writeFile(fileName, buff.Bytes(), u.fileMode())
 	if err != nil {
+		return fmt.Errorf("could not create unit file '%s': %w", fileName, err)
+	}
+	return nil
+}
+
+// mkdirAll creates a directory and any parents.
+func (u Unit) mkdirAll(dir string) error {
+	if u.step(Action{Kind: ActionMkdir, Path: dir}) {
+		return nil
+	}
+	if u.remote != nil && u.fileModes.Dir != 0 {
+		_, err := u.remoteFile("mkdir", dir, nil, `mkdir -p -m "$2" -- "$1"`, modeString(u.fileModes.Dir))
 		return err
 	}
-	err = u.runExpectZero(u.systemCtlPath, "--user", "restart", u.name)
-	if err != nil {
+	if u.remote != nil {
+		_, err := u.remoteFile("mkdir", dir, nil, `mkdir -p -- "$1"`)
 		return err
 	}
+	if u.escalate != nil && u.fileModes.Dir != 0 {
+		return u.runEscalated("mkdir", "-p", "-m", modeString(u.fileModes.Dir), "--", dir)
+	}
+	if u.escalate != nil {
+		return u.runEscalated("mkdir", "-p", "--", dir)
+	}
+	return os.MkdirAll(dir, u.dirMode())
+}
+
+// removeFile removes a unit file.
+func (u Unit) removeFile(fileName string) error {
+	if u.step(Action{Kind: ActionRemove, Path: fileName}) {
+		return nil
+	}
+	if u.remote != nil {
+		return u.removeRemoteFile(fileName)
+	}
+	if u.escalate != nil {
+		return u.runEscalated("rm", "--", fileName)
+	}
+	return os.Remove(fileName)
+}
+
+func (u Unit) writeTemplate(f io.Writer) error {
+	t := u.serviceTemplate
+	if t == nil {
+		var err error
+		t, err = template.New("").ParseFS(fs, "templates/*")
+		if err != nil {
+			return err
+		}
+		t = t.Lookup("basic.service")
+	}
+	return t.Execute(f, u.templateData())
+}
+
+// startUnit enables and (re)starts the unit. If changed is false the unit
+// files were already up to date, so the unit is only started if it is not
+// already running.
+func (u Unit) startUnit(changed bool) error {
+	if u.isTemplate() {
+		// nothing to start until an instance is deployed
+		return nil
+	}
+	enable := []string{"enable"}
+	start := []string{"start"}
+	if changed && !u.target {
+		// restarting a target would restart everything PartOf it
+		start = u.restartCommands()
+	}
+	switch u.deployMode {
+	case DeployEnableOnly:
+		start = nil
+	case DeployStartOnly:
+		enable = nil
+	case DeployEnableNow:
+		enable = []string{"enable", "--now"}
+		start = nil
+	}
+	if u.quadlet != nil {
+		// the generated service is enabled by its [Install] section
+		if u.deployMode == DeployEnableNow {
+			start = []string{"start"}
+		}
+		enable = nil
+	}
+
+	if changed && len(u.triggers) > 0 && start != nil {
+		// stop any running instance of the service, so the next activation
+		// runs with the new configuration
+		err := u.systemctl("stop", u.serviceName())
+		if err != nil {
+			return err
+		}
+	}
+	for _, unit := range u.activeUnits() {
+		if enable != nil {
+			err := u.systemctl(append(enable[:len(enable):len(enable)], unit)...)
+			if err != nil {
+				return err
+			}
+		}
+		for _, command := range start {
+			err := u.systemctl(command, unit)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
 // Undeploy is the opposite of deploy - it will stop the service, disable it,
-// remove the service file and refresh systemd.
+// remove the service file and refresh systemd. Any triggers are stopped,
+// disabled and removed too.
+// For units with OptInstances, Undeploy on an Instance only stops and disables
+// that instance. Otherwise all instances are stopped and disabled, and the
+// template unit file is removed.
+// For units with OptDropIn, only the drop-in is removed, and the service is
+// restarted if it is running.
+// With OptInstall, the installed copy of the program is removed as well, and
+// so are the credentials written for OptCredential.
 func (u Unit) Undeploy() error {
-	err := u.runExpectZero(u.systemCtlPath, "--user", "disable", u.name)
+	if u.dryRun && u.plan == nil {
+		return errDryRun
+	}
+	if u.reports() {
+		return u.reported("undeploy", Unit.Undeploy)
+	}
+	release, err := u.lock()
+	if err != nil {
+		return err
+	}
+	defer release()
+	err = u.undeployUnits()
+	if err != nil || u.instance != "" {
+		return err
+	}
+	err = u.removeCredentials()
+	if err != nil || u.installShared {
+		return err
+	}
+	return u.removeInstalled()
+}
+
+// undeployUnits stops, disables and removes the units.
+func (u Unit) undeployUnits() error {
+	if u.dropIn != "" {
+		return u.undeployDropIn()
+	}
+	if u.backend != nil {
+		return u.backend.undeploy(u)
+	}
+	if u.target {
+		return u.undeployTarget()
+	}
+	if u.slice {
+		return u.undeploySlice()
+	}
+	if u.quadlet != nil {
+		return u.undeployQuadlet()
+	}
+	if u.instances {
+		err := u.checkInstance()
+		if err != nil {
+			return err
+		}
+		if u.instance != "" {
+			err = u.disableAndStop(u.serviceName())
+			if err != nil {
+				return err
+			}
+			return u.purge()
+		}
+		err = u.systemctl("stop", u.name+"@*.service")
+		if err != nil {
+			return err
+		}
+		err = u.systemctl("disable", u.name+"@.service")
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, unit := range u.activeUnits() {
+			err := u.disableAndStop(unit)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, t := range u.triggers {
+		err := u.removeFile(u.unitFilename(t.unitType()))
+		if err != nil {
+			return err
+		}
+	}
+	if len(u.triggers) > 0 {
+		// the service may still be running if it was recently triggered
+		err := u.systemctl("stop", u.serviceName())
+		if err != nil {
+			return err
+		}
+	}
+	if !u.instances {
+		err := u.purge()
+		if err != nil {
+			return err
+		}
+	}
+	err := u.removeFile(u.UnitFilename())
 	if err != nil {
 		return err
 	}
-	err = u.runExpectZero(u.systemCtlPath, "--user", "stop", u.name)
+	err = u.removeFailureHandler()
 	if err != nil {
 		return err
 	}
-	err = os.Remove(u.UnitFilename())
+	err = u.removeLogFiles()
 	if err != nil {
 		return err
 	}
-	err = u.runExpectZero(u.systemCtlPath, "--user", "daemon-reload")
+	err = u.removeAccounts()
+	if err != nil {
+		return err
+	}
+	err = u.systemctl("daemon-reload")
 	if err != nil {
 		return err
 	}
@@ -166,30 +862,87 @@ func (u Unit) Undeploy() error {
 	return nil
 }
 
+// systemctl runs systemctl with the given arguments, against the user or
+// system manager as appropriate for the scope.
+func (u Unit) systemctl(args ...string) error {
+	if u.root != "" {
+		// staged files are not loaded by a running systemd
+		return nil
+	}
+	if (u.client != nil || u.dbus) && u.plan == nil {
+		u.step(Action{Kind: ActionRun, Command: append([]string{"systemctl"}, args...)})
+		if u.client != nil {
+			return u.client.Systemctl(u.context(), u.scope, args...)
+		}
+		return u.busSystemctl(args...)
+	}
+	if u.scope == ScopeUser {
+		args = append([]string{"--user"}, args...)
+	}
+	if u.escalate != nil {
+		return u.runEscalated(u.systemCtlPath, args...)
+	}
+	return u.runExpectZero(u.systemCtlPath, args...)
+}
+
+// disableAndStop disables and stops a single unit.
+func (u Unit) disableAndStop(unit string) error {
+	err := u.systemctl("disable", unit)
+	if err != nil {
+		return err
+	}
+	return u.systemctl("stop", unit)
+}
+
 // runExpectZero runs a command + optional arguments, returning an
 // error if it cannot be run, or if it returns a non-zero exit code
 func (u Unit) runExpectZero(command string, args ...string) error {
-	cmd := exec.Command(command, args...)
-	err := cmd.Start()
-	if err != nil {
-		return fmt.Errorf("could not start systemctl: %s", err)
+	if u.step(Action{Kind: ActionRun, Command: append([]string{command}, args...)}) {
+		return nil
 	}
+	_, err := u.runOutput(command, args...)
+	return err
+}
 
-	logStringA := []string{command}
-	logStringA = append(logStringA, args...)
-	logString := strings.Join(logStringA, " ")
-
-	err = cmd.Wait()
+// runOutput runs a command + optional arguments like runExpectZero,
+// returning its standard output.
+func (u Unit) runOutput(command string, args ...string) (string, error) {
+	return u.runInput(nil, command, args...)
+}
 
+// runInput is like runOutput, with stdin as the command's standard input.
+// With OptRemote the command is run on the remote host.
+func (u Unit) runInput(stdin io.Reader, command string, args ...string) (string, error) {
+	if u.remote != nil {
+		command, args = u.remote.command(command, args)
+	}
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	cmd := exec.CommandContext(u.context(), command, args...)
+	cmd.Stdin = stdin
+	cmd.Env = u.commandEnv()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Start()
 	if err != nil {
-		return fmt.Errorf("problem running '%s': %s", logString, err)
+		return "", fmt.Errorf("could not start %s: %w", path.Base(command), err)
 	}
 
-	if cmd.ProcessState.ExitCode() != 0 {
-		return fmt.Errorf("problem running '%s': exit code non-zero: %d", logString, cmd.ProcessState.ExitCode())
+	err = cmd.Wait()
+	if ctxErr := u.context().Err(); err != nil && ctxErr != nil {
+		// killed because the context is done
+		err = ctxErr
+	}
+	if err != nil {
+		return "", &SystemctlError{
+			Args:     append([]string{command}, args...),
+			ExitCode: cmd.ProcessState.ExitCode(),
+			Stderr:   strings.TrimSpace(stderr.String()),
+			Err:      err,
+		}
 	}
 
-	return nil
+	return stdout.String(), nil
 }
 
 // binaryName returns the fully-qualified path to the binary and the qualified path of the binary
@@ -210,41 +963,85 @@ func checkName(name string) bool {
 
 // setupEnvironment ensures we have systemd installed and other things ready
 func (u *Unit) setupEnvironment() error {
+	if u.root != "" {
+		return u.setupRoot()
+	}
+	if u.remote != nil {
+		return u.setupRemote()
+	}
+	err := u.setupInstall()
+	if err != nil {
+		return err
+	}
+	if u.backend == nil && !u.backendChosen && u.client == nil && !u.dbus {
+		// a client or D-Bus is always systemd, whatever else is installed
+		b, err := newBackend(DetectBackend())
+		if err != nil {
+			return err
+		}
+		u.backend = b
+	}
+	if u.backend != nil {
+		return u.backend.setup(u)
+	}
+	if u.client != nil {
+		return u.setupClient()
+	}
+	if u.dryRun {
+		return u.setupDryRun()
+	}
+
 	// check we have systemctl
 	systemCtlPath, err := exec.LookPath("systemctl")
-	if err != nil {
-		return fmt.Errorf("could not find systemctl: %s", err)
+	if err != nil && !u.dbus {
+		return fmt.Errorf("%w: %s", ErrSystemctlNotFound, err)
+	} else if err != nil {
+		systemCtlPath = "systemctl"
 	}
 	u.systemCtlPath = systemCtlPath
 
-	// check we aren't root
 	uid := os.Getuid()
+	if uid == -1 {
+		return errors.New("cannot run on windows")
+	}
+
+	if u.scope == ScopeSystem {
+		// system units need root, or a way to get it
+		if uid != 0 {
+			escalate, err := u.escalation.command()
+			if err != nil {
+				return err
+			}
+			u.escalate = escalate
+		}
+		u.unitFilePath = u.unitDirectoryOr(systemUnitDirectory)
+		return nil
+	}
+
+	// check we aren't root
 	if uid == 0 {
-		return fmt.Errorf("cannot run as root")
+		return ErrRootNotAllowed
 	}
-	if uid == -1 {
-		return fmt.Errorf("cannot run on windows")
+	err = u.checkUserBus(uid)
+	if err != nil {
+		return err
 	}
 
 	// check for the service file path
-	userHomeDir, err := os.UserHomeDir()
+	unitFileDirectory, err := userUnitDirectory()
 	if err != nil {
-		return fmt.Errorf("could not find users home dir: %s", err)
+		return err
 	}
-	unitFileDirectory := fmt.Sprintf("%s%c%s%c%s%c%s", userHomeDir, os.PathSeparator,
-		".config", os.PathSeparator,
-		"systemd", os.PathSeparator,
-		"user",
-	)
 
+	unitFileDirectory = u.unitDirectoryOr(unitFileDirectory)
 	err = os.MkdirAll(unitFileDirectory, 0700)
 	if err != nil {
-		return fmt.Errorf("cannot create the user systemd path '%s': %s", unitFileDirectory, err)
+		return fmt.Errorf("cannot create the user systemd path '%s': %w", unitFileDirectory, err)
 	}
 
 	sfp, err := os.Stat(unitFileDirectory)
 	if err != nil {
-		return fmt.Errorf("could not find user service directory '%s': %s", unitFileDirectory, err)
+		return fmt.Errorf("could not find user service directory '%s': %w", unitFileDirectory, err)
 	}
 
 	if !sfp.IsDir() {
@@ -252,5 +1049,27 @@ func (u *Unit) setupEnvironment() error {
 	}
 
 	u.unitFilePath = unitFileDirectory
-	return nil
+	return u.checkLinger("")
+}
+
+// userUnitDirectory returns the directory user unit files are installed in.
+func userUnitDirectory() (string, error) {
+	configHome, err := userConfigHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configHome, "systemd", "user"), nil
+}
+
+// userConfigHome returns $XDG_CONFIG_HOME, or ~/.config if it is unset, as
+// systemd does. A relative XDG_CONFIG_HOME is ignored, as the spec says.
+func userConfigHome() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	userHomeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find users home dir: %w", err)
+	}
+	return filepath.Join(userHomeDir, ".config"), nil
 }
@@ -0,0 +1,63 @@
+// Package unitardcli provides a urfave/cli command managing a program's own
+// service, for programs with a urfave/cli command line:
+//
+//	app.Commands = append(app.Commands, unitardcli.Command("myapp", unitard.OptProgramArgs{Args: "serve"}))
+//
+// adds "myapp service deploy", "service undeploy", "service status" and
+// "service logs". It is a separate module so that unitard itself doesn't
+// depend on urfave/cli.
+package unitardcli
+
+import (
+	"github.com/tardisx/unitard"
+	"github.com/tardisx/unitard/unitardflag"
+	"github.com/urfave/cli/v2"
+)
+
+// Command returns a "service" command with deploy, undeploy, status and
+// logs subcommands, for the unit name created with opts. The unit is only
+// created when a subcommand runs.
+func Command(name string, opts ...unitard.UnitOpts) *cli.Command {
+	run := func(set func(*cli.Context, *unitardflag.Flags)) cli.ActionFunc {
+		return func(c *cli.Context) error {
+			f := &unitardflag.Flags{Output: c.App.Writer}
+			set(c, f)
+			_, err := f.Run(name, opts...)
+			return err
+		}
+	}
+
+	return &cli.Command{
+		Name:  "service",
+		Usage: "Manage the " + name + " service",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "deploy",
+				Usage:  "Install and start the service",
+				Action: run(func(c *cli.Context, f *unitardflag.Flags) { f.Deploy = true }),
+			},
+			{
+				Name:   "undeploy",
+				Usage:  "Stop and remove the service",
+				Action: run(func(c *cli.Context, f *unitardflag.Flags) { f.Undeploy = true }),
+			},
+			{
+				Name:   "status",
+				Usage:  "Show the state of the service",
+				Action: run(func(c *cli.Context, f *unitardflag.Flags) { f.Status = true }),
+			},
+			{
+				Name:  "logs",
+				Usage: "Show the journal of the service",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "follow", Aliases: []string{"f"}, Usage: "keep showing new entries"},
+					&cli.IntFlag{Name: "lines", Aliases: []string{"n"}, Usage: "show only the last n entries"},
+				},
+				Action: run(func(c *cli.Context, f *unitardflag.Flags) {
+					f.Logs = true
+					f.LogOptions = unitard.LogOptions{Follow: c.Bool("follow"), Lines: c.Int("lines")}
+				}),
+			},
+		},
+	}
+}
@@ -0,0 +1,44 @@
+package unitardcli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tardisx/unitard"
+	"github.com/tardisx/unitard/unitardtest"
+	"github.com/urfave/cli/v2"
+)
+
+func TestCommand(t *testing.T) {
+	fake := unitardtest.New(t)
+	out := &bytes.Buffer{}
+	app := &cli.App{
+		Name:     "test_unit",
+		Writer:   out,
+		Commands: []*cli.Command{Command("test_unit", fake.Option(), unitard.OptSkipVerify{})},
+	}
+
+	execute := func(args ...string) string {
+		t.Helper()
+		out.Reset()
+		if err := app.Run(append([]string{"test_unit"}, args...)); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	if out := execute("service", "deploy"); !strings.HasPrefix(out, "test_unit deployed\n") {
+		t.Errorf("wrong output %q", out)
+	}
+	if !fake.Active("test_unit.service") {
+		t.Error("unit was not deployed")
+	}
+	if out := execute("service", "status"); !strings.HasPrefix(out, "test_unit: active") {
+		t.Errorf("wrong status %q", out)
+	}
+	execute("service", "undeploy")
+	if fake.Active("test_unit.service") {
+		t.Error("unit was not undeployed")
+	}
+}
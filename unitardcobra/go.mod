@@ -0,0 +1,16 @@
+module github.com/tardisx/unitard/unitardcobra
+
+go 1.21
+
+require (
+	github.com/spf13/cobra v1.8.1
+	github.com/tardisx/unitard v0.0.0
+)
+
+require (
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/sys v0.26.0 // indirect
+)
+
+replace github.com/tardisx/unitard => ../
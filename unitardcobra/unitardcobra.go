@@ -0,0 +1,70 @@
+// Package unitardcobra provides a cobra command managing a program's own
+// service, for programs with a cobra command line:
+//
+//	root.AddCommand(unitardcobra.Command("myapp", unitard.OptProgramArgs{Args: "serve"}))
+//
+// adds "myapp service deploy", "service undeploy", "service status" and
+// "service logs". It is a separate module so that unitard itself doesn't
+// depend on cobra.
+package unitardcobra
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/tardisx/unitard"
+	"github.com/tardisx/unitard/unitardflag"
+)
+
+// Command returns a "service" command with deploy, undeploy, status and
+// logs subcommands, for the unit name created with opts. The unit is only
+// created when a subcommand runs.
+func Command(name string, opts ...unitard.UnitOpts) *cobra.Command {
+	service := &cobra.Command{
+		Use:   "service",
+		Short: "Manage the " + name + " service",
+	}
+
+	run := func(set func(*unitardflag.Flags)) func(*cobra.Command, []string) error {
+		return func(cmd *cobra.Command, args []string) error {
+			f := &unitardflag.Flags{Output: cmd.OutOrStdout()}
+			set(f)
+			_, err := f.Run(name, opts...)
+			return err
+		}
+	}
+
+	logs := &unitardflag.Flags{}
+	logsCmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Show the journal of the service",
+		Args:  cobra.NoArgs,
+		RunE: run(func(f *unitardflag.Flags) {
+			f.Logs = true
+			f.LogOptions = logs.LogOptions
+		}),
+	}
+	logsCmd.Flags().BoolVarP(&logs.LogOptions.Follow, "follow", "f", false, "keep showing new entries")
+	logsCmd.Flags().IntVarP(&logs.LogOptions.Lines, "lines", "n", 0, "show only the last n entries")
+
+	service.AddCommand(
+		&cobra.Command{
+			Use:   "deploy",
+			Short: "Install and start the service",
+			Args:  cobra.NoArgs,
+			RunE:  run(func(f *unitardflag.Flags) { f.Deploy = true }),
+		},
+		&cobra.Command{
+			Use:   "undeploy",
+			Short: "Stop and remove the service",
+			Args:  cobra.NoArgs,
+			RunE:  run(func(f *unitardflag.Flags) { f.Undeploy = true }),
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "Show the state of the service",
+			Args:  cobra.NoArgs,
+			RunE:  run(func(f *unitardflag.Flags) { f.Status = true }),
+		},
+		logsCmd,
+	)
+	return service
+}
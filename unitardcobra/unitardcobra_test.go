@@ -0,0 +1,42 @@
+package unitardcobra
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/tardisx/unitard"
+	"github.com/tardisx/unitard/unitardtest"
+)
+
+func TestCommand(t *testing.T) {
+	fake := unitardtest.New(t)
+	root := &cobra.Command{Use: "test_unit"}
+	root.AddCommand(Command("test_unit", fake.Option(), unitard.OptSkipVerify{}))
+
+	execute := func(args ...string) string {
+		t.Helper()
+		out := &bytes.Buffer{}
+		root.SetOut(out)
+		root.SetArgs(args)
+		if err := root.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	if out := execute("service", "deploy"); !strings.HasPrefix(out, "test_unit deployed\n") {
+		t.Errorf("wrong output %q", out)
+	}
+	if !fake.Active("test_unit.service") {
+		t.Error("unit was not deployed")
+	}
+	if out := execute("service", "status"); !strings.HasPrefix(out, "test_unit: active") {
+		t.Errorf("wrong status %q", out)
+	}
+	execute("service", "undeploy")
+	if fake.Active("test_unit.service") {
+		t.Error("unit was not undeployed")
+	}
+}
@@ -0,0 +1,177 @@
+// Package unitardflag adds the usual -deploy, -undeploy, -status and -logs
+// flags to a program, and does what they say, so each program using unitard
+// needn't write the same few lines.
+//
+//	deploy := unitardflag.Register(flag.CommandLine)
+//	flag.Parse()
+//	deploy.Handle("myapp", unitard.OptProgramArgs{Args: "-serve"})
+//	// not deploying, so carry on running
+package unitardflag
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tardisx/unitard"
+)
+
+// Flags are the flags added by Register, or found by Parse.
+type Flags struct {
+	Deploy   bool
+	Undeploy bool
+	Status   bool
+	Logs     bool
+
+	// LogOptions selects the journal entries printed for Logs.
+	LogOptions unitard.LogOptions
+
+	// Output is where Handle and Run say what they did, os.Stdout by
+	// default.
+	Output io.Writer
+}
+
+// Register adds -deploy, -undeploy, -status and -logs flags to fs. Call Handle
+// after fs is parsed.
+func Register(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.BoolVar(&f.Deploy, "deploy", false, "install and start the service, then exit")
+	fs.BoolVar(&f.Undeploy, "undeploy", false, "stop and remove the service, then exit")
+	fs.BoolVar(&f.Status, "status", false, "show the state of the service, then exit")
+	fs.BoolVar(&f.Logs, "logs", false, "show the journal of the service, then exit")
+	return f
+}
+
+// Parse looks for the flags in args (such as os.Args[1:]) without a
+// flag.FlagSet, for programs which parse their arguments some other way.
+// As with flag, they start with - or --, and may be given a value, as in
+// -deploy=false. Other arguments, and everything after --, are ignored.
+func Parse(args []string) *Flags {
+	f := &Flags{}
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, ok := strings.CutPrefix(arg, "-")
+		if !ok {
+			continue
+		}
+		name = strings.TrimPrefix(name, "-")
+		name, value, hasValue := strings.Cut(name, "=")
+		set := true
+		if hasValue {
+			var err error
+			set, err = strconv.ParseBool(value)
+			if err != nil {
+				continue
+			}
+		}
+		switch name {
+		case "deploy":
+			f.Deploy = set
+		case "undeploy":
+			f.Undeploy = set
+		case "status":
+			f.Status = set
+		case "logs":
+			f.Logs = set
+		}
+	}
+	return f
+}
+
+// Handle does what the flags say with the unit name, created with opts,
+// and exits: with status 0 if it worked, or after printing the error to
+// os.Stderr with status 1. It returns, doing nothing, if none of the flags
+// were given.
+func (f *Flags) Handle(name string, opts ...unitard.UnitOpts) {
+	handled, err := f.Run(name, opts...)
+	if !handled {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// Run is Handle without the exit: it returns false if none of the flags
+// were given, and otherwise true and whether it worked.
+func (f *Flags) Run(name string, opts ...unitard.UnitOpts) (bool, error) {
+	given := 0
+	for _, set := range []bool{f.Deploy, f.Undeploy, f.Status, f.Logs} {
+		if set {
+			given++
+		}
+	}
+	if given == 0 {
+		return false, nil
+	}
+	if given > 1 {
+		return true, errors.New("use only one of -deploy, -undeploy, -status and -logs")
+	}
+	out := f.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
+	u, err := unitard.NewUnit(name, opts...)
+	if err != nil {
+		return true, err
+	}
+	switch {
+	case f.Deploy:
+		err = u.Deploy()
+		if err != nil {
+			return true, err
+		}
+		fmt.Fprintf(out, "%s deployed\n", name)
+	case f.Undeploy:
+		err = u.Undeploy()
+		if err != nil {
+			return true, err
+		}
+		fmt.Fprintf(out, "%s undeployed\n", name)
+		return true, nil
+	case f.Logs:
+		logs, err := u.Logs(f.LogOptions)
+		if err != nil {
+			return true, err
+		}
+		defer logs.Close()
+		_, err = io.Copy(out, logs)
+		return true, err
+	}
+	status, err := u.Status()
+	if err != nil {
+		return true, err
+	}
+	fmt.Fprintln(out, describe(name, status))
+	return true, nil
+}
+
+// describe returns a line describing the state of the service.
+func describe(name string, s unitard.Status) string {
+	if s.LoadState == "not-found" {
+		return name + ": not deployed"
+	}
+	line := fmt.Sprintf("%s: %s", name, s.ActiveState)
+	if s.SubState != "" {
+		line += " (" + s.SubState + ")"
+	}
+	if s.UnitFileState != "" {
+		line += ", " + s.UnitFileState
+	}
+	if s.MainPID != 0 {
+		line += fmt.Sprintf(", pid %d", s.MainPID)
+	}
+	if s.NRestarts > 0 {
+		line += fmt.Sprintf(", restarted %d times", s.NRestarts)
+	}
+	return line
+}
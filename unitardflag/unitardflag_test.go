@@ -0,0 +1,72 @@
+package unitardflag
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/tardisx/unitard"
+	"github.com/tardisx/unitard/unitardtest"
+)
+
+func TestRegister(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := Register(fs)
+	if err := fs.Parse([]string{"-deploy"}); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Deploy || f.Undeploy || f.Status {
+		t.Errorf("wrong flags %+v", f)
+	}
+
+	for _, c := range []struct {
+		args []string
+		want Flags
+	}{
+		{[]string{"serve", "--status", "-v"}, Flags{Status: true}},
+		{[]string{"-deploy=true", "--logs=false"}, Flags{Deploy: true}},
+		{[]string{"-undeploy", "-undeploy=0", "-status=maybe"}, Flags{}},
+		// positional words and flag values are not flags
+		{[]string{"status"}, Flags{}},
+		{[]string{"-mode", "deploy", "logs", "undeploy"}, Flags{}},
+		{[]string{"---deploy", "-", "--", "-deploy"}, Flags{}},
+	} {
+		if f := Parse(c.args); *f != c.want {
+			t.Errorf("%v: wrong flags %+v", c.args, f)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	fake := unitardtest.New(t)
+	out := &bytes.Buffer{}
+	opts := []unitard.UnitOpts{fake.Option(), unitard.OptSkipVerify{}}
+
+	if handled, err := (&Flags{Output: out}).Run("test_unit", opts...); handled || err != nil {
+		t.Errorf("nothing should be done without a flag: %v %v", handled, err)
+	}
+
+	handled, err := (&Flags{Deploy: true, Output: out}).Run("test_unit", opts...)
+	if !handled || err != nil {
+		t.Fatalf("deploy failed: %v %v", handled, err)
+	}
+	if !fake.Active("test_unit.service") {
+		t.Error("unit was not deployed")
+	}
+	if !strings.HasPrefix(out.String(), "test_unit deployed\ntest_unit: active") {
+		t.Errorf("wrong output %q", out)
+	}
+
+	out.Reset()
+	if _, err := (&Flags{Undeploy: true, Output: out}).Run("test_unit", opts...); err != nil {
+		t.Fatal(err)
+	}
+	if fake.Active("test_unit.service") || out.String() != "test_unit undeployed\n" {
+		t.Errorf("unit was not undeployed: %q", out)
+	}
+
+	if _, err := (&Flags{Deploy: true, Undeploy: true}).Run("test_unit", opts...); err == nil {
+		t.Error("expected an error for conflicting flags")
+	}
+}
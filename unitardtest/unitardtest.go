@@ -0,0 +1,343 @@
+// Package unitardtest provides a fake systemd, so that programs using
+// unitard can test their deploy and undeploy flows without a real one.
+//
+//	fake := unitardtest.New(t)
+//	unit, _ := unitard.NewUnit("myapp", fake.Option())
+//	unit.Deploy()
+//	if !fake.Active("myapp.service") { ... }
+package unitardtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tardisx/unitard"
+)
+
+// unitTypes are the suffixes which make a name a full unit name.
+var unitTypes = []string{".service", ".socket", ".timer", ".path", ".target", ".slice", ".mount"}
+
+// ErrFake is returned for failures set with Fail without an error.
+var ErrFake = errors.New("unitardtest: simulated failure")
+
+// Call is a systemctl command given to the fake.
+type Call struct {
+	Scope unitard.Scope
+	Args  []string
+}
+
+func (c Call) String() string {
+	return strings.Join(c.Args, " ")
+}
+
+// Fake is an in-memory systemd manager implementing
+// unitard.SystemdClient. Unit files are installed in a temporary
+// directory. It is safe for concurrent use.
+type Fake struct {
+	mu       sync.Mutex
+	dir      string
+	calls    []Call
+	failures map[string]error
+	active   map[string]bool
+	enabled  map[string]bool
+	failed   map[string]bool
+	started  map[string]int // number of times each unit was started
+}
+
+// New returns a Fake with no units, installing unit files in a directory
+// which is removed when the test finishes.
+func New(t testing.TB) *Fake {
+	return &Fake{
+		dir:      t.TempDir(),
+		failures: map[string]error{},
+		active:   map[string]bool{},
+		enabled:  map[string]bool{},
+		failed:   map[string]bool{},
+		started:  map[string]int{},
+	}
+}
+
+// Option returns the option to pass to unitard.NewUnit to use the fake.
+func (f *Fake) Option() unitard.UnitOpts {
+	return unitard.OptClient{Client: f}
+}
+
+// UnitDirectory returns the directory unit files are installed in, for
+// either scope.
+func (f *Fake) UnitDirectory(scope unitard.Scope) (string, error) {
+	return f.dir, nil
+}
+
+// Fail makes a command fail with err, or ErrFake if err is nil. command is
+// either a verb ("restart"), or a verb and a full unit name
+// ("restart myapp.service"). A unit which fails to start is left failed.
+func (f *Fake) Fail(command string, err error) {
+	if err == nil {
+		err = ErrFake
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[command] = err
+}
+
+// Calls returns the commands the fake has been given, in order.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call{}, f.calls...)
+}
+
+// Commands returns the commands the fake has been given as strings, such
+// as "enable myapp".
+func (f *Fake) Commands() []string {
+	commands := []string{}
+	for _, c := range f.Calls() {
+		commands = append(commands, c.String())
+	}
+	return commands
+}
+
+// Reset forgets the commands given so far, keeping the state of the units.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+}
+
+// Files returns the installed unit files and drop-ins, by their path
+// relative to the unit directory (eg "myapp.service").
+func (f *Fake) Files() map[string]string {
+	files := map[string]string{}
+	filepath.Walk(f.dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		content, err := os.ReadFile(name)
+		if err == nil {
+			rel, _ := filepath.Rel(f.dir, name)
+			files[rel] = string(content)
+		}
+		return nil
+	})
+	return files
+}
+
+// Active returns true if the unit (such as "myapp.service") is running.
+func (f *Fake) Active(unit string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active[unit]
+}
+
+// Enabled returns true if the unit has been enabled.
+func (f *Fake) Enabled(unit string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.enabled[unit]
+}
+
+// Started returns the number of times the unit has been started or
+// restarted.
+func (f *Fake) Started(unit string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.started[unit]
+}
+
+// Systemctl records the command and updates the state of the units.
+func (f *Fake) Systemctl(ctx context.Context, scope unitard.Scope, args ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, Call{Scope: scope, Args: append([]string{}, args...)})
+	if len(args) == 0 {
+		return errors.New("unitardtest: no command")
+	}
+
+	verb := args[0]
+	now := false
+	units := []string{}
+	for _, arg := range args[1:] {
+		if arg == "--now" {
+			now = true
+		} else if !strings.HasPrefix(arg, "--") {
+			units = append(units, f.expand(fullUnitName(arg))...)
+		}
+	}
+
+	if err := f.failures[verb]; err != nil {
+		f.failUnits(verb, units)
+		return err
+	}
+	for _, unit := range units {
+		if err := f.failures[verb+" "+unit]; err != nil {
+			f.failUnits(verb, []string{unit})
+			return err
+		}
+	}
+
+	switch verb {
+	case "daemon-reload", "clean":
+	case "enable", "disable":
+		for _, unit := range units {
+			if !f.installed(unit) {
+				return fmt.Errorf("unitardtest: unit %s does not exist", unit)
+			}
+			f.enabled[unit] = verb == "enable"
+			if now {
+				f.setActive(unit, verb == "enable")
+			}
+		}
+	case "start", "restart", "reload-or-restart":
+		for _, unit := range units {
+			if !f.installed(unit) {
+				return fmt.Errorf("unitardtest: unit %s not found", unit)
+			}
+			if verb == "start" && f.active[unit] {
+				continue
+			}
+			f.setActive(unit, true)
+		}
+	case "try-restart":
+		for _, unit := range units {
+			if f.active[unit] {
+				f.setActive(unit, true)
+			}
+		}
+	case "reload":
+		for _, unit := range units {
+			if !f.active[unit] {
+				return fmt.Errorf("unitardtest: unit %s is not active", unit)
+			}
+		}
+	case "stop":
+		for _, unit := range units {
+			f.setActive(unit, false)
+		}
+	default:
+		return fmt.Errorf("unitardtest: unsupported command %s", verb)
+	}
+	return nil
+}
+
+// failUnits leaves units failed if starting them failed.
+func (f *Fake) failUnits(verb string, units []string) {
+	if verb == "start" || verb == "restart" || verb == "reload-or-restart" {
+		for _, unit := range units {
+			f.active[unit] = false
+			f.failed[unit] = true
+		}
+	}
+}
+
+func (f *Fake) setActive(unit string, active bool) {
+	if active {
+		// as in systemd, stopping a failed unit leaves it failed
+		f.started[unit]++
+		f.failed[unit] = false
+	}
+	f.active[unit] = active
+}
+
+// installed returns true if there is a unit file for the unit, including
+// the template for an instance.
+func (f *Fake) installed(unit string) bool {
+	name := unit
+	if at := strings.Index(unit, "@"); at >= 0 {
+		// an instance is installed if its template is
+		name = unit[:at+1] + path.Ext(unit)
+	}
+	_, err := os.Stat(filepath.Join(f.dir, name))
+	return err == nil
+}
+
+// expand returns the known units matching a unit name with wildcards.
+func (f *Fake) expand(unit string) []string {
+	if !strings.ContainsAny(unit, "*?[") {
+		return []string{unit}
+	}
+	matches := []string{}
+	for _, known := range []map[string]bool{f.active, f.enabled} {
+		for name := range known {
+			if ok, _ := path.Match(unit, name); ok && !contains(matches, name) {
+				matches = append(matches, name)
+			}
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Show returns the state of a unit in the format of `systemctl show`.
+func (f *Fake) Show(ctx context.Context, scope unitard.Scope, unit string, properties ...string) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	unit = fullUnitName(unit)
+
+	all := map[string]string{
+		"LoadState":     "not-found",
+		"ActiveState":   "inactive",
+		"SubState":      "dead",
+		"UnitFileState": "disabled",
+		"Result":        "success",
+		"MainPID":       "0",
+		"NRestarts":     "0",
+	}
+	if f.installed(unit) {
+		all["LoadState"] = "loaded"
+	}
+	if f.enabled[unit] {
+		all["UnitFileState"] = "enabled"
+	}
+	if f.active[unit] {
+		all["ActiveState"] = "active"
+		all["SubState"] = "running"
+		all["MainPID"] = strconv.Itoa(1000 + f.started[unit])
+	} else if f.failed[unit] {
+		all["ActiveState"] = "failed"
+		all["SubState"] = "failed"
+		all["Result"] = "exit-code"
+	}
+
+	props := map[string]string{}
+	for _, p := range properties {
+		if v, ok := all[p]; ok {
+			props[p] = v
+		}
+	}
+	return props, nil
+}
+
+// fullUnitName adds .service to a unit name without a type, as systemctl
+// does.
+func fullUnitName(name string) string {
+	for _, t := range unitTypes {
+		if strings.HasSuffix(name, t) {
+			return name
+		}
+	}
+	return name + ".service"
+}
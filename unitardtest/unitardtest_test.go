@@ -0,0 +1,93 @@
+package unitardtest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tardisx/unitard"
+)
+
+func TestDeploy(t *testing.T) {
+	fake := New(t)
+	u, err := unitard.NewUnit("test_unit", fake.Option(), unitard.OptSkipVerify{})
+	if err != nil {
+		t.Fatalf("could not create unit: %s", err)
+	}
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	if !fake.Active("test_unit.service") || !fake.Enabled("test_unit.service") {
+		t.Error("unit should be active and enabled")
+	}
+	if _, ok := fake.Files()["test_unit.service"]; !ok {
+		t.Errorf("unit file not installed: %v", fake.Files())
+	}
+	s, err := u.Status()
+	if err != nil || s.ActiveState != "active" || s.UnitFileState != "enabled" || s.MainPID == 0 {
+		t.Errorf("wrong status %+v %v", s, err)
+	}
+
+	// an unchanged deploy doesn't restart
+	fake.Reset()
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("second deploy failed: %s", err)
+	}
+	if got := strings.Join(fake.Commands(), "\n"); got != "enable test_unit\nstart test_unit" {
+		t.Errorf("wrong commands for unchanged deploy:\n%s", got)
+	}
+	if fake.Started("test_unit.service") != 1 {
+		t.Errorf("unit should have been started once, got %d", fake.Started("test_unit.service"))
+	}
+
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if fake.Active("test_unit.service") || len(fake.Files()) != 0 {
+		t.Errorf("unit should be gone, files %v", fake.Files())
+	}
+}
+
+func TestFail(t *testing.T) {
+	fake := New(t)
+	fake.Fail("restart test_unit.service", nil)
+	u, err := unitard.NewUnit("test_unit", fake.Option(), unitard.OptSkipVerify{})
+	if err != nil {
+		t.Fatalf("could not create unit: %s", err)
+	}
+
+	err = u.Deploy()
+	var rollbackErr *unitard.RollbackError
+	if !errors.As(err, &rollbackErr) || !errors.Is(err, ErrFake) {
+		t.Errorf("expected a rolled back failure, got %v", err)
+	}
+	if len(fake.Files()) != 0 {
+		t.Errorf("failed first deploy should leave no files, got %v", fake.Files())
+	}
+	s, _ := u.Status()
+	if s.ActiveState != "failed" {
+		t.Errorf("unit should be failed, got %+v", s)
+	}
+}
+
+func TestInstances(t *testing.T) {
+	fake := New(t)
+	u, err := unitard.NewUnit("test_unit", fake.Option(), unitard.OptSkipVerify{}, unitard.OptInstances{})
+	if err != nil {
+		t.Fatalf("could not create unit: %s", err)
+	}
+	for _, name := range []string{"blue", "green"} {
+		if err := u.Instance(name).Deploy(); err != nil {
+			t.Fatalf("deploy of %s failed: %s", name, err)
+		}
+	}
+	if !fake.Active("test_unit@blue.service") || !fake.Active("test_unit@green.service") {
+		t.Error("instances should be active")
+	}
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if fake.Active("test_unit@blue.service") {
+		t.Error("instances should be stopped")
+	}
+}
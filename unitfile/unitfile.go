@@ -0,0 +1,246 @@
+// Package unitfile parses and writes systemd unit files (see
+// systemd.syntax(7)), keeping their sections, repeated keys and comments so
+// a file can be changed and written back without losing anything.
+package unitfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// File is a parsed unit file.
+type File struct {
+	// Sections in the order they appear. Comments and blank lines before
+	// the first section header are kept in a section with an empty Name.
+	Sections []*Section
+}
+
+// Section is a [Section] of a unit file.
+type Section struct {
+	Name  string
+	Lines []Line
+}
+
+// Line is a line of a section. Comment and blank lines have an empty Key.
+type Line struct {
+	Key     string
+	Value   string
+	Comment string // the whole line, including the # or ;, for comment lines
+}
+
+// IsComment returns true if the line is a comment.
+func (l Line) IsComment() bool {
+	return l.Comment != ""
+}
+
+// IsBlank returns true if the line is empty.
+func (l Line) IsBlank() bool {
+	return l.Key == "" && l.Comment == ""
+}
+
+// Parse reads a unit file. Lines continued with a trailing backslash are
+// joined into a single value.
+func Parse(r io.Reader) (*File, error) {
+	f := &File{}
+	var section *Section
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	continued := false
+	for scanner.Scan() {
+		lineNo++
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+
+		if continued {
+			last := &section.Lines[len(section.Lines)-1]
+			continued = strings.HasSuffix(trimmed, "\\")
+			last.Value += " " + strings.TrimSuffix(trimmed, "\\")
+			if !continued {
+				last.Value = strings.TrimSpace(last.Value)
+			}
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			if section == nil {
+				section = f.addSection("")
+			}
+			section.Lines = append(section.Lines, Line{})
+		case trimmed[0] == '#' || trimmed[0] == ';':
+			if section == nil {
+				section = f.addSection("")
+			}
+			section.Lines = append(section.Lines, Line{Comment: trimmed})
+		case trimmed[0] == '[':
+			if !strings.HasSuffix(trimmed, "]") || len(trimmed) < 3 {
+				return nil, fmt.Errorf("line %d: bad section header '%s'", lineNo, trimmed)
+			}
+			section = f.addSection(trimmed[1 : len(trimmed)-1])
+		default:
+			if section == nil || section.Name == "" {
+				return nil, fmt.Errorf("line %d: assignment outside of a section", lineNo)
+			}
+			i := strings.Index(trimmed, "=")
+			if i < 1 {
+				return nil, fmt.Errorf("line %d: missing '=' in '%s'", lineNo, trimmed)
+			}
+			value := strings.TrimSpace(trimmed[i+1:])
+			continued = strings.HasSuffix(value, "\\")
+			value = strings.TrimSpace(strings.TrimSuffix(value, "\\"))
+			section.Lines = append(section.Lines, Line{Key: strings.TrimSpace(trimmed[:i]), Value: value})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) addSection(name string) *Section {
+	s := &Section{Name: name}
+	f.Sections = append(f.Sections, s)
+	return s
+}
+
+// WriteTo writes the unit file to w.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, f.String())
+	return int64(n), err
+}
+
+// String returns the unit file contents.
+func (f *File) String() string {
+	b := strings.Builder{}
+	for _, s := range f.Sections {
+		if s.Name != "" {
+			b.WriteString("[" + s.Name + "]\n")
+		}
+		for _, l := range s.Lines {
+			switch {
+			case l.IsComment():
+				b.WriteString(l.Comment)
+			case l.Key != "":
+				b.WriteString(l.Key + "=" + l.Value)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// Section returns the first section with the given name, or nil if there
+// is none.
+func (f *File) Section(name string) *Section {
+	for _, s := range f.Sections {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Get returns the value of the last assignment of key in the section. As
+// in systemd, a later assignment overrides an earlier one.
+func (f *File) Get(section, key string) (string, bool) {
+	values := f.all(section, key)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// Values returns the values of a key which is a list (such as After or
+// ExecStartPre), in order. An empty assignment resets the list, as it does
+// in systemd.
+func (f *File) Values(section, key string) []string {
+	values := []string{}
+	for _, v := range f.all(section, key) {
+		if v == "" {
+			values = []string{}
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+func (f *File) all(section, key string) []string {
+	values := []string{}
+	for _, s := range f.Sections {
+		if s.Name != section {
+			continue
+		}
+		for _, l := range s.Lines {
+			if l.Key == key {
+				values = append(values, l.Value)
+			}
+		}
+	}
+	return values
+}
+
+// Set replaces all assignments of key in the section with a single one,
+// in place of the first. The section is added if needed.
+func (f *File) Set(section, key, value string) {
+	set := false
+	for _, s := range f.Sections {
+		if s.Name != section {
+			continue
+		}
+		lines := s.Lines[:0]
+		for _, l := range s.Lines {
+			if l.Key == key {
+				if set {
+					continue
+				}
+				l.Value = value
+				set = true
+			}
+			lines = append(lines, l)
+		}
+		s.Lines = lines
+	}
+	if !set {
+		f.Add(section, key, value)
+	}
+}
+
+// Add appends an assignment of key to the section, adding to a list key
+// rather than replacing it. The section is added if needed.
+func (f *File) Add(section, key, value string) {
+	s := f.Section(section)
+	if s == nil {
+		if n := len(f.Sections); n > 0 {
+			last := f.Sections[n-1]
+			if len(last.Lines) > 0 && !last.Lines[len(last.Lines)-1].IsBlank() {
+				last.Lines = append(last.Lines, Line{})
+			}
+		}
+		s = f.addSection(section)
+	}
+	// keep any blank lines separating this section from the next
+	i := len(s.Lines)
+	for i > 0 && s.Lines[i-1].IsBlank() {
+		i--
+	}
+	s.Lines = append(s.Lines[:i], append([]Line{{Key: key, Value: value}}, s.Lines[i:]...)...)
+}
+
+// Remove removes all assignments of key from the section.
+func (f *File) Remove(section, key string) {
+	for _, s := range f.Sections {
+		if s.Name != section {
+			continue
+		}
+		lines := s.Lines[:0]
+		for _, l := range s.Lines {
+			if l.Key != key {
+				lines = append(lines, l)
+			}
+		}
+		s.Lines = lines
+	}
+}
@@ -0,0 +1,92 @@
+package unitfile
+
+import (
+	"strings"
+	"testing"
+)
+
+const example = `# service file automatically created with github.com/tardisx/unitard
+
+[Unit]
+Description=test_unit
+After=network.target
+After=foo.service
+
+[Service]
+; a comment
+ExecStart=/bin/foo \
+  -flag
+ExecStartPre=/bin/one
+ExecStartPre=
+ExecStartPre=/bin/two
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse(strings.NewReader(example))
+	if err != nil {
+		t.Fatalf("could not parse: %s", err)
+	}
+	if len(f.Sections) != 3 || f.Sections[0].Name != "" || f.Sections[2].Name != "Service" {
+		t.Errorf("wrong sections %v", f.Sections)
+	}
+	if v, ok := f.Get("Unit", "Description"); !ok || v != "test_unit" {
+		t.Errorf("wrong description %q", v)
+	}
+	if v, _ := f.Get("Service", "ExecStart"); v != "/bin/foo -flag" {
+		t.Errorf("continuation not joined: %q", v)
+	}
+	if v := f.Values("Unit", "After"); len(v) != 2 {
+		t.Errorf("wrong After %v", v)
+	}
+	if v := f.Values("Service", "ExecStartPre"); len(v) != 1 || v[0] != "/bin/two" {
+		t.Errorf("empty value should reset the list, got %v", v)
+	}
+	if _, ok := f.Get("Install", "WantedBy"); ok {
+		t.Error("missing key should not be found")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	f, err := Parse(strings.NewReader(example))
+	if err != nil {
+		t.Fatalf("could not parse: %s", err)
+	}
+	want := strings.Replace(example, "/bin/foo \\\n  -flag", "/bin/foo -flag", 1)
+	if f.String() != want {
+		t.Errorf("round trip changed the file:\n%s", f.String())
+	}
+}
+
+func TestModify(t *testing.T) {
+	f, _ := Parse(strings.NewReader(example))
+	f.Set("Unit", "After", "other.target")
+	f.Add("Install", "WantedBy", "default.target")
+	f.Add("Unit", "Wants", "other.target")
+	f.Remove("Service", "ExecStartPre")
+
+	want := `# service file automatically created with github.com/tardisx/unitard
+
+[Unit]
+Description=test_unit
+After=other.target
+Wants=other.target
+
+[Service]
+; a comment
+ExecStart=/bin/foo -flag
+
+[Install]
+WantedBy=default.target
+`
+	if f.String() != want {
+		t.Errorf("wrong file after changes:\n%s", f.String())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, bad := range []string{"Key=value\n", "[Unit\n", "[Unit]\nno equals\n", "[Unit]\n=value\n"} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q should not parse", bad)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultUpgradeTimeout is how long Upgrade waits for the new binary to
+// come up, unless OptWaitActive or UpgradeOpts say otherwise.
+const defaultUpgradeTimeout = 30 * time.Second
+
+// UpgradeOpts are the options for Upgrade.
+type UpgradeOpts struct {
+	SHA256  string        // the expected hex encoded sha256 of the new binary, not checked if empty
+	Timeout time.Duration // how long to wait for the service to come up, 30 seconds if zero (or that of OptWaitActive)
+}
+
+// Upgrade replaces the binary the unit runs with newBinary, and restarts
+// the service with it. The new binary is checked (against opts.SHA256, if
+// given) and swapped into place atomically, the unit files are updated and
+// the service is restarted and waited for. If it doesn't come up, the
+// previous binary and unit files are put back and started again, and a
+// *RollbackError is returned.
+//
+// With OptInstall the installed copy is replaced; with Versioned the new
+// build is installed alongside the old one. Restarting the service stops
+// every process in it, so a service upgrading itself should run Upgrade in
+// a process of its own, such as with systemd-run.
+func (u Unit) Upgrade(newBinary string, opts UpgradeOpts) error {
+	if u.reports() {
+		return u.reported("upgrade", func(u Unit) error { return u.Upgrade(newBinary, opts) })
+	}
+	if u.remote != nil || u.root != "" {
+		return errors.New("Upgrade cannot be used with OptRemote or OptRoot, use Deploy")
+	}
+	newBinary, err := filepath.Abs(newBinary)
+	if err != nil {
+		return err
+	}
+	err = checkExecutable(newBinary)
+	if err != nil {
+		return err
+	}
+	hash := fileHash(newBinary)
+	if hash == "" {
+		return fmt.Errorf("could not read the new binary '%s'", newBinary)
+	}
+	if opts.SHA256 != "" && !strings.EqualFold(opts.SHA256, hash) {
+		return fmt.Errorf("checksum of '%s' is %s, not %s", newBinary, hash, opts.SHA256)
+	}
+	if newBinary == u.binary {
+		return errors.New("the new binary is the one the unit already runs")
+	}
+
+	u.binaryHash = hash
+	if u.install != nil && u.install.Versioned {
+		u.binary = filepath.Join(u.binaryPath, u.name+"-"+hash[:12]+filepath.Ext(u.binary))
+	}
+	// the new binary is installed here, not the running program
+	u.installFrom = ""
+	u.alwaysRestart = true
+	if u.waitTimeout == 0 {
+		u.waitTimeout = opts.Timeout
+	}
+	if u.waitTimeout == 0 {
+		u.waitTimeout = defaultUpgradeTimeout
+	}
+
+	release, err := u.lock()
+	if err != nil {
+		return err
+	}
+	defer release()
+	d, err := u.prepareDeploy()
+	if err != nil {
+		return err
+	}
+	d.upgrade = newBinary
+	return d.run()
+}
+
+// oldBinary is where swapBinary keeps the previous binary.
+func (u Unit) oldBinary() string {
+	return u.binary + ".old"
+}
+
+// swapBinary replaces the binary the unit runs with newBinary, keeping the
+// previous one for restoreBinary. It returns whether there was one.
+func (u Unit) swapBinary(newBinary string) (bool, error) {
+	_, err := os.Stat(u.binary)
+	previous := err == nil
+	if previous {
+		err := u.copyBinary(u.binary, u.oldBinary())
+		if err != nil {
+			return false, fmt.Errorf("could not keep the previous binary: %w", err)
+		}
+	}
+	err = u.mkdirAll(u.binaryPath)
+	if err == nil {
+		err = u.copyBinary(newBinary, u.binary)
+	}
+	if err != nil {
+		return previous, fmt.Errorf("could not replace the binary: %w", err)
+	}
+	return previous, nil
+}
+
+// copyBinary copies an executable, replacing dst atomically.
+func (u Unit) copyBinary(src, dst string) error {
+	if u.escalate != nil {
+		err := u.runEscalated("install", "-m", "0755", src, dst+".new")
+		if err != nil {
+			return err
+		}
+		return u.runEscalated("mv", "-f", "--", dst+".new", dst)
+	}
+	if u.step(Action{Kind: ActionRun, Command: []string{"install", "-m", "0755", src, dst}}) {
+		return nil
+	}
+	return copyExecutable(src, dst)
+}
+
+// restoreBinary puts back the binary which swapBinary replaced, or removes
+// the new one if there wasn't one before.
+func (u Unit) restoreBinary(previous bool) error {
+	if !previous {
+		err := u.removeFile(u.binary)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if u.escalate != nil {
+		return u.runEscalated("mv", "-f", "--", u.oldBinary(), u.binary)
+	}
+	if u.step(Action{Kind: ActionRun, Command: []string{"mv", "-f", u.oldBinary(), u.binary}}) {
+		return nil
+	}
+	return os.Rename(u.oldBinary(), u.binary)
+}
@@ -0,0 +1,110 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeUpgradeSystemctl is a systemctl whose service is failed while binary
+// contains "broken".
+func fakeUpgradeSystemctl(t *testing.T, binary string) (string, string) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	script := filepath.Join(dir, "systemctl")
+	os.WriteFile(script, []byte(`#!/bin/sh
+echo "$@" >> `+log+`
+if [ "$2" = show ]; then
+	if grep -q broken `+binary+`; then
+		printf 'ActiveState=failed\nSubState=failed\nResult=exit-code\n'
+	else
+		printf 'ActiveState=active\nSubState=running\nMainPID=100\nNRestarts=0\n'
+	fi
+fi
+exit 0
+`), 0700)
+	return script, log
+}
+
+func TestUpgrade(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "foobar")
+	os.WriteFile(binary, []byte("#!/bin/sh\n# build 1\n"), 0755)
+	systemctl, log := fakeUpgradeSystemctl(t, binary)
+	u := Unit{name: "test_unit", binary: binary, binaryPath: dir, binaryHash: fileHash(binary), skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	build2 := filepath.Join(t.TempDir(), "foobar")
+	os.WriteFile(build2, []byte("#!/bin/sh\n# build 2\n"), 0755)
+	if err := u.Upgrade(build2, UpgradeOpts{SHA256: "0000"}); err == nil {
+		t.Error("a wrong checksum should be refused")
+	}
+	os.Truncate(log, 0)
+	if err := u.Upgrade(build2, UpgradeOpts{SHA256: fileHash(build2)}); err != nil {
+		t.Fatalf("upgrade failed: %s", err)
+	}
+	if content, _ := os.ReadFile(binary); !strings.Contains(string(content), "build 2") {
+		t.Errorf("binary was not replaced: %s", content)
+	}
+	if _, err := os.Stat(binary + ".old"); !os.IsNotExist(err) {
+		t.Error("previous binary should be removed after a successful upgrade")
+	}
+	unit, _ := os.ReadFile(u.UnitFilename())
+	if !strings.Contains(string(unit), markerBinaryHash+"="+fileHash(build2)) {
+		t.Errorf("unit file should have the new hash:\n%s", unit)
+	}
+	if commands, _ := os.ReadFile(log); !strings.Contains(string(commands), "restart test_unit") {
+		t.Errorf("service was not restarted: %s", commands)
+	}
+
+	// a broken build is rolled back
+	u.binaryHash = fileHash(binary)
+	build3 := filepath.Join(t.TempDir(), "foobar")
+	os.WriteFile(build3, []byte("#!/bin/sh\n# broken\n"), 0755)
+	err := u.Upgrade(build3, UpgradeOpts{})
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) || rollbackErr.RollbackErr != nil {
+		t.Fatalf("expected a successful rollback, got %v", err)
+	}
+	if content, _ := os.ReadFile(binary); !strings.Contains(string(content), "build 2") {
+		t.Errorf("previous binary was not restored: %s", content)
+	}
+	if content, _ := os.ReadFile(u.UnitFilename()); string(content) != string(unit) {
+		t.Errorf("previous unit file was not restored:\n%s", content)
+	}
+}
+
+func TestUpgradeVersioned(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(t.TempDir(), "foobar")
+	os.WriteFile(src, []byte("#!/bin/sh\n# build 1\n"), 0755)
+	u := Unit{name: "test_unit", binary: src, binaryHash: fileHash(src), skipVerify: true, unitFilePath: t.TempDir()}
+	(OptInstall{Dir: dir, Versioned: true}).Apply(&u)
+	u.setupInstall()
+	systemctl, _ := fakeUpgradeSystemctl(t, u.binary)
+	u.systemCtlPath = systemctl
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	first := u.binary
+
+	build2 := filepath.Join(t.TempDir(), "foobar")
+	os.WriteFile(build2, []byte("#!/bin/sh\n# build 2\n"), 0755)
+	if err := u.Upgrade(build2, UpgradeOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	second := filepath.Join(dir, "test_unit-"+fileHash(build2)[:12])
+	if content, _ := os.ReadFile(second); !strings.Contains(string(content), "build 2") {
+		t.Errorf("new build was not installed: %s", content)
+	}
+	if _, err := os.Stat(first); err != nil {
+		t.Errorf("previous build should be kept: %s", err)
+	}
+	if unit, _ := os.ReadFile(u.UnitFilename()); !strings.Contains(string(unit), "ExecStart="+second) {
+		t.Errorf("unit should run the new build:\n%s", unit)
+	}
+}
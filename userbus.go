@@ -0,0 +1,93 @@
+package unitard
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// UserBusError is returned by NewUnit for a user scope unit when the user's
+// systemd instance can't be reached, usually because the program is run
+// over ssh without a login session, from cron or after su. It matches
+// ErrNoUserBus, and the message says how to fix it.
+type UserBusError struct {
+	UID        string // the user's id
+	RuntimeDir string // the runtime directory which was looked in, if any
+	Reason     string // what is missing
+}
+
+func (e *UserBusError) Error() string {
+	fix := "log in to start a session, or enable lingering with 'loginctl enable-linger' so the user's systemd runs without one"
+	if e.RuntimeDir != "" && e.Reason == userBusNotSet {
+		fix = fmt.Sprintf("set XDG_RUNTIME_DIR=%s (or use OptDeriveRuntimeDir)", e.RuntimeDir)
+	}
+	return fmt.Sprintf("%s: %s; %s", ErrNoUserBus, e.Reason, fix)
+}
+
+func (e *UserBusError) Is(target error) bool {
+	return target == ErrNoUserBus
+}
+
+// userBusNotSet is the Reason when neither variable is set.
+const userBusNotSet = "XDG_RUNTIME_DIR and DBUS_SESSION_BUS_ADDRESS are not set"
+
+// OptDeriveRuntimeDir sets XDG_RUNTIME_DIR to /run/user/$UID for the
+// commands unitard runs, when it is not set, as it isn't under cron or su.
+// The user's systemd must still be running, which needs a login session or
+// lingering (see OptLinger).
+type OptDeriveRuntimeDir struct{}
+
+func (o OptDeriveRuntimeDir) Apply(u *Unit) error {
+	u.deriveRuntimeDir = true
+	return nil
+}
+
+// runtimeDirectory is where the runtime directory of a user is.
+func runtimeDirectory(uid int) string {
+	return filepath.Join("/run/user", strconv.Itoa(uid))
+}
+
+// checkUserBus checks the user's systemd instance can be reached, before
+// running systemctl --user, deriving XDG_RUNTIME_DIR with
+// OptDeriveRuntimeDir.
+func (u *Unit) checkUserBus(uid int) error {
+	return u.findUserBus(strconv.Itoa(uid), runtimeDirectory(uid))
+}
+
+// findUserBus looks for the bus in XDG_RUNTIME_DIR, or derived, the
+// runtime directory of the user uid.
+func (u *Unit) findUserBus(uid string, derived string) error {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+		return nil
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		_, err := os.Stat(derived)
+		if !u.deriveRuntimeDir || err != nil {
+			e := &UserBusError{UID: uid, Reason: userBusNotSet}
+			if err == nil {
+				// the variable is all that's missing
+				e.RuntimeDir = derived
+			}
+			return e
+		}
+		dir = derived
+		u.runtimeDir = dir
+	}
+	for _, socket := range []string{"bus", "systemd/private"} {
+		if _, err := os.Stat(filepath.Join(dir, socket)); err == nil {
+			return nil
+		}
+	}
+	return &UserBusError{UID: uid, RuntimeDir: dir, Reason: "the user's systemd is not running in " + dir}
+}
+
+// commandEnv returns the environment for the commands unitard runs, or nil
+// to use this process's.
+func (u Unit) commandEnv() []string {
+	if u.runtimeDir == "" || u.remote != nil {
+		return nil
+	}
+	return append(os.Environ(), "XDG_RUNTIME_DIR="+u.runtimeDir)
+}
@@ -0,0 +1,58 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindUserBus(t *testing.T) {
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "")
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	dir := t.TempDir()
+
+	u := Unit{name: "test_unit"}
+	err := u.findUserBus("1000", filepath.Join(dir, "missing"))
+	var busErr *UserBusError
+	if !errors.As(err, &busErr) || !errors.Is(err, ErrNoUserBus) || busErr.RuntimeDir != "" {
+		t.Fatalf("expected a UserBusError without a runtime directory, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "enable-linger") {
+		t.Errorf("the error should suggest lingering: %s", err)
+	}
+
+	err = u.findUserBus("1000", dir)
+	if !errors.As(err, &busErr) || busErr.RuntimeDir != dir || !strings.Contains(err.Error(), "XDG_RUNTIME_DIR="+dir) {
+		t.Errorf("the error should suggest setting XDG_RUNTIME_DIR: %v", err)
+	}
+
+	(OptDeriveRuntimeDir{}).Apply(&u)
+	err = u.findUserBus("1000", dir)
+	if !errors.As(err, &busErr) || !strings.Contains(busErr.Reason, "not running") {
+		t.Errorf("expected the user's systemd not running, got %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "bus"), nil, 0600)
+	u.runtimeDir = ""
+	if err := u.findUserBus("1000", dir); err != nil || u.runtimeDir != dir {
+		t.Fatalf("XDG_RUNTIME_DIR should be derived: %v %q", err, u.runtimeDir)
+	}
+	env := strings.Join(u.commandEnv(), "\n")
+	if !strings.Contains(env, "XDG_RUNTIME_DIR="+dir) {
+		t.Error("XDG_RUNTIME_DIR should be passed to commands")
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	u = Unit{name: "test_unit"}
+	if err := u.findUserBus("1000", "/nonexistent"); err != nil || u.commandEnv() != nil {
+		t.Errorf("a set XDG_RUNTIME_DIR should be used as it is: %v", err)
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path=/somewhere")
+	if err := u.findUserBus("1000", "/nonexistent"); err != nil {
+		t.Errorf("DBUS_SESSION_BUS_ADDRESS should be trusted: %s", err)
+	}
+}
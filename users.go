@@ -0,0 +1,122 @@
+package unitard
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"text/template"
+)
+
+const (
+	// sysusersDirectory is where OptUser writes its sysusers.d
+	// configuration, and vendorSysusersDirectory where it is staged under
+	// OptRoot, relative to the root.
+	sysusersDirectory       = "/etc/sysusers.d"
+	vendorSysusersDirectory = "usr/lib/sysusers.d"
+)
+
+// checkAccounts returns an error if the accounts OptUser should Check are
+// missing. Accounts on the target system of OptRoot can't be checked.
+func (u Unit) checkAccounts() error {
+	if u.account == nil || !u.account.Check || u.root != "" {
+		return nil
+	}
+	if u.account.User != "" {
+		err := u.lookupAccount("passwd", u.account.User)
+		if err != nil {
+			return fmt.Errorf("sorry, user '%s' does not exist, use Create to add it: %w", u.account.User, err)
+		}
+	}
+	for _, group := range append([]string{u.account.Group}, u.account.SupplementaryGroups...) {
+		if group == "" {
+			continue
+		}
+		err := u.lookupAccount("group", group)
+		if err != nil {
+			return fmt.Errorf("sorry, group '%s' does not exist, use Create to add it: %w", group, err)
+		}
+	}
+	return nil
+}
+
+// lookupAccount looks up a user (in passwd) or group, on the remote host
+// with OptRemote.
+func (u Unit) lookupAccount(database, name string) error {
+	if u.remote != nil {
+		_, err := u.runOutput("getent", database, name)
+		return err
+	}
+	if database == "passwd" {
+		_, err := user.Lookup(name)
+		return err
+	}
+	_, err := user.LookupGroup(name)
+	return err
+}
+
+// sysusersFilename returns the path of the sysusers.d configuration.
+func (u Unit) sysusersFilename() string {
+	if u.root != "" {
+		return filepath.Join(u.root, vendorSysusersDirectory, u.name+".conf")
+	}
+	return filepath.Join(sysusersDirectory, u.name+".conf")
+}
+
+// createAccounts writes the sysusers.d configuration for OptUser with
+// Create, and applies it.
+func (u Unit) createAccounts() error {
+	if u.account == nil || !u.account.Create {
+		return nil
+	}
+	err := u.mkdirAll(filepath.Dir(u.sysusersFilename()))
+	if err != nil {
+		return err
+	}
+	err = u.createFile(u.sysusersFilename(), u.writeSysusersTemplate)
+	if err != nil || u.root != "" {
+		return err
+	}
+	if u.escalate != nil {
+		return u.runEscalated("systemd-sysusers", u.sysusersFilename())
+	}
+	return u.runExpectZero("systemd-sysusers", u.sysusersFilename())
+}
+
+// removeAccounts removes the sysusers.d configuration. The accounts are
+// left alone.
+func (u Unit) removeAccounts() error {
+	if u.account == nil || !u.account.Create {
+		return nil
+	}
+	err := u.removeFile(u.sysusersFilename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (u Unit) writeSysusersTemplate(f io.Writer) error {
+	t, err := template.New("").ParseFS(fs, "templates/*")
+	if err != nil {
+		return err
+	}
+	groups := []string{}
+	if u.account.Group != "" {
+		groups = append(groups, u.account.Group)
+	}
+	members := u.account.SupplementaryGroups
+	if u.account.User == "" {
+		// there is nobody to add, so just create them
+		groups = append(groups, members...)
+		members = nil
+	}
+	data := map[string]interface{}{
+		"Name":    u.name,
+		"User":    u.account.User,
+		"Groups":  groups,
+		"Members": members,
+	}
+	return t.ExecuteTemplate(f, "sysusers.conf", data)
+}
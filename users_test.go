@@ -0,0 +1,83 @@
+package unitard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUserCreate(t *testing.T) {
+	t.Setenv("PATH", "")
+	root := t.TempDir()
+	u, err := NewUnit("test_unit", OptRoot{Dir: root, Binary: "/usr/bin/foobar"}, OptScope{Scope: ScopeSystem},
+		OptUser{User: "foobar", Group: "foobar-data", SupplementaryGroups: []string{"adm", "systemd-journal"}, Create: true})
+	if err != nil {
+		t.Fatalf("could not create unit: %s", err)
+	}
+	if err := u.Deploy(); err != nil {
+		t.Fatalf("deploy failed: %s", err)
+	}
+	content, _ := os.ReadFile(u.UnitFilename())
+	for _, want := range []string{"User=foobar\n", "Group=foobar-data\n", "SupplementaryGroups=adm systemd-journal\n"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("unit file is missing %q:\n%s", want, content)
+		}
+	}
+	sysusers := filepath.Join(root, "usr/lib/sysusers.d/test_unit.conf")
+	content, err = os.ReadFile(sysusers)
+	if err != nil {
+		t.Fatalf("sysusers.d configuration was not staged: %s", err)
+	}
+	want := "g foobar-data -\nu foobar - \"test_unit service\" -\nm foobar adm\nm foobar systemd-journal\n"
+	if !strings.HasSuffix(string(content), want) {
+		t.Errorf("wrong sysusers.d configuration:\n%s", content)
+	}
+
+	if err := u.Undeploy(); err != nil {
+		t.Fatalf("undeploy failed: %s", err)
+	}
+	if _, err := os.Stat(sysusers); !os.IsNotExist(err) {
+		t.Error("sysusers.d configuration was not removed")
+	}
+}
+
+func TestUserCreateDryRun(t *testing.T) {
+	u, err := NewUnit("test_unit", OptDryRun{}, OptScope{Scope: ScopeSystem}, OptUser{Group: "foobar", Create: true})
+	if err != nil {
+		t.Fatalf("could not create unit: %s", err)
+	}
+	plan, err := u.DryRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := plan.Files()["/etc/sysusers.d/test_unit.conf"]; !strings.HasSuffix(content, "\ng foobar -\n") {
+		t.Errorf("wrong sysusers.d configuration:\n%s", content)
+	}
+	if commands := strings.Join(plan.Commands(), "\n"); !strings.Contains(commands, "systemd-sysusers /etc/sysusers.d/test_unit.conf") {
+		t.Errorf("accounts are not created:\n%s", commands)
+	}
+}
+
+func TestUserCheck(t *testing.T) {
+	u := Unit{}
+	(OptUser{User: "root", Group: "root", Check: true}).Apply(&u)
+	if err := u.checkAccounts(); err != nil {
+		t.Errorf("root should exist: %s", err)
+	}
+	u = Unit{}
+	(OptUser{User: "root", SupplementaryGroups: []string{"no-such-group-x"}, Check: true}).Apply(&u)
+	if err := u.checkAccounts(); err == nil || !strings.Contains(err.Error(), "no-such-group-x") {
+		t.Errorf("expected a missing group, got %v", err)
+	}
+
+	for _, o := range []OptUser{
+		{User: "foo bar"},
+		{User: "foobar", SupplementaryGroups: []string{""}},
+		{User: "foobar", Check: true, Create: true},
+	} {
+		if err := o.Apply(&Unit{}); err == nil {
+			t.Errorf("%+v should be rejected", o)
+		}
+	}
+}
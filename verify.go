@@ -0,0 +1,96 @@
+package unitard
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// VerifyError is returned by Deploy when the rendered unit files do not
+// pass `systemd-analyze verify`. Nothing has been changed on the system.
+type VerifyError struct {
+	Diagnostics []string // the problems reported by systemd-analyze
+}
+
+func (e *VerifyError) Error() string {
+	return "unit failed verification: " + strings.Join(e.Diagnostics, "; ")
+}
+
+// OptSkipVerify stops Deploy from checking the unit files with
+// `systemd-analyze verify` before installing them.
+type OptSkipVerify struct{}
+
+func (o OptSkipVerify) Apply(u *Unit) error {
+	u.skipVerify = true
+	return nil
+}
+
+// verify renders the unit files to a temporary directory and runs them
+// through `systemd-analyze verify`, returning a *VerifyError if any problems
+// are found. It is skipped if systemd-analyze is not installed.
+func (u Unit) verify() error {
+	if u.skipVerify || u.dropIn != "" || u.target || u.backend != nil || u.remote != nil || u.root != "" || u.quadlet != nil || u.client != nil {
+		// a drop-in can't be checked without the unit it belongs to, the
+		// units a target wants are checked themselves, the binary of a
+		// remote or staged unit is not on this machine, systemd can't
+		// read Quadlet files, and a client's units needn't be checked by
+		// the systemd installed here
+		return nil
+	}
+	analyze, err := exec.LookPath("systemd-analyze")
+	if err != nil {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "unitard-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	rendered, err := u.renderTo(dir)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"verify"}
+	if u.scope == ScopeUser {
+		args = append([]string{"--user"}, args...)
+	}
+	names := []string{}
+	for _, f := range rendered.unitFiles() {
+		args = append(args, f.name)
+		names = append(names, filepath.Base(f.name))
+	}
+	out, runErr := exec.CommandContext(u.context(), analyze, args...).CombinedOutput()
+
+	diagnostics := parseVerify(string(out), names, dir, u.unitFilePath)
+	if runErr != nil && len(diagnostics) == 0 {
+		// something went wrong which doesn't mention our units
+		diagnostics = strings.Split(strings.TrimSpace(string(out)), "\n")
+		if diagnostics[0] == "" {
+			diagnostics = []string{runErr.Error()}
+		}
+	}
+	if len(diagnostics) > 0 {
+		return &VerifyError{Diagnostics: diagnostics}
+	}
+	return nil
+}
+
+// parseVerify returns the lines of systemd-analyze output which refer to the
+// named units in dir, reported as if they were in unitDir.
+func parseVerify(out string, names []string, dir string, unitDir string) []string {
+	diagnostics := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		ours := strings.Contains(line, dir)
+		for _, name := range names {
+			ours = ours || strings.HasPrefix(line, name+":")
+		}
+		if ours {
+			diagnostics = append(diagnostics, strings.ReplaceAll(line, dir, unitDir))
+		}
+	}
+	return diagnostics
+}
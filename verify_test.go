@@ -0,0 +1,50 @@
+package unitard
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestParseVerify(t *testing.T) {
+	out := `/tmp/unitard-123/test_unit.service:5: Unknown key 'Foo' in section [Service], ignoring.
+/usr/lib/systemd/system/other.service:1: something unrelated
+test_unit.timer: Refusing, service is not loaded.
+`
+	d := parseVerify(out, []string{"test_unit.service", "test_unit.timer"}, "/tmp/unitard-123", "/units")
+	if len(d) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", d)
+	}
+	if d[0] != "/units/test_unit.service:5: Unknown key 'Foo' in section [Service], ignoring." {
+		t.Errorf("wrong diagnostic %q", d[0])
+	}
+}
+
+func TestVerify(t *testing.T) {
+	if _, err := exec.LookPath("systemd-analyze"); err != nil {
+		t.Skip("systemd-analyze is not installed")
+	}
+
+	good := Unit{name: "test_unit", binary: "/bin/true", binaryPath: "/", scope: ScopeSystem}
+	if err := good.verify(); err != nil {
+		t.Errorf("unit should verify: %s", err)
+	}
+
+	bad := Unit{name: "test_unit", binary: "/no/such/binary", binaryPath: "/", scope: ScopeSystem}
+	err := bad.verify()
+	var verifyErr *VerifyError
+	if !errors.As(err, &verifyErr) {
+		t.Fatalf("expected a VerifyError, got %v", err)
+	}
+	t.Logf("diagnostics: %v", verifyErr.Diagnostics)
+
+	bad.skipVerify = true
+	if err := bad.verify(); err != nil {
+		t.Errorf("verify should have been skipped: %s", err)
+	}
+	bad.skipVerify = false
+	bad.client = &recordingClient{dir: t.TempDir()}
+	if err := bad.verify(); err != nil {
+		t.Errorf("verify should be skipped with a client: %s", err)
+	}
+}
@@ -0,0 +1,171 @@
+package unitard
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// directiveVersion is the systemd version a directive first appeared in,
+// and what to do on older versions.
+type directiveVersion struct {
+	version  int
+	fallback string // an older directive doing the same, if any
+	optional bool   // leave it out rather than fail, as for sandboxing
+}
+
+// directiveVersions are the directives unitard uses which need a newer
+// systemd than most long-term distributions have shipped.
+var directiveVersions = map[string]directiveVersion{
+	"MemoryMax":               {version: 231, fallback: "MemoryLimit"},
+	"RuntimeMaxSec":           {version: 229},
+	"DynamicUser":             {version: 232},
+	"StateDirectory":          {version: 235},
+	"CacheDirectory":          {version: 235},
+	"LogsDirectory":           {version: 235},
+	"ConfigurationDirectory":  {version: 235},
+	"ExecCondition":           {version: 243},
+	"ConditionEnvironment":    {version: 246},
+	"AssertEnvironment":       {version: 246},
+	"LoadCredential":          {version: 247},
+	"SetCredential":           {version: 247},
+	"Upholds":                 {version: 249},
+	"LoadCredentialEncrypted": {version: 250},
+	"SetCredentialEncrypted":  {version: 250},
+	"ProtectHostname":         {version: 242, optional: true},
+	"RestrictSUIDSGID":        {version: 242, optional: true},
+	"ProtectKernelLogs":       {version: 244, optional: true},
+	"ProtectClock":            {version: 245, optional: true},
+}
+
+// SystemdVersionError is returned by NewUnit when an option needs a newer
+// systemd than the one installed.
+type SystemdVersionError struct {
+	Directive string // the directive which isn't supported
+	Required  int    // the systemd version it needs
+	Version   int    // the systemd version installed
+}
+
+func (e *SystemdVersionError) Error() string {
+	return fmt.Sprintf("%s: %s needs systemd %d, but %d is installed", ErrSystemdTooOld, e.Directive, e.Required, e.Version)
+}
+
+func (e *SystemdVersionError) Is(target error) bool {
+	return target == ErrSystemdTooOld
+}
+
+// OptSystemdVersion sets the systemd version the unit is written for,
+// instead of asking the installed systemd. Use it with OptRoot for the
+// systemd of the target system, or with OptClient.
+type OptSystemdVersion struct {
+	Version int // such as 252
+}
+
+func (o OptSystemdVersion) Apply(u *Unit) error {
+	if o.Version <= 0 {
+		return fmt.Errorf("sorry, systemd version '%d' is not valid", o.Version)
+	}
+	u.systemdVersion = o.Version
+	return nil
+}
+
+// SystemdVersion returns the version of systemd the unit is deployed to,
+// or 0 if it isn't known.
+func (u Unit) SystemdVersion() int {
+	return u.systemdVersion
+}
+
+// setupSystemdVersion finds the systemd version, if it wasn't given with
+// OptSystemdVersion, and adapts the directives to it. Options needing a
+// newer version fall back to older directives where there are any, and
+// otherwise return a *SystemdVersionError.
+func (u *Unit) setupSystemdVersion() error {
+	if u.systemdVersion == 0 {
+		u.systemdVersion = u.detectSystemdVersion()
+	}
+	if u.systemdVersion == 0 {
+		// assume it is new enough
+		return nil
+	}
+	for _, d := range u.credentialDirectives() {
+		err := u.checkDirectiveVersion(d.Key)
+		if err != nil {
+			return err
+		}
+	}
+	kept := []Directive{}
+	for _, d := range u.directives {
+		v, ok := directiveVersions[d.Key]
+		if !ok || u.systemdVersion >= v.version {
+			kept = append(kept, d)
+		} else if v.fallback != "" {
+			u.log(slog.LevelDebug, "using older directive", "directive", d.Key, "fallback", v.fallback, "systemd", u.systemdVersion)
+			kept = append(kept, Directive{Section: d.Section, Key: v.fallback, Value: d.Value})
+		} else if v.optional {
+			u.log(slog.LevelWarn, "directive not supported, leaving it out", "directive", d.Key, "systemd", u.systemdVersion)
+		} else {
+			return u.checkDirectiveVersion(d.Key)
+		}
+	}
+	u.directives = kept
+	return nil
+}
+
+// checkDirectiveVersion returns a *SystemdVersionError if the directive is
+// too new for the systemd version.
+func (u Unit) checkDirectiveVersion(key string) error {
+	v, ok := directiveVersions[key]
+	if !ok || u.systemdVersion == 0 || u.systemdVersion >= v.version {
+		return nil
+	}
+	return &SystemdVersionError{Directive: key, Required: v.version, Version: u.systemdVersion}
+}
+
+// detectSystemdVersion asks systemd (or systemctl) for its version,
+// returning 0 if it can't be found. There is no systemd to ask for other
+// backends, OptRoot, OptDryRun or OptClient.
+func (u Unit) detectSystemdVersion() int {
+	if u.backend != nil || u.root != "" || u.dryRun || u.client != nil {
+		return 0
+	}
+	if u.dbus {
+		c, err := u.dialSystemd()
+		if err != nil {
+			return 0
+		}
+		defer c.Close()
+		body, err := c.call(systemdBusName, systemdBusPath, "org.freedesktop.DBus.Properties", "Get", "ss", systemdManager, "Version")
+		if err != nil || len(body) == 0 {
+			return 0
+		}
+		v, _ := body[0].(busVariant)
+		version, _ := v.value.(string)
+		return parseSystemdVersion(version)
+	}
+	out, err := u.runOutput(u.systemCtlPath, "--version")
+	if err != nil {
+		return 0
+	}
+	// such as "systemd 252 (252.22-1~deb12u1)"
+	fields := strings.Fields(out)
+	if len(fields) < 2 || fields[0] != "systemd" {
+		return 0
+	}
+	return parseSystemdVersion(fields[1])
+}
+
+// parseSystemdVersion returns the major version from a systemd version
+// string such as "252", "v255" or "252.22-1~deb12u1", or 0.
+func parseSystemdVersion(s string) int {
+	s = strings.TrimPrefix(s, "v")
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	version, err := strconv.Atoi(s[:end])
+	if err != nil {
+		return 0
+	}
+	return version
+}
@@ -0,0 +1,96 @@
+package unitard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSystemdVersion(t *testing.T) {
+	for s, want := range map[string]int{"252": 252, "v255": 255, "252.22-1~deb12u1": 252, "": 0, "unknown": 0} {
+		if got := parseSystemdVersion(s); got != want {
+			t.Errorf("version of %q is %d, not %d", s, got, want)
+		}
+	}
+
+	script := filepath.Join(t.TempDir(), "systemctl")
+	os.WriteFile(script, []byte("#!/bin/sh\necho 'systemd 249 (249.11-0ubuntu3)'\necho '+PAM +AUDIT'\n"), 0700)
+	u := Unit{systemCtlPath: script}
+	if v := u.detectSystemdVersion(); v != 249 {
+		t.Errorf("detected version %d, not 249", v)
+	}
+	u.root = "/pkgroot"
+	if v := u.detectSystemdVersion(); v != 0 {
+		t.Errorf("version should not be detected with OptRoot, got %d", v)
+	}
+}
+
+func TestSystemdVersionGating(t *testing.T) {
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar"}
+	for _, o := range []UnitOpts{
+		OptSystemdVersion{Version: 230},
+		OptResourceLimits{MemoryMax: "1G"},
+		OptHardening{Profile: HardeningStrict},
+	} {
+		if err := o.Apply(&u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := u.setupSystemdVersion(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := u.Render()
+	content := files[u.UnitFilename()]
+	if !strings.Contains(content, "MemoryLimit=1G\n") || strings.Contains(content, "MemoryMax") {
+		t.Errorf("MemoryMax should fall back to MemoryLimit:\n%s", content)
+	}
+	if strings.Contains(content, "ProtectClock") || !strings.Contains(content, "PrivateTmp=yes\n") {
+		t.Errorf("only unsupported sandboxing should be left out:\n%s", content)
+	}
+
+	u = Unit{name: "test_unit", systemdVersion: 249}
+	(OptCredential{Name: "db", File: "/etc/foobar/db.cred", Encrypt: true}).Apply(&u)
+	err := u.setupSystemdVersion()
+	var versionErr *SystemdVersionError
+	if !errors.Is(err, ErrSystemdTooOld) || !errors.As(err, &versionErr) || versionErr.Required != 250 {
+		t.Errorf("expected LoadCredentialEncrypted to need systemd 250, got %v", err)
+	}
+	u.systemdVersion = 252
+	if err := u.setupSystemdVersion(); err != nil {
+		t.Errorf("systemd 252 should be new enough: %s", err)
+	}
+
+	if err := (OptSystemdVersion{}).Apply(&u); err == nil {
+		t.Error("a zero version should be rejected")
+	}
+}
+
+func TestDirectiveVersions(t *testing.T) {
+	// the first systemd release with each directive
+	for directive, first := range map[string]int{
+		"RuntimeMaxSec":           229,
+		"MemoryMax":               231,
+		"DynamicUser":             232,
+		"StateDirectory":          235,
+		"LogsDirectory":           235,
+		"ProtectHostname":         242,
+		"ExecCondition":           243,
+		"ProtectKernelLogs":       244,
+		"ProtectClock":            245,
+		"ConditionEnvironment":    246,
+		"LoadCredential":          247,
+		"Upholds":                 249,
+		"LoadCredentialEncrypted": 250,
+	} {
+		u := Unit{systemdVersion: first - 1}
+		if err := u.checkDirectiveVersion(directive); err == nil {
+			t.Errorf("%s should need systemd %d, allowed on %d", directive, first, first-1)
+		}
+		u.systemdVersion = first
+		if err := u.checkDirectiveVersion(directive); err != nil {
+			t.Errorf("%s should be allowed on systemd %d: %s", directive, first, err)
+		}
+	}
+}
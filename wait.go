@@ -0,0 +1,145 @@
+package unitard
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// waitInterval is how often the unit state is polled while waiting.
+const waitInterval = 250 * time.Millisecond
+
+// defaultJournalLines is the number of journal lines included in errors when
+// the unit fails.
+const defaultJournalLines = 10
+
+// OptWaitActive makes Deploy wait until the service (or its triggers) is
+// active, returning an error if it fails to start or crash-loops within
+// the timeout.
+type OptWaitActive struct {
+	Timeout      time.Duration // how long to wait
+	JournalLines int           // lines of journal output to include on failure, default 10
+}
+
+func (o OptWaitActive) Apply(u *Unit) error {
+	if o.Timeout <= 0 {
+		return errors.New("wait timeout must be positive")
+	}
+	if o.JournalLines < 0 {
+		return errors.New("journal lines cannot be negative")
+	}
+	u.waitTimeout = o.Timeout
+	u.journalLines = o.JournalLines
+	if u.journalLines == 0 {
+		u.journalLines = defaultJournalLines
+	}
+	return nil
+}
+
+// WaitUntilActive blocks until the service (or its triggers, if it has any)
+// is active, or the timeout expires. The service must remain active, with
+// the same process and without restarting, across two checks to be
+// considered up - so a crash-looping service results in an error, which
+// includes the last lines of its journal.
+func (u Unit) WaitUntilActive(timeout time.Duration) error {
+	err := u.checkRunnable("wait for")
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for _, unit := range u.activeUnits() {
+		err := u.waitForUnit(unit, deadline)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForUnit polls a single unit until it is active, failed or the deadline
+// passes.
+func (u Unit) waitForUnit(unit string, deadline time.Time) error {
+	var prev *Status
+	for {
+		props, err := u.show(unit, statusProperties...)
+		if err != nil {
+			return err
+		}
+		status, err := parseStatus(props)
+		if err != nil {
+			return err
+		}
+
+		done, err := checkActive(prev, status)
+		if err != nil {
+			return u.failedError(unit, err.Error())
+		}
+		if done {
+			return nil
+		}
+		prev = &status
+
+		if time.Now().After(deadline) {
+			return u.failedError(unit, fmt.Sprintf("timed out waiting, state is %s (%s)", status.ActiveState, status.SubState))
+		}
+		select {
+		case <-u.context().Done():
+			return u.context().Err()
+		case <-time.After(waitInterval):
+		}
+	}
+}
+
+// checkActive decides whether a unit has come up, given its previous and
+// current status. It returns an error if the unit has failed.
+func checkActive(prev *Status, cur Status) (bool, error) {
+	if cur.ActiveState == "failed" {
+		return false, fmt.Errorf("failed (%s)", cur.Result)
+	}
+	if cur.SubState == "auto-restart" {
+		return false, errors.New("is restarting after a failure")
+	}
+	if prev != nil && cur.NRestarts > prev.NRestarts {
+		return false, errors.New("restarted after a failure")
+	}
+	if cur.ActiveState != "active" {
+		return false, nil
+	}
+	// active twice in a row, and still the same process
+	return prev != nil && prev.ActiveState == "active" && prev.MainPID == cur.MainPID, nil
+}
+
+// failedError returns an error for a unit which did not come up, including
+// the end of the service's journal if it can be read.
+func (u Unit) failedError(unit string, reason string) error {
+	msg := fmt.Sprintf("unit %s %s", unit, reason)
+	lines := u.journalLines
+	if lines == 0 {
+		lines = defaultJournalLines
+	}
+	journal := u.journalTail(lines)
+	if journal != "" {
+		msg += "\n" + journal
+	}
+	return errors.New(msg)
+}
+
+// journalTail returns the last lines of the service's journal, or an empty
+// string if they cannot be read.
+func (u Unit) journalTail(lines int) string {
+	journalctl, err := u.lookTool("journalctl")
+	if err != nil {
+		return ""
+	}
+	args := []string{"-u", u.serviceName(), "-n", strconv.Itoa(lines), "--no-pager", "--quiet"}
+	if u.scope == ScopeUser {
+		args = append([]string{"--user"}, args...)
+	}
+	out, err := u.runOutput(journalctl, args...)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(out, "\n")
+}
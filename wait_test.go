@@ -0,0 +1,52 @@
+package unitard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckActive(t *testing.T) {
+	running := Status{ActiveState: "active", SubState: "running", MainPID: 100}
+
+	if done, err := checkActive(nil, running); done || err != nil {
+		t.Errorf("first active check should not be done: %v %v", done, err)
+	}
+	if done, err := checkActive(&running, running); !done || err != nil {
+		t.Errorf("second active check should be done: %v %v", done, err)
+	}
+
+	newPid := running
+	newPid.MainPID = 101
+	if done, _ := checkActive(&running, newPid); done {
+		t.Error("changed pid should not be done")
+	}
+
+	restarted := running
+	restarted.NRestarts = 1
+	if _, err := checkActive(&running, restarted); err == nil {
+		t.Error("restart should be an error")
+	}
+
+	if _, err := checkActive(nil, Status{ActiveState: "activating", SubState: "auto-restart"}); err == nil {
+		t.Error("auto-restart should be an error")
+	}
+	if _, err := checkActive(nil, Status{ActiveState: "failed", Result: "exit-code"}); err == nil {
+		t.Error("failed should be an error")
+	}
+	if done, err := checkActive(nil, Status{ActiveState: "activating", SubState: "start"}); done || err != nil {
+		t.Error("activating should keep waiting")
+	}
+}
+
+func TestWaitActiveOpts(t *testing.T) {
+	u := Unit{}
+	if (OptWaitActive{}).Apply(&u) == nil {
+		t.Error("zero timeout should not be valid")
+	}
+	if err := (OptWaitActive{Timeout: time.Minute}).Apply(&u); err != nil {
+		t.Errorf("should be valid: %s", err)
+	}
+	if u.journalLines != defaultJournalLines {
+		t.Errorf("journal lines should default to %d", defaultJournalLines)
+	}
+}
@@ -0,0 +1,102 @@
+package unitard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tardisx/unitard/unitfile"
+)
+
+// installKeys are the [Install] directives which decide the links systemctl
+// enable creates.
+var installKeys = []string{"WantedBy", "RequiredBy", "UpheldBy", "Alias", "Also"}
+
+// OptWantedBy sets the [Install] section of the service, which decides when
+// the enabled service starts. WantedBy replaces the default target,
+// default.target for user scope and multi-user.target for system scope,
+// such as with graphical-session.target for a user service which needs the
+// desktop. Alias gives the service other names (which must end in
+// .service), and the units in Also are enabled and disabled along with it.
+//
+// When the [Install] section of a deployed service changes, Deploy
+// re-enables it, so links from the old targets are removed.
+type OptWantedBy struct {
+	WantedBy []string // the targets which start the service
+	Alias    []string // other names for the service
+	Also     []string // units enabled along with the service
+}
+
+func (o OptWantedBy) Apply(u *Unit) error {
+	if len(o.WantedBy) == 0 && len(o.Alias) == 0 && len(o.Also) == 0 {
+		return errors.New("OptWantedBy needs a WantedBy, Alias or Also")
+	}
+	for _, unit := range append(append(append([]string{}, o.WantedBy...), o.Alias...), o.Also...) {
+		if !unitNameRegexp.MatchString(unit) {
+			return fmt.Errorf("sorry, unit name '%s' is not valid", unit)
+		}
+	}
+	for _, alias := range o.Alias {
+		if !strings.HasSuffix(alias, ".service") {
+			return fmt.Errorf("sorry, alias '%s' must end in .service", alias)
+		}
+	}
+	if len(o.WantedBy) > 0 {
+		u.wantedBy = append([]string{}, o.WantedBy...)
+	}
+	for _, d := range []struct {
+		key   string
+		units []string
+	}{{"Alias", o.Alias}, {"Also", o.Also}} {
+		if len(d.units) == 0 {
+			continue
+		}
+		err := u.addDirective(SectionInstall, d.key, strings.Join(d.units, " "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// installTargets returns the WantedBy= of the service.
+func (u Unit) installTargets() string {
+	if len(u.wantedBy) > 0 {
+		return strings.Join(u.wantedBy, " ")
+	}
+	return u.scope.wantedBy()
+}
+
+// installSectionChanged returns true if the deployed service file in
+// backups is enabled differently from the new one, so it must be disabled
+// before it is enabled again.
+func (u Unit) installSectionChanged(backups []backup) bool {
+	var old []byte
+	for _, b := range backups {
+		if b.name == u.UnitFilename() && b.existed {
+			old = b.content
+		}
+	}
+	if old == nil {
+		return false
+	}
+	buff := bytes.NewBuffer(nil)
+	if u.writeTemplate(buff) != nil {
+		return false
+	}
+	before, err := unitfile.Parse(bytes.NewReader(old))
+	if err != nil {
+		return false
+	}
+	after, err := unitfile.Parse(buff)
+	if err != nil {
+		return false
+	}
+	for _, key := range installKeys {
+		if strings.Join(before.Values("Install", key), " ") != strings.Join(after.Values("Install", key), " ") {
+			return true
+		}
+	}
+	return false
+}
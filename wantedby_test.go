@@ -0,0 +1,68 @@
+package unitard
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWantedBy(t *testing.T) {
+	systemctl, log := fakeSystemctl(t, "")
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(u.UnitFilename())
+	if !strings.Contains(string(content), "WantedBy=default.target\n") {
+		t.Errorf("unit is not wanted by default.target:\n%s", content)
+	}
+
+	err := (OptWantedBy{WantedBy: []string{"graphical-session.target"}, Alias: []string{"foobar.service"}, Also: []string{"test_unit.socket"}}).Apply(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Truncate(log, 0)
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	content, _ = os.ReadFile(u.UnitFilename())
+	for _, want := range []string{"WantedBy=graphical-session.target\n", "Alias=foobar.service\n", "Also=test_unit.socket\n"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("unit file is missing %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(string(content), "default.target") {
+		t.Errorf("default target was not replaced:\n%s", content)
+	}
+	if commands, _ := os.ReadFile(log); !strings.Contains(string(commands), "--user disable test_unit\n--user enable test_unit\n") {
+		t.Errorf("changed [Install] section should re-enable the unit:\n%s", commands)
+	}
+
+	// a change elsewhere doesn't need the links redone
+	u.binaryArgs = "-v"
+	os.Truncate(log, 0)
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	if commands, _ := os.ReadFile(log); strings.Contains(string(commands), "disable") {
+		t.Errorf("unit should not be re-enabled:\n%s", commands)
+	}
+}
+
+func TestWantedByOptions(t *testing.T) {
+	for _, o := range []OptWantedBy{
+		{},
+		{WantedBy: []string{"multi-user"}},
+		{Alias: []string{"foobar.socket"}},
+		{Also: []string{"bad name.socket"}},
+	} {
+		if err := o.Apply(&Unit{}); err == nil {
+			t.Errorf("%+v should be rejected", o)
+		}
+	}
+	u := Unit{dropIn: "override"}
+	(OptWantedBy{WantedBy: []string{"multi-user.target"}}).Apply(&u)
+	if err := u.validate(); err == nil {
+		t.Error("OptWantedBy should be rejected with OptDropIn")
+	}
+}
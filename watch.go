@@ -0,0 +1,73 @@
+package unitard
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// defaultWatchInterval is how often Watch checks the unit, unless told
+// otherwise.
+const defaultWatchInterval = time.Minute
+
+// Remediation is what Watch did about drift it found.
+type Remediation struct {
+	Report DriftReport // the drift found
+	Action string      // "redeploy" if the unit files or enablement had drifted, otherwise "start"
+	Err    error       // why the remediation failed, if it did
+}
+
+// Watch checks the unit with Verify every interval (a minute, if zero)
+// until ctx is done, and deploys it again when it has drifted: unit files
+// which were edited are overwritten (as with OptForce), and units which
+// should be enabled or running are enabled and started. onRemediate, if
+// not nil, is called after each attempt, so the program can log it.
+//
+// Watch blocks, so run it in a goroutine, and returns ctx.Err() when ctx is
+// done. Checks which fail (say if systemd is briefly unreachable) are
+// passed to onRemediate with an empty Action, and tried again at the next
+// interval.
+func (u Unit) Watch(ctx context.Context, interval time.Duration, onRemediate func(Remediation)) error {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	u = u.withContext(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if ctx.Err() != nil {
+			// both were ready, and select chose the tick
+			return ctx.Err()
+		}
+		r, ok := u.remediate()
+		if ok && onRemediate != nil {
+			onRemediate(r)
+		}
+	}
+}
+
+// remediate deploys the unit again if it has drifted, returning what was
+// done, or false if it was fine.
+func (u Unit) remediate() (Remediation, bool) {
+	report, err := u.Verify()
+	if err != nil {
+		return Remediation{Err: err}, true
+	}
+	if !report.Drifted {
+		return Remediation{}, false
+	}
+	r := Remediation{Report: report, Action: "start"}
+	if len(report.Files) > 0 || len(report.NotEnabled) > 0 {
+		r.Action = "redeploy"
+	}
+	u.log(slog.LevelWarn, "unit has drifted, deploying again", "drift", report.String())
+	// put back unit files which were edited
+	u.force = true
+	r.Err = u.Deploy()
+	return r, true
+}
@@ -0,0 +1,56 @@
+package unitard
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state")
+	healthy := "ActiveState=active\nUnitFileState=enabled\n"
+	systemctl := filepath.Join(dir, "systemctl")
+	// starting the unit makes it healthy again
+	os.WriteFile(systemctl, []byte("#!/bin/sh\n[ \"$2\" = show ] && cat "+state+"\ncase \"$2\" in start|restart) printf '"+strings.ReplaceAll(healthy, "\n", `\n`)+"' > "+state+";; esac\nexit 0\n"), 0700)
+	os.WriteFile(state, []byte(healthy), 0600)
+	u := Unit{name: "test_unit", binary: "/fullpath/to/foobar", skipVerify: true, systemCtlPath: systemctl, unitFilePath: t.TempDir()}
+	if err := u.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(u.UnitFilename())
+
+	// tampered with, and stopped
+	os.WriteFile(u.UnitFilename(), append(content, []byte("ExecStartPre=/tmp/evil\n")...), 0644)
+	os.WriteFile(state, []byte("ActiveState=failed\nUnitFileState=enabled\n"), 0600)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	remediations := []Remediation{}
+	err := u.Watch(ctx, 10*time.Millisecond, func(r Remediation) {
+		remediations = append(remediations, r)
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("watch should stop when cancelled, got %v", err)
+	}
+	if len(remediations) != 1 || remediations[0].Action != "redeploy" || remediations[0].Err != nil {
+		t.Fatalf("expected one redeploy, got %+v", remediations)
+	}
+	if now, _ := os.ReadFile(u.UnitFilename()); string(now) != string(content) {
+		t.Errorf("tampered unit file was not restored:\n%s", now)
+	}
+	if r, ok := u.remediate(); ok {
+		t.Errorf("healed unit should not need remediating: %+v", r)
+	}
+
+	// only stopped
+	os.WriteFile(state, []byte("ActiveState=failed\nUnitFileState=enabled\n"), 0600)
+	if r, ok := u.remediate(); !ok || r.Action != "start" || r.Err != nil {
+		t.Errorf("expected the unit to be started, got %+v", r)
+	}
+}